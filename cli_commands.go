@@ -0,0 +1,472 @@
+//go:build !windows || dev
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/bartek5186/pcm2www/internal/adminapi"
+	conf "github.com/bartek5186/pcm2www/internal/config"
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/integrations/importer"
+	"github.com/bartek5186/pcm2www/internal/integrations/woocommerce"
+	logs "github.com/bartek5186/pcm2www/internal/logs"
+	syncer "github.com/bartek5186/pcm2www/internal/syncer"
+	"github.com/rs/zerolog"
+)
+
+// Komendy nieinteraktywne: pcm2www <komenda> [argumenty]. Wynik trafia na stdout jako JSON
+// (raporty bez --out: CSV albo JSON), błąd na stderr jako {"error": "..."}.
+// Kody wyjścia: 0 = OK, 1 = błąd wykonania, 2 = niepoprawne wywołanie.
+
+const cliUsage = `Użycie: pcm2www <komenda> [argumenty]
+
+  run                                   syncer bez konsoli (systemd); SIGHUP przeładowuje config
+  import <plik> [--source ID]           import pliku XML/ZIP/XLSX, relink i planowanie tasków
+  relink                                ponowne linkowanie towarów z cache Woo
+  plan --import N                       planowanie tasków dla importu N
+  tasks list [--status s] [--kind k] [--shop s] [--import N] [--limit N]
+  tasks retry <task_id>                 task wraca do pending
+  tasks cancel <task_id>                anuluje task pending
+  cache prime|sweep [--shop S]          pełne pobranie / przyrostowe odświeżenie cache Woo
+  reports list                          dostępne raporty
+  reports <raport> [--format csv|json|xlsx] [--out plik] [--shop S] [--import N]
+                   [--xlsx plik] [--only-diff] [--split]
+  migrate status|up                     migracje schematu bazy
+`
+
+// cliApp to wspólny bootstrap komend: katalog aplikacji, config, log i otwarta baza.
+type cliApp struct {
+	appDir  string
+	cfgPath string
+	cfg     *conf.Config
+	log     zerolog.Logger
+	dbh     *db.Handle
+}
+
+// openApp wczytuje config i otwiera bazę. console = log także na stdout (REPL, run);
+// migrate = dołóż brakujące migracje (bez niego baza musi być już aktualna).
+func openApp(console, migrate bool) (*cliApp, error) {
+	app := &cliApp{appDir: mustAppDataDir("pcm2www")}
+	app.log = logs.New(filepath.Join(app.appDir, "app.log"), console)
+	app.cfgPath = filepath.Join(app.appDir, "config.json")
+
+	cfg, firstRun, err := conf.LoadOrCreate(app.cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	if firstRun {
+		app.log.Info().Msgf("Utworzono domyślną konfigurację: %s", app.cfgPath)
+	}
+	app.cfg = cfg
+
+	app.dbh, err = db.OpenWithConfig(app.appDir, db.OpenConfig{
+		Driver: cfg.Database.Driver,
+		DSN:    cfg.Database.DSN,
+		Path:   cfg.Database.Path,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DB open error: %w", err)
+	}
+	if migrate {
+		if err := app.dbh.Migrate(); err != nil {
+			app.Close()
+			return nil, fmt.Errorf("DB migrate error: %w", err)
+		}
+	}
+	app.log.Info().Str("driver", app.dbh.Driver).Str("db", app.dbh.Path).Msg("DB ready")
+	return app, nil
+}
+
+func (a *cliApp) Close() {
+	if sqlDB, err := a.dbh.DB.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// importer tworzy importer z sekcji integrations.importer (bez startu obserwacji katalogów).
+func (a *cliApp) importer() (*importer.Importer, error) {
+	raw, ok := a.cfg.Integrations["importer"]
+	if !ok {
+		return nil, errors.New("brak sekcji integrations.importer w configu")
+	}
+	return importer.New(a.log.With().Str("integration", "importer").Logger(), raw, a.dbh.DB)
+}
+
+// woo tworzy instancję sklepu z sekcji integrations.woocommerce[:<shop>].
+func (a *cliApp) woo(shop string) (*woocommerce.Woo, error) {
+	name := "woocommerce"
+	if shop != "" {
+		name += ":" + shop
+	}
+	raw, ok := a.cfg.Integrations[name]
+	if !ok {
+		return nil, fmt.Errorf("brak sekcji integrations.%s w configu", name)
+	}
+	return woocommerce.New(a.log.With().Str("integration", name).Logger(), shop, raw)
+}
+
+// usageError: niepoprawne wywołanie komendy (kod wyjścia 2).
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+type cliCommand func(app *cliApp, args []string) error
+
+var cliCommands = map[string]cliCommand{
+	"import":  cmdImport,
+	"relink":  cmdRelink,
+	"plan":    cmdPlan,
+	"tasks":   cmdTasks,
+	"cache":   cmdCache,
+	"reports": cmdReports,
+}
+
+// runSubcommand wykonuje komendę i zwraca kod wyjścia procesu.
+func runSubcommand(args []string) int {
+	name, rest := args[0], args[1:]
+	switch name {
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	case "run":
+		return exitCode(cmdRun(rest))
+	case "migrate":
+		// migrate działa także na bazie nowszej niż program (status) — bez automatycznej migracji
+		app, err := openApp(false, false)
+		if err != nil {
+			return exitCode(err)
+		}
+		defer app.Close()
+		return exitCode(cmdMigrate(app, rest))
+	}
+
+	cmd, ok := cliCommands[name]
+	if !ok {
+		return exitCode(usagef("nieznana komenda %q (pcm2www help)", name))
+	}
+	app, err := openApp(false, true)
+	if err != nil {
+		return exitCode(err)
+	}
+	defer app.Close()
+	return exitCode(cmd(app, rest))
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	enc := json.NewEncoder(os.Stderr)
+	_ = enc.Encode(map[string]string{"error": err.Error()})
+	var usage usageError
+	if errors.As(err, &usage) {
+		return 2
+	}
+	return 1
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseArgs parsuje flagi przemieszane z argumentami pozycyjnymi (import plik.xml --source b).
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usagef("%s: %v", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// cmdRun: syncer jak w trybie AutoStart, bez konsoli. Kończy się na SIGINT/SIGTERM.
+func cmdRun(args []string) error {
+	if len(args) > 0 {
+		return usagef("run nie przyjmuje argumentów")
+	}
+	app, err := openApp(true, true)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	s := syncer.New(app.log, app.cfg, app.dbh.DB)
+	if err := s.Start(ctx); err != nil {
+		return err
+	}
+	app.log.Info().Msgf("PCM2WWW Sync %s — działa (run)", ver)
+	for {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+			return nil
+		case <-hup:
+			newCfg, _, err := conf.LoadOrCreate(app.cfgPath)
+			if err != nil {
+				app.log.Error().Err(err).Msg("Błąd reloadu")
+				continue
+			}
+			s.UpdateConfig(newCfg)
+			app.log.Info().Msg("Konfiguracja przeładowana (SIGHUP)")
+		}
+	}
+}
+
+func cmdImport(app *cliApp, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	source := fs.String("source", "", "id źródła z importer.sources")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usagef("użycie: import <plik> [--source ID]")
+	}
+	imp, err := app.importer()
+	if err != nil {
+		return err
+	}
+	ids, err := imp.ImportFile(*source, pos[0])
+	if err != nil {
+		return err
+	}
+	if ids == nil {
+		ids = []uint{}
+	}
+	return printJSON(map[string]any{"file": pos[0], "import_ids": ids, "already_imported": len(ids) == 0})
+}
+
+func cmdRelink(app *cliApp, args []string) error {
+	if len(args) > 0 {
+		return usagef("relink nie przyjmuje argumentów")
+	}
+	imp, err := app.importer()
+	if err != nil {
+		return err
+	}
+	if err := imp.LinkProductsByEAN(); err != nil {
+		return err
+	}
+	var linked, issues int64
+	if err := app.dbh.DB.Model(&db.WooProductCache{}).Where("towar_id IS NOT NULL").Count(&linked).Error; err != nil {
+		return err
+	}
+	if err := app.dbh.DB.Model(&db.LinkIssue{}).Count(&issues).Error; err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"linked_products": linked, "link_issues": issues})
+}
+
+func cmdPlan(app *cliApp, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	importID := fs.Uint("import", 0, "import_id")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 || *importID == 0 {
+		return usagef("użycie: plan --import N")
+	}
+	imp, err := app.importer()
+	if err != nil {
+		return err
+	}
+	if err := imp.PlanWooTasks(*importID); err != nil {
+		return err
+	}
+	var rows []struct {
+		Kind   string
+		Status string
+		N      int64
+	}
+	if err := app.dbh.DB.Model(&db.WooTask{}).Select("kind, status, COUNT(*) AS n").
+		Where("import_id = ?", *importID).Group("kind, status").Scan(&rows).Error; err != nil {
+		return err
+	}
+	tasks := make(map[string]map[string]int64)
+	for _, r := range rows {
+		if tasks[r.Kind] == nil {
+			tasks[r.Kind] = make(map[string]int64)
+		}
+		tasks[r.Kind][r.Status] = r.N
+	}
+	return printJSON(map[string]any{"import_id": *importID, "tasks": tasks})
+}
+
+func cmdTasks(app *cliApp, args []string) error {
+	if len(args) == 0 {
+		return usagef("użycie: tasks list|retry|cancel")
+	}
+	switch args[0] {
+	case "list":
+		return cmdTasksList(app, args[1:])
+	case "retry", "cancel":
+		if len(args) != 2 {
+			return usagef("użycie: tasks %s <task_id>", args[0])
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return usagef("niepoprawne task_id %q", args[1])
+		}
+		var task db.WooTask
+		if args[0] == "retry" {
+			task, err = db.RetryTask(app.dbh.DB, uint(id))
+		} else {
+			task, err = db.CancelTask(app.dbh.DB, uint(id), "cancelled via CLI")
+		}
+		if err != nil {
+			return err
+		}
+		app.log.Info().Uint("task_id", task.TaskID).Str("action", args[0]).Msg("cli: zmiana taska")
+		return printJSON(adminapi.NewTaskView(task))
+	default:
+		return usagef("nieznana komenda tasks %q (list|retry|cancel)", args[0])
+	}
+}
+
+func cmdTasksList(app *cliApp, args []string) error {
+	fs := flag.NewFlagSet("tasks list", flag.ContinueOnError)
+	status := fs.String("status", "", "statusy po przecinku")
+	kind := fs.String("kind", "", "rodzaje po przecinku")
+	shop := fs.String("shop", "", "sklep Woo")
+	importID := fs.Uint("import", 0, "import_id")
+	limit := fs.Int("limit", 100, "maksymalna liczba tasków")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 || *limit <= 0 {
+		return usagef("użycie: tasks list [--status s] [--kind k] [--shop s] [--import N] [--limit N]")
+	}
+
+	tx := app.dbh.DB.Model(&db.WooTask{})
+	if v := splitCSV(*status); len(v) > 0 {
+		tx = tx.Where("status IN ?", v)
+	}
+	if v := splitCSV(*kind); len(v) > 0 {
+		tx = tx.Where("kind IN ?", v)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "shop" {
+			tx = tx.Where("shop = ?", *shop)
+		}
+	})
+	if *importID > 0 {
+		tx = tx.Where("import_id = ?", *importID)
+	}
+	var tasks []db.WooTask
+	if err := tx.Order("task_id DESC").Limit(*limit).Find(&tasks).Error; err != nil {
+		return err
+	}
+	out := make([]adminapi.TaskView, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, adminapi.NewTaskView(t))
+	}
+	return printJSON(out)
+}
+
+func cmdCache(app *cliApp, args []string) error {
+	if len(args) == 0 || (args[0] != "prime" && args[0] != "sweep") {
+		return usagef("użycie: cache prime|sweep [--shop S]")
+	}
+	fs := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	shop := fs.String("shop", "", "sklep Woo (woocommerce:<shop>)")
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		return usagef("użycie: cache %s [--shop S]", args[0])
+	}
+	w, err := app.woo(*shop)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	out := map[string]any{"integration": w.Name(), "action": args[0]}
+	if args[0] == "prime" {
+		if err := w.PrimeCache(ctx, app.dbh.DB); err != nil {
+			return err
+		}
+	} else {
+		n, err := w.SweepCache(ctx, app.dbh.DB)
+		if err != nil {
+			return err
+		}
+		out["upserts"] = n
+	}
+	var cached int64
+	if err := app.dbh.DB.Model(&db.WooProductCache{}).Where("shop = ?", *shop).Count(&cached).Error; err != nil {
+		return err
+	}
+	out["cached_products"] = cached
+	return printJSON(out)
+}
+
+func cmdMigrate(app *cliApp, args []string) error {
+	if len(args) != 1 {
+		return usagef("%s", migrateUsage)
+	}
+	switch args[0] {
+	case "status":
+		status, err := app.dbh.SchemaStatus()
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]any{"latest": db.LatestSchemaVersion(), "migrations": status})
+	case "up":
+		done, err := app.dbh.MigrateUp()
+		if err != nil {
+			return err
+		}
+		if done == nil {
+			done = []int{}
+		}
+		return printJSON(map[string]any{"applied": done, "latest": db.LatestSchemaVersion()})
+	default:
+		return usagef("%s", migrateUsage)
+	}
+}
+
+func splitCSV(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+//go:build !windows || dev
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// runLinkCommand obsługuje komendy ręcznych powiązań: pin / unpin / pins
+// oraz propozycji z dopasowania po nazwie: proposals / approve / reject.
+// Każda przyjmuje --shop <id> (sklep woocommerce:<id>); bez niej dotyczy sklepu domyślnego,
+// a pins / proposals bez --shop pokazują wszystkie sklepy.
+// Zwraca false, jeśli linia nie jest taką komendą.
+func runLinkCommand(gdb *gorm.DB, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	cmd := strings.ToLower(fields[0])
+	switch cmd {
+	case "pin", "unpin", "pins", "proposals", "approve", "reject":
+	default:
+		return false
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	shop := fs.String("shop", "", "sklep woocommerce:<shop>")
+	args, err := parseArgs(fs, fields[1:])
+	if err != nil {
+		fmt.Println("Błąd:", err)
+		return true
+	}
+	shopSet := false
+	fs.Visit(func(f *flag.Flag) { shopSet = shopSet || f.Name == "shop" })
+	fields = append([]string{cmd}, args...)
+
+	switch cmd {
+	case "pin":
+		if len(fields) < 3 {
+			fmt.Println("Użycie: pin <towar_id> <woo_id> [notatka] [--shop <id>]")
+			return true
+		}
+		towarID, err1 := strconv.ParseInt(fields[1], 10, 64)
+		wooID, err2 := strconv.ParseUint(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			fmt.Println("Niepoprawne towar_id / woo_id")
+			return true
+		}
+		link, err := db.PinManualLink(gdb, *shop, towarID, uint(wooID), strings.Join(fields[3:], " "))
+		if err != nil {
+			fmt.Println("Błąd:", err)
+			return true
+		}
+		fmt.Printf("Przypięto towar_id=%d → woo_id=%d%s\n", link.TowarID, link.WooID, shopLabel(link.Shop))
+
+	case "unpin":
+		if len(fields) != 2 {
+			fmt.Println("Użycie: unpin <towar_id> [--shop <id>]")
+			return true
+		}
+		towarID, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			fmt.Println("Niepoprawne towar_id")
+			return true
+		}
+		removed, err := db.UnpinManualLink(gdb, *shop, towarID)
+		switch {
+		case err != nil:
+			fmt.Println("Błąd:", err)
+		case !removed:
+			fmt.Printf("towar_id=%d nie ma ręcznego powiązania%s\n", towarID, shopLabel(*shop))
+		default:
+			fmt.Printf("Usunięto powiązanie towar_id=%d%s (cache odświeży się przy kolejnym relinku)\n", towarID, shopLabel(*shop))
+		}
+
+	case "pins":
+		tx := gdb.Order("shop, towar_id")
+		if shopSet {
+			tx = tx.Where("shop = ?", *shop)
+		}
+		var links []db.ManualLink
+		if err := tx.Find(&links).Error; err != nil {
+			fmt.Println("Błąd:", err)
+			return true
+		}
+		if len(links) == 0 {
+			fmt.Println("Brak ręcznych powiązań")
+			return true
+		}
+		for _, l := range links {
+			fmt.Printf("towar_id=%d woo_id=%d%s %s\n", l.TowarID, l.WooID, shopLabel(l.Shop), l.Note)
+		}
+
+	case "proposals":
+		tx := gdb.Where("status = ?", "pending").Order("score DESC, id")
+		if shopSet {
+			tx = tx.Where("shop = ?", *shop)
+		}
+		var proposals []db.LinkProposal
+		if err := tx.Find(&proposals).Error; err != nil {
+			fmt.Println("Błąd:", err)
+			return true
+		}
+		if len(proposals) == 0 {
+			fmt.Println("Brak propozycji powiązań")
+			return true
+		}
+		for _, p := range proposals {
+			fmt.Printf("#%d towar_id=%d %q → woo_id=%d %q score=%.2f (%s)%s\n", p.ID, p.TowarID, p.TowarName, p.WooID, p.WooName, p.Score, p.Quality, shopLabel(p.Shop))
+		}
+
+	case "approve", "reject":
+		if len(fields) != 2 {
+			fmt.Printf("Użycie: %s <id_propozycji> [--shop <id>]\n", cmd)
+			return true
+		}
+		id, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			fmt.Println("Niepoprawne id propozycji")
+			return true
+		}
+		var p db.LinkProposal
+		if cmd == "approve" {
+			p, err = db.ApproveLinkProposal(gdb, *shop, uint(id))
+		} else {
+			p, err = db.RejectLinkProposal(gdb, *shop, uint(id))
+		}
+		if err != nil {
+			fmt.Println("Błąd:", err)
+			return true
+		}
+		fmt.Printf("Propozycja #%d: %s (towar_id=%d, woo_id=%d%s)\n", p.ID, p.Status, p.TowarID, p.WooID, shopLabel(p.Shop))
+	}
+	return true
+}
+
+// shopLabel opisuje sklep w komunikatach konsoli; sklep domyślny nie jest wypisywany.
+func shopLabel(shop string) string {
+	if shop == "" {
+		return ""
+	}
+	return " [sklep " + shop + "]"
+}
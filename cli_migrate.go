@@ -0,0 +1,66 @@
+//go:build !windows || dev
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+)
+
+const migrateUsage = "Użycie: migrate <status|up>"
+
+// runMigrateCommand obsługuje komendy migracji schematu: migrate status / migrate up.
+// Zwraca false, jeśli linia nie jest taką komendą.
+func runMigrateCommand(dbh *db.Handle, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.ToLower(fields[0]) != "migrate" {
+		return false
+	}
+	if err := migrateCommand(dbh, fields[1:]); err != nil {
+		fmt.Println("Błąd:", err)
+	}
+	return true
+}
+
+// migrateCommand wykonuje migrate status / migrate up (REPL i `pcm2www migrate ...`).
+func migrateCommand(dbh *db.Handle, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	switch strings.ToLower(args[0]) {
+	case "status":
+		status, err := dbh.SchemaStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schemat: program zna wersje do %d\n", db.LatestSchemaVersion())
+		for _, st := range status {
+			switch {
+			case st.Unknown:
+				fmt.Printf("  %4d  %-30s  NIEZNANA (baza z nowszego programu, %s)\n", st.Version, st.Name, st.AppliedAt.Format("2006-01-02 15:04:05"))
+			case st.AppliedAt != nil:
+				fmt.Printf("  %4d  %-30s  zastosowana %s\n", st.Version, st.Name, st.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("  %4d  %-30s  oczekuje\n", st.Version, st.Name)
+			}
+		}
+		return nil
+	case "up":
+		done, err := dbh.MigrateUp()
+		for _, version := range done {
+			fmt.Printf("Zastosowano migrację %d\n", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("Schemat aktualny")
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !windows || dev
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/reports"
+	"gorm.io/gorm"
+)
+
+// runPreviewCommand obsługuje eksport podglądu dry_run: preview <csv|json|xlsx> [import_id] [plik].
+// To ten sam format co raport preview (jeden wiersz na zmienione pole). Bez pliku podgląd trafia
+// na stdout. Zwraca false, jeśli linia nie jest taką komendą.
+func runPreviewCommand(gdb *gorm.DB, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.ToLower(fields[0]) != "preview" {
+		return false
+	}
+	if len(fields) < 2 || len(fields) > 4 {
+		fmt.Println("Użycie: preview <csv|json|xlsx> [import_id] [plik]")
+		return true
+	}
+
+	format, err := reports.ParseFormat(strings.ToLower(fields[1]))
+	if err != nil {
+		fmt.Println("Błąd:", err)
+		return true
+	}
+	var importID uint64
+	path := ""
+	for _, arg := range fields[2:] {
+		if n, err := strconv.ParseUint(arg, 10, 64); err == nil && importID == 0 && path == "" {
+			importID = n
+			continue
+		}
+		path = arg
+	}
+	if format == reports.FormatXLSX && path == "" {
+		fmt.Println("Format xlsx wymaga pliku")
+		return true
+	}
+
+	tables, err := reports.Run(gdb, "preview", reports.Params{ImportID: uint(importID)})
+	if err != nil {
+		fmt.Println("Błąd:", err)
+		return true
+	}
+	if len(tables[0].Rows) == 0 {
+		fmt.Println("Brak tasków previewed (czy woocommerce.dry_run jest włączony?)")
+		return true
+	}
+
+	if path == "" {
+		err = reports.Write(os.Stdout, format, tables)
+	} else {
+		_, err = reports.WriteFile(path, format, tables)
+	}
+	if err != nil {
+		fmt.Println("Błąd:", err)
+		return true
+	}
+	if path != "" {
+		fmt.Printf("Zapisano podgląd (%d zmian) do %s\n", len(tables[0].Rows), path)
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+//go:build !windows || dev
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/bartek5186/pcm2www/internal/reports"
+)
+
+const reportsUsage = "użycie: reports list | reports <raport> [--format csv|json|xlsx] [--out plik] [--shop S] [--import N] [--xlsx plik] [--only-diff] [--split]"
+
+// cmdReports: raporty z rejestru internal/reports. Bez --out wynik idzie na stdout
+// (CSV / JSON; XLSX tylko do pliku), z --out na stdout trafia podsumowanie JSON.
+func cmdReports(app *cliApp, args []string) error {
+	if len(args) == 0 {
+		return usagef(reportsUsage)
+	}
+	name := args[0]
+	if name == "list" {
+		type reportView struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			NeedsXLSX   bool   `json:"needs_xlsx,omitempty"`
+		}
+		var out []reportView
+		for _, r := range reports.All() {
+			out = append(out, reportView{Name: r.Name, Description: r.Description, NeedsXLSX: r.NeedsXLSX})
+		}
+		return printJSON(out)
+	}
+	if _, ok := reports.Get(name); !ok {
+		return usagef("nieznany raport %q (reports list)", name)
+	}
+
+	fs := flag.NewFlagSet("reports "+name, flag.ContinueOnError)
+	formatFlag := fs.String("format", "json", "csv|json|xlsx")
+	outPath := fs.String("out", "", "plik wynikowy (domyślnie stdout)")
+	var p reports.Params
+	fs.StringVar(&p.Shop, "shop", "", "sklep Woo (woocommerce:<shop>)")
+	fs.UintVar(&p.ImportID, "import", 0, "import_id")
+	fs.StringVar(&p.XLSXPath, "xlsx", "", "plik XLS towary z PC-Market")
+	fs.BoolVar(&p.OnlyDiff, "only-diff", false, "porównania bez wierszy MATCH")
+	fs.BoolVar(&p.Split, "split", false, "raporty różnic: osobny arkusz na każdy typ różnicy")
+	pos, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		return usagef(reportsUsage)
+	}
+	fs.Visit(func(f *flag.Flag) { p.ShopSet = p.ShopSet || f.Name == "shop" })
+	format, err := reports.ParseFormat(*formatFlag)
+	if err != nil {
+		return usagef("%v", err)
+	}
+	if format == reports.FormatXLSX && *outPath == "" {
+		return usagef("format xlsx wymaga --out")
+	}
+
+	tables, err := reports.Run(app.dbh.DB, name, p)
+	if err != nil {
+		return err
+	}
+	if *outPath == "" {
+		return reports.Write(os.Stdout, format, tables)
+	}
+	files, err := reports.WriteFile(*outPath, format, tables)
+	if err != nil {
+		return err
+	}
+	rows := make(map[string]int, len(tables))
+	for _, t := range tables {
+		rows[t.Name] = len(t.Rows)
+	}
+	return printJSON(map[string]any{"report": name, "files": files, "rows": rows})
+}
@@ -0,0 +1,472 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type page[T any] struct {
+	Items  []T   `json:"items"`
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// TaskView to task woo_tasks w JSON API (i CLI `tasks`).
+type TaskView struct {
+	TaskID        uint       `json:"task_id"`
+	TaskKey       string     `json:"task_key"`
+	Shop          string     `json:"shop,omitempty"` // instancja woocommerce[:<shop>] ("" = domyślna)
+	ImportID      uint       `json:"import_id"`
+	TowarID       *int64     `json:"towar_id"`
+	WooID         *uint      `json:"woo_id"`
+	Kind          string     `json:"kind"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	Payload       any        `json:"payload"`
+	Preview       any        `json:"preview,omitempty"` // dry_run: request i diff pól
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type importView struct {
+	ImportID     uint           `json:"import_id"`
+	Filename     string         `json:"filename"`
+	ArchiveName  string         `json:"archive_name,omitempty"`
+	SourceID     string         `json:"source_id,omitempty"`
+	ExportKind   string         `json:"export_kind"`
+	TransmisjaID string         `json:"transmisja_id"`
+	FileTimeUTC  string         `json:"file_time_utc"`
+	SizeBytes    int64          `json:"size_bytes"`
+	Status       string         `json:"status"`
+	LastError    string         `json:"last_error"`
+	ReceivedAt   time.Time      `json:"received_at"`
+	ProcessedAt  *time.Time     `json:"processed_at"`
+	Products     int64          `json:"products"`
+	Tasks        map[string]int `json:"tasks"`
+}
+
+type linkIssueView struct {
+	ID        uint      `json:"id"`
+	Shop      string    `json:"shop,omitempty"`
+	TowarID   int64     `json:"towar_id"`
+	Reason    string    `json:"reason"`
+	Kod       string    `json:"kod"`
+	WooIDs    string    `json:"woo_ids"`
+	Details   string    `json:"details"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GET /api/tasks?status=pending,error&kind=price.update&shop=b2b&import_id=12&woo_id=&towar_id=&limit=&offset=
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tx := s.db.Model(&db.WooTask{})
+	if v := splitList(q.Get("status")); len(v) > 0 {
+		tx = tx.Where("status IN ?", v)
+	}
+	if v := splitList(q.Get("kind")); len(v) > 0 {
+		tx = tx.Where("kind IN ?", v)
+	}
+	if q.Has("shop") {
+		tx = tx.Where("shop = ?", q.Get("shop"))
+	}
+	for _, col := range []string{"import_id", "woo_id", "towar_id"} {
+		if raw := q.Get(col); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "niepoprawny "+col)
+				return
+			}
+			tx = tx.Where(col+" = ?", id)
+		}
+	}
+	tx = tx.Session(&gorm.Session{})
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var out page[TaskView]
+	out.Limit, out.Offset = limit, offset
+	if err := tx.Count(&out.Total).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var tasks []db.WooTask
+	if err := tx.Order("task_id DESC").Limit(limit).Offset(offset).Find(&tasks).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out.Items = make([]TaskView, 0, len(tasks))
+	for _, t := range tasks {
+		out.Items = append(out.Items, NewTaskView(t))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.loadTask(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, NewTaskView(task))
+}
+
+// POST /api/tasks/{id}/retry — task error/skipped/cancelled/previewed wraca do pending z wyzerowanym licznikiem prób.
+// Task pending czekający na backoff jest odblokowany od razu.
+func (s *Server) retryTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskIDParam(w, r)
+	if !ok {
+		return
+	}
+	task, err := db.RetryTask(s.db, id)
+	s.finishTaskChange(w, task, err, "retry")
+}
+
+// POST /api/tasks/{id}/cancel — tylko task pending; running kończy worker.
+func (s *Server) cancelTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskIDParam(w, r)
+	if !ok {
+		return
+	}
+	task, err := db.CancelTask(s.db, id, "cancelled via admin API")
+	s.finishTaskChange(w, task, err, "cancel")
+}
+
+func (s *Server) finishTaskChange(w http.ResponseWriter, task db.WooTask, err error, action string) {
+	switch {
+	case errors.Is(err, db.ErrTaskNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, db.ErrTaskStatus):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.log.Info().Uint("task_id", task.TaskID).Str("action", action).Msg("admin api: zmiana taska")
+	writeJSON(w, http.StatusOK, NewTaskView(task))
+}
+
+func taskIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "niepoprawne id taska")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (s *Server) loadTask(w http.ResponseWriter, r *http.Request) (db.WooTask, bool) {
+	id, ok := taskIDParam(w, r)
+	if !ok {
+		return db.WooTask{}, false
+	}
+	var task db.WooTask
+	switch err := s.db.Where("task_id = ?", id).Take(&task).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "task nie istnieje")
+		return db.WooTask{}, false
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return db.WooTask{}, false
+	}
+	return task, true
+}
+
+// GET /api/imports?status=done|error|pending&export_kind=wyk&source_id=&limit=&offset=
+func (s *Server) listImports(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tx := s.db.Model(&db.ImportFile{})
+	if raw := q.Get("status"); raw != "" {
+		code, ok := importStatusCode(raw)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "niepoprawny status importu")
+			return
+		}
+		tx = tx.Where("status = ?", code)
+	}
+	if raw := q.Get("export_kind"); raw != "" {
+		tx = tx.Where("export_kind = ?", raw)
+	}
+	if q.Has("source_id") {
+		tx = tx.Where("source_id = ?", q.Get("source_id"))
+	}
+	tx = tx.Session(&gorm.Session{})
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var out page[importView]
+	out.Limit, out.Offset = limit, offset
+	if err := tx.Count(&out.Total).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var files []db.ImportFile
+	if err := tx.Order("import_id DESC").Limit(limit).Offset(offset).Find(&files).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items, err := s.importViews(files)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out.Items = items
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getImport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "niepoprawne id importu")
+		return
+	}
+	var file db.ImportFile
+	switch err := s.db.Where("import_id = ?", id).Take(&file).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "import nie istnieje")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items, err := s.importViews([]db.ImportFile{file})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, items[0])
+}
+
+// importViews dokleja do importów statystyki: liczbę towarów ze staging i taski wg statusu.
+func (s *Server) importViews(files []db.ImportFile) ([]importView, error) {
+	items := make([]importView, 0, len(files))
+	if len(files) == 0 {
+		return items, nil
+	}
+	ids := make([]uint, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.ImportID)
+	}
+
+	var taskRows []struct {
+		ImportID uint
+		Status   string
+		Count    int
+	}
+	if err := s.db.Model(&db.WooTask{}).
+		Select("import_id, status, COUNT(*) AS count").
+		Where("import_id IN ?", ids).
+		Group("import_id, status").
+		Find(&taskRows).Error; err != nil {
+		return nil, err
+	}
+	tasks := make(map[uint]map[string]int, len(files))
+	for _, row := range taskRows {
+		if tasks[row.ImportID] == nil {
+			tasks[row.ImportID] = map[string]int{}
+		}
+		tasks[row.ImportID][row.Status] = row.Count
+	}
+
+	var productRows []struct {
+		ImportID uint
+		Count    int64
+	}
+	if err := s.db.Model(&db.StProduct{}).
+		Select("import_id, COUNT(*) AS count").
+		Where("import_id IN ?", ids).
+		Group("import_id").
+		Find(&productRows).Error; err != nil {
+		return nil, err
+	}
+	products := make(map[uint]int64, len(productRows))
+	for _, row := range productRows {
+		products[row.ImportID] = row.Count
+	}
+
+	for _, f := range files {
+		stats := tasks[f.ImportID]
+		if stats == nil {
+			stats = map[string]int{}
+		}
+		items = append(items, importView{
+			ImportID:     f.ImportID,
+			Filename:     f.Filename,
+			ArchiveName:  f.ArchiveName,
+			SourceID:     f.SourceID,
+			ExportKind:   f.ExportKind,
+			TransmisjaID: f.TransmisjaID,
+			FileTimeUTC:  f.FileTimeUTC,
+			SizeBytes:    f.SizeBytes,
+			Status:       importStatusName(f.Status),
+			LastError:    f.LastError,
+			ReceivedAt:   f.ReceivedAt,
+			ProcessedAt:  f.ProcessedAt,
+			Products:     products[f.ImportID],
+			Tasks:        stats,
+		})
+	}
+	return items, nil
+}
+
+// GET /api/link-issues?reason=missing_in_shop&shop=&towar_id=&kod=&limit=&offset=
+func (s *Server) listLinkIssues(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tx := s.db.Model(&db.LinkIssue{})
+	if v := splitList(q.Get("reason")); len(v) > 0 {
+		tx = tx.Where("reason IN ?", v)
+	}
+	if raw := q.Get("towar_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "niepoprawny towar_id")
+			return
+		}
+		tx = tx.Where("towar_id = ?", id)
+	}
+	if raw := q.Get("kod"); raw != "" {
+		tx = tx.Where("kod = ?", raw)
+	}
+	if q.Has("shop") {
+		tx = tx.Where("shop = ?", q.Get("shop"))
+	}
+	tx = tx.Session(&gorm.Session{})
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var out page[linkIssueView]
+	out.Limit, out.Offset = limit, offset
+	if err := tx.Count(&out.Total).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var issues []db.LinkIssue
+	if err := tx.Order("reason, towar_id").Limit(limit).Offset(offset).Find(&issues).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out.Items = make([]linkIssueView, 0, len(issues))
+	for _, li := range issues {
+		out.Items = append(out.Items, linkIssueView{
+			ID:        li.ID,
+			Shop:      li.Shop,
+			TowarID:   li.TowarID,
+			Reason:    li.Reason,
+			Kod:       li.Kod,
+			WooIDs:    li.WooIDs,
+			Details:   li.Details,
+			UpdatedAt: li.UpdatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// NewTaskView buduje widok taska; payload i podgląd dry_run zostają surowym JSON-em.
+func NewTaskView(t db.WooTask) TaskView {
+	var payload any = t.PayloadJSON
+	if json.Valid([]byte(t.PayloadJSON)) {
+		payload = json.RawMessage(t.PayloadJSON)
+	}
+	var preview any
+	if t.PreviewJSON != "" && json.Valid([]byte(t.PreviewJSON)) {
+		preview = json.RawMessage(t.PreviewJSON)
+	}
+	return TaskView{
+		TaskID:        t.TaskID,
+		TaskKey:       t.TaskKey,
+		Shop:          t.Shop,
+		ImportID:      t.ImportID,
+		TowarID:       t.TowarID,
+		WooID:         t.WooID,
+		Kind:          t.Kind,
+		Status:        t.Status,
+		Attempts:      t.Attempts,
+		NextAttemptAt: t.NextAttemptAt,
+		LastError:     t.LastError,
+		Payload:       payload,
+		Preview:       preview,
+		StartedAt:     t.StartedAt,
+		FinishedAt:    t.FinishedAt,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	q := r.URL.Query()
+	if raw := q.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			return 0, 0, errors.New("niepoprawny limit")
+		}
+		limit = min(limit, maxPageLimit)
+	}
+	if raw := q.Get("offset"); raw != "" {
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			return 0, 0, errors.New("niepoprawny offset")
+		}
+	}
+	return limit, offset, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for part := range strings.SplitSeq(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func importStatusName(status int) string {
+	switch status {
+	case 0:
+		return "pending"
+	case 1:
+		return "done"
+	case 2:
+		return "error"
+	default:
+		return strconv.Itoa(status)
+	}
+}
+
+func importStatusCode(name string) (int, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "pending", "0":
+		return 0, true
+	case "done", "1":
+		return 1, true
+	case "error", "2":
+		return 2, true
+	default:
+		return 0, false
+	}
+}
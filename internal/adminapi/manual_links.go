@@ -0,0 +1,174 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+type manualLinkView struct {
+	Shop      string    `json:"shop,omitempty"`
+	TowarID   int64     `json:"towar_id"`
+	WooID     uint      `json:"woo_id"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newManualLinkView(l db.ManualLink) manualLinkView {
+	return manualLinkView{Shop: l.Shop, TowarID: l.TowarID, WooID: l.WooID, Note: l.Note, CreatedAt: l.CreatedAt, UpdatedAt: l.UpdatedAt}
+}
+
+// GET /api/manual-links?shop=
+func (s *Server) listManualLinks(w http.ResponseWriter, r *http.Request) {
+	tx := s.db.Model(&db.ManualLink{})
+	if q := r.URL.Query(); q.Has("shop") {
+		tx = tx.Where("shop = ?", q.Get("shop"))
+	}
+	var links []db.ManualLink
+	if err := tx.Order("shop, towar_id").Find(&links).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items := make([]manualLinkView, 0, len(links))
+	for _, l := range links {
+		items = append(items, newManualLinkView(l))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// POST /api/manual-links {"shop": "", "towar_id": 1, "woo_id": 2, "note": "..."} (shop pominięty = sklep domyślny)
+func (s *Server) pinManualLink(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Shop    string `json:"shop"`
+		TowarID int64  `json:"towar_id"`
+		WooID   uint   `json:"woo_id"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "niepoprawny JSON")
+		return
+	}
+	if body.TowarID <= 0 || body.WooID == 0 {
+		writeError(w, http.StatusBadRequest, "wymagane towar_id i woo_id")
+		return
+	}
+	link, err := db.PinManualLink(s.db, body.Shop, body.TowarID, body.WooID, body.Note)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.log.Info().Str("shop", link.Shop).Int64("towar_id", link.TowarID).Uint("woo_id", link.WooID).Msg("admin api: przypięto powiązanie")
+	writeJSON(w, http.StatusOK, newManualLinkView(link))
+}
+
+// DELETE /api/manual-links/{towar_id}?shop=
+func (s *Server) unpinManualLink(w http.ResponseWriter, r *http.Request) {
+	towarID, err := strconv.ParseInt(r.PathValue("towar_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "niepoprawny towar_id")
+		return
+	}
+	shop := r.URL.Query().Get("shop")
+	removed, err := db.UnpinManualLink(s.db, shop, towarID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, "brak ręcznego powiązania dla towaru")
+		return
+	}
+	s.log.Info().Str("shop", shop).Int64("towar_id", towarID).Msg("admin api: usunięto powiązanie")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type linkProposalView struct {
+	ID           uint      `json:"id"`
+	Shop         string    `json:"shop,omitempty"`
+	TowarID      int64     `json:"towar_id"`
+	WooID        uint      `json:"woo_id"`
+	Strategy     string    `json:"strategy"`
+	Score        float64   `json:"score"`
+	Quality      string    `json:"quality"`
+	SharedTokens string    `json:"shared_tokens"`
+	TowarName    string    `json:"towar_name"`
+	WooName      string    `json:"woo_name"`
+	Status       string    `json:"status"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func newLinkProposalView(p db.LinkProposal) linkProposalView {
+	return linkProposalView{
+		ID:           p.ID,
+		Shop:         p.Shop,
+		TowarID:      p.TowarID,
+		WooID:        p.WooID,
+		Strategy:     p.Strategy,
+		Score:        p.Score,
+		Quality:      p.Quality,
+		SharedTokens: p.SharedTokens,
+		TowarName:    p.TowarName,
+		WooName:      p.WooName,
+		Status:       p.Status,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+// GET /api/link-proposals?status=pending&shop= (domyślnie pending; "all" = wszystkie)
+func (s *Server) listLinkProposals(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tx := s.db.Model(&db.LinkProposal{})
+	if q.Has("shop") {
+		tx = tx.Where("shop = ?", q.Get("shop"))
+	}
+	switch status := q.Get("status"); status {
+	case "":
+		tx = tx.Where("status = ?", "pending")
+	case "all":
+	default:
+		tx = tx.Where("status IN ?", splitList(status))
+	}
+	var proposals []db.LinkProposal
+	if err := tx.Order("score DESC, id").Find(&proposals).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items := make([]linkProposalView, 0, len(proposals))
+	for _, p := range proposals {
+		items = append(items, newLinkProposalView(p))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// POST /api/link-proposals/{id}/approve | /reject ?shop= (sklep propozycji; pominięty = sklep domyślny)
+func (s *Server) decideLinkProposal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "niepoprawne id propozycji")
+		return
+	}
+	shop := r.URL.Query().Get("shop")
+	var p db.LinkProposal
+	if strings.HasSuffix(r.URL.Path, "/approve") {
+		p, err = db.ApproveLinkProposal(s.db, shop, uint(id))
+	} else {
+		p, err = db.RejectLinkProposal(s.db, shop, uint(id))
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "propozycja nie istnieje")
+		return
+	case err != nil:
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.log.Info().Str("shop", p.Shop).Uint("proposal_id", p.ID).Str("status", p.Status).Msg("admin api: decyzja o propozycji powiązania")
+	writeJSON(w, http.StatusOK, newLinkProposalView(p))
+}
@@ -0,0 +1,129 @@
+// internal/adminapi/server.go
+package adminapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// Config lokalnego API administracyjnego (sekcja "admin_api" w config.json).
+type Config struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen,omitempty"` // domyślnie 127.0.0.1:8787
+	Token   string `json:"token"`            // wymagany; nagłówek Authorization: Bearer <token>
+}
+
+const defaultListen = "127.0.0.1:8787"
+
+func (c Config) listenAddr() string {
+	if strings.TrimSpace(c.Listen) == "" {
+		return defaultListen
+	}
+	return strings.TrimSpace(c.Listen)
+}
+
+// Server udostępnia kolejkę woo_tasks, import_files i link_issues jako JSON.
+type Server struct {
+	log zerolog.Logger
+	db  *gorm.DB
+	cfg Config
+	srv *http.Server
+}
+
+func New(log zerolog.Logger, gdb *gorm.DB, cfg Config) (*Server, error) {
+	if gdb == nil {
+		return nil, errors.New("admin api: brak *gorm.DB")
+	}
+	if strings.TrimSpace(cfg.Token) == "" {
+		return nil, errors.New("admin api: brak tokenu (admin_api.token)")
+	}
+	return &Server{log: log, db: gdb, cfg: cfg}, nil
+}
+
+// Start otwiera port i obsługuje requesty w tle. Błąd bindowania zwracany jest od razu.
+func (s *Server) Start() error {
+	addr := s.cfg.listenAddr()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("admin api: listen %s: %w", addr, err)
+	}
+	if !isLoopback(ln.Addr()) {
+		s.log.Warn().Str("listen", ln.Addr().String()).Msg("admin api: nasłuch poza localhost — dostęp chroni tylko token")
+	}
+	s.srv = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error().Err(err).Msg("admin api: serwer zakończony z błędem")
+		}
+	}()
+	s.log.Info().Str("listen", ln.Addr().String()).Msg("admin api: start")
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	err := s.srv.Shutdown(ctx)
+	s.log.Info().Msg("admin api: stop")
+	return err
+}
+
+// Handler zwraca router z autoryzacją tokenem (bez otwierania portu — używane też w testach).
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", s.listTasks)
+	mux.HandleFunc("GET /api/tasks/{id}", s.getTask)
+	mux.HandleFunc("POST /api/tasks/{id}/retry", s.retryTask)
+	mux.HandleFunc("POST /api/tasks/{id}/cancel", s.cancelTask)
+	mux.HandleFunc("GET /api/imports", s.listImports)
+	mux.HandleFunc("GET /api/imports/{id}", s.getImport)
+	mux.HandleFunc("GET /api/link-issues", s.listLinkIssues)
+	mux.HandleFunc("GET /api/manual-links", s.listManualLinks)
+	mux.HandleFunc("POST /api/manual-links", s.pinManualLink)
+	mux.HandleFunc("DELETE /api/manual-links/{towar_id}", s.unpinManualLink)
+	mux.HandleFunc("GET /api/link-proposals", s.listLinkProposals)
+	mux.HandleFunc("POST /api/link-proposals/{id}/approve", s.decideLinkProposal)
+	mux.HandleFunc("POST /api/link-proposals/{id}/reject", s.decideLinkProposal)
+	return s.requireToken(mux)
+}
+
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	want := []byte(s.cfg.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), want) != 1 {
+			writeError(w, http.StatusUnauthorized, "brak lub niepoprawny token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
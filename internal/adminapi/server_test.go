@@ -0,0 +1,238 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestAdminAPIRequiresToken(t *testing.T) {
+	h := newAdminAPITestServer(t, newAdminAPITestDB(t)).Handler()
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("auth %q: expected 401, got %d", auth, rec.Code)
+		}
+	}
+
+	if _, err := New(zerolog.Nop(), newAdminAPITestDB(t), Config{Enabled: true}); err == nil {
+		t.Fatal("expected error for missing token")
+	}
+}
+
+func TestAdminAPIListsFiltersRetriesAndCancelsTasks(t *testing.T) {
+	gdb := newAdminAPITestDB(t)
+	h := newAdminAPITestServer(t, gdb).Handler()
+
+	next := time.Now().Add(time.Hour)
+	tasks := []db.WooTask{
+		{TaskKey: "price.update:1", ImportID: 7, Kind: "price.update", Status: "error", Attempts: 5, LastError: "http 503", PayloadJSON: `{"woo_id":1}`},
+		{TaskKey: "stock.update:1", ImportID: 7, Kind: "stock.update", Status: "pending", Attempts: 1, NextAttemptAt: &next},
+		{TaskKey: "stock.update:2", ImportID: 8, Kind: "stock.update", Status: "done"},
+	}
+	for idx := range tasks {
+		if err := gdb.Create(&tasks[idx]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var list page[TaskView]
+	doAdminRequest(t, h, http.MethodGet, "/api/tasks?status=error,pending&import_id=7", http.StatusOK, &list)
+	if list.Total != 2 || len(list.Items) != 2 {
+		t.Fatalf("expected 2 filtered tasks, got %+v", list)
+	}
+	doAdminRequest(t, h, http.MethodGet, "/api/tasks?kind=stock.update&limit=1", http.StatusOK, &list)
+	if list.Total != 2 || len(list.Items) != 1 || list.Limit != 1 {
+		t.Fatalf("expected paged stock tasks, got %+v", list)
+	}
+
+	var view TaskView
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/tasks/%d/retry", tasks[0].TaskID), http.StatusOK, &view)
+	if view.Status != "pending" || view.Attempts != 0 || view.LastError != "" || view.NextAttemptAt != nil {
+		t.Fatalf("unexpected retried task: %+v", view)
+	}
+	if payload, ok := view.Payload.(map[string]any); !ok || payload["woo_id"] != float64(1) {
+		t.Fatalf("expected decoded payload, got %#v", view.Payload)
+	}
+
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/tasks/%d/cancel", tasks[1].TaskID), http.StatusOK, &view)
+	if view.Status != "cancelled" || view.FinishedAt == nil {
+		t.Fatalf("unexpected cancelled task: %+v", view)
+	}
+
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/tasks/%d/cancel", tasks[2].TaskID), http.StatusConflict, nil)
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/tasks/%d/retry", tasks[2].TaskID), http.StatusConflict, nil)
+	doAdminRequest(t, h, http.MethodGet, "/api/tasks/999", http.StatusNotFound, nil)
+}
+
+func TestAdminAPIListsImportsWithStatsAndLinkIssues(t *testing.T) {
+	gdb := newAdminAPITestDB(t)
+	h := newAdminAPITestServer(t, gdb).Handler()
+
+	if err := gdb.Create(&db.ImportFile{ImportID: 3, Filename: "exp_wyk_1.xml", TransmisjaID: "T1", SHA256: "a", ExportKind: "wyk", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.ImportFile{ImportID: 4, Filename: "exp_wyk_2.xml", TransmisjaID: "T2", SHA256: "b", ExportKind: "wyk", Status: 2, LastError: "broken"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&[]db.StProduct{{TowarID: 1, Kod: "1", ImportID: 3}, {TowarID: 2, Kod: "2", ImportID: 3}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&[]db.WooTask{
+		{TaskKey: "a", ImportID: 3, Kind: "price.update", Status: "done"},
+		{TaskKey: "b", ImportID: 3, Kind: "stock.update", Status: "done"},
+		{TaskKey: "c", ImportID: 3, Kind: "ean.update", Status: "error"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&[]db.LinkIssue{
+		{TowarID: 1, Reason: "missing_in_shop", Kod: "1"},
+		{TowarID: 2, Reason: "duplicate_ean", Kod: "2", WooIDs: "5,6"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var imports page[importView]
+	doAdminRequest(t, h, http.MethodGet, "/api/imports", http.StatusOK, &imports)
+	if imports.Total != 2 || imports.Items[0].ImportID != 4 || imports.Items[0].Status != "error" {
+		t.Fatalf("expected newest import first, got %+v", imports)
+	}
+	done := imports.Items[1]
+	if done.Products != 2 || done.Tasks["done"] != 2 || done.Tasks["error"] != 1 {
+		t.Fatalf("unexpected import stats: %+v", done)
+	}
+	doAdminRequest(t, h, http.MethodGet, "/api/imports?status=done", http.StatusOK, &imports)
+	if imports.Total != 1 || imports.Items[0].ImportID != 3 {
+		t.Fatalf("expected only done import, got %+v", imports)
+	}
+
+	var issues page[linkIssueView]
+	doAdminRequest(t, h, http.MethodGet, "/api/link-issues?reason=duplicate_ean", http.StatusOK, &issues)
+	if issues.Total != 1 || issues.Items[0].WooIDs != "5,6" {
+		t.Fatalf("unexpected link issues: %+v", issues)
+	}
+}
+
+func TestAdminAPIPinsAndUnpinsManualLinks(t *testing.T) {
+	gdb := newAdminAPITestDB(t)
+	h := newAdminAPITestServer(t, gdb).Handler()
+
+	if err := gdb.Create(&db.WooProductCache{WooID: 20, Name: "Woo"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/manual-links", strings.NewReader(`{"towar_id": 5, "woo_id": 20, "note": "ręcznie"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("pin: got %d: %s", rec.Code, rec.Body.String())
+	}
+	var cache db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 20).Take(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cache.TowarID == nil || *cache.TowarID != 5 {
+		t.Fatalf("expected pin to link cache immediately, got %+v", cache.TowarID)
+	}
+
+	var list struct {
+		Items []manualLinkView `json:"items"`
+	}
+	doAdminRequest(t, h, http.MethodGet, "/api/manual-links", http.StatusOK, &list)
+	if len(list.Items) != 1 || list.Items[0].WooID != 20 || list.Items[0].Note != "ręcznie" {
+		t.Fatalf("unexpected manual links: %+v", list)
+	}
+
+	doAdminRequest(t, h, http.MethodDelete, "/api/manual-links/5", http.StatusNoContent, nil)
+	doAdminRequest(t, h, http.MethodDelete, "/api/manual-links/5", http.StatusNotFound, nil)
+
+	proposal := db.LinkProposal{TowarID: 6, WooID: 20, Strategy: "name", Score: 0.8, Quality: "medium_name_match", Status: "pending"}
+	if err := gdb.Create(&proposal).Error; err != nil {
+		t.Fatal(err)
+	}
+	var proposals struct {
+		Items []linkProposalView `json:"items"`
+	}
+	doAdminRequest(t, h, http.MethodGet, "/api/link-proposals", http.StatusOK, &proposals)
+	if len(proposals.Items) != 1 || proposals.Items[0].ID != proposal.ID {
+		t.Fatalf("unexpected proposals: %+v", proposals)
+	}
+	var decided linkProposalView
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/link-proposals/%d/approve", proposal.ID), http.StatusOK, &decided)
+	if decided.Status != "approved" {
+		t.Fatalf("expected approved proposal, got %+v", decided)
+	}
+	doAdminRequest(t, h, http.MethodPost, fmt.Sprintf("/api/link-proposals/%d/reject", proposal.ID), http.StatusConflict, nil)
+	doAdminRequest(t, h, http.MethodGet, "/api/manual-links", http.StatusOK, &list)
+	if len(list.Items) != 1 || list.Items[0].TowarID != 6 {
+		t.Fatalf("approved proposal should become a manual link, got %+v", list)
+	}
+}
+
+func doAdminRequest(t *testing.T, h http.Handler, method, target string, wantStatus int, out any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != wantStatus {
+		t.Fatalf("%s %s: got %d want %d: %s", method, target, rec.Code, wantStatus, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newAdminAPITestServer(t *testing.T, gdb *gorm.DB) *Server {
+	t.Helper()
+	srv, err := New(zerolog.Nop(), gdb, Config{Enabled: true, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func newAdminAPITestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.AutoMigrate(
+		&db.ImportFile{},
+		&db.StProduct{},
+		&db.WooTask{},
+		&db.LinkIssue{},
+		&db.ManualLink{},
+		&db.LinkProposal{},
+		&db.WooOrder{},
+		&db.WooOrderLine{},
+		&db.WooProductCache{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	return gdb
+}
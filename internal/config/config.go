@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/bartek5186/pcm2www/internal/adminapi"
 	"github.com/bartek5186/pcm2www/internal/integrations/woocommerce"
 )
 
@@ -26,6 +27,7 @@ type Config struct {
 	AutoStart           bool                       `json:"auto_start"`
 	SyncIntervalSeconds int                        `json:"sync_interval_seconds"`
 	Integrations        map[string]json.RawMessage `json:"integrations"` // nazwa -> surowy JSON integracji
+	AdminAPI            adminapi.Config            `json:"admin_api"`    // lokalne API JSON (kolejka, importy, link issues)
 	// (opcjonalnie, zostaw jeśli nadal używasz gdzieś indziej)
 	WatchDir string `json:"watch_dir,omitempty"`
 }
@@ -38,6 +40,7 @@ type WooDefaults struct {
 	PollSec      int                             `json:"poll_sec"`
 	Workers      int                             `json:"workers"`
 	Cache        woocommerce.WooCache            `json:"cache"`
+	Retry        woocommerce.WooRetry            `json:"retry"`
 	CustomFields []woocommerce.CustomFieldConfig `json:"custom_fields,omitempty"`
 }
 
@@ -56,9 +59,15 @@ func LoadOrCreate(path string) (*Config, bool, error) {
 				PollSec:     10,
 				Workers:     3,
 				Cache: woocommerce.WooCache{
-					PrimeOnStart:         true,
-					SweepIntervalMinutes: 360, //6h
-					Fields:               "id,sku,name,regular_price,sale_price,stock_quantity,manage_stock,status,date_modified_gmt,type,global_unique_id",
+					PrimeOnStart:             true,
+					SweepIntervalMinutes:     360,  //6h
+					ReconcileIntervalMinutes: 1440, // raz na dobę
+					Fields:                   "id,sku,name,regular_price,sale_price,stock_quantity,manage_stock,status,date_modified_gmt,type,global_unique_id",
+				},
+				Retry: woocommerce.WooRetry{
+					MaxAttempts:  5,
+					BaseDelaySec: 30,
+					MaxDelaySec:  3600,
 				},
 				CustomFields: []woocommerce.CustomFieldConfig{
 					{
@@ -102,6 +111,9 @@ func LoadOrCreate(path string) (*Config, bool, error) {
 	if strings.TrimSpace(cfg.Database.Driver) == "" {
 		cfg.Database.Driver = "sqlite"
 	}
+	if err := woocommerce.CheckWebhookListeners(cfg.Integrations); err != nil {
+		return nil, false, fmt.Errorf("błąd configa: %w", err)
+	}
 	return &cfg, false, nil
 }
 
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PinManualLink zapisuje ręczne powiązanie towar_id ↔ woo_id w sklepie shop (nadpisuje poprzednie
+// dla tego towaru w tym sklepie) i od razu przenosi link w cache sklepu, żeby planner nie czekał
+// na kolejny relink. shop "" to sklep domyślny.
+func PinManualLink(gdb *gorm.DB, shop string, towarID int64, wooID uint, note string) (ManualLink, error) {
+	if towarID <= 0 || wooID == 0 {
+		return ManualLink{}, errors.New("manual link: towar_id i woo_id muszą być dodatnie")
+	}
+	var link ManualLink
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		var err error
+		link, err = pinManualLinkTx(tx, shop, towarID, wooID, note)
+		return err
+	})
+	return link, err
+}
+
+// pinManualLinkTx to PinManualLink w transakcji wywołującego (zatwierdzenie propozycji przypina
+// i zmienia statusy propozycji atomowo).
+func pinManualLinkTx(tx *gorm.DB, shop string, towarID int64, wooID uint, note string) (ManualLink, error) {
+	var other ManualLink
+	switch err := tx.Where("shop = ? AND woo_id = ? AND towar_id <> ?", shop, wooID, towarID).Take(&other).Error; {
+	case err == nil:
+		return ManualLink{}, fmt.Errorf("manual link: woo_id %d jest już przypięty do towar_id %d%s", wooID, other.TowarID, shopSuffix(shop))
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return ManualLink{}, err
+	}
+
+	link := ManualLink{Shop: shop, TowarID: towarID, WooID: wooID, Note: note}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "shop"}, {Name: "towar_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"woo_id", "note", "updated_at"}),
+	}).Create(&link).Error; err != nil {
+		return ManualLink{}, err
+	}
+	if err := tx.Where("shop = ? AND towar_id = ?", shop, towarID).Take(&link).Error; err != nil {
+		return ManualLink{}, err
+	}
+
+	if err := tx.Model(&WooProductCache{}).
+		Where("shop = ? AND towar_id = ? AND woo_id <> ?", shop, towarID, wooID).
+		Update("towar_id", nil).Error; err != nil {
+		return ManualLink{}, err
+	}
+	if err := tx.Model(&WooProductCache{}).
+		Where("shop = ? AND woo_id = ?", shop, wooID).
+		Update("towar_id", towarID).Error; err != nil {
+		return ManualLink{}, err
+	}
+	return link, nil
+}
+
+// UnpinManualLink usuwa ręczne powiązanie towaru w sklepie shop; link z cache znika przy najbliższym relinku.
+// Zwraca false, gdy towar nie miał przypięcia w tym sklepie.
+func UnpinManualLink(gdb *gorm.DB, shop string, towarID int64) (bool, error) {
+	res := gdb.Where("shop = ? AND towar_id = ?", shop, towarID).Delete(&ManualLink{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ApproveLinkProposal zatwierdza propozycję sklepu shop: w jednej transakcji przypina towar do produktu
+// Woo w tym sklepie i oznacza pozostałe propozycje tego towaru w sklepie jako odrzucone.
+// Propozycja z innego sklepu jest traktowana jak nieistniejąca (gorm.ErrRecordNotFound).
+func ApproveLinkProposal(gdb *gorm.DB, shop string, id uint) (LinkProposal, error) {
+	var p LinkProposal
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if p, err = pendingLinkProposal(tx, shop, id); err != nil {
+			return err
+		}
+		note := fmt.Sprintf("propozycja #%d (%s, score %.2f)", p.ID, p.Quality, p.Score)
+		if _, err := pinManualLinkTx(tx, p.Shop, p.TowarID, p.WooID, note); err != nil {
+			return err
+		}
+		if err := tx.Model(&LinkProposal{}).Where("id = ?", p.ID).Update("status", "approved").Error; err != nil {
+			return err
+		}
+		return tx.Model(&LinkProposal{}).
+			Where("shop = ? AND towar_id = ? AND id <> ? AND status = ?", p.Shop, p.TowarID, p.ID, "pending").
+			Update("status", "rejected").Error
+	})
+	if err != nil {
+		return LinkProposal{}, err
+	}
+	p.Status = "approved"
+	return p, nil
+}
+
+// RejectLinkProposal odrzuca propozycję sklepu shop; linker nie zaproponuje tej pary ponownie.
+func RejectLinkProposal(gdb *gorm.DB, shop string, id uint) (LinkProposal, error) {
+	p, err := pendingLinkProposal(gdb, shop, id)
+	if err != nil {
+		return LinkProposal{}, err
+	}
+	if err := gdb.Model(&LinkProposal{}).Where("id = ?", p.ID).Update("status", "rejected").Error; err != nil {
+		return LinkProposal{}, err
+	}
+	p.Status = "rejected"
+	return p, nil
+}
+
+// pendingLinkProposal wczytuje propozycję sklepu shop czekającą na decyzję.
+func pendingLinkProposal(gdb *gorm.DB, shop string, id uint) (LinkProposal, error) {
+	var p LinkProposal
+	if err := gdb.Where("shop = ? AND id = ?", shop, id).Take(&p).Error; err != nil {
+		return LinkProposal{}, err
+	}
+	if p.Status != "pending" {
+		return LinkProposal{}, fmt.Errorf("link proposal %d: status %s, oczekiwano pending", id, p.Status)
+	}
+	return p, nil
+}
+
+// shopSuffix dopisuje nazwę sklepu do komunikatu; sklep domyślny ("") nie jest wymieniany.
+func shopSuffix(shop string) string {
+	if shop == "" {
+		return ""
+	}
+	return fmt.Sprintf(" w sklepie %q", shop)
+}
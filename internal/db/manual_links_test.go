@@ -0,0 +1,58 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestApproveLinkProposalIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenWithConfig(dir, OpenConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := h.DB.DB()
+	defer sqlDB.Close()
+	if err := h.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// woo_id 20 jest już przypięty do innego towaru — przypięcie musi się nie udać
+	if _, err := PinManualLink(h.DB, "", 5, 20, "ręcznie"); err != nil {
+		t.Fatal(err)
+	}
+	proposals := []LinkProposal{
+		{TowarID: 6, WooID: 20, Strategy: "name", Score: 0.9, Status: "pending"},
+		{TowarID: 6, WooID: 21, Strategy: "name", Score: 0.7, Status: "pending"},
+	}
+	if err := h.DB.Create(&proposals).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := ApproveLinkProposal(h.DB, "", proposals[0].ID); err == nil || p.Status != "" {
+		t.Fatalf("approve of a conflicting pin must fail without a status, got %+v, %v", p, err)
+	}
+	var pending int64
+	if err := h.DB.Model(&LinkProposal{}).Where("status = ?", "pending").Count(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pending != 2 {
+		t.Fatalf("failed approve must leave proposals pending, got %d pending", pending)
+	}
+
+	p, err := ApproveLinkProposal(h.DB, "", proposals[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sibling LinkProposal
+	if err := h.DB.Take(&sibling, proposals[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var link ManualLink
+	if err := h.DB.Where("shop = ? AND towar_id = ?", "", 6).Take(&link).Error; err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != "approved" || sibling.Status != "rejected" || link.WooID != 21 {
+		t.Fatalf("unexpected approve result: proposal=%+v sibling=%s link=%+v", p, sibling.Status, link)
+	}
+}
@@ -1,51 +1,154 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
 )
 
-// Migrate tworzy/aktualizuje schemat bazy.
-// Kolejność:
-//  1. jeśli tabela istnieje -> hard purge + drop index
-//  2. AutoMigrate
-//  3. upewnij się, że indeks istnieje (jeśli NIE używasz tagów unikalności w modelu)
+// Schemat bazy zmienia się wyłącznie przez numerowane migracje (lista migrations niżej).
+// Zastosowane wersje leżą w schema_migrations; Migrate dokłada brakujące po kolei, każdą
+// w osobnej transakcji. Baza z wersją spoza listy pochodzi z nowszego programu — wtedy
+// Migrate odmawia startu, zamiast pracować na nieznanym schemacie.
+//
+// Zmiana modelu = nowa migracja na końcu listy (AddColumn, backfill, przebudowa indeksu).
+// Zastosowanych migracji nie wolno edytować ani przenumerowywać. Kroki (migrate_steps.go) pracują
+// na zamrożonych kopiach modeli z chwili zmiany, nie na bieżących typach z models.go — inaczej
+// stary krok zmieniałby znaczenie razem z modelem.
+
+// SchemaMigration to wpis o zastosowanej migracji.
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:191"`
+	AppliedAt time.Time
+}
+
+// migration to jeden krok schematu. up dostaje transakcję i sterownik (sqlite / postgres / mysql).
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB, driver string) error
+}
+
+// migrations — pełna historia schematu, rosnąco po version.
+var migrations = []migration{
+	{1, "baseline", migrateBaseline},
+	{2, "import_files_export_kind", migrateImportFilesExportKind},
+	{3, "woo_tasks_next_attempt_at", migrateWooTasksNextAttemptAt},
+	{4, "manual_links_and_proposals", migrateManualLinksAndProposals},
+	{5, "woo_product_caches_variations_prices", migrateWooProductCachesVariationsPrices},
+	{6, "woo_tasks_preview_json", migrateWooTasksPreviewJSON},
+	{7, "woo_orders", migrateWooOrders},
+	{8, "import_source_id", migrateImportSourceID},
+	{9, "woo_shop", migrateWooShop},
+	{10, "import_files_sha256_per_source", migrateImportFilesSHA256PerSource},
+	{11, "manual_links_shop", migrateManualLinksShop},
+}
+
+// ErrSchemaTooNew: baza była migrowana nowszą wersją programu.
+var ErrSchemaTooNew = errors.New("schemat bazy jest nowszy niż program")
+
+// MigrationStatus opisuje migrację z listy albo wersję znaną tylko bazie (Unknown).
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Unknown   bool       `json:"unknown,omitempty"` // wersja zapisana w bazie, nieznana temu programowi
+}
+
+// LatestSchemaVersion to najnowsza wersja schematu znana programowi.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate dokłada brakujące migracje. Zwraca ErrSchemaTooNew, gdy baza ma wersję nowszą niż program.
 func (h *Handle) Migrate() error {
-	gdb := h.DB
+	_, err := h.MigrateUp()
+	return err
+}
+
+// MigrateUp stosuje brakujące migracje i zwraca listę zastosowanych wersji.
+func (h *Handle) MigrateUp() ([]int, error) {
+	applied, err := h.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	var done []int
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx, h.Driver); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migracja %d (%s): %w", m.version, m.name, err)
+		}
+		done = append(done, m.version)
+	}
+	return done, nil
+}
 
-	// 1) Jeśli tabela istnieje: twardo wyczyść i zdejmij indeks, żeby AutoMigrate się nie wywalił
-	if gdb.Migrator().HasTable(&LinkIssue{}) {
-		// HARD DELETE: usuń wszystko, łącznie z soft-deleted (deleted_at)
-		if err := gdb.Unscoped().Where("1=1").Delete(&LinkIssue{}).Error; err != nil {
-			return fmt.Errorf("hard purge link_issues failed: %w", err)
+// SchemaStatus zwraca stan wszystkich migracji programu oraz wersje znane tylko bazie.
+func (h *Handle) SchemaStatus() ([]MigrationStatus, error) {
+	applied, err := h.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(migrations))
+	known := make(map[int]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = struct{}{}
+		st := MigrationStatus{Version: m.version, Name: m.name}
+		if row, ok := applied[m.version]; ok {
+			at := row.AppliedAt
+			st.AppliedAt = &at
 		}
-		// Zdejmij stary indeks, jeśli istnieje (bez błędu jeśli go nie ma)
-		_ = gdb.Exec(`DROP INDEX IF EXISTS uniq_issue_key;`).Error
-	}
-
-	// 2) AutoMigrate – dopiero teraz (gdy nie ma duplikatów) może bezpiecznie tworzyć indeksy z tagów
-	if err := gdb.AutoMigrate(
-		&ImportFile{},
-		&StProduct{},
-		&StStock{},
-		&WooProductCache{},
-		&WooTask{},
-		&KV{},
-		&LinkIssue{},
-	); err != nil {
-		return fmt.Errorf("AutoMigrate error: %w", err)
-	}
-
-	// 3) Jeżeli w modelu LinkIssue NIE masz tagów unique dla wspólnego indeksu,
-	//    to możesz wymusić indeks tutaj. Jeśli tagi masz – ten blok można pominąć.
-	//    Zostawiam defensywnie:
-	if !gdb.Migrator().HasIndex(&LinkIssue{}, "uniq_issue_key") {
-		if err := gdb.Exec(`
-			CREATE UNIQUE INDEX IF NOT EXISTS uniq_issue_key
-			ON link_issues(towar_id, reason, kod);
-		`).Error; err != nil {
-			return fmt.Errorf("create index uniq_issue_key: %w", err)
+		out = append(out, st)
+	}
+	for version, row := range applied {
+		if _, ok := known[version]; ok {
+			continue
 		}
+		at := row.AppliedAt
+		out = append(out, MigrationStatus{Version: version, Name: row.Name, AppliedAt: &at, Unknown: true})
 	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Version < out[b].Version })
+	return out, nil
+}
+
+func (h *Handle) appliedMigrations() (map[int]SchemaMigration, error) {
+	if err := h.DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("schema_migrations: %w", err)
+	}
+	var rows []SchemaMigration
+	if err := h.DB.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("odczyt schema_migrations: %w", err)
+	}
+	out := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		out[row.Version] = row
+	}
+	return out, nil
+}
 
+// checkSchemaVersion odrzuca bazę z wersją wyższą niż najnowsza migracja programu.
+func checkSchemaVersion(applied map[int]SchemaMigration) error {
+	latest := LatestSchemaVersion()
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: baza w wersji %d, program zna wersje do %d — zaktualizuj pcm2www", ErrSchemaTooNew, version, latest)
+		}
+	}
 	return nil
 }
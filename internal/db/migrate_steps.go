@@ -0,0 +1,494 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kroki migracji. Każdy krok deklaruje lokalnie zamrożone kopie modeli (stan z chwili zmiany)
+// i wykonuje jawne operacje: AddColumn, przebudowę indeksu, nową tabelę. Kroki są odporne na
+// powtórzenie (sprawdzają kolumny / indeksy), bo bazy sprzed wersjonowania mogły mieć część
+// zmian założonych przez dawne AutoMigrate.
+
+// migrateBaseline — schemat sprzed wersjonowania: import_files, staging, cache Woo, taski, kv, link_issues.
+// Na bazie z poprzednich wersji programu nic nie zmienia, na pustej zakłada tabele.
+func migrateBaseline(tx *gorm.DB, _ string) error {
+	type importFile struct {
+		ImportID     uint   `gorm:"primaryKey;column:import_id"`
+		Filename     string `gorm:"uniqueIndex"`
+		FileTimeUTC  string
+		TransmisjaID string `gorm:"uniqueIndex"`
+		SHA256       string `gorm:"uniqueIndex"`
+		SizeBytes    int64
+		Status       int       `gorm:"index"`
+		LastError    string    `gorm:"type:text"`
+		ReceivedAt   time.Time `gorm:"autoCreateTime"`
+		ProcessedAt  *time.Time
+	}
+	type stProduct struct {
+		ID               uint   `gorm:"primaryKey;autoIncrement"`
+		TowarID          int64  `gorm:"uniqueIndex:uniq_towar_kod"`
+		Kod              string `gorm:"uniqueIndex:uniq_towar_kod"`
+		Nazwa            string
+		Opis1            string
+		VatID            int64
+		KategoriaID      int64
+		GrupaID          int64
+		JmID             int64
+		CenaDetal        float64
+		CenaHurtowa      float64
+		CenaNocna        float64
+		CenaDodatkowa    float64
+		CenaDetPrzedProm float64
+		NajCena30Det     float64
+		AktywnyWSI       bool
+		DoUsuniecia      bool
+		DataAktualizacji string
+		FolderZdjec      string
+		PlikZdjecia      string
+		ImportID         uint `gorm:"index"`
+		UpdatedAt        time.Time
+	}
+	type stStock struct {
+		ID         uint  `gorm:"primaryKey;autoIncrement"`
+		TowarID    int64 `gorm:"uniqueIndex:uniq_towar_mag"`
+		MagazynID  int64 `gorm:"uniqueIndex:uniq_towar_mag"`
+		Stan       float64
+		StanPrev   *float64
+		Rezerwacja float64
+		ImportID   uint `gorm:"index"`
+		UpdatedAt  time.Time
+	}
+	type wooProductCache struct {
+		WooID             uint   `gorm:"primaryKey"`
+		TowarID           *int64 `gorm:"index"`
+		Kod               string `gorm:"index"`
+		Ean               string `gorm:"index"`
+		Name              string
+		PriceRegular      float64
+		PriceSale         float64
+		HurtPrice         float64
+		TaxClass          string
+		StockQty          float64
+		StockManaged      bool
+		StockStatus       string
+		Backorders        string
+		CatalogVisibility string
+		Status            string
+		Type              string
+		DateModified      string
+	}
+	type wooTask struct {
+		TaskID      uint   `gorm:"primaryKey;column:task_id"`
+		TaskKey     string `gorm:"uniqueIndex"`
+		ImportID    uint   `gorm:"index"`
+		TowarID     *int64 `gorm:"index"`
+		WooID       *uint  `gorm:"index"`
+		Kind        string `gorm:"index"`
+		PayloadJSON string `gorm:"type:text"`
+		DependsOn   *uint
+		Status      string `gorm:"index;default:pending"`
+		Attempts    int
+		StartedAt   *time.Time
+		FinishedAt  *time.Time
+		LastError   string    `gorm:"type:text"`
+		CreatedAt   time.Time `gorm:"autoCreateTime"`
+		UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	}
+	type kv struct {
+		K string `gorm:"primaryKey"`
+		V string
+	}
+	type linkIssue struct {
+		ID        uint `gorm:"primaryKey"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		TowarID   int64  `gorm:"uniqueIndex:uniq_issue_key"`
+		Reason    string `gorm:"uniqueIndex:uniq_issue_key"`
+		Kod       string `gorm:"uniqueIndex:uniq_issue_key"`
+		WooIDs    string `gorm:"type:text"`
+		Details   string `gorm:"type:text"`
+	}
+
+	// link_issues bez indeksu uniq_issue_key mogą mieć duplikaty, na których założenie indeksu
+	// by się wywróciło. To diagnostyki odtwarzane przy każdym relinku — czyścimy je tylko wtedy.
+	issues := tx.Table("link_issues")
+	if issues.Migrator().HasTable("link_issues") && !issues.Migrator().HasIndex(&linkIssue{}, "uniq_issue_key") {
+		if err := tx.Exec("DELETE FROM link_issues").Error; err != nil {
+			return fmt.Errorf("hard purge link_issues failed: %w", err)
+		}
+	}
+
+	for _, t := range []struct {
+		table string
+		model any
+	}{
+		{"import_files", &importFile{}},
+		{"st_products", &stProduct{}},
+		{"st_stocks", &stStock{}},
+		{"woo_product_caches", &wooProductCache{}},
+		{"woo_tasks", &wooTask{}},
+		{"kvs", &kv{}},
+		{"link_issues", &linkIssue{}},
+	} {
+		if err := tx.Table(t.table).AutoMigrate(t.model); err != nil {
+			return fmt.Errorf("%s: %w", t.table, err)
+		}
+	}
+	return nil
+}
+
+// migrateImportFilesExportKind — typ eksportu PCM (wyk / stany / ceny) i archiwum ZIP pliku.
+func migrateImportFilesExportKind(tx *gorm.DB, _ string) error {
+	type importFile struct {
+		ExportKind  string `gorm:"index"`
+		ArchiveName string `gorm:"index"`
+	}
+	if err := addColumns(tx, "import_files", &importFile{}, "ExportKind", "ArchiveName"); err != nil {
+		return err
+	}
+	return createIndexes(tx, "import_files", &importFile{}, "ExportKind", "ArchiveName")
+}
+
+// migrateWooTasksNextAttemptAt — backoff ponowień po błędach przejściowych Woo.
+func migrateWooTasksNextAttemptAt(tx *gorm.DB, _ string) error {
+	type wooTask struct {
+		NextAttemptAt *time.Time `gorm:"index"`
+	}
+	if err := addColumns(tx, "woo_tasks", &wooTask{}, "NextAttemptAt"); err != nil {
+		return err
+	}
+	return createIndexes(tx, "woo_tasks", &wooTask{}, "NextAttemptAt")
+}
+
+// migrateManualLinksAndProposals — ręczne powiązania towar_id ↔ woo_id i propozycje z dopasowania po nazwie.
+func migrateManualLinksAndProposals(tx *gorm.DB, _ string) error {
+	type manualLink struct {
+		ID        uint      `gorm:"primaryKey"`
+		TowarID   int64     `gorm:"uniqueIndex"`
+		WooID     uint      `gorm:"uniqueIndex"`
+		Note      string    `gorm:"type:text"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+		UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	}
+	type linkProposal struct {
+		ID           uint  `gorm:"primaryKey"`
+		TowarID      int64 `gorm:"uniqueIndex:uniq_link_proposal"`
+		WooID        uint  `gorm:"uniqueIndex:uniq_link_proposal"`
+		Strategy     string
+		Score        float64
+		Quality      string
+		SharedTokens string `gorm:"type:text"`
+		TowarName    string
+		WooName      string
+		Status       string    `gorm:"index;default:pending"`
+		CreatedAt    time.Time `gorm:"autoCreateTime"`
+		UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+	}
+	if err := tx.Table("manual_links").AutoMigrate(&manualLink{}); err != nil {
+		return fmt.Errorf("manual_links: %w", err)
+	}
+	if err := tx.Table("link_proposals").AutoMigrate(&linkProposal{}); err != nil {
+		return fmt.Errorf("link_proposals: %w", err)
+	}
+	return nil
+}
+
+// migrateWooProductCachesVariationsPrices — warianty (parent_id), cena Omnibus i custom fields w cache Woo.
+func migrateWooProductCachesVariationsPrices(tx *gorm.DB, _ string) error {
+	type wooProductCache struct {
+		ParentID     uint `gorm:"index"`
+		OmnibusPrice float64
+		CustomFields string
+	}
+	if err := addColumns(tx, "woo_product_caches", &wooProductCache{}, "ParentID", "OmnibusPrice", "CustomFields"); err != nil {
+		return err
+	}
+	return createIndexes(tx, "woo_product_caches", &wooProductCache{}, "ParentID")
+}
+
+// migrateWooTasksPreviewJSON — podgląd requestu zapisywany przez worker w trybie dry_run.
+func migrateWooTasksPreviewJSON(tx *gorm.DB, _ string) error {
+	type wooTask struct {
+		PreviewJSON string `gorm:"type:text"`
+	}
+	return addColumns(tx, "woo_tasks", &wooTask{}, "PreviewJSON")
+}
+
+// migrateWooOrders — zamówienia pobrane z Woo i ich pozycje.
+func migrateWooOrders(tx *gorm.DB, _ string) error {
+	type wooOrder struct {
+		OrderID      uint   `gorm:"primaryKey;column:order_id;autoIncrement:false"`
+		Number       string `gorm:"index"`
+		Status       string `gorm:"index"`
+		Currency     string
+		Total        float64
+		DateCreated  string
+		DateModified string
+		ExportFile   string
+		ExportedAt   *time.Time `gorm:"index"`
+		CreatedAt    time.Time  `gorm:"autoCreateTime"`
+		UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+	}
+	type wooOrderLine struct {
+		ID          uint `gorm:"primaryKey"`
+		OrderID     uint `gorm:"uniqueIndex:uniq_order_line"`
+		LineID      uint `gorm:"uniqueIndex:uniq_order_line"`
+		ProductID   uint
+		VariationID uint
+		WooID       uint   `gorm:"index"`
+		TowarID     *int64 `gorm:"index"`
+		Ean         string
+		SKU         string
+		Name        string
+		Quantity    float64
+		PriceGross  float64
+		TotalGross  float64
+	}
+	if err := tx.Table("woo_orders").AutoMigrate(&wooOrder{}); err != nil {
+		return fmt.Errorf("woo_orders: %w", err)
+	}
+	if err := tx.Table("woo_order_lines").AutoMigrate(&wooOrderLine{}); err != nil {
+		return fmt.Errorf("woo_order_lines: %w", err)
+	}
+	return nil
+}
+
+// migrateImportSourceID — źródła importu: source_id w import_files i w kluczu stanów magazynowych.
+func migrateImportSourceID(tx *gorm.DB, _ string) error {
+	type importFile struct {
+		SourceID string `gorm:"index;not null;default:''"`
+	}
+	type stStock struct {
+		TowarID   int64  `gorm:"uniqueIndex:uniq_towar_mag"`
+		MagazynID int64  `gorm:"uniqueIndex:uniq_towar_mag"`
+		SourceID  string `gorm:"uniqueIndex:uniq_towar_mag;not null;default:''"`
+	}
+	if err := addColumns(tx, "import_files", &importFile{}, "SourceID"); err != nil {
+		return err
+	}
+	if err := createIndexes(tx, "import_files", &importFile{}, "SourceID"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, "st_stocks", &stStock{}, "SourceID"); err != nil {
+		return err
+	}
+	return rebuildIndex(tx, "st_stocks", &stStock{}, "uniq_towar_mag")
+}
+
+// migrateWooShop — nazwane sklepy woocommerce:<shop>. Istniejące wiersze należą do sklepu domyślnego (shop = "").
+// woo_product_caches i woo_orders zmieniają klucz główny na (shop, id), więc są przebudowywane.
+func migrateWooShop(tx *gorm.DB, _ string) error {
+	type wooTask struct {
+		Shop string `gorm:"index;not null;default:''"`
+	}
+	type linkIssue struct {
+		Shop    string `gorm:"uniqueIndex:uniq_issue_key;not null;default:''"`
+		TowarID int64  `gorm:"uniqueIndex:uniq_issue_key"`
+		Reason  string `gorm:"uniqueIndex:uniq_issue_key"`
+		Kod     string `gorm:"uniqueIndex:uniq_issue_key"`
+	}
+	type wooOrderLine struct {
+		Shop    string `gorm:"uniqueIndex:uniq_order_line;not null;default:''"`
+		OrderID uint   `gorm:"uniqueIndex:uniq_order_line"`
+		LineID  uint   `gorm:"uniqueIndex:uniq_order_line"`
+	}
+	type wooProductCache struct {
+		Shop              string `gorm:"primaryKey;default:''"`
+		WooID             uint   `gorm:"primaryKey;autoIncrement:false"`
+		ParentID          uint   `gorm:"index"`
+		TowarID           *int64 `gorm:"index"`
+		Kod               string `gorm:"index"`
+		Ean               string `gorm:"index"`
+		Name              string
+		PriceRegular      float64
+		PriceSale         float64
+		HurtPrice         float64
+		OmnibusPrice      float64
+		CustomFields      string
+		TaxClass          string
+		StockQty          float64
+		StockManaged      bool
+		StockStatus       string
+		Backorders        string
+		CatalogVisibility string
+		Status            string
+		Type              string
+		DateModified      string
+	}
+	type wooOrder struct {
+		Shop         string `gorm:"primaryKey;default:''"`
+		OrderID      uint   `gorm:"primaryKey;column:order_id;autoIncrement:false"`
+		Number       string `gorm:"index"`
+		Status       string `gorm:"index"`
+		Currency     string
+		Total        float64
+		DateCreated  string
+		DateModified string
+		ExportFile   string
+		ExportedAt   *time.Time `gorm:"index"`
+		CreatedAt    time.Time  `gorm:"autoCreateTime"`
+		UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+	}
+
+	if err := addColumns(tx, "woo_tasks", &wooTask{}, "Shop"); err != nil {
+		return err
+	}
+	if err := createIndexes(tx, "woo_tasks", &wooTask{}, "Shop"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, "link_issues", &linkIssue{}, "Shop"); err != nil {
+		return err
+	}
+	if err := rebuildIndex(tx, "link_issues", &linkIssue{}, "uniq_issue_key"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, "woo_order_lines", &wooOrderLine{}, "Shop"); err != nil {
+		return err
+	}
+	if err := rebuildIndex(tx, "woo_order_lines", &wooOrderLine{}, "uniq_order_line"); err != nil {
+		return err
+	}
+	if err := rebuildWithShop(tx, "woo_product_caches", &wooProductCache{}); err != nil {
+		return err
+	}
+	return rebuildWithShop(tx, "woo_orders", &wooOrder{})
+}
+
+// migrateImportFilesSHA256PerSource — dedup po SHA w obrębie źródła: identyczny plik w dwóch źródłach
+// to dwa importy, więc globalny unikat sha256 przechodzi na (sha256, source_id).
+func migrateImportFilesSHA256PerSource(tx *gorm.DB, _ string) error {
+	type importFile struct {
+		SHA256   string `gorm:"uniqueIndex:uniq_source_sha256"`
+		SourceID string `gorm:"uniqueIndex:uniq_source_sha256"`
+	}
+	m := tx.Migrator()
+	if m.HasIndex("import_files", "idx_import_files_sha256") {
+		if err := m.DropIndex("import_files", "idx_import_files_sha256"); err != nil {
+			return fmt.Errorf("import_files: drop index idx_import_files_sha256: %w", err)
+		}
+	}
+	return createIndexes(tx, "import_files", &importFile{}, "uniq_source_sha256")
+}
+
+// migrateManualLinksShop — ręczne powiązania i propozycje osobno dla każdego sklepu: shop wchodzi
+// do kluczy (shop, towar_id), (shop, woo_id) i (shop, towar_id, woo_id). Istniejące należą do sklepu domyślnego.
+func migrateManualLinksShop(tx *gorm.DB, _ string) error {
+	type manualLink struct {
+		Shop    string `gorm:"uniqueIndex:uniq_manual_link_towar;uniqueIndex:uniq_manual_link_woo;not null;default:''"`
+		TowarID int64  `gorm:"uniqueIndex:uniq_manual_link_towar"`
+		WooID   uint   `gorm:"uniqueIndex:uniq_manual_link_woo"`
+	}
+	type linkProposal struct {
+		Shop    string `gorm:"uniqueIndex:uniq_link_proposal;not null;default:''"`
+		TowarID int64  `gorm:"uniqueIndex:uniq_link_proposal"`
+		WooID   uint   `gorm:"uniqueIndex:uniq_link_proposal"`
+	}
+
+	if err := addColumns(tx, "manual_links", &manualLink{}, "Shop"); err != nil {
+		return err
+	}
+	m := tx.Migrator()
+	for _, idx := range []string{"idx_manual_links_towar_id", "idx_manual_links_woo_id"} {
+		if !m.HasIndex("manual_links", idx) {
+			continue
+		}
+		if err := m.DropIndex("manual_links", idx); err != nil {
+			return fmt.Errorf("manual_links: drop index %s: %w", idx, err)
+		}
+	}
+	if err := createIndexes(tx, "manual_links", &manualLink{}, "uniq_manual_link_towar", "uniq_manual_link_woo"); err != nil {
+		return err
+	}
+
+	if err := addColumns(tx, "link_proposals", &linkProposal{}, "Shop"); err != nil {
+		return err
+	}
+	return rebuildIndex(tx, "link_proposals", &linkProposal{}, "uniq_link_proposal")
+}
+
+// addColumns dokłada kolumny pól fields zamrożonego modelu, których tabela jeszcze nie ma.
+func addColumns(tx *gorm.DB, table string, model any, fields ...string) error {
+	m := tx.Table(table).Migrator()
+	for _, field := range fields {
+		if m.HasColumn(model, field) {
+			continue
+		}
+		if err := m.AddColumn(model, field); err != nil {
+			return fmt.Errorf("%s: add column %s: %w", table, field, err)
+		}
+	}
+	return nil
+}
+
+// createIndexes zakłada brakujące indeksy zamrożonego modelu (nazwa indeksu albo pola z tagu index).
+func createIndexes(tx *gorm.DB, table string, model any, names ...string) error {
+	m := tx.Table(table).Migrator()
+	for _, name := range names {
+		if m.HasIndex(model, name) {
+			continue
+		}
+		if err := m.CreateIndex(model, name); err != nil {
+			return fmt.Errorf("%s: create index %s: %w", table, name, err)
+		}
+	}
+	return nil
+}
+
+// rebuildIndex zakłada indeks od nowa według zamrożonego modelu — po dopisaniu kolumny do klucza
+// (CreateIndex nie zmienia istniejącego indeksu o tej samej nazwie).
+func rebuildIndex(tx *gorm.DB, table string, model any, name string) error {
+	m := tx.Table(table).Migrator()
+	if m.HasIndex(model, name) {
+		if err := m.DropIndex(model, name); err != nil {
+			return fmt.Errorf("%s: drop index %s: %w", table, name, err)
+		}
+	}
+	if err := m.CreateIndex(model, name); err != nil {
+		return fmt.Errorf("%s: create index %s: %w", table, name, err)
+	}
+	return nil
+}
+
+// rebuildWithShop przenosi tabelę na klucz główny z kolumną shop — tego ALTER TABLE nie zrobi
+// przenośnie. Dane przechodzą w SQL: kopia CREATE TABLE … AS SELECT, tabela zakładana od nowa
+// z modelu, powrót przez INSERT … SELECT; shop dostaje wartość domyślną "" (sklep domyślny).
+func rebuildWithShop(tx *gorm.DB, table string, model any) error {
+	m := tx.Migrator()
+	if !m.HasTable(table) || m.HasColumn(table, "shop") {
+		return nil
+	}
+	quote := func(name string) string { return tx.Statement.Quote(clause.Table{Name: name}) }
+	tmp := table + "_rebuild"
+
+	if err := tx.Exec("CREATE TABLE " + quote(tmp) + " AS SELECT * FROM " + quote(table)).Error; err != nil {
+		return fmt.Errorf("rebuild %s: kopia: %w", table, err)
+	}
+	cols, err := m.ColumnTypes(tmp)
+	if err != nil {
+		return fmt.Errorf("rebuild %s: kolumny: %w", table, err)
+	}
+	if err := m.DropTable(table); err != nil {
+		return fmt.Errorf("rebuild %s: drop: %w", table, err)
+	}
+	if err := tx.Table(table).AutoMigrate(model); err != nil {
+		return fmt.Errorf("rebuild %s: create: %w", table, err)
+	}
+
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		names = append(names, tx.Statement.Quote(clause.Column{Name: c.Name()}))
+	}
+	list := strings.Join(names, ", ")
+	if err := tx.Exec("INSERT INTO " + quote(table) + " (" + list + ") SELECT " + list + " FROM " + quote(tmp)).Error; err != nil {
+		return fmt.Errorf("rebuild %s: przepisanie wierszy: %w", table, err)
+	}
+	if err := m.DropTable(tmp); err != nil {
+		return fmt.Errorf("rebuild %s: drop kopii: %w", table, err)
+	}
+	return nil
+}
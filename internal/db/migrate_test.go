@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMigrateRecordsVersionsAndRefusesNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenWithConfig(dir, OpenConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := h.DB.DB()
+	defer sqlDB.Close()
+
+	done, err := h.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations) {
+		t.Fatalf("expected all %d migrations applied, got %v", len(migrations), done)
+	}
+	if !h.DB.Migrator().HasTable(&WooTask{}) {
+		t.Fatal("expected woo_tasks after migrate")
+	}
+
+	// link_issues nie są już czyszczone przy każdym starcie
+	if err := h.DB.Create(&LinkIssue{TowarID: 1, Reason: "missing_ean_src"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if done, err := h.MigrateUp(); err != nil || len(done) != 0 {
+		t.Fatalf("expected no-op second migrate, got %v, %v", done, err)
+	}
+	var issues int64
+	if err := h.DB.Model(&LinkIssue{}).Count(&issues).Error; err != nil {
+		t.Fatal(err)
+	}
+	if issues != 1 {
+		t.Fatalf("expected link_issues kept across migrate, got %d", issues)
+	}
+
+	newer := SchemaMigration{Version: LatestSchemaVersion() + 1, Name: "from_future", AppliedAt: time.Now()}
+	if err := h.DB.Create(&newer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Migrate(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+	status, err := h.SchemaStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := status[len(status)-1]; !last.Unknown || last.Version != newer.Version {
+		t.Fatalf("expected unknown newer version in status, got %+v", last)
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	h := newMigrateTestHandle(t)
+	if err := h.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := h.DB.Migrator()
+	for _, model := range []any{
+		&ImportFile{}, &StProduct{}, &StStock{}, &WooProductCache{}, &WooTask{}, &KV{},
+		&LinkIssue{}, &ManualLink{}, &LinkProposal{}, &WooOrder{}, &WooOrderLine{},
+	} {
+		stmt := &gorm.Statement{DB: h.DB}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range stmt.Schema.DBNames {
+			if !m.HasColumn(model, column) {
+				t.Errorf("%s: migrations miss column %s", stmt.Schema.Table, column)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !m.HasIndex(model, idx.Name) {
+				t.Errorf("%s: migrations miss index %s", stmt.Schema.Table, idx.Name)
+			}
+		}
+	}
+}
+
+func TestMigrateKeepsBaselineRowsInDefaultShop(t *testing.T) {
+	h := newMigrateTestHandle(t)
+	if err := h.DB.Transaction(func(tx *gorm.DB) error { return migrations[0].up(tx, h.Driver) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.Exec(`INSERT INTO woo_product_caches (woo_id, towar_id, kod, ean, name, price_regular, status)
+		VALUES (10, 1, 'SKU-10', '5900000000010', 'Produkt', 12.5, 'publish')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.Exec(`INSERT INTO link_issues (towar_id, reason, kod) VALUES (1, 'missing_ean_src', '')`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.Create(&SchemaMigration{Version: 1, Name: "baseline", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	var cached WooProductCache
+	if err := h.DB.Where("shop = '' AND woo_id = ?", 10).Take(&cached).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cached.TowarID == nil || *cached.TowarID != 1 || cached.Kod != "SKU-10" || cached.PriceRegular != 12.5 {
+		t.Fatalf("cache row must survive the rebuild, got %+v", cached)
+	}
+	// klucz (shop, woo_id): ten sam produkt Woo w innym sklepie to osobny wiersz
+	if err := h.DB.Create(&WooProductCache{Shop: "b2b", WooID: 10, Kod: "SKU-10"}).Error; err != nil {
+		t.Fatalf("expected (shop, woo_id) primary key, got %v", err)
+	}
+	var issues int64
+	if err := h.DB.Model(&LinkIssue{}).Where("shop = ''").Count(&issues).Error; err != nil {
+		t.Fatal(err)
+	}
+	if issues != 1 {
+		t.Fatalf("expected link issue kept in default shop, got %d", issues)
+	}
+}
+
+func newMigrateTestHandle(t *testing.T) *Handle {
+	t.Helper()
+	dir := t.TempDir()
+	h, err := OpenWithConfig(dir, OpenConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := h.DB.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return h
+}
@@ -11,9 +11,12 @@ type ImportFile struct {
 	Filename     string `gorm:"uniqueIndex"`
 	FileTimeUTC  string
 	TransmisjaID string `gorm:"uniqueIndex"`
-	SHA256       string `gorm:"uniqueIndex"`
+	SHA256       string `gorm:"uniqueIndex:uniq_source_sha256"` // ten sam plik w dwóch źródłach to dwa importy
 	SizeBytes    int64
-	Status       int       `gorm:"index"` // 0=pending, 1=done, 2=error
+	ExportKind   string    `gorm:"index"`                                                    // wyk / stany / ceny (typ eksportu PCM)
+	ArchiveName  string    `gorm:"index"`                                                    // archiwum ZIP, z którego pochodzi plik ("" = zwykły XML)
+	SourceID     string    `gorm:"index;uniqueIndex:uniq_source_sha256;not null;default:''"` // źródło importu (importer sources; "" = główny watch_dir)
+	Status       int       `gorm:"index"`                                                    // 0=pending, 1=done, 2=error
 	LastError    string    `gorm:"type:text"`
 	ReceivedAt   time.Time `gorm:"autoCreateTime"`
 	ProcessedAt  *time.Time
@@ -47,9 +50,10 @@ type StProduct struct {
 
 // st_stock (staging)
 type StStock struct {
-	ID         uint  `gorm:"primaryKey;autoIncrement"`
-	TowarID    int64 `gorm:"uniqueIndex:uniq_towar_mag"`
-	MagazynID  int64 `gorm:"uniqueIndex:uniq_towar_mag"`
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	TowarID    int64  `gorm:"uniqueIndex:uniq_towar_mag"`
+	MagazynID  int64  `gorm:"uniqueIndex:uniq_towar_mag"`
+	SourceID   string `gorm:"uniqueIndex:uniq_towar_mag;not null;default:''"` // źródło (sklep PCM); "" = główny watch_dir
 	Stan       float64
 	StanPrev   *float64 // poprzednia wartość stan (NULL = brak historii, pierwszy import)
 	Rezerwacja float64
@@ -58,41 +62,51 @@ type StStock struct {
 }
 
 // woo_products_cache
+// Klucz: (shop, woo_id) — każda instancja woocommerce[:<shop>] ma własne wiersze cache ("" = sklep domyślny).
 type WooProductCache struct {
-	WooID        uint   `gorm:"primaryKey"`
-	TowarID      *int64 `gorm:"index"`
-	Kod          string `gorm:"index"` // SKU
-	Ean          string `gorm:"index"`
-	Name         string
-	PriceRegular float64
-	PriceSale    float64
-	HurtPrice    float64
-	TaxClass     string  // "" = standard, "2300", "800", "500", "zero-rate"
-	StockQty     float64
+	Shop              string `gorm:"primaryKey;default:''"`
+	WooID             uint   `gorm:"primaryKey;autoIncrement:false"`
+	ParentID          uint   `gorm:"index"` // produkt variable, do którego należy wariant (0 = zwykły produkt)
+	TowarID           *int64 `gorm:"index"`
+	Kod               string `gorm:"index"` // SKU
+	Ean               string `gorm:"index"`
+	Name              string
+	PriceRegular      float64
+	PriceSale         float64
+	HurtPrice         float64
+	OmnibusPrice      float64 // najniższa cena z 30 dni (custom field omnibus_price)
+	CustomFields      string  // JSON {kod: wartość} pozostałych custom fields (reguły cen importera)
+	TaxClass          string  // "" = standard, "2300", "800", "500", "zero-rate"
+	StockQty          float64
 	StockManaged      bool
 	StockStatus       string // instock / outofstock / onbackorder
 	Backorders        string // no / notify / yes
 	CatalogVisibility string // visible / hidden / catalog / search
 	Status            string // publish/draft/trash
-	Type         string
-	DateModified string
+	Type              string // simple / variable / variation / ...
+	DateModified      string
 }
 
 // woo_tasks
 type WooTask struct {
 	TaskID      uint   `gorm:"primaryKey;column:task_id"`
 	TaskKey     string `gorm:"uniqueIndex"`
+	Shop        string `gorm:"index;not null;default:''"` // instancja woocommerce[:<shop>], która wykonuje task ("" = domyślna)
 	ImportID    uint   `gorm:"index"`
 	TowarID     *int64 `gorm:"index"`
 	WooID       *uint  `gorm:"index"`
 	Kind        string `gorm:"index"` // np. product.update, stock.update
 	PayloadJSON string `gorm:"type:text"`
 	DependsOn   *uint
-	Status      string `gorm:"index;default:pending"` // pending/done/error
+	Status      string `gorm:"index;default:pending"` // pending/done/error (previewed = dry_run)
 	Attempts    int
-	StartedAt   *time.Time
-	FinishedAt  *time.Time
-	LastError   string    `gorm:"type:text"`
+	// NextAttemptAt: najwcześniejszy moment ponownej próby po błędzie przejściowym (nil = od razu)
+	NextAttemptAt *time.Time `gorm:"index"`
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+	LastError     string `gorm:"type:text"`
+	// PreviewJSON: WooTaskPreview zapisany przez worker w trybie dry_run (status previewed)
+	PreviewJSON string    `gorm:"type:text"`
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
 }
@@ -102,6 +116,7 @@ type LinkIssue struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
+	Shop    string `gorm:"uniqueIndex:uniq_issue_key;not null;default:''"` // sklep Woo, którego dotyczy problem
 	TowarID int64  `gorm:"uniqueIndex:uniq_issue_key"`
 	Reason  string `gorm:"uniqueIndex:uniq_issue_key"`
 	Kod     string `gorm:"uniqueIndex:uniq_issue_key"`
@@ -109,8 +124,82 @@ type LinkIssue struct {
 	Details string `gorm:"type:text"`
 }
 
+// ManualLink przypina towar PCM do produktu Woo niezależnie od EAN — osobno w każdym sklepie.
+// Linker stosuje te powiązania przed dopasowaniem po EAN i nie zgłasza ich w link_issues.
+type ManualLink struct {
+	ID        uint      `gorm:"primaryKey"`
+	Shop      string    `gorm:"uniqueIndex:uniq_manual_link_towar;uniqueIndex:uniq_manual_link_woo;not null;default:''"` // instancja woocommerce[:<shop>]
+	TowarID   int64     `gorm:"uniqueIndex:uniq_manual_link_towar"`
+	WooID     uint      `gorm:"uniqueIndex:uniq_manual_link_woo"`
+	Note      string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// LinkProposal to propozycja powiązania z dopasowania po nazwie, czekająca na decyzję obsługi.
+// Status: pending / approved (zatwierdzenie tworzy ManualLink) / rejected (nie wraca przy kolejnym relinku).
+type LinkProposal struct {
+	ID           uint    `gorm:"primaryKey"`
+	Shop         string  `gorm:"uniqueIndex:uniq_link_proposal;not null;default:''"` // sklep, z którego cache pochodzi produkt Woo
+	TowarID      int64   `gorm:"uniqueIndex:uniq_link_proposal"`
+	WooID        uint    `gorm:"uniqueIndex:uniq_link_proposal"`
+	Strategy     string  // np. name
+	Score        float64 // 0..1
+	Quality      string  // exact_name / strong_name_match / medium_name_match / fuzzy_name_match
+	SharedTokens string  `gorm:"type:text"`
+	TowarName    string
+	WooName      string
+	Status       string    `gorm:"index;default:pending"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+// WooOrder to nagłówek zamówienia pobranego z Woo (order pull).
+// ExportedAt != nil — dokument zamówienia trafił już do outbox dla PC-Market i nie jest eksportowany ponownie.
+type WooOrder struct {
+	Shop         string `gorm:"primaryKey;default:''"`                          // instancja woocommerce[:<shop>]
+	OrderID      uint   `gorm:"primaryKey;column:order_id;autoIncrement:false"` // id zamówienia w Woo
+	Number       string `gorm:"index"`
+	Status       string `gorm:"index"` // pending / processing / completed / cancelled / refunded / ...
+	Currency     string
+	Total        float64 // brutto
+	DateCreated  string  // date_created_gmt z Woo
+	DateModified string  // date_modified_gmt z Woo
+	ExportFile   string
+	ExportedAt   *time.Time `gorm:"index"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+}
+
+// WooOrderLine to pozycja zamówienia (line_items). TowarID pochodzi z cache (powiązanie linkera) w chwili pobrania.
+type WooOrderLine struct {
+	ID          uint   `gorm:"primaryKey"`
+	Shop        string `gorm:"uniqueIndex:uniq_order_line;not null;default:''"`
+	OrderID     uint   `gorm:"uniqueIndex:uniq_order_line"`
+	LineID      uint   `gorm:"uniqueIndex:uniq_order_line"` // line_items[].id
+	ProductID   uint   // product_id z Woo
+	VariationID uint   // variation_id z Woo (0 = zwykły produkt)
+	WooID       uint   `gorm:"index"` // variation_id albo product_id — klucz do woo_product_caches
+	TowarID     *int64 `gorm:"index"`
+	Ean         string // EAN z cache
+	SKU         string
+	Name        string
+	Quantity    float64
+	PriceGross  float64 // cena jednostkowa brutto
+	TotalGross  float64 // wartość pozycji brutto
+}
+
 // internal/db/models.go
 type KV struct {
 	K string `gorm:"primaryKey"`
 	V string
 }
+
+// TaxClassesKVKey to klucz kvs z listą slugów /taxes/classes sklepu shop ("" = sklep domyślny).
+// Zapisuje go integracja woocommerce, czyta planner importera.
+func TaxClassesKVKey(shop string) string {
+	if shop == "" {
+		return "woo_tax_classes"
+	}
+	return "woo_tax_classes:" + shop
+}
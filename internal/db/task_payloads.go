@@ -5,11 +5,14 @@ const (
 	WooTaskKindStockUpdate        = "stock.update"
 	WooTaskKindPriceUpdate        = "price.update"
 	WooTaskKindAvailabilityUpdate = "availability.update"
+	WooTaskKindProductCreate      = "product.create"
+	WooTaskKindVisibilityUpdate   = "visibility.update"
 )
 
 type WooEANUpdatePayload struct {
 	ImportID    uint   `json:"import_id"`
 	WooID       uint   `json:"woo_id"`
+	ParentID    uint   `json:"parent_id,omitempty"` // != 0: wariant, worker używa /products/{parent_id}/variations
 	TowarID     int64  `json:"towar_id"`
 	SKU         string `json:"sku"`
 	ProductName string `json:"product_name"`
@@ -21,6 +24,7 @@ type WooEANUpdatePayload struct {
 type WooStockUpdatePayload struct {
 	ImportID      uint    `json:"import_id"`
 	WooID         uint    `json:"woo_id"`
+	ParentID      uint    `json:"parent_id,omitempty"`
 	TowarID       int64   `json:"towar_id"`
 	SKU           string  `json:"sku"`
 	ProductName   string  `json:"product_name"`
@@ -37,6 +41,7 @@ type WooStockUpdatePayload struct {
 type WooAvailabilityPayload struct {
 	ImportID    uint   `json:"import_id"`
 	WooID       uint   `json:"woo_id"`
+	ParentID    uint   `json:"parent_id,omitempty"`
 	TowarID     int64  `json:"towar_id"`
 	SKU         string `json:"sku"`
 	ProductName string `json:"product_name"`
@@ -46,6 +51,7 @@ type WooAvailabilityPayload struct {
 type WooPriceUpdatePayload struct {
 	ImportID        uint    `json:"import_id"`
 	WooID           uint    `json:"woo_id"`
+	ParentID        uint    `json:"parent_id,omitempty"`
 	TowarID         int64   `json:"towar_id"`
 	SKU             string  `json:"sku"`
 	ProductName     string  `json:"product_name"`
@@ -56,4 +62,73 @@ type WooPriceUpdatePayload struct {
 	DesiredHurt     float64 `json:"desired_hurt"`
 	CurrentTaxClass string  `json:"current_tax_class"`
 	DesiredTaxClass string  `json:"desired_tax_class"`
+
+	// Tryb promocji (importer promo_prices): regular = cena sprzed promocji, sale = cena promocyjna,
+	// DesiredSale=0 czyści sale_price po końcu promocji. DesiredOmnibus trafia do custom field omnibus_price.
+	PromoMode      bool    `json:"promo_mode,omitempty"`
+	DesiredSale    float64 `json:"desired_sale,omitempty"`
+	DesiredOmnibus float64 `json:"desired_omnibus,omitempty"`
+
+	// Reguły cen (importer prices): PriceRules=true — custom fields cenowe to dokładnie Fields,
+	// a DesiredHurt / CurrentHurt są tylko lustrem pola hurt_price. Taski sprzed reguł ustawiają hurt_price z DesiredHurt.
+	PriceRules bool            `json:"price_rules,omitempty"`
+	Fields     []WooPriceField `json:"fields,omitempty"`
+}
+
+// WooPriceField to cena zapisywana w custom field Woo (kod z woocommerce custom_fields).
+type WooPriceField struct {
+	Code    string  `json:"code"`
+	Current float64 `json:"current"`
+	Desired float64 `json:"desired"`
+}
+
+// WooProductCreatePayload opisuje nowy produkt (simple, draft) zakładany w Woo
+// dla towaru z PCM, który nie ma jeszcze powiązania w cache.
+type WooProductCreatePayload struct {
+	ImportID        uint    `json:"import_id"`
+	TowarID         int64   `json:"towar_id"`
+	SKU             string  `json:"sku"`
+	ProductName     string  `json:"product_name"`
+	EAN             string  `json:"ean"`
+	DesiredRegular  float64 `json:"desired_regular"`
+	DesiredHurt     float64 `json:"desired_hurt"`
+	DesiredTaxClass string  `json:"desired_tax_class"`
+	DesiredStock    float64 `json:"desired_stock"`
+
+	PriceRules bool            `json:"price_rules,omitempty"` // jak w WooPriceUpdatePayload
+	Fields     []WooPriceField `json:"fields,omitempty"`
+}
+
+// WooVisibilityPayload wycofuje produkt ze sklepu, gdy PCM oznaczy towar do_usuniecia=Y
+// albo zdejmie aktywny_w_SI (Retire=true), i przywraca go po ponownej aktywacji (Retire=false).
+// Mode to tryb z konfiguracji importera: draft / private (pole status) albo hidden (catalog_visibility).
+// Puste Desired* oznacza, że pole nie jest zmieniane.
+type WooVisibilityPayload struct {
+	ImportID          uint   `json:"import_id"`
+	WooID             uint   `json:"woo_id"`
+	ParentID          uint   `json:"parent_id,omitempty"`
+	TowarID           int64  `json:"towar_id"`
+	SKU               string `json:"sku"`
+	ProductName       string `json:"product_name"`
+	Retire            bool   `json:"retire"`
+	Mode              string `json:"mode"`
+	CurrentStatus     string `json:"current_status"`
+	CurrentVisibility string `json:"current_visibility"`
+	DesiredStatus     string `json:"desired_status,omitempty"`
+	DesiredVisibility string `json:"desired_visibility,omitempty"`
+}
+
+// WooTaskPreview to zapis taska obsłużonego w trybie dry_run (status previewed):
+// request, który worker wysłałby do Woo, i różnice pól względem aktualnego produktu.
+type WooTaskPreview struct {
+	Method string         `json:"method"`
+	Path   string         `json:"path"`
+	Body   map[string]any `json:"body"`
+	Diff   []WooFieldDiff `json:"diff"`
+}
+
+type WooFieldDiff struct {
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
 }
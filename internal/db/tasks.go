@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ręczne operacje na kolejce woo_tasks — wspólne dla API administracyjnego i CLI.
+
+var (
+	// ErrTaskNotFound: task o podanym ID nie istnieje.
+	ErrTaskNotFound = errors.New("task nie istnieje")
+	// ErrTaskStatus: task jest w statusie, który nie pozwala na operację (albo zmienił go w trakcie).
+	ErrTaskStatus = errors.New("niedozwolony status taska")
+)
+
+// RetryTask przywraca task error/skipped/cancelled/previewed do pending z wyzerowanym licznikiem prób.
+// Task pending czekający na backoff jest odblokowany od razu.
+func RetryTask(gdb *gorm.DB, taskID uint) (WooTask, error) {
+	task, err := loadTask(gdb, taskID)
+	if err != nil {
+		return task, err
+	}
+	switch task.Status {
+	case "pending", "error", "skipped", "cancelled", "previewed":
+	default:
+		return task, fmt.Errorf("%w: nie można ponowić taska w statusie %s", ErrTaskStatus, task.Status)
+	}
+	res := gdb.Model(&WooTask{}).
+		Where("task_id = ? AND status = ?", task.TaskID, task.Status).
+		Updates(map[string]any{
+			"status":          "pending",
+			"attempts":        0,
+			"last_error":      "",
+			"started_at":      nil,
+			"finished_at":     nil,
+			"next_attempt_at": nil,
+			"preview_json":    "",
+		})
+	return finishTaskChange(gdb, task.TaskID, res)
+}
+
+// CancelTask anuluje task pending (running kończy worker); reason trafia do last_error.
+func CancelTask(gdb *gorm.DB, taskID uint, reason string) (WooTask, error) {
+	task, err := loadTask(gdb, taskID)
+	if err != nil {
+		return task, err
+	}
+	if task.Status != "pending" {
+		return task, fmt.Errorf("%w: można anulować tylko task pending, status: %s", ErrTaskStatus, task.Status)
+	}
+	res := gdb.Model(&WooTask{}).
+		Where("task_id = ? AND status = ?", task.TaskID, "pending").
+		Updates(map[string]any{
+			"status":          "cancelled",
+			"last_error":      reason,
+			"finished_at":     time.Now(),
+			"next_attempt_at": nil,
+		})
+	return finishTaskChange(gdb, task.TaskID, res)
+}
+
+func loadTask(gdb *gorm.DB, taskID uint) (WooTask, error) {
+	var task WooTask
+	// Find zamiast Take: brak taska to zwykły wynik, nie błąd logowany przez gorm
+	res := gdb.Where("task_id = ?", taskID).Limit(1).Find(&task)
+	if res.Error != nil {
+		return task, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return task, ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// finishTaskChange sprawdza wynik warunkowego UPDATE i zwraca task po zmianie.
+func finishTaskChange(gdb *gorm.DB, taskID uint, res *gorm.DB) (WooTask, error) {
+	if res.Error != nil {
+		return WooTask{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return WooTask{}, fmt.Errorf("%w: task zmienił status w trakcie operacji", ErrTaskStatus)
+	}
+	return loadTask(gdb, taskID)
+}
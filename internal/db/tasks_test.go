@@ -0,0 +1,46 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetryAndCancelTaskChecksStatus(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenWithConfig(dir, OpenConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := h.DB.DB()
+	defer sqlDB.Close()
+	if err := h.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	task := WooTask{Kind: "stock.update", Status: "error", Attempts: 5, LastError: "HTTP 500"}
+	if err := h.DB.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CancelTask(h.DB, task.TaskID, "test"); !errors.Is(err, ErrTaskStatus) {
+		t.Fatalf("expected ErrTaskStatus for cancel of error task, got %v", err)
+	}
+	retried, err := RetryTask(h.DB, task.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Status != "pending" || retried.Attempts != 0 || retried.LastError != "" {
+		t.Fatalf("expected reset pending task, got %+v", retried)
+	}
+	cancelled, err := CancelTask(h.DB, task.TaskID, "cancelled via CLI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != "cancelled" || cancelled.LastError != "cancelled via CLI" || cancelled.FinishedAt == nil {
+		t.Fatalf("expected cancelled task with reason, got %+v", cancelled)
+	}
+	if _, err := RetryTask(h.DB, task.TaskID+100); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bartek5186/pcm2www/internal/db"
@@ -20,13 +22,29 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/net/html/charset"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type Config struct {
-	WatchDir  string `json:"watch_dir"`            // np. ~/pcm2www/imports
-	PollSec   int    `json:"poll_sec"`             // np. 5-10s w dev
-	PriceMode string `json:"price_mode,omitempty"` // gross (domyślnie) albo net
+	WatchDir       string `json:"watch_dir"`                 // np. ~/pcm2www/imports
+	PollSec        int    `json:"poll_sec"`                  // np. 5-10s w dev
+	Watch          string `json:"watch,omitempty"`           // auto (domyślnie: inotify na Linuksie + poll_sec) / poll
+	StableSec      int    `json:"stable_sec,omitempty"`      // plik musi mieć niezmieniony rozmiar i mtime przez N sekund (domyślnie 5)
+	DoneMarker     bool   `json:"done_marker,omitempty"`     // przetwarzaj tylko pliki ze znacznikiem <plik>.done
+	PriceMode      string `json:"price_mode,omitempty"`      // gross (domyślnie) albo net
+	CreateProducts bool   `json:"create_products,omitempty"` // zakładaj w Woo brakujące towary z aktywny_w_SI=Y
+	RetireMode     string `json:"retire_mode,omitempty"`     // off (domyślnie) / draft / private / hidden — co robić z towarem wycofanym w PCM
+	PromoPrices    bool   `json:"promo_prices,omitempty"`    // promocje PCM → sale_price (regular = cena sprzed promocji) + cena Omnibus
+
+	Warehouses   WarehouseConfig `json:"warehouses,omitempty"`     // wybór magazynów PCM zasilających stan w Woo
+	XLSMagazynID int64           `json:"xls_magazyn_id,omitempty"` // magazyn_id, pod którym zapisywana jest „Ilość” z arkuszy XLS (domyślnie 1)
+	Linking      LinkingConfig   `json:"linking,omitempty"`        // strategie linkowania po EAN: SKU, nazwa
+
+	Sources      []SourceConfig `json:"sources,omitempty"`       // dodatkowe instalacje PCM (każda z własnym katalogiem)
+	StockCombine string         `json:"stock_combine,omitempty"` // sum (domyślnie) / max / min — łączenie stanu ze źródeł
+
+	Prices []PriceRule  `json:"prices,omitempty"` // kolumna PCM → pole cenowe Woo (puste = regular ← cena_detal, hurt_price ← cena_hurtowa)
+	Vat    []VatRule    `json:"vat,omitempty"`    // vat_id PCM → stawka i tax_class Woo (puste = 2300/800/500/0/-1)
+	Shops  []ShopConfig `json:"shops,omitempty"`  // reguły cen nazwanych sklepów Woo (woocommerce:<id>)
 }
 
 type Importer struct {
@@ -36,6 +54,13 @@ type Importer struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	db     *gorm.DB
+	stable *stableTracker // tracker źródła domyślnego; nil = bez sprawdzania stabilności (scanOnce w testach)
+	shop   ShopConfig     // sklep Woo, dla którego planuje kopia z forShop (zero = sklep domyślny)
+	// klasy podatkowe sklepu z kvs (loadTaxClasses); nil = nieznane, bez sprawdzania
+	taxClasses map[string]struct{}
+
+	// skany źródeł biegną w osobnych gorutynach, ale import → linkowanie → planowanie idzie pojedynczo
+	scanMu sync.Mutex
 }
 
 // minimalny model pod to, co potrzebujesz teraz
@@ -45,13 +70,6 @@ type xmlMagazyn struct {
 	Rezerwacja string `xml:"rezerwacja_ilosci"` // jw.
 }
 
-// liczniki
-var (
-	seenTowar   int
-	insProducts int
-	insStocks   int
-)
-
 type xmlTowar struct {
 	TowarID     int64  `xml:"towar_id"`
 	Kod         string `xml:"kod"`
@@ -96,6 +114,8 @@ func (i *Importer) Start(ctx context.Context) error {
 	// DEV: powtórny relink po 15 sekundach od startu, żeby Woo cache już był
 	go func() {
 		time.Sleep(15 * time.Second)
+		i.scanMu.Lock()
+		defer i.scanMu.Unlock()
 		if err := i.LinkProductsByEAN(); err != nil {
 			i.log.Error().Err(err).Msg("LinkProductsByEAN retry failed")
 			return
@@ -105,25 +125,82 @@ func (i *Importer) Start(ctx context.Context) error {
 		}
 	}()
 
-	dir := expandHome(i.cfg.WatchDir)
-	ticker := time.NewTicker(i.interval())
+	var wg sync.WaitGroup
+	for _, src := range i.watchSources() {
+		if src.id == "" {
+			i.stable = src.stable
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.watchLoop(src)
+		}()
+	}
+	wg.Wait()
+	i.log.Info().Str("integration", i.Name()).Msg("stop")
+	return nil
+}
+
+// watchLoop obserwuje katalog jednego źródła do zatrzymania importera.
+func (i *Importer) watchLoop(src *watchSource) {
+	ticker := time.NewTicker(src.poll)
 	defer ticker.Stop()
 
+	// zdarzenia katalogu (inotify) przyspieszają reakcję; ticker zostaje jako zabezpieczenie
+	events, stopWatch := i.startDirWatch(src.dir)
+	if stopWatch != nil {
+		defer stopWatch()
+	}
+
+	// ponowne sprawdzenie plików odłożonych przez test stabilności
+	recheck := time.NewTimer(time.Hour)
+	recheck.Stop()
+	defer recheck.Stop()
+
+	scan := func() {
+		i.scanSource(src)
+		if src.stable.pending() > 0 {
+			recheck.Reset(src.stable.window)
+		}
+	}
+
 	// pierwszy przebieg
-	i.scanOnce(dir)
+	scan()
 
 	for {
 		select {
 		case <-i.ctx.Done():
-			i.log.Info().Str("integration", i.Name()).Msg("stop")
-			return nil
+			return
 		case <-ticker.C:
-			i.scanOnce(dir)
-			ticker.Reset(i.interval())
+			scan()
+			ticker.Reset(src.poll)
+		case _, ok := <-events:
+			if !ok {
+				i.log.Warn().Str("dir", src.dir).Msg("obserwacja katalogu przerwana — zostaje odpytywanie co poll_sec")
+				events = nil
+				continue
+			}
+			scan()
+		case <-recheck.C:
+			scan()
 		}
 	}
 }
 
+// startDirWatch włącza obserwację zdarzeń katalogu, chyba że watch=poll albo platforma jej nie ma.
+func (i *Importer) startDirWatch(dir string) (<-chan struct{}, func()) {
+	if strings.EqualFold(strings.TrimSpace(i.cfg.Watch), "poll") {
+		return nil, nil
+	}
+	events, stop, err := watchDirEvents(dir)
+	if err != nil {
+		i.log.Info().Err(err).Str("dir", dir).Msg("importer: obserwacja zdarzeń niedostępna, odpytywanie co poll_sec")
+		return nil, nil
+	}
+	i.log.Info().Str("dir", dir).Msg("importer: obserwacja katalogu (inotify) + odpytywanie co poll_sec")
+	return events, stop
+}
+
 func (i *Importer) Stop() {
 	if i.cancel != nil {
 		i.cancel()
@@ -137,76 +214,53 @@ func (i *Importer) interval() time.Duration {
 	return time.Duration(i.cfg.PollSec) * time.Second
 }
 
+// scanOnce skanuje katalog jako źródło domyślne.
 func (i *Importer) scanOnce(dir string) {
+	i.scanSource(&watchSource{dir: dir, stable: i.stable})
+}
+
+func (i *Importer) scanSource(src *watchSource) {
+	i.scanMu.Lock()
+	defer i.scanMu.Unlock()
+
+	dir := src.dir
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		i.log.Error().Err(err).Str("dir", dir).Msg("nie mogę odczytać katalogu")
+		i.log.Error().Err(err).Str("dir", dir).Str("source", src.id).Msg("nie mogę odczytać katalogu")
 		return
 	}
 
 	processed := false
 	processedImportIDs := make([]uint, 0, 4)
+	present := make(map[string]struct{}, len(entries))
+	src.stable.beginScan()
+	defer src.stable.forget(present)
 
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		name := e.Name()
-		if !strings.HasPrefix(name, "exp_wyk_") || !(strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".zip")) {
-			continue
-		}
+		present[name] = struct{}{}
 		full := filepath.Join(dir, name)
-
-		// dedup po filename/sha/transmisja_id
-		importID, already, err := i.registerFile(full, name)
-		if err != nil {
-			i.log.Error().Err(err).Str("file", name).Msg("rejestracja pliku nieudana")
+		parser, ok := detectExportParser(full, name)
+		if !ok {
 			continue
 		}
-
-		if already {
-			// sprawdź status — jeśli != done (1), to reprocess
-			var rec db.ImportFile
-			if err := i.db.Where("import_id = ?", importID).Take(&rec).Error; err == nil {
-				if rec.Status != 1 {
-					i.log.Warn().Str("file", name).Uint("import_id", importID).
-						Int("status", rec.Status).Msg("plik istnieje, ale nie DONE — ponawiam przetwarzanie")
-					// leć dalej do processFile
-				} else {
-					//i.log.Debug().Str("file", name).Msg("plik już był i DONE — pomijam")
-					if archivedPath, err := archiveProcessedFile(dir, full, name, importID); err != nil {
-						i.log.Error().Err(err).Str("file", name).Msg("archiwizacja już przetworzonego pliku nieudana")
-					} else {
-						i.log.Info().Str("file", name).Str("archived_path", archivedPath).Msg("przeniesiono już przetworzony plik do parsed")
-					}
-					continue
-				}
-			} else {
-				// nie znalazłem? przetwarzaj ostrożnie
-				i.log.Warn().Str("file", name).Msg("brak rekordu import_files dla istniejącego pliku — przetwarzam")
-			}
-		}
-
-		// PRZETWARZANIE
-		if err := i.processFile(importID, full); err != nil {
-			i.log.Error().Err(err).Str("file", name).Uint("import_id", importID).Msg("błąd przetwarzania pliku")
-			_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
-				Updates(map[string]any{"status": 2, "last_error": err.Error()})
+		// plik jeszcze zapisywany przez PCM — wróci w kolejnym skanie
+		if !src.stable.ready(dir, name) {
+			i.log.Debug().Str("file", name).Msg("plik jeszcze niestabilny — czekam")
 			continue
 		}
-		// sukces
-		now := time.Now()
-		_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
-			Updates(map[string]any{"status": 1, "processed_at": now})
 
-		archivedPath, archiveErr := archiveProcessedFile(dir, full, name, importID)
-		if archiveErr != nil {
-			i.log.Error().Err(archiveErr).Str("file", name).Uint("import_id", importID).Msg("archiwizacja przetworzonego pliku nieudana")
+		ids, err := i.importEntry(src.id, dir, name, parser)
+		if err != nil {
+			i.log.Error().Err(err).Str("file", name).Str("source", src.id).Msg("błąd importu pliku")
+		}
+		if len(ids) > 0 {
+			processed = true
+			processedImportIDs = append(processedImportIDs, ids...)
 		}
-
-		i.log.Info().Str("file", name).Str("archived_path", archivedPath).Uint("import_id", importID).Msg("przetworzono OK")
-		processed = true
-		processedImportIDs = append(processedImportIDs, importID)
 	}
 
 	if processed {
@@ -221,6 +275,108 @@ func (i *Importer) scanOnce(dir string) {
 
 }
 
+// ImportFile importuje wskazany plik (XML, ZIP albo XLSX) jak skan katalogu źródła sourceID:
+// deduplikacja, staging, przeniesienie do parsed/ obok pliku, relink i planowanie tasków.
+// Zwraca import_id przetworzonych importów (pusty = plik był już zaimportowany).
+func (i *Importer) ImportFile(sourceID, path string) ([]uint, error) {
+	if _, ok := i.cfg.source(sourceID); !ok {
+		return nil, fmt.Errorf("importer: nieznane lub nieaktywne źródło %q", sourceID)
+	}
+	full, err := filepath.Abs(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	dir, name := filepath.Split(full)
+	parser, ok := detectExportParser(full, name)
+	if !ok {
+		return nil, fmt.Errorf("importer: %s nie jest rozpoznanym eksportem PC-Market", name)
+	}
+
+	i.scanMu.Lock()
+	defer i.scanMu.Unlock()
+	ids, err := i.importEntry(sourceID, filepath.Clean(dir), name, parser)
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	if err := i.LinkProductsByEAN(); err != nil {
+		return ids, fmt.Errorf("relink po imporcie: %w", err)
+	}
+	if err := i.PlanWooTasksForImports(ids); err != nil {
+		return ids, fmt.Errorf("planowanie po imporcie: %w", err)
+	}
+	return ids, nil
+}
+
+// importEntry importuje jeden plik z katalogu źródła (XML, archiwum ZIP albo arkusz XLSX): deduplikacja,
+// staging i przeniesienie do parsed/. Zwraca import_id przetworzonych teraz importów —
+// pusty wynik oznacza plik zaimportowany wcześniej.
+func (i *Importer) importEntry(sourceID, dir, name string, parser exportParser) ([]uint, error) {
+	full := filepath.Join(dir, name)
+
+	// archiwum ZIP — każdy plik XML w środku to osobny import
+	if isZipName(name) {
+		ids, err := i.processZip(sourceID, dir, full, name, parser)
+		if _, statErr := os.Stat(full); os.IsNotExist(statErr) {
+			removeDoneMarker(dir, name)
+		}
+		if err != nil {
+			return ids, fmt.Errorf("błąd przetwarzania archiwum ZIP: %w", err)
+		}
+		return ids, nil
+	}
+
+	// dedup po filename/sha/transmisja_id
+	importID, already, err := i.registerFile(sourceID, full, name, parser.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("rejestracja pliku nieudana: %w", err)
+	}
+
+	if already {
+		// sprawdź status — jeśli != done (1), to reprocess
+		var rec db.ImportFile
+		if err := i.db.Where("import_id = ?", importID).Take(&rec).Error; err == nil {
+			if rec.Status != 1 {
+				i.log.Warn().Str("file", name).Uint("import_id", importID).
+					Int("status", rec.Status).Msg("plik istnieje, ale nie DONE — ponawiam przetwarzanie")
+				// leć dalej do processFile
+			} else {
+				//i.log.Debug().Str("file", name).Msg("plik już był i DONE — pomijam")
+				archivedPath, err := archiveProcessedFile(dir, full, name, importID)
+				if err != nil {
+					return nil, fmt.Errorf("archiwizacja już przetworzonego pliku nieudana: %w", err)
+				}
+				removeDoneMarker(dir, name)
+				i.log.Info().Str("file", name).Str("archived_path", archivedPath).Msg("przeniesiono już przetworzony plik do parsed")
+				return nil, nil
+			}
+		} else {
+			// nie znalazłem? przetwarzaj ostrożnie
+			i.log.Warn().Str("file", name).Msg("brak rekordu import_files dla istniejącego pliku — przetwarzam")
+		}
+	}
+
+	// PRZETWARZANIE
+	if err := i.processFile(importID, full, parser); err != nil {
+		_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
+			Updates(map[string]any{"status": 2, "last_error": err.Error()})
+		return nil, fmt.Errorf("błąd przetwarzania pliku (import_id=%d): %w", importID, err)
+	}
+	// sukces
+	now := time.Now()
+	_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
+		Updates(map[string]any{"status": 1, "processed_at": now})
+
+	archivedPath, archiveErr := archiveProcessedFile(dir, full, name, importID)
+	if archiveErr != nil {
+		i.log.Error().Err(archiveErr).Str("file", name).Uint("import_id", importID).Msg("archiwizacja przetworzonego pliku nieudana")
+	} else {
+		removeDoneMarker(dir, name)
+	}
+
+	i.log.Info().Str("file", name).Str("source", sourceID).Str("archived_path", archivedPath).Uint("import_id", importID).Msg("przetworzono OK")
+	return []uint{importID}, nil
+}
+
 func archiveProcessedFile(dir, fullPath, name string, importID uint) (string, error) {
 	parsedDir := filepath.Join(dir, "parsed")
 	if err := os.MkdirAll(parsedDir, 0o755); err != nil {
@@ -250,7 +406,7 @@ func archiveProcessedFile(dir, fullPath, name string, importID uint) (string, er
 	return dest, nil
 }
 
-func (i *Importer) registerFile(fullPath, name string) (uint, bool, error) {
+func (i *Importer) registerFile(sourceID, fullPath, name, exportKind string) (uint, bool, error) {
 	fi, err := os.Stat(fullPath)
 	if err != nil {
 		return 0, false, err
@@ -268,20 +424,35 @@ func (i *Importer) registerFile(fullPath, name string) (uint, bool, error) {
 			transID = tid
 		}
 	}
+	// arkusz XLS nie ma transmisja_id, a kolumna jest unikalna — drugi arkusz z pustym
+	// identyfikatorem nie dałby się zarejestrować; identyfikatorem jest hash pliku
+	if exportKind == exportKindXLS {
+		transID = exportKindXLS + ":" + h
+	}
 
-	rec := db.ImportFile{
+	return i.registerImport(db.ImportFile{
 		Filename:     name,
 		FileTimeUTC:  inferTimeFromName(name),
 		TransmisjaID: transID,
 		SHA256:       h,
 		SizeBytes:    fi.Size(),
+		ExportKind:   exportKind,
+		SourceID:     sourceID,
 		Status:       0,
-	}
+	})
+}
+
+// registerImport zakłada rekord import_files albo zwraca istniejący (dedup po SHA w obrębie źródła, nazwie, transmisja_id).
+// Dla źródeł innych niż domyślne nazwa i transmisja_id dostają prefiks źródła.
+func (i *Importer) registerImport(rec db.ImportFile) (uint, bool, error) {
+	rec.Filename = scopeToSource(rec.SourceID, "/", rec.Filename)
+	rec.TransmisjaID = scopeToSource(rec.SourceID, ":", rec.TransmisjaID)
 
 	// idempotencja: po SHA lub nazwie/transmisja_id
 	var existing db.ImportFile
 	if err := i.db.
-		Where("sha256 = ? OR filename = ? OR (transmisja_id <> '' AND transmisja_id = ?)", h, name, transID).
+		Where("(sha256 = ? AND source_id = ?) OR filename = ? OR (transmisja_id <> '' AND transmisja_id = ?)",
+			rec.SHA256, rec.SourceID, rec.Filename, rec.TransmisjaID).
 		Take(&existing).Error; err == nil {
 		return existing.ImportID, true, nil
 	}
@@ -292,23 +463,32 @@ func (i *Importer) registerFile(fullPath, name string) (uint, bool, error) {
 	return rec.ImportID, false, nil
 }
 
-func (i *Importer) processFile(importID uint, fullPath string) error {
+func (i *Importer) processFile(importID uint, fullPath string, parser exportParser) error {
+	if parser.File != nil {
+		return i.withStaging(importID, parser, func(_ *gorm.DB, w *stagingWriter) error {
+			return parser.File(i, w, fullPath)
+		})
+	}
 	f, err := os.Open(fullPath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	br := bufio.NewReader(f)
-	dec := xml.NewDecoder(br)
-	dec.CharsetReader = func(cs string, in io.Reader) (io.Reader, error) {
-		return charset.NewReaderLabel(normalizeCharset(cs), in)
-	}
+	return i.processReader(importID, f, parser)
+}
 
-	const batchSize = 500
-	prodBatch := make([]db.StProduct, 0, batchSize)
-	stockBatch := make([]db.StStock, 0, batchSize)
+// processReader parsuje strumień XML eksportu i zapisuje go do staging w jednej transakcji.
+func (i *Importer) processReader(importID uint, r io.Reader, parser exportParser) error {
+	dec := newExportDecoder(bufio.NewReader(r))
+	return i.withStaging(importID, parser, func(tx *gorm.DB, w *stagingWriter) error {
+		return i.decodeExport(tx, w, dec, parser)
+	})
+}
 
+// withStaging otwiera transakcję i stagingWriter dla importu, woła parse, zapisuje resztę
+// batchy i zatwierdza całość. Błąd parse wycofuje wszystkie wiersze importu.
+func (i *Importer) withStaging(importID uint, parser exportParser, parse func(tx *gorm.DB, w *stagingWriter) error) error {
 	tx := i.db.Begin()
 	committed := false
 	defer func() {
@@ -317,70 +497,47 @@ func (i *Importer) processFile(importID uint, fullPath string) error {
 		}
 	}()
 
-	insProducts, insStocks := 0, 0
-
-	// ✅ Upsert w batchach
-	flushBatches := func(tx *gorm.DB) error {
-		// ---- produkty ----
-		if len(prodBatch) > 0 {
-			err := tx.Clauses(clause.OnConflict{
-				Columns: []clause.Column{{Name: "towar_id"}, {Name: "kod"}},
-				DoUpdates: clause.Assignments(map[string]interface{}{
-					"nazwa":               gorm.Expr("excluded.nazwa"),
-					"opis1":               gorm.Expr("excluded.opis1"),
-					"vat_id":              gorm.Expr("excluded.vat_id"),
-					"kategoria_id":        gorm.Expr("excluded.kategoria_id"),
-					"grupa_id":            gorm.Expr("excluded.grupa_id"),
-					"jm_id":               gorm.Expr("excluded.jm_id"),
-					"cena_detal":          gorm.Expr("excluded.cena_detal"),
-					"cena_hurtowa":        gorm.Expr("excluded.cena_hurtowa"),
-					"cena_nocna":          gorm.Expr("excluded.cena_nocna"),
-					"cena_dodatkowa":      gorm.Expr("excluded.cena_dodatkowa"),
-					"cena_det_przed_prom": gorm.Expr("excluded.cena_det_przed_prom"),
-					"naj_cena30_det":      gorm.Expr("excluded.naj_cena30_det"),
-					"aktywny_wsi":         gorm.Expr("excluded.aktywny_wsi"),
-					"do_usuniecia":        gorm.Expr("excluded.do_usuniecia"),
-					"data_aktualizacji":   gorm.Expr("excluded.data_aktualizacji"),
-					"folder_zdjec":        gorm.Expr("excluded.folder_zdjec"),
-					"plik_zdjecia":        gorm.Expr("excluded.plik_zdjecia"),
-					"import_id":           gorm.Expr("excluded.import_id"),
-					"updated_at":          gorm.Expr("CURRENT_TIMESTAMP"),
-				}),
-			}).Create(&prodBatch).Error
-			if err != nil {
-				i.log.Error().Err(err).Int("n", len(prodBatch)).Msg("upsert st_products batch failed")
-				return err
-			}
-			insProducts += len(prodBatch)
-			prodBatch = prodBatch[:0]
-		}
+	var sourceID string
+	if err := tx.Model(&db.ImportFile{}).Where("import_id = ?", importID).
+		Select("source_id").Scan(&sourceID).Error; err != nil {
+		return err
+	}
+	w := newStagingWriter(tx, importID, sourceID)
 
-		// ---- stany ----
-		if len(stockBatch) > 0 {
-			err := tx.Clauses(clause.OnConflict{
-				Columns: []clause.Column{{Name: "towar_id"}, {Name: "magazyn_id"}},
-				DoUpdates: clause.Assignments(map[string]interface{}{
-					"stan_prev":  gorm.Expr("stan"),
-					"stan":       gorm.Expr("excluded.stan"),
-					"rezerwacja": gorm.Expr("excluded.rezerwacja"),
-					"import_id":  gorm.Expr("excluded.import_id"),
-					"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
-				}),
-			}).Create(&stockBatch).Error
-			if err != nil {
-				i.log.Error().Err(err).Int("n", len(stockBatch)).Msg("upsert st_stock batch failed")
-				return err
-			}
-			insStocks += len(stockBatch)
-			stockBatch = stockBatch[:0]
-		}
-		return nil
+	if err := parse(tx, w); err != nil {
+		return err
 	}
 
+	if err := w.flush(); err != nil {
+		i.log.Error().Err(err).Msg("zapis staging nieudany")
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		i.log.Error().Err(err).Msg("tx commit failed")
+		return err
+	}
+	committed = true
+
+	i.log.Info().
+		Uint("import_id", importID).
+		Str("export_kind", parser.Kind).
+		Int("products_upserted", w.ProductsUpserted).
+		Int("stocks_upserted", w.StocksUpserted).
+		Int("prices_updated", w.PricesUpdated).
+		Int("unknown_towary", w.UnknownTowary).
+		Msg("eksport sparsowany → staging upsert OK")
+
+	return nil
+}
+
+// decodeExport czyta tokeny XML eksportu: transmisja_id do import_files, <towary> do parsera.
+func (i *Importer) decodeExport(tx *gorm.DB, w *stagingWriter, dec *xml.Decoder, parser exportParser) error {
+	sourceID, importID := w.sourceID, w.importID
 	for {
 		tok, err := dec.Token()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
 			return err
@@ -394,7 +551,7 @@ func (i *Importer) processFile(importID uint, fullPath string) error {
 				if err := dec.DecodeElement(&tid, &se); err != nil {
 					return err
 				}
-				tid = strings.TrimSpace(tid)
+				tid = scopeToSource(sourceID, ":", strings.TrimSpace(tid))
 				if tid != "" {
 					_ = tx.Model(&db.ImportFile{}).
 						Where("import_id = ?", importID).
@@ -403,76 +560,23 @@ func (i *Importer) processFile(importID uint, fullPath string) error {
 				continue
 			}
 
-			// towary
+			// towary — format zależy od typu eksportu
 			if strings.EqualFold(se.Name.Local, "towary") {
-				var tw struct {
-					Items []xmlTowar `xml:"towar"`
-				}
-				if err := dec.DecodeElement(&tw, &se); err != nil {
+				if err := parser.Towary(w, dec, &se); err != nil {
+					i.log.Error().Err(err).Str("export_kind", parser.Kind).Msg("parsowanie towarów nieudane")
 					return err
 				}
-
-				for _, t := range tw.Items {
-					prodBatch = append(prodBatch, db.StProduct{
-						ImportID:         importID,
-						TowarID:          t.TowarID,
-						Kod:              strings.TrimSpace(t.Kod),
-						Nazwa:            strings.TrimSpace(t.Nazwa),
-						Opis1:            t.Opis1,
-						VatID:            t.VatID,
-						KategoriaID:      i64(t.KategoriaID),
-						GrupaID:          i64(t.GrupaID),
-						JmID:             t.JmID,
-						CenaDetal:        f64(t.CenaDetal),
-						CenaHurtowa:      f64(t.CenaHurtowa),
-						CenaNocna:        f64(t.CenaNocna),
-						CenaDodatkowa:    f64(t.CenaDodatkowa),
-						CenaDetPrzedProm: f64(t.CenaDetPrzed),
-						NajCena30Det:     f64(t.NajCena30Det),
-						AktywnyWSI:       yn(t.AktywnyWSI),
-						DoUsuniecia:      yn(t.DoUsuniecia),
-						DataAktualizacji: t.DataAktualizacji,
-						FolderZdjec:      t.FolderZdjec,
-						PlikZdjecia:      t.PlikZdjecia,
-					})
-
-					for _, m := range t.Magazyny {
-						stockBatch = append(stockBatch, db.StStock{
-							ImportID:   importID,
-							TowarID:    t.TowarID,
-							MagazynID:  m.MagazynID,
-							Stan:       f64(m.Stan),
-							Rezerwacja: f64(m.Rezerwacja),
-						})
-					}
-
-					if len(prodBatch) >= batchSize || len(stockBatch) >= batchSize {
-						if err := flushBatches(tx); err != nil {
-							return err
-						}
-					}
-				}
 			}
 		}
 	}
+}
 
-	if err := flushBatches(tx); err != nil {
-		return err
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		i.log.Error().Err(err).Msg("tx commit failed")
-		return err
+func newExportDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(cs string, in io.Reader) (io.Reader, error) {
+		return charset.NewReaderLabel(normalizeCharset(cs), in)
 	}
-	committed = true
-
-	i.log.Info().
-		Uint("import_id", importID).
-		Int("products_upserted", insProducts).
-		Int("stocks_upserted", insStocks).
-		Msg("XML parsed → staging upsert OK")
-
-	return nil
+	return dec
 }
 
 func fileSHA256(path string) (string, error) {
@@ -508,7 +612,11 @@ func readTransmisjaID(path string) (string, error) {
 		return "", err
 	}
 	defer f.Close()
-	dec := xml.NewDecoder(f)
+	return readTransmisjaIDFrom(f)
+}
+
+func readTransmisjaIDFrom(r io.Reader) (string, error) {
+	dec := xml.NewDecoder(r)
 	for {
 		tok, err := dec.Token()
 		if err == io.EOF {
@@ -537,7 +645,17 @@ func expandHome(p string) string {
 	return p
 }
 
-func factory(log zerolog.Logger, raw json.RawMessage) (integrations.Integration, error) {
+func factory(log zerolog.Logger, instance string, raw json.RawMessage) (integrations.Integration, error) {
+	if instance != "" {
+		// importer jest jeden — kilka instalacji PCM obsługuje sekcja sources
+		return nil, fmt.Errorf("importer: nazwane instancje (importer:%s) nie są obsługiwane, użyj sources", instance)
+	}
+	return New(log, raw, nil)
+}
+
+// New tworzy importer z sekcji configu. gdb może być nil — Start bierze bazę z kontekstu;
+// CLI podaje ją od razu i woła ImportFile / LinkProductsByEAN / PlanWooTasks bez Start.
+func New(log zerolog.Logger, raw json.RawMessage, gdb *gorm.DB) (*Importer, error) {
 	var cfg Config
 	if err := json.Unmarshal(raw, &cfg); err != nil {
 		return nil, err
@@ -547,7 +665,30 @@ func factory(log zerolog.Logger, raw json.RawMessage) (integrations.Integration,
 		return nil, err
 	}
 	cfg.PriceMode = mode
-	return &Importer{log: log, cfg: cfg}, nil
+	retireMode, err := normalizeRetireMode(cfg.RetireMode)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RetireMode = retireMode
+	if err := cfg.Warehouses.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Linking.normalize(); err != nil {
+		return nil, err
+	}
+	if err := cfg.normalizeSources(); err != nil {
+		return nil, err
+	}
+	if err := normalizePriceRules(cfg.Prices); err != nil {
+		return nil, fmt.Errorf("importer %w", err)
+	}
+	if err := normalizeVatRules(cfg.Vat); err != nil {
+		return nil, fmt.Errorf("importer %w", err)
+	}
+	if err := cfg.normalizeShops(); err != nil {
+		return nil, err
+	}
+	return &Importer{log: log, cfg: cfg, db: gdb}, nil
 }
 
 func init() {
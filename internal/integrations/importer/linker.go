@@ -24,6 +24,8 @@ func cleanEAN(s string) string {
 
 // LinkProductsByEAN — pełny relink Woo ↔ Magazyn po EAN
 // Skasuje i przebuduje całą tabelę link_issues od zera.
+// Ręczne powiązania z manual_links mają pierwszeństwo przed EAN i nie trafiają do link_issues.
+// Towary niepowiązane po EAN przechodzą przez strategie zapasowe z configu (SKU, nazwa).
 func (i *Importer) LinkProductsByEAN() error {
 	tx := i.db.Begin()
 	committed := false
@@ -71,23 +73,46 @@ func (i *Importer) LinkProductsByEAN() error {
 	}
 
 	// 4️⃣ Wczytaj staging (produkty z magazynu)
-	var st []struct {
-		TowarID int64
-		Kod     string
-	}
+	var st []linkStagingRow
 	if err := tx.Model(&db.StProduct{}).
-		Select("towar_id", "kod").
+		Select("towar_id", "kod", "nazwa").
 		Find(&st).Error; err != nil {
 		return fmt.Errorf("błąd odczytu st_products: %w", err)
 	}
 
-	// 5️⃣ Wczytaj Woo cache (produkty z Woo)
-	var wc []struct {
-		WooID uint
-		Ean   string
+	// każda instancja woocommerce[:<shop>] ma własny cache — linkujemy sklepy niezależnie
+	var shops []string
+	if err := tx.Model(&db.WooProductCache{}).Distinct("shop").Order("shop").Pluck("shop", &shops).Error; err != nil {
+		return fmt.Errorf("błąd odczytu sklepów z woo_product_caches: %w", err)
+	}
+	for _, shop := range shops {
+		if err := i.linkShopByEAN(tx, shop, st); err != nil {
+			return err
+		}
 	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// linkShopByEAN linkuje cache jednego sklepu. Ręczne powiązania, strategie zapasowe (SKU, nazwa)
+// i propozycje są osobne dla każdego sklepu (manual_links.shop, link_proposals.shop).
+func (i *Importer) linkShopByEAN(tx *gorm.DB, shop string, st []linkStagingRow) error {
+	// 3a Ręczne powiązania (manual_links) — przed dopasowaniem po EAN
+	pinnedTowar, pinnedWoo, err := i.applyManualLinks(tx, shop)
+	if err != nil {
+		return err
+	}
+
+	// 5️⃣ Wczytaj Woo cache (produkty z Woo)
+	// produkt variable nie ma własnego stanu ani ceny — linkujemy jego warianty (osobne wiersze z parent_id)
+	var wc []linkCacheRow
 	if err := tx.Model(&db.WooProductCache{}).
-		Select("woo_id", "ean").
+		Select("woo_id", "ean", "kod", "name").
+		Where("shop = ? AND (type IS NULL OR type <> ?)", shop, "variable").
 		Find(&wc).Error; err != nil {
 		return fmt.Errorf("błąd odczytu woo_product_caches: %w", err)
 	}
@@ -98,6 +123,9 @@ func (i *Importer) LinkProductsByEAN() error {
 	emptyEanWc := 0
 	byEAN := make(map[string][]uint, len(wc))
 	for _, c := range wc {
+		if _, pinned := pinnedWoo[c.WooID]; pinned {
+			continue // produkt Woo przypięty ręcznie nie bierze udziału w dopasowaniu po EAN
+		}
 		e := cleanEAN(c.Ean)
 		if e == "" {
 			emptyEanWc++
@@ -125,7 +153,16 @@ func (i *Importer) LinkProductsByEAN() error {
 	)
 
 	// 8️⃣ Pętla po produktach magazynowych
+	// problemy zapisujemy dopiero po strategiach zapasowych — towar powiązany po SKU/nazwie nie jest problemem
+	takenWoo := make(map[uint]struct{}, len(pinnedWoo))
+	for id := range pinnedWoo {
+		takenWoo[id] = struct{}{}
+	}
+	var unresolved []linkStagingRow
 	for _, p := range st {
+		if _, pinned := pinnedTowar[p.TowarID]; pinned {
+			continue
+		}
 		rawKod := strings.TrimSpace(p.Kod)
 		ean := cleanEAN(p.Kod)
 
@@ -137,9 +174,9 @@ func (i *Importer) LinkProductsByEAN() error {
 					Msg("linker: EMPTY EAN in staging (kod after clean is empty)")
 				dbgNoMatchCount++
 			}
-			saveLinkIssue(tx, p.TowarID, p.Kod, "",
-				"missing_ean_src",
-				"Brak EAN w eksporcie (pole 'kod' puste/niecyfrowe)")
+			p.issue = db.LinkIssue{TowarID: p.TowarID, Kod: p.Kod, Reason: "missing_ean_src",
+				Details: "Brak EAN w eksporcie (pole 'kod' puste/niecyfrowe)"}
+			unresolved = append(unresolved, p)
 			continue
 		}
 
@@ -154,16 +191,17 @@ func (i *Importer) LinkProductsByEAN() error {
 					Msg("linker: NO MATCH in cache by EAN")
 				dbgNoMatchCount++
 			}
-			saveLinkIssue(tx, p.TowarID, p.Kod, "",
-				"missing_in_shop_by_ean",
-				fmt.Sprintf("Brak produktu o EAN=%s w Woo", ean))
+			p.issue = db.LinkIssue{TowarID: p.TowarID, Kod: p.Kod, Reason: "missing_in_shop_by_ean",
+				Details: fmt.Sprintf("Brak produktu o EAN=%s w Woo", ean)}
+			unresolved = append(unresolved, p)
 
 		case 1:
 			if err := tx.Model(&db.WooProductCache{}).
-				Where("woo_id = ?", cands[0]).
+				Where("shop = ? AND woo_id = ?", shop, cands[0]).
 				Update("towar_id", p.TowarID).Error; err != nil {
 				return fmt.Errorf("update Woo towar_id=%d error: %w", p.TowarID, err)
 			}
+			takenWoo[cands[0]] = struct{}{}
 			matchedByEAN++
 			if dbgMatchedCount < maxDbgMatched {
 				i.log.Debug().
@@ -185,12 +223,21 @@ func (i *Importer) LinkProductsByEAN() error {
 					Msg("linker: MULTI-MATCH by EAN (duplicate EAN in Woo)")
 				dbgMultiMatchCount++
 			}
-			saveLinkIssue(tx, p.TowarID, p.Kod, string(idsJSON),
-				"duplicate_ean_shop",
-				fmt.Sprintf("EAN=%s występuje %d× w Woo (woo_id: %v)", ean, len(cands), cands))
+			p.issue = db.LinkIssue{TowarID: p.TowarID, Kod: p.Kod, WooIDs: string(idsJSON), Reason: "duplicate_ean_shop",
+				Details: fmt.Sprintf("EAN=%s występuje %d× w Woo (woo_id: %v)", ean, len(cands), cands)}
+			unresolved = append(unresolved, p)
 		}
 	}
 
+	// 8️⃣b Strategie zapasowe (SKU, nazwa) dla towarów bez linku po EAN
+	unresolved, fallback, err := i.linkByFallbackStrategies(tx, shop, unresolved, wc, takenWoo)
+	if err != nil {
+		return err
+	}
+	for _, p := range unresolved {
+		saveLinkIssue(tx, shop, p.issue.TowarID, p.issue.Kod, p.issue.WooIDs, p.issue.Reason, p.issue.Details)
+	}
+
 	// 9️⃣ Zbuduj zestaw EANów z magazynu (dla odwrotnego porównania)
 	magEans := make(map[string]struct{}, len(st))
 	for _, p := range st {
@@ -206,6 +253,9 @@ func (i *Importer) LinkProductsByEAN() error {
 	const maxDbgMissing = 10
 
 	for _, w := range wc {
+		if _, taken := takenWoo[w.WooID]; taken {
+			continue // powiązany (EAN, manual_links, SKU, nazwa) — ma odpowiednik w magazynie
+		}
 		ean := cleanEAN(w.Ean)
 		if ean == "" {
 			continue // pomiń produkty Woo bez EAN
@@ -220,7 +270,7 @@ func (i *Importer) LinkProductsByEAN() error {
 				dbgPrinted++
 			}
 			idsJSON := fmt.Sprintf("[%d]", w.WooID)
-			saveLinkIssue(tx, 0, ean, idsJSON,
+			saveLinkIssue(tx, shop, 0, ean, idsJSON,
 				"missing_in_magazine_by_ean",
 				fmt.Sprintf("Produkt o EAN=%s jest w Woo (woo_id=%d), ale nie ma go w magazynie", ean, w.WooID))
 		}
@@ -228,23 +278,53 @@ func (i *Importer) LinkProductsByEAN() error {
 
 	// 1️⃣1️⃣ Podsumowanie
 	i.log.Info().
+		Str("shop", shop).
+		Int("manual_links", len(pinnedTowar)).
 		Int("matched_by_ean", matchedByEAN).
+		Int("matched_by_sku", fallback.BySKU).
+		Int("matched_by_name", fallback.ByName).
+		Int("name_proposals", fallback.Proposals).
 		Int("missing_in_magazine_by_ean", missingInMag).
 		Int("dbg_no_match_printed", dbgNoMatchCount).
 		Int("dbg_multi_match_printed", dbgMultiMatchCount).
 		Int("dbg_matched_printed", dbgMatchedCount).
 		Msg("EAN linking finished")
+	return nil
+}
 
-	if err := tx.Commit().Error; err != nil {
-		return err
+// applyManualLinks ustawia towar_id w cache sklepu wg jego manual_links i zwraca przypięte towary / produkty Woo.
+// Przypięcie do woo_id, którego nie ma w cache, jest tylko logowane (produkt mógł zniknąć ze sklepu).
+func (i *Importer) applyManualLinks(tx *gorm.DB, shop string) (map[int64]struct{}, map[uint]struct{}, error) {
+	var links []db.ManualLink
+	if err := tx.Where("shop = ?", shop).Find(&links).Error; err != nil {
+		return nil, nil, fmt.Errorf("błąd odczytu manual_links: %w", err)
 	}
-	committed = true
-	return nil
+	pinnedTowar := make(map[int64]struct{}, len(links))
+	pinnedWoo := make(map[uint]struct{}, len(links))
+	for _, l := range links {
+		pinnedTowar[l.TowarID] = struct{}{}
+		pinnedWoo[l.WooID] = struct{}{}
+		res := tx.Model(&db.WooProductCache{}).
+			Where("shop = ? AND woo_id = ?", shop, l.WooID).
+			Update("towar_id", l.TowarID)
+		if res.Error != nil {
+			return nil, nil, fmt.Errorf("manual link towar_id=%d woo_id=%d: %w", l.TowarID, l.WooID, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			i.log.Warn().
+				Str("shop", shop).
+				Int64("towar_id", l.TowarID).
+				Uint("woo_id", l.WooID).
+				Msg("linker: manual link wskazuje woo_id, którego nie ma w cache")
+		}
+	}
+	return pinnedTowar, pinnedWoo, nil
 }
 
 // saveLinkIssue – zapisuje pojedynczy problem w linkowaniu
-func saveLinkIssue(tx *gorm.DB, towarID int64, kod, wooIDs, reason, details string) {
+func saveLinkIssue(tx *gorm.DB, shop string, towarID int64, kod, wooIDs, reason, details string) {
 	issue := db.LinkIssue{
+		Shop:    shop,
 		TowarID: towarID,
 		Kod:     kod,
 		WooIDs:  wooIDs,
@@ -254,6 +334,7 @@ func saveLinkIssue(tx *gorm.DB, towarID int64, kod, wooIDs, reason, details stri
 
 	err := tx.Clauses(clause.OnConflict{
 		Columns: []clause.Column{
+			{Name: "shop"},
 			{Name: "towar_id"},
 			{Name: "reason"},
 			{Name: "kod"},
@@ -0,0 +1,338 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/namematch"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	linkStrategySKU  = "sku"
+	linkStrategyName = "name"
+
+	nameMatchPropose = "propose"
+	nameMatchAuto    = "auto"
+
+	defaultNameMinScore      = 0.70
+	defaultNameAutoThreshold = 0.90
+	nameProposalsPerTowar    = 3
+)
+
+// LinkingConfig włącza strategie linkowania uruchamiane po EAN (EAN jest zawsze pierwszy).
+// Pusta konfiguracja = tylko EAN (zachowanie domyślne).
+type LinkingConfig struct {
+	Strategies []string        `json:"strategies,omitempty"` // kolejność strategii zapasowych: "sku", "name"
+	Name       NameMatchConfig `json:"name,omitempty"`
+}
+
+// NameMatchConfig steruje dopasowaniem po nazwie (namematch.Score).
+type NameMatchConfig struct {
+	Mode          string  `json:"mode,omitempty"`           // propose (domyślnie) — tylko propozycje; auto — linkuj powyżej auto_threshold
+	MinScore      float64 `json:"min_score,omitempty"`      // minimalny wynik propozycji (domyślnie 0.70)
+	AutoThreshold float64 `json:"auto_threshold,omitempty"` // próg automatycznego linku w trybie auto (domyślnie 0.90)
+}
+
+func (c *LinkingConfig) normalize() error {
+	seen := make(map[string]struct{}, len(c.Strategies))
+	out := make([]string, 0, len(c.Strategies))
+	for _, s := range c.Strategies {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "ean":
+			continue // EAN i tak jest zawsze pierwszy
+		case linkStrategySKU, linkStrategyName:
+		default:
+			return fmt.Errorf("importer linking: nieznana strategia %q (dozwolone: ean, sku, name)", s)
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	c.Strategies = out
+
+	switch mode := strings.ToLower(strings.TrimSpace(c.Name.Mode)); mode {
+	case "", nameMatchPropose:
+		c.Name.Mode = nameMatchPropose
+	case nameMatchAuto:
+		c.Name.Mode = nameMatchAuto
+	default:
+		return fmt.Errorf("importer linking: nieznany name.mode %q (dozwolone: propose, auto)", c.Name.Mode)
+	}
+	if c.Name.MinScore <= 0 {
+		c.Name.MinScore = defaultNameMinScore
+	}
+	if c.Name.AutoThreshold <= 0 {
+		c.Name.AutoThreshold = defaultNameAutoThreshold
+	}
+	if c.Name.MinScore > 1 || c.Name.AutoThreshold > 1 {
+		return fmt.Errorf("importer linking: min_score i auto_threshold muszą być w zakresie 0..1")
+	}
+	return nil
+}
+
+// linkStagingRow to towar ze staging, którego nie powiązał EAN; issue zostanie zapisany,
+// jeśli żadna strategia zapasowa go nie powiąże.
+type linkStagingRow struct {
+	TowarID int64
+	Kod     string
+	Nazwa   string
+	issue   db.LinkIssue
+}
+
+type linkCacheRow struct {
+	WooID uint
+	Ean   string
+	Kod   string
+	Name  string
+}
+
+type fallbackLinkStats struct {
+	BySKU     int
+	ByName    int
+	Proposals int
+}
+
+// linkByFallbackStrategies próbuje powiązać towary nieobsłużone przez EAN w cache sklepu shop
+// kolejnymi strategiami z configu. takenWoo to produkty Woo już powiązane w tym przebiegu
+// (EAN / manual_links) — nie są brane pod uwagę i po udanym linku są uzupełniane.
+// Zwraca towary, które nadal są niepowiązane.
+func (i *Importer) linkByFallbackStrategies(tx *gorm.DB, shop string, unresolved []linkStagingRow, wc []linkCacheRow, takenWoo map[uint]struct{}) ([]linkStagingRow, fallbackLinkStats, error) {
+	var stats fallbackLinkStats
+	cfg := i.cfg.Linking
+	proposalsRebuilt := false
+
+	for _, strategy := range cfg.Strategies {
+		if len(unresolved) == 0 {
+			break
+		}
+		var err error
+		switch strategy {
+		case linkStrategySKU:
+			var n int
+			unresolved, n, err = linkBySKU(tx, shop, unresolved, wc, takenWoo)
+			stats.BySKU += n
+		case linkStrategyName:
+			var linked, proposed int
+			unresolved, linked, proposed, err = i.linkByName(tx, shop, unresolved, wc, takenWoo)
+			stats.ByName += linked
+			stats.Proposals += proposed
+			proposalsRebuilt = true
+		}
+		if err != nil {
+			return nil, stats, err
+		}
+	}
+
+	if !proposalsRebuilt {
+		// strategia name wyłączona — stare propozycje oczekujące nie mają już sensu
+		if err := tx.Where("shop = ? AND status = ?", shop, "pending").Delete(&db.LinkProposal{}).Error; err != nil {
+			return nil, stats, fmt.Errorf("błąd czyszczenia link_proposals: %w", err)
+		}
+	}
+	return unresolved, stats, nil
+}
+
+// linkBySKU wiąże towar z produktem Woo, którego SKU jest równe kodowi PCM albo towar_id.
+// Link powstaje tylko przy dokładnie jednym wolnym kandydacie.
+func linkBySKU(tx *gorm.DB, shop string, unresolved []linkStagingRow, wc []linkCacheRow, takenWoo map[uint]struct{}) ([]linkStagingRow, int, error) {
+	bySKU := make(map[string][]uint, len(wc))
+	for _, c := range wc {
+		if _, taken := takenWoo[c.WooID]; taken {
+			continue
+		}
+		sku := strings.ToLower(strings.TrimSpace(c.Kod))
+		if sku == "" {
+			continue
+		}
+		bySKU[sku] = append(bySKU[sku], c.WooID)
+	}
+
+	remaining := unresolved[:0]
+	linked := 0
+	for _, p := range unresolved {
+		cands := skuCandidates(bySKU, p, takenWoo)
+		if len(cands) != 1 {
+			remaining = append(remaining, p)
+			continue
+		}
+		if err := tx.Model(&db.WooProductCache{}).
+			Where("shop = ? AND woo_id = ?", shop, cands[0]).
+			Update("towar_id", p.TowarID).Error; err != nil {
+			return nil, 0, fmt.Errorf("update Woo towar_id=%d (sku) error: %w", p.TowarID, err)
+		}
+		takenWoo[cands[0]] = struct{}{}
+		linked++
+	}
+	return remaining, linked, nil
+}
+
+func skuCandidates(bySKU map[string][]uint, p linkStagingRow, takenWoo map[uint]struct{}) []uint {
+	keys := []string{strconv.FormatInt(p.TowarID, 10)}
+	if kod := strings.ToLower(strings.TrimSpace(p.Kod)); kod != "" && kod != keys[0] {
+		keys = append(keys, kod)
+	}
+	var out []uint
+	seen := make(map[uint]struct{})
+	for _, k := range keys {
+		for _, id := range bySKU[k] {
+			if _, taken := takenWoo[id]; taken {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+type nameMatchPair struct {
+	row     linkStagingRow
+	wooID   uint
+	wooName string
+	score   float64
+	quality string
+	shared  []string
+}
+
+// linkByName porównuje nazwy niepowiązanych towarów z produktami Woo bez EAN.
+// W trybie auto najlepsze pary z wynikiem ≥ auto_threshold są linkowane (każdy towar i produkt Woo raz);
+// pozostałe pary ≥ min_score trafiają do link_proposals. Propozycje pending są przebudowywane co relink,
+// a odrzucone/zatwierdzone pary nie są proponowane ponownie.
+func (i *Importer) linkByName(tx *gorm.DB, shop string, unresolved []linkStagingRow, wc []linkCacheRow, takenWoo map[uint]struct{}) ([]linkStagingRow, int, int, error) {
+	cfg := i.cfg.Linking.Name
+
+	if err := tx.Where("shop = ? AND status = ?", shop, "pending").Delete(&db.LinkProposal{}).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("błąd czyszczenia link_proposals: %w", err)
+	}
+	var decided []db.LinkProposal
+	if err := tx.Select("towar_id", "woo_id").Where("shop = ? AND status <> ?", shop, "pending").Find(&decided).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("błąd odczytu link_proposals: %w", err)
+	}
+	decidedPairs := make(map[[2]uint64]struct{}, len(decided))
+	for _, d := range decided {
+		decidedPairs[[2]uint64{uint64(d.TowarID), uint64(d.WooID)}] = struct{}{}
+	}
+
+	type preparedWoo struct {
+		wooID uint
+		name  string
+		prep  namematch.Name
+	}
+	woo := make([]preparedWoo, 0)
+	for _, c := range wc {
+		if _, taken := takenWoo[c.WooID]; taken || cleanEAN(c.Ean) != "" {
+			continue
+		}
+		prep := namematch.Prepare(c.Name)
+		if prep.Empty() {
+			continue
+		}
+		woo = append(woo, preparedWoo{wooID: c.WooID, name: c.Name, prep: prep})
+	}
+
+	var pairs []nameMatchPair
+	for _, p := range unresolved {
+		prep := namematch.Prepare(p.Nazwa)
+		if prep.Empty() {
+			continue
+		}
+		for _, w := range woo {
+			if _, ok := decidedPairs[[2]uint64{uint64(p.TowarID), uint64(w.wooID)}]; ok {
+				continue
+			}
+			score, quality, shared, ok := namematch.Score(prep, w.prep)
+			if !ok || score < cfg.MinScore {
+				continue
+			}
+			pairs = append(pairs, nameMatchPair{row: p, wooID: w.wooID, wooName: w.name, score: score, quality: quality, shared: shared})
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a].score != pairs[b].score {
+			return pairs[a].score > pairs[b].score
+		}
+		if pairs[a].row.TowarID != pairs[b].row.TowarID {
+			return pairs[a].row.TowarID < pairs[b].row.TowarID
+		}
+		return pairs[a].wooID < pairs[b].wooID
+	})
+
+	linkedTowar := make(map[int64]struct{})
+	if cfg.Mode == nameMatchAuto {
+		for _, pair := range pairs {
+			if pair.score < cfg.AutoThreshold {
+				break
+			}
+			if _, done := linkedTowar[pair.row.TowarID]; done {
+				continue
+			}
+			if _, taken := takenWoo[pair.wooID]; taken {
+				continue
+			}
+			if err := tx.Model(&db.WooProductCache{}).
+				Where("shop = ? AND woo_id = ?", shop, pair.wooID).
+				Update("towar_id", pair.row.TowarID).Error; err != nil {
+				return nil, 0, 0, fmt.Errorf("update Woo towar_id=%d (name) error: %w", pair.row.TowarID, err)
+			}
+			takenWoo[pair.wooID] = struct{}{}
+			linkedTowar[pair.row.TowarID] = struct{}{}
+			i.log.Debug().
+				Str("shop", shop).
+				Int64("towar_id", pair.row.TowarID).
+				Uint("woo_id", pair.wooID).
+				Float64("score", pair.score).
+				Msg("linker: AUTO-LINKED by name")
+		}
+	}
+
+	perTowar := make(map[int64]int)
+	proposals := make([]db.LinkProposal, 0)
+	for _, pair := range pairs {
+		if _, done := linkedTowar[pair.row.TowarID]; done {
+			continue
+		}
+		if _, taken := takenWoo[pair.wooID]; taken {
+			continue
+		}
+		if perTowar[pair.row.TowarID] >= nameProposalsPerTowar {
+			continue
+		}
+		perTowar[pair.row.TowarID]++
+		proposals = append(proposals, db.LinkProposal{
+			Shop:         shop,
+			TowarID:      pair.row.TowarID,
+			WooID:        pair.wooID,
+			Strategy:     linkStrategyName,
+			Score:        pair.score,
+			Quality:      pair.quality,
+			SharedTokens: strings.Join(pair.shared, "|"),
+			TowarName:    pair.row.Nazwa,
+			WooName:      pair.wooName,
+			Status:       "pending",
+		})
+	}
+	if len(proposals) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&proposals, stagingBatchSize).Error; err != nil {
+			return nil, 0, 0, fmt.Errorf("błąd zapisu link_proposals: %w", err)
+		}
+	}
+
+	remaining := unresolved[:0]
+	for _, p := range unresolved {
+		if _, done := linkedTowar[p.TowarID]; !done {
+			remaining = append(remaining, p)
+		}
+	}
+	return remaining, len(linkedTowar), len(proposals), nil
+}
@@ -0,0 +1,382 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	exportKindWykaz  = "wyk"   // pełny wykaz towarów (exp_wyk_*)
+	exportKindStany  = "stany" // same stany magazynowe (exp_stn_*)
+	exportKindCeny   = "ceny"  // same zmiany cen (exp_cen_*)
+	exportKindXLS    = "xls"   // arkusz XLS „towary” (*.xlsx)
+	stagingBatchSize = 500
+)
+
+// exportParser opisuje jeden typ eksportu PC-Market.
+// Plik jest rozpoznawany po prefiksie nazwy, a gdy prefiks jest nieznany (exp_*.xml) —
+// po nazwie elementu głównego XML. Formaty inne niż XML rozpoznaje rozszerzenie (Ext).
+type exportParser struct {
+	Kind   string
+	Prefix string
+	Root   string
+	Ext    string // rozszerzenie pliku spoza XML/ZIP (np. ".xlsx"); plik czyta File zamiast Towary
+	// Towary dekoduje element <towary> i zapisuje wiersze przez stagingWriter.
+	Towary func(w *stagingWriter, dec *xml.Decoder, se *xml.StartElement) error
+	// File czyta cały plik formatu Ext i zapisuje wiersze przez stagingWriter.
+	File func(i *Importer, w *stagingWriter, path string) error
+}
+
+var exportParsers []exportParser
+
+func registerExportParser(p exportParser) {
+	exportParsers = append(exportParsers, p)
+}
+
+func init() {
+	registerExportParser(exportParser{Kind: exportKindWykaz, Prefix: "exp_wyk_", Towary: parseTowaryWykaz})
+	registerExportParser(exportParser{Kind: exportKindStany, Prefix: "exp_stn_", Root: "stany", Towary: parseTowaryStany})
+	registerExportParser(exportParser{Kind: exportKindCeny, Prefix: "exp_cen_", Root: "ceny", Towary: parseTowaryCeny})
+	registerExportParser(exportParser{Kind: exportKindXLS, Ext: ".xlsx", File: parseTowaryXLS})
+}
+
+func isExportFileExt(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".xml") || strings.HasSuffix(lower, ".zip")
+}
+
+// parserForFilename zwraca parser po rozszerzeniu (formaty spoza XML) albo prefiksie nazwy pliku.
+// Pliki blokady Excela (~$*) są pomijane.
+func parserForFilename(name string) (exportParser, bool) {
+	lower := strings.ToLower(name)
+	for _, p := range exportParsers {
+		if p.Ext != "" && strings.HasSuffix(lower, p.Ext) && !strings.HasPrefix(name, "~$") {
+			return p, true
+		}
+	}
+	if !isExportFileExt(name) {
+		return exportParser{}, false
+	}
+	for _, p := range exportParsers {
+		if p.Prefix != "" && strings.HasPrefix(name, p.Prefix) {
+			return p, true
+		}
+	}
+	return exportParser{}, false
+}
+
+// parserForRoot zwraca parser po nazwie elementu głównego XML.
+func parserForRoot(root string) (exportParser, bool) {
+	for _, p := range exportParsers {
+		if p.Root != "" && strings.EqualFold(p.Root, root) {
+			return p, true
+		}
+	}
+	return exportParser{}, false
+}
+
+// detectExportParser rozpoznaje typ eksportu: najpierw po prefiksie nazwy,
+// a dla pozostałych plików exp_*.xml po elemencie głównym.
+func detectExportParser(fullPath, name string) (exportParser, bool) {
+	if p, ok := parserForFilename(name); ok {
+		return p, true
+	}
+	if !strings.HasPrefix(name, "exp_") || !strings.HasSuffix(strings.ToLower(name), ".xml") {
+		return exportParser{}, false
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return exportParser{}, false
+	}
+	defer f.Close()
+	root, err := readRootElement(f)
+	if err != nil {
+		return exportParser{}, false
+	}
+	return parserForRoot(root)
+}
+
+func readRootElement(r io.Reader) (string, error) {
+	dec := newExportDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// stProductUpsertColumns — kolumny st_products nadpisywane przez pełny wykaz (exp_wyk_*).
+var stProductUpsertColumns = []string{
+	"nazwa", "opis1", "vat_id", "kategoria_id", "grupa_id", "jm_id",
+	"cena_detal", "cena_hurtowa", "cena_nocna", "cena_dodatkowa", "cena_det_przed_prom", "naj_cena30_det",
+	"aktywny_wsi", "do_usuniecia", "data_aktualizacji", "folder_zdjec", "plik_zdjecia",
+}
+
+// stagingWriter zbiera wiersze staging w batchach i zapisuje je upsertami w jednej transakcji.
+type stagingWriter struct {
+	tx       *gorm.DB
+	importID uint
+	sourceID string // źródło importu — stany są trzymane osobno per źródło
+
+	// kolumny st_products nadpisywane przy konflikcie (nil = stProductUpsertColumns);
+	// import_id i updated_at są ustawiane zawsze
+	productColumns []string
+
+	prodBatch  []db.StProduct
+	stockBatch []db.StStock
+	touched    []int64
+
+	ProductsUpserted int
+	StocksUpserted   int
+	PricesUpdated    int
+	UnknownTowary    int
+}
+
+func newStagingWriter(tx *gorm.DB, importID uint, sourceID string) *stagingWriter {
+	return &stagingWriter{
+		tx:         tx,
+		importID:   importID,
+		sourceID:   sourceID,
+		prodBatch:  make([]db.StProduct, 0, stagingBatchSize),
+		stockBatch: make([]db.StStock, 0, stagingBatchSize),
+	}
+}
+
+func (w *stagingWriter) addProduct(p db.StProduct) error {
+	p.ImportID = w.importID
+	w.prodBatch = append(w.prodBatch, p)
+	return w.maybeFlush()
+}
+
+func (w *stagingWriter) addStock(s db.StStock) error {
+	s.ImportID = w.importID
+	s.SourceID = w.sourceID
+	w.stockBatch = append(w.stockBatch, s)
+	return w.maybeFlush()
+}
+
+// touchProduct oznacza towar jako zmieniony w tym imporcie (bez zmiany jego danych),
+// żeby planner uwzględnił go przy eksportach częściowych.
+func (w *stagingWriter) touchProduct(towarID int64) error {
+	w.touched = append(w.touched, towarID)
+	return w.maybeFlush()
+}
+
+func (w *stagingWriter) maybeFlush() error {
+	if len(w.prodBatch) >= stagingBatchSize || len(w.stockBatch) >= stagingBatchSize || len(w.touched) >= stagingBatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *stagingWriter) flush() error {
+	// ---- produkty ----
+	if len(w.prodBatch) > 0 {
+		cols := w.productColumns
+		if cols == nil {
+			cols = stProductUpsertColumns
+		}
+		updates := make(map[string]interface{}, len(cols)+2)
+		for _, col := range cols {
+			updates[col] = gorm.Expr("excluded." + col)
+		}
+		updates["import_id"] = gorm.Expr("excluded.import_id")
+		updates["updated_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+
+		err := w.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "towar_id"}, {Name: "kod"}},
+			DoUpdates: clause.Assignments(updates),
+		}).Create(&w.prodBatch).Error
+		if err != nil {
+			return fmt.Errorf("upsert st_products batch (n=%d): %w", len(w.prodBatch), err)
+		}
+		w.ProductsUpserted += len(w.prodBatch)
+		w.prodBatch = w.prodBatch[:0]
+	}
+
+	// ---- stany ----
+	if len(w.stockBatch) > 0 {
+		err := w.tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "towar_id"}, {Name: "magazyn_id"}, {Name: "source_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"stan_prev":  gorm.Expr("stan"),
+				"stan":       gorm.Expr("excluded.stan"),
+				"rezerwacja": gorm.Expr("excluded.rezerwacja"),
+				"import_id":  gorm.Expr("excluded.import_id"),
+				"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+			}),
+		}).Create(&w.stockBatch).Error
+		if err != nil {
+			return fmt.Errorf("upsert st_stocks batch (n=%d): %w", len(w.stockBatch), err)
+		}
+		w.StocksUpserted += len(w.stockBatch)
+		w.stockBatch = w.stockBatch[:0]
+	}
+
+	// ---- towary dotknięte eksportem częściowym ----
+	if len(w.touched) > 0 {
+		if err := w.tx.Model(&db.StProduct{}).
+			Where("towar_id IN ?", w.touched).
+			Updates(map[string]any{
+				"import_id":  w.importID,
+				"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+			}).Error; err != nil {
+			return fmt.Errorf("touch st_products (n=%d): %w", len(w.touched), err)
+		}
+		w.touched = w.touched[:0]
+	}
+	return nil
+}
+
+// parseTowaryWykaz — pełny wykaz (exp_wyk_*): produkt + stany wszystkich magazynów.
+func parseTowaryWykaz(w *stagingWriter, dec *xml.Decoder, se *xml.StartElement) error {
+	var tw struct {
+		Items []xmlTowar `xml:"towar"`
+	}
+	if err := dec.DecodeElement(&tw, se); err != nil {
+		return err
+	}
+
+	for _, t := range tw.Items {
+		if err := w.addProduct(db.StProduct{
+			TowarID:          t.TowarID,
+			Kod:              strings.TrimSpace(t.Kod),
+			Nazwa:            strings.TrimSpace(t.Nazwa),
+			Opis1:            t.Opis1,
+			VatID:            t.VatID,
+			KategoriaID:      i64(t.KategoriaID),
+			GrupaID:          i64(t.GrupaID),
+			JmID:             t.JmID,
+			CenaDetal:        f64(t.CenaDetal),
+			CenaHurtowa:      f64(t.CenaHurtowa),
+			CenaNocna:        f64(t.CenaNocna),
+			CenaDodatkowa:    f64(t.CenaDodatkowa),
+			CenaDetPrzedProm: f64(t.CenaDetPrzed),
+			NajCena30Det:     f64(t.NajCena30Det),
+			AktywnyWSI:       yn(t.AktywnyWSI),
+			DoUsuniecia:      yn(t.DoUsuniecia),
+			DataAktualizacji: t.DataAktualizacji,
+			FolderZdjec:      t.FolderZdjec,
+			PlikZdjecia:      t.PlikZdjecia,
+		}); err != nil {
+			return err
+		}
+
+		for _, m := range t.Magazyny {
+			if err := w.addStock(db.StStock{
+				TowarID:    t.TowarID,
+				MagazynID:  m.MagazynID,
+				Stan:       f64(m.Stan),
+				Rezerwacja: f64(m.Rezerwacja),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseTowaryStany — eksport samych stanów (exp_stn_*): towar_id + magazyny.
+// Dane produktu zostają bez zmian, towar jest tylko oznaczany jako dotknięty importem.
+func parseTowaryStany(w *stagingWriter, dec *xml.Decoder, se *xml.StartElement) error {
+	var tw struct {
+		Items []xmlTowar `xml:"towar"`
+	}
+	if err := dec.DecodeElement(&tw, se); err != nil {
+		return err
+	}
+
+	for _, t := range tw.Items {
+		if t.TowarID == 0 {
+			continue
+		}
+		for _, m := range t.Magazyny {
+			if err := w.addStock(db.StStock{
+				TowarID:    t.TowarID,
+				MagazynID:  m.MagazynID,
+				Stan:       f64(m.Stan),
+				Rezerwacja: f64(m.Rezerwacja),
+			}); err != nil {
+				return err
+			}
+		}
+		if err := w.touchProduct(t.TowarID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xmlCenaTowar — wiersz eksportu cen; wskaźniki odróżniają brak pola od pustej wartości.
+type xmlCenaTowar struct {
+	TowarID       int64   `xml:"towar_id"`
+	VatID         *string `xml:"vat_id"`
+	CenaDetal     *string `xml:"cena_detal"`
+	CenaHurtowa   *string `xml:"cena_hurtowa"`
+	CenaNocna     *string `xml:"cena_nocna"`
+	CenaDodatkowa *string `xml:"cena_dodatkowa"`
+	CenaDetPrzed  *string `xml:"cena_detal_przed_prom"`
+	NajCena30Det  *string `xml:"najnizsza_cena_30_dni_detal"`
+}
+
+// parseTowaryCeny — eksport zmian cen (exp_cen_*): aktualizuje tylko przesłane kolumny cen
+// istniejących towarów. Towary nieznane w staging są pomijane (brak kodu/nazwy do założenia).
+func parseTowaryCeny(w *stagingWriter, dec *xml.Decoder, se *xml.StartElement) error {
+	var tw struct {
+		Items []xmlCenaTowar `xml:"towar"`
+	}
+	if err := dec.DecodeElement(&tw, se); err != nil {
+		return err
+	}
+
+	// najpierw zapisz oczekujące batche, żeby UPDATE widział świeże wiersze
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	for _, t := range tw.Items {
+		if t.TowarID == 0 {
+			continue
+		}
+		updates := map[string]any{
+			"import_id":  w.importID,
+			"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		}
+		setPrice := func(col string, raw *string) {
+			if raw != nil {
+				updates[col] = f64(*raw)
+			}
+		}
+		setPrice("cena_detal", t.CenaDetal)
+		setPrice("cena_hurtowa", t.CenaHurtowa)
+		setPrice("cena_nocna", t.CenaNocna)
+		setPrice("cena_dodatkowa", t.CenaDodatkowa)
+		setPrice("cena_det_przed_prom", t.CenaDetPrzed)
+		setPrice("naj_cena30_det", t.NajCena30Det)
+		if t.VatID != nil {
+			updates["vat_id"] = i64(*t.VatID)
+		}
+
+		res := w.tx.Model(&db.StProduct{}).Where("towar_id = ?", t.TowarID).Updates(updates)
+		if res.Error != nil {
+			return fmt.Errorf("update prices towar_id=%d: %w", t.TowarID, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			w.UnknownTowary++
+			continue
+		}
+		w.PricesUpdated++
+	}
+	return nil
+}
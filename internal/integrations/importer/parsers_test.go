@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestImportStockOnlyAndPriceOnlyExports(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{PriceMode: "gross"}}
+
+	if err := gdb.Create(&db.WooProductCache{
+		WooID:        10,
+		Kod:          "SKU-10",
+		Ean:          "5901234567890",
+		Name:         "Woo product",
+		PriceRegular: 10,
+		HurtPrice:    7,
+		TaxClass:     "2300",
+		StockQty:     5,
+		StockManaged: true,
+		StockStatus:  "instock",
+		Backorders:   "notify",
+		Status:       "publish",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	writeExportFile(t, watchDir, "exp_wyk_1_20260101120000.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>T1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Produkt</nazwa><vat_id>2300</vat_id>
+<cena_detal>10</cena_detal><cena_hurtowa>7</cena_hurtowa><aktywny_w_SI>Y</aktywny_w_SI><do_usuniecia>N</do_usuniecia>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>5</stan_magazynu><rezerwacja_ilosci>0</rezerwacja_ilosci></magazyn></magazyny>
+</towar></towary></wykaz>`)
+	imp.scanOnce(watchDir)
+
+	full := mustImportFile(t, gdb, "exp_wyk_1_20260101120000.xml")
+	if full.Status != 1 || full.ExportKind != exportKindWykaz {
+		t.Fatalf("full export: status=%d kind=%q error=%q", full.Status, full.ExportKind, full.LastError)
+	}
+	assertTaskCount(t, gdb, 0)
+
+	writeExportFile(t, watchDir, "exp_stn_1_20260102120000.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<stany><transmisja_id>T2</transmisja_id><towary>
+<towar><towar_id>1</towar_id>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>8</stan_magazynu><rezerwacja_ilosci>0</rezerwacja_ilosci></magazyn></magazyny>
+</towar></towary></stany>`)
+	imp.scanOnce(watchDir)
+
+	stockImport := mustImportFile(t, gdb, "exp_stn_1_20260102120000.xml")
+	if stockImport.Status != 1 || stockImport.ExportKind != exportKindStany {
+		t.Fatalf("stock export: status=%d kind=%q error=%q", stockImport.Status, stockImport.ExportKind, stockImport.LastError)
+	}
+	var product db.StProduct
+	if err := gdb.Where("towar_id = ?", 1).Take(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	if product.ImportID != stockImport.ImportID || product.Nazwa != "Produkt" || product.CenaDetal != 10 {
+		t.Fatalf("stock export should only touch product, got %+v", product)
+	}
+	var stock db.StStock
+	if err := gdb.Where("towar_id = ? AND magazyn_id = ?", 1, 1).Take(&stock).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stock.Stan != 8 || stock.StanPrev == nil || *stock.StanPrev != 5 {
+		t.Fatalf("unexpected stock after stock-only export: %+v", stock)
+	}
+	assertTaskKind(t, gdb, stockImport.ImportID, db.WooTaskKindStockUpdate)
+
+	// nieznany prefiks — typ rozpoznany po elemencie głównym <ceny>
+	writeExportFile(t, watchDir, "exp_zmc_1_20260103120000.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<ceny><transmisja_id>T3</transmisja_id><towary>
+<towar><towar_id>1</towar_id><cena_detal>12,50</cena_detal></towar>
+<towar><towar_id>999</towar_id><cena_detal>1</cena_detal></towar>
+</towary></ceny>`)
+	imp.scanOnce(watchDir)
+
+	priceImport := mustImportFile(t, gdb, "exp_zmc_1_20260103120000.xml")
+	if priceImport.Status != 1 || priceImport.ExportKind != exportKindCeny {
+		t.Fatalf("price export: status=%d kind=%q error=%q", priceImport.Status, priceImport.ExportKind, priceImport.LastError)
+	}
+	if err := gdb.Where("towar_id = ?", 1).Take(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	if product.CenaDetal != 12.5 || product.CenaHurtowa != 7 || product.ImportID != priceImport.ImportID {
+		t.Fatalf("price export should update only sent columns, got %+v", product)
+	}
+	var unknown int64
+	mustCount(t, gdb.Model(&db.StProduct{}).Where("towar_id = ?", 999), &unknown)
+	if unknown != 0 {
+		t.Fatalf("price export must not create unknown products, got %d", unknown)
+	}
+	assertTaskKind(t, gdb, priceImport.ImportID, db.WooTaskKindPriceUpdate)
+}
+
+func TestDetectExportParserIgnoresUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeExportFile(t, dir, "exp_abc_1.xml", `<?xml version="1.0"?><inne><towary/></inne>`)
+	writeExportFile(t, dir, "notatki.xml", `<?xml version="1.0"?><ceny/>`)
+
+	if _, ok := detectExportParser(filepath.Join(dir, "exp_abc_1.xml"), "exp_abc_1.xml"); ok {
+		t.Fatal("unknown root element should not match any parser")
+	}
+	if _, ok := detectExportParser(filepath.Join(dir, "notatki.xml"), "notatki.xml"); ok {
+		t.Fatal("files without exp_ prefix should be ignored")
+	}
+	if p, ok := detectExportParser(filepath.Join(dir, "exp_wyk_1.zip"), "exp_wyk_1.zip"); !ok || p.Kind != exportKindWykaz {
+		t.Fatalf("exp_wyk_ zip should use full export parser, got %+v ok=%v", p, ok)
+	}
+}
+
+func writeExportFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertTaskCount(t *testing.T, gdb *gorm.DB, want int64) {
+	t.Helper()
+	var got int64
+	mustCount(t, gdb.Model(&db.WooTask{}), &got)
+	if got != want {
+		t.Fatalf("expected %d woo tasks, got %d", want, got)
+	}
+}
+
+func assertTaskKind(t *testing.T, gdb *gorm.DB, importID uint, kind string) {
+	t.Helper()
+	var got int64
+	mustCount(t, gdb.Model(&db.WooTask{}).Where("import_id = ? AND kind = ?", importID, kind), &got)
+	if got != 1 {
+		t.Fatalf("expected one %s task for import %d, got %d", kind, importID, got)
+	}
+}
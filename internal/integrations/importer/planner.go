@@ -19,6 +19,14 @@ const (
 	priceModeNet   = "net"
 )
 
+// Tryby wycofania produktu (retire_mode) dla towarów do_usuniecia=Y / aktywny_w_SI=N.
+const (
+	retireModeOff     = "off"
+	retireModeDraft   = "draft"
+	retireModePrivate = "private"
+	retireModeHidden  = "hidden"
+)
+
 type plannerSourceRow struct {
 	ImportID       uint
 	TowarID        int64
@@ -27,15 +35,24 @@ type plannerSourceRow struct {
 	VatID          int64
 	CenaDetal      float64
 	CenaHurtowa    float64
+	CenaNocna      float64
+	CenaDodatkowa  float64
+	CenaDetPrzed   float64 // cena_det_przed_prom
+	NajCena30Det   float64 // najniższa cena z 30 dni (Omnibus)
 	AktywnyWSI     bool
 	DoUsuniecia    bool
 	TotalStock     float64
 	TotalReserved  float64
 	TotalStockPrev *float64 // NULL jeśli brak historii dla choć jednego magazynu
+
+	// wyliczane przez applyWarehouseSelection (magazyny, mnożniki, bufory)
+	DesiredStock     float64  `gorm:"-"`
+	DesiredStockPrev *float64 `gorm:"-"`
 }
 
 type plannerCacheRow struct {
 	WooID             uint
+	ParentID          uint // != 0: wariant produktu variable
 	TowarID           *int64
 	Kod               string
 	Ean               string
@@ -43,16 +60,25 @@ type plannerCacheRow struct {
 	PriceRegular      float64
 	PriceSale         float64
 	HurtPrice         float64
+	OmnibusPrice      float64
+	CustomFields      string // JSON {kod: wartość} custom fields spoza hurt_price / omnibus_price
 	TaxClass          string
 	StockQty          float64
 	StockManaged      bool
 	StockStatus       string
 	Backorders        string
 	CatalogVisibility string
+	Status            string
+}
+
+// isVariation: warianty nie mają catalog_visibility — widoczność dziedziczą z produktu variable.
+func (c plannerCacheRow) isVariation() bool {
+	return c.ParentID != 0
 }
 
 type plannerStats struct {
 	ImportID                  uint
+	Shop                      string
 	Filename                  string
 	ProductsSeen              int
 	LinkedProducts            int
@@ -66,11 +92,17 @@ type plannerStats struct {
 	PriceTasksRequeued        int
 	AvailabilityTasksCreated  int
 	AvailabilityTasksRequeued int
+	CreateTasksCreated        int
+	CreateTasksRequeued       int
+	VisibilityTasksCreated    int
+	VisibilityTasksRequeued   int
 	ExistingPendingOrDone     int
 	PolicySkipEANPresent      int
 	PolicySkipDuplicateEAN    int
 	PolicySkipStockUnmanaged  int
 	PolicySkipPriceSale       int
+	PolicySkipCreate          int
+	PolicySkipUnknownVat      int
 }
 
 func (i *Importer) PlanWooTasksForImports(importIDs []uint) error {
@@ -125,7 +157,7 @@ func (i *Importer) PlanWooTasks(importID uint) error {
 		}
 	}()
 
-	stats, err := i.planWooTasksTx(tx, importID)
+	perShop, err := i.planWooTasksTx(tx, importID)
 	if err != nil {
 		return err
 	}
@@ -135,9 +167,17 @@ func (i *Importer) PlanWooTasks(importID uint) error {
 	}
 	committed = true
 
+	for _, stats := range perShop {
+		i.logPlannerStats(stats)
+	}
+	return nil
+}
+
+func (i *Importer) logPlannerStats(stats plannerStats) {
 	i.log.Info().
 		Uint("import_id", stats.ImportID).
 		Str("file", stats.Filename).
+		Str("shop", stats.Shop).
 		Int("products_seen", stats.ProductsSeen).
 		Int("linked_products", stats.LinkedProducts).
 		Int("unlinked_products", stats.UnlinkedProducts).
@@ -155,35 +195,61 @@ func (i *Importer) PlanWooTasks(importID uint) error {
 		Int("skip_price_sale", stats.PolicySkipPriceSale).
 		Int("availability_tasks_created", stats.AvailabilityTasksCreated).
 		Int("availability_tasks_requeued", stats.AvailabilityTasksRequeued).
+		Int("create_tasks_created", stats.CreateTasksCreated).
+		Int("create_tasks_requeued", stats.CreateTasksRequeued).
+		Int("skip_create", stats.PolicySkipCreate).
+		Int("skip_unknown_vat", stats.PolicySkipUnknownVat).
+		Int("visibility_tasks_created", stats.VisibilityTasksCreated).
+		Int("visibility_tasks_requeued", stats.VisibilityTasksRequeued).
 		Msg("woo task planning finished")
-
-	return nil
 }
 
-func (i *Importer) planWooTasksTx(tx *gorm.DB, importID uint) (plannerStats, error) {
-	stats := plannerStats{ImportID: importID}
-
+// planWooTasksTx planuje taski importu dla każdego sklepu z cache (fan-out) — jeden wpis statystyk na sklep.
+func (i *Importer) planWooTasksTx(tx *gorm.DB, importID uint) ([]plannerStats, error) {
 	var importFile db.ImportFile
 	if err := tx.Where("import_id = ?", importID).Take(&importFile).Error; err != nil {
-		return stats, fmt.Errorf("plan tasks import %d: %w", importID, err)
+		return nil, fmt.Errorf("plan tasks import %d: %w", importID, err)
 	}
-	stats.Filename = importFile.Filename
 
 	sourceRows, err := loadPlannerSourceRows(tx, importID)
 	if err != nil {
-		return stats, err
+		return nil, err
 	}
-	stats.ProductsSeen = len(sourceRows)
 	if len(sourceRows) == 0 {
-		return stats, nil
+		return []plannerStats{{ImportID: importID, Filename: importFile.Filename}}, nil
+	}
+	if err := i.applyWarehouseSelection(tx, sourceRows); err != nil {
+		return nil, err
+	}
+
+	shops, err := loadPlannerShops(tx)
+	if err != nil {
+		return nil, err
+	}
+	// ceny liczone w trybie price_mode źródła, z którego przyszedł import
+	priceMode := i.cfg.sourcePriceMode(importFile.SourceID)
+	perShop := make([]plannerStats, 0, len(shops))
+	for _, shop := range shops {
+		stats, err := i.forShop(shop, priceMode).planShopTasks(tx, importID, sourceRows)
+		if err != nil {
+			return nil, fmt.Errorf("plan tasks import %d shop %q: %w", importID, shop, err)
+		}
+		stats.Filename = importFile.Filename
+		perShop = append(perShop, stats)
 	}
+	return perShop, nil
+}
+
+// planShopTasks planuje taski jednego sklepu (i.shop) na podstawie jego cache.
+func (i *Importer) planShopTasks(tx *gorm.DB, importID uint, sourceRows []plannerSourceRow) (plannerStats, error) {
+	stats := plannerStats{ImportID: importID, Shop: i.shop.ID, ProductsSeen: len(sourceRows)}
 
 	towarIDs := make([]int64, 0, len(sourceRows))
 	for _, row := range sourceRows {
 		towarIDs = append(towarIDs, row.TowarID)
 	}
 
-	cacheRows, err := loadPlannerCacheRows(tx, towarIDs)
+	cacheRows, err := i.loadPlannerCacheRows(tx, towarIDs)
 	if err != nil {
 		return stats, err
 	}
@@ -196,16 +262,48 @@ func (i *Importer) planWooTasksTx(tx *gorm.DB, importID uint) (plannerStats, err
 		cacheByTowarID[*row.TowarID] = append(cacheByTowarID[*row.TowarID], row)
 	}
 
-	eanOwners, err := loadCacheEANOwners(tx)
+	eanOwners, err := i.loadCacheEANOwners(tx)
 	if err != nil {
 		return stats, err
 	}
+	if err := i.loadTaxClasses(tx); err != nil {
+		return stats, err
+	}
+
+	// Bez zasilonego cache każdy towar wygląda na niepowiązany — wtedy nie zakładamy produktów.
+	var cacheCount int64
+	if i.cfg.CreateProducts {
+		if err := i.ofShop(tx).Model(&db.WooProductCache{}).Count(&cacheCount).Error; err != nil {
+			return stats, err
+		}
+	}
 
 	for _, row := range sourceRows {
 		candidates := cacheByTowarID[row.TowarID]
 		switch len(candidates) {
 		case 0:
 			stats.UnlinkedProducts++
+			if i.cfg.CreateProducts && cacheCount > 0 {
+				if !i.checkVat(tx, row) {
+					stats.PolicySkipUnknownVat++
+					continue
+				}
+				created, requeued, existed, skipped, err := i.planProductCreateTask(tx, importID, row, eanOwners)
+				if err != nil {
+					return stats, err
+				}
+				switch {
+				case created:
+					stats.CreateTasksCreated++
+				case requeued:
+					stats.CreateTasksRequeued++
+				case existed:
+					stats.ExistingPendingOrDone++
+				case skipped:
+					stats.PolicySkipCreate++
+				}
+				continue
+			}
 			i.log.Debug().
 				Uint("import_id", importID).
 				Int64("towar_id", row.TowarID).
@@ -254,6 +352,19 @@ func (i *Importer) planWooTasksTx(tx *gorm.DB, importID uint) (plannerStats, err
 			}
 		}
 
+		if created, requeued, existed, err := i.planVisibilityUpdateTask(tx, importID, row, cache); err != nil {
+			return stats, err
+		} else {
+			switch {
+			case created:
+				stats.VisibilityTasksCreated++
+			case requeued:
+				stats.VisibilityTasksRequeued++
+			case existed:
+				stats.ExistingPendingOrDone++
+			}
+		}
+
 		if created, requeued, existed, err := i.planAvailabilityUpdateTask(tx, importID, row, cache); err != nil {
 			return stats, err
 		} else {
@@ -282,6 +393,10 @@ func (i *Importer) planWooTasksTx(tx *gorm.DB, importID uint) (plannerStats, err
 			}
 		}
 
+		if !i.checkVat(tx, row) {
+			stats.PolicySkipUnknownVat++
+			continue
+		}
 		if created, requeued, existed, skipped, err := i.planPriceUpdateTask(tx, importID, row, cache); err != nil {
 			return stats, err
 		} else {
@@ -311,6 +426,10 @@ SELECT
 	p.vat_id,
 	p.cena_detal,
 	p.cena_hurtowa,
+	p.cena_nocna,
+	p.cena_dodatkowa,
+	p.cena_det_przed_prom AS cena_det_przed,
+	p.naj_cena30_det,
 	p.aktywny_wsi,
 	p.do_usuniecia,
 	COALESCE(SUM(s.stan), 0) AS total_stock,
@@ -327,6 +446,10 @@ GROUP BY
 	p.vat_id,
 	p.cena_detal,
 	p.cena_hurtowa,
+	p.cena_nocna,
+	p.cena_dodatkowa,
+	p.cena_det_przed_prom,
+	p.naj_cena30_det,
 	p.aktywny_wsi,
 	p.do_usuniecia
 ORDER BY p.towar_id;
@@ -335,26 +458,26 @@ ORDER BY p.towar_id;
 	return rows, tx.Raw(q, importID).Scan(&rows).Error
 }
 
-func loadPlannerCacheRows(tx *gorm.DB, towarIDs []int64) ([]plannerCacheRow, error) {
+func (i *Importer) loadPlannerCacheRows(tx *gorm.DB, towarIDs []int64) ([]plannerCacheRow, error) {
 	var rows []plannerCacheRow
 	if len(towarIDs) == 0 {
 		return rows, nil
 	}
-	if err := tx.Model(&db.WooProductCache{}).
+	if err := i.ofShop(tx).Model(&db.WooProductCache{}).
 		Where("towar_id IN ?", towarIDs).
-		Select("woo_id", "towar_id", "kod", "ean", "name", "price_regular", "price_sale", "hurt_price", "tax_class", "stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility").
+		Select("woo_id", "parent_id", "towar_id", "kod", "ean", "name", "price_regular", "price_sale", "hurt_price", "omnibus_price", "custom_fields", "tax_class", "stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility", "status").
 		Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	return rows, nil
 }
 
-func loadCacheEANOwners(tx *gorm.DB) (map[string][]uint, error) {
+func (i *Importer) loadCacheEANOwners(tx *gorm.DB) (map[string][]uint, error) {
 	var rows []struct {
 		WooID uint
 		Ean   string
 	}
-	if err := tx.Model(&db.WooProductCache{}).
+	if err := i.ofShop(tx).Model(&db.WooProductCache{}).
 		Select("woo_id", "ean").
 		Find(&rows).Error; err != nil {
 		return nil, err
@@ -404,6 +527,7 @@ func (i *Importer) planEANUpdateTask(tx *gorm.DB, importID uint, src plannerSour
 	payload := db.WooEANUpdatePayload{
 		ImportID:    importID,
 		WooID:       cache.WooID,
+		ParentID:    cache.ParentID,
 		TowarID:     src.TowarID,
 		SKU:         cache.Kod,
 		ProductName: cache.Name,
@@ -412,7 +536,8 @@ func (i *Importer) planEANUpdateTask(tx *gorm.DB, importID uint, src plannerSour
 		DesiredEAN:  desiredEAN,
 	}
 	task := db.WooTask{
-		TaskKey:     buildTaskKey(db.WooTaskKindEANUpdate, cache.WooID, desiredEAN),
+		TaskKey:     i.taskKey(db.WooTaskKindEANUpdate, cache.WooID, desiredEAN),
+		Shop:        i.shop.ID,
 		ImportID:    importID,
 		TowarID:     ptrInt64(src.TowarID),
 		WooID:       ptrUint(cache.WooID),
@@ -424,24 +549,23 @@ func (i *Importer) planEANUpdateTask(tx *gorm.DB, importID uint, src plannerSour
 }
 
 func (i *Importer) planStockUpdateTask(tx *gorm.DB, importID uint, src plannerSourceRow, cache plannerCacheRow) (created, requeued, existed, skipped bool, err error) {
-	if floatAlmostEqual(src.CenaDetal, 0) {
+	if floatAlmostEqual(i.regularGross(src), 0) {
 		return false, false, false, false, nil // produkt niedostępny (brak ceny) — stock obsługuje availability.update
 	}
-	desiredStock := math.Max(src.TotalStock-src.TotalReserved, 0)
+	desiredStock := src.DesiredStock
 	if floatAlmostEqual(cache.StockQty, desiredStock) {
 		return false, false, false, false, nil
 	}
 	// Jeśli mamy historię PCM i efektywny stan się nie zmienił, nie nadpisuj Woo —
 	// różnica w cache może wynikać ze sprzedaży w sklepie (której PCM jeszcze nie zna).
-	if src.TotalStockPrev != nil {
-		prevNet := math.Max(*src.TotalStockPrev-src.TotalReserved, 0)
-		if floatAlmostEqual(desiredStock, prevNet) {
+	if src.DesiredStockPrev != nil {
+		if floatAlmostEqual(desiredStock, *src.DesiredStockPrev) {
 			i.log.Debug().
 				Uint("import_id", importID).
 				Uint("woo_id", cache.WooID).
 				Int64("towar_id", src.TowarID).
-				Float64("pcm_stock", src.TotalStock).
-				Float64("pcm_stock_prev", *src.TotalStockPrev).
+				Float64("pcm_stock", desiredStock).
+				Float64("pcm_stock_prev", *src.DesiredStockPrev).
 				Float64("cache_stock", cache.StockQty).
 				Msg("task planner: skip stock update — PCM unchanged, cache diff likely from Woo sale")
 			return false, false, false, false, nil
@@ -461,6 +585,7 @@ func (i *Importer) planStockUpdateTask(tx *gorm.DB, importID uint, src plannerSo
 	payload := db.WooStockUpdatePayload{
 		ImportID:      importID,
 		WooID:         cache.WooID,
+		ParentID:      cache.ParentID,
 		TowarID:       src.TowarID,
 		SKU:           cache.Kod,
 		ProductName:   cache.Name,
@@ -471,7 +596,8 @@ func (i *Importer) planStockUpdateTask(tx *gorm.DB, importID uint, src plannerSo
 		SourceReserve: src.TotalReserved,
 	}
 	task := db.WooTask{
-		TaskKey:     buildTaskKey(db.WooTaskKindStockUpdate, cache.WooID, normalizeFloatKey(desiredStock)),
+		TaskKey:     i.taskKey(db.WooTaskKindStockUpdate, cache.WooID, normalizeFloatKey(desiredStock)),
+		Shop:        i.shop.ID,
 		ImportID:    importID,
 		TowarID:     ptrInt64(src.TowarID),
 		WooID:       ptrUint(cache.WooID),
@@ -483,36 +609,6 @@ func (i *Importer) planStockUpdateTask(tx *gorm.DB, importID uint, src plannerSo
 	return created, requeued, existed, false, err
 }
 
-func vatIDToTaxClass(vatID int64) string {
-	switch vatID {
-	case 2300:
-		return "2300"
-	case 800:
-		return "800"
-	case 500:
-		return "500"
-	case 0, -1: // 0% i ZW
-		return "zero-rate"
-	default:
-		return "" // standard rate (23% fallback)
-	}
-}
-
-func vatIDToRate(vatID int64) float64 {
-	switch vatID {
-	case 2300:
-		return 0.23
-	case 800:
-		return 0.08
-	case 500:
-		return 0.05
-	case 0, -1:
-		return 0
-	default:
-		return 0.23
-	}
-}
-
 func normalizePriceMode(mode string) (string, error) {
 	mode = strings.TrimSpace(strings.ToLower(mode))
 	if mode == "" {
@@ -526,26 +622,21 @@ func normalizePriceMode(mode string) (string, error) {
 	}
 }
 
-func (i *Importer) wooPriceFromGross(gross float64, vatID int64) float64 {
-	mode, err := normalizePriceMode(i.cfg.PriceMode)
-	if err != nil || mode == priceModeGross {
-		return gross
-	}
-	rate := vatIDToRate(vatID)
-	if rate == 0 {
-		return gross
-	}
-	return math.Round((gross/(1+rate))*100) / 100
-}
-
 func (i *Importer) planPriceUpdateTask(tx *gorm.DB, importID uint, src plannerSourceRow, cache plannerCacheRow) (created, requeued, existed, skipped bool, err error) {
-	if floatAlmostEqual(src.CenaDetal, 0) {
+	if floatAlmostEqual(i.regularGross(src), 0) {
 		return false, false, false, false, nil // produkt niedostępny (brak ceny) — nie ustawiaj ceny 0
 	}
-	desiredRegular := i.wooPriceFromGross(src.CenaDetal, src.VatID)
-	desiredHurt := i.wooPriceFromGross(src.CenaHurtowa, src.VatID)
-	desiredTaxClass := vatIDToTaxClass(src.VatID)
-	if floatAlmostEqual(cache.PriceRegular, desiredRegular) && floatAlmostEqual(cache.HurtPrice, desiredHurt) && cache.TaxClass == desiredTaxClass {
+	desiredRegular := i.desiredRegular(src)
+	fields := i.desiredPriceFields(src, cache)
+	currentHurt, desiredHurt := hurtField(fields)
+	vat, _ := i.vatRule(src.VatID) // planShopTasks sprawdza mapowanie przez checkVat
+	desiredTaxClass := vat.TaxClass
+
+	if i.cfg.PromoPrices && i.regularRule().Source == priceSourceDetal { // promocje PCM dotyczą ceny detalicznej
+		return i.planPromoPriceUpdateTask(tx, importID, src, cache, fields, desiredTaxClass)
+	}
+
+	if floatAlmostEqual(cache.PriceRegular, desiredRegular) && priceFieldsMatch(fields) && cache.TaxClass == desiredTaxClass {
 		return false, false, false, false, nil
 	}
 	if cache.PriceSale > 0 {
@@ -562,19 +653,86 @@ func (i *Importer) planPriceUpdateTask(tx *gorm.DB, importID uint, src plannerSo
 	payload := db.WooPriceUpdatePayload{
 		ImportID:        importID,
 		WooID:           cache.WooID,
+		ParentID:        cache.ParentID,
+		TowarID:         src.TowarID,
+		SKU:             cache.Kod,
+		ProductName:     cache.Name,
+		CurrentRegular:  cache.PriceRegular,
+		DesiredRegular:  desiredRegular,
+		CurrentSale:     cache.PriceSale,
+		CurrentHurt:     currentHurt,
+		DesiredHurt:     desiredHurt,
+		CurrentTaxClass: cache.TaxClass,
+		DesiredTaxClass: desiredTaxClass,
+		PriceRules:      true,
+		Fields:          fields,
+	}
+	keyParts := append([]string{normalizeFloatKey(desiredRegular)}, priceFieldKeys(fields)...)
+	task := db.WooTask{
+		TaskKey:     i.taskKey(db.WooTaskKindPriceUpdate, cache.WooID, append(keyParts, desiredTaxClass)...),
+		Shop:        i.shop.ID,
+		ImportID:    importID,
+		TowarID:     ptrInt64(src.TowarID),
+		WooID:       ptrUint(cache.WooID),
+		Kind:        db.WooTaskKindPriceUpdate,
+		PayloadJSON: mustJSON(payload),
+		Status:      "pending",
+	}
+	created, requeued, existed, err = enqueueWooTask(tx, task)
+	return created, requeued, existed, false, err
+}
+
+// planPromoPriceUpdateTask — tryb promo_prices: gdy cena sprzed promocji z PCM jest wyższa od cena_detal,
+// regular_price = cena sprzed promocji, sale_price = cena_detal, a najniższa cena z 30 dni trafia do
+// custom field omnibus_price. Bez promocji sale_price i cena Omnibus są czyszczone.
+// Wszystkie trzy ceny przechodzą przez regułę regular_price (narzut, zaokrąglenie, brutto/netto).
+func (i *Importer) planPromoPriceUpdateTask(tx *gorm.DB, importID uint, src plannerSourceRow, cache plannerCacheRow, fields []db.WooPriceField, desiredTaxClass string) (created, requeued, existed, skipped bool, err error) {
+	rule := i.regularRule()
+	vat, _ := i.vatRule(src.VatID)
+	desiredRegular := rule.apply(src.CenaDetal, vat, i.cfg.PriceMode)
+	desiredSale, desiredOmnibus := 0.0, 0.0
+	if src.CenaDetPrzed > src.CenaDetal && !floatAlmostEqual(src.CenaDetPrzed, src.CenaDetal) {
+		desiredRegular = rule.apply(src.CenaDetPrzed, vat, i.cfg.PriceMode)
+		desiredSale = rule.apply(src.CenaDetal, vat, i.cfg.PriceMode)
+		if src.NajCena30Det > 0 {
+			desiredOmnibus = rule.apply(src.NajCena30Det, vat, i.cfg.PriceMode)
+		}
+	}
+	currentHurt, desiredHurt := hurtField(fields)
+
+	if floatAlmostEqual(cache.PriceRegular, desiredRegular) &&
+		floatAlmostEqual(cache.PriceSale, desiredSale) &&
+		floatAlmostEqual(cache.OmnibusPrice, desiredOmnibus) &&
+		priceFieldsMatch(fields) &&
+		cache.TaxClass == desiredTaxClass {
+		return false, false, false, false, nil
+	}
+
+	payload := db.WooPriceUpdatePayload{
+		ImportID:        importID,
+		WooID:           cache.WooID,
+		ParentID:        cache.ParentID,
 		TowarID:         src.TowarID,
 		SKU:             cache.Kod,
 		ProductName:     cache.Name,
 		CurrentRegular:  cache.PriceRegular,
 		DesiredRegular:  desiredRegular,
 		CurrentSale:     cache.PriceSale,
-		CurrentHurt:     cache.HurtPrice,
+		CurrentHurt:     currentHurt,
 		DesiredHurt:     desiredHurt,
 		CurrentTaxClass: cache.TaxClass,
 		DesiredTaxClass: desiredTaxClass,
+		PromoMode:       true,
+		DesiredSale:     desiredSale,
+		DesiredOmnibus:  desiredOmnibus,
+		PriceRules:      true,
+		Fields:          fields,
 	}
+	keyParts := append([]string{normalizeFloatKey(desiredRegular)}, priceFieldKeys(fields)...)
+	keyParts = append(keyParts, desiredTaxClass, "promo", normalizeFloatKey(desiredSale), normalizeFloatKey(desiredOmnibus))
 	task := db.WooTask{
-		TaskKey:     buildTaskKey(db.WooTaskKindPriceUpdate, cache.WooID, normalizeFloatKey(desiredRegular), normalizeFloatKey(desiredHurt), desiredTaxClass),
+		TaskKey:     i.taskKey(db.WooTaskKindPriceUpdate, cache.WooID, keyParts...),
+		Shop:        i.shop.ID,
 		ImportID:    importID,
 		TowarID:     ptrInt64(src.TowarID),
 		WooID:       ptrUint(cache.WooID),
@@ -587,14 +745,17 @@ func (i *Importer) planPriceUpdateTask(tx *gorm.DB, importID uint, src plannerSo
 }
 
 func (i *Importer) planAvailabilityUpdateTask(tx *gorm.DB, importID uint, src plannerSourceRow, cache plannerCacheRow) (created, requeued, existed bool, err error) {
-	unavailable := floatAlmostEqual(src.CenaDetal, 0)
+	if i.retireMode() == retireModeHidden && src.retired() && !cache.isVariation() {
+		return false, false, false, nil // catalog_visibility należy do visibility.update
+	}
+	unavailable := floatAlmostEqual(i.regularGross(src), 0)
 
 	if unavailable {
-		if !cache.StockManaged && cache.StockStatus == "outofstock" && cache.CatalogVisibility == "hidden" {
+		if !cache.StockManaged && cache.StockStatus == "outofstock" && (cache.isVariation() || cache.CatalogVisibility == "hidden") {
 			return false, false, false, nil
 		}
 	} else {
-		if cache.StockManaged && cache.Backorders == "notify" && cache.CatalogVisibility != "hidden" {
+		if cache.StockManaged && cache.Backorders == "notify" && (cache.isVariation() || cache.CatalogVisibility != "hidden") {
 			return false, false, false, nil
 		}
 	}
@@ -607,13 +768,15 @@ func (i *Importer) planAvailabilityUpdateTask(tx *gorm.DB, importID uint, src pl
 	payload := db.WooAvailabilityPayload{
 		ImportID:    importID,
 		WooID:       cache.WooID,
+		ParentID:    cache.ParentID,
 		TowarID:     src.TowarID,
 		SKU:         cache.Kod,
 		ProductName: cache.Name,
 		Unavailable: unavailable,
 	}
 	task := db.WooTask{
-		TaskKey:     buildTaskKey(db.WooTaskKindAvailabilityUpdate, cache.WooID, stateKey),
+		TaskKey:     i.taskKey(db.WooTaskKindAvailabilityUpdate, cache.WooID, stateKey),
+		Shop:        i.shop.ID,
 		ImportID:    importID,
 		TowarID:     ptrInt64(src.TowarID),
 		WooID:       ptrUint(cache.WooID),
@@ -624,6 +787,194 @@ func (i *Importer) planAvailabilityUpdateTask(tx *gorm.DB, importID uint, src pl
 	return enqueueWooTask(tx, task)
 }
 
+// retired — towar wycofany w PCM: oznaczony do usunięcia albo zdjęty ze sklepu internetowego.
+func (src plannerSourceRow) retired() bool {
+	return src.DoUsuniecia || !src.AktywnyWSI
+}
+
+func normalizeRetireMode(mode string) (string, error) {
+	mode = strings.TrimSpace(strings.ToLower(mode))
+	if mode == "" {
+		return retireModeOff, nil
+	}
+	switch mode {
+	case retireModeOff, retireModeDraft, retireModePrivate, retireModeHidden:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported importer retire_mode %q (allowed: %s, %s, %s, %s)", mode, retireModeOff, retireModeDraft, retireModePrivate, retireModeHidden)
+	}
+}
+
+func (i *Importer) retireMode() string {
+	mode, err := normalizeRetireMode(i.cfg.RetireMode)
+	if err != nil {
+		return retireModeOff
+	}
+	return mode
+}
+
+// planVisibilityUpdateTask wycofuje ze sklepu towar oznaczony w PCM jako do_usuniecia / nieaktywny w SI
+// (status draft/private albo catalog_visibility=hidden, wg retire_mode) i przywraca go po reaktywacji.
+// Przywracany jest tylko stan ustawiony wcześniej przez integrator (ostatni zakończony task
+// visibility.update był wycofaniem) — np. szkice z product.create nie są publikowane.
+func (i *Importer) planVisibilityUpdateTask(tx *gorm.DB, importID uint, src plannerSourceRow, cache plannerCacheRow) (created, requeued, existed bool, err error) {
+	mode := i.retireMode()
+	if mode == retireModeOff || cache.Status == "trash" {
+		return false, false, false, nil
+	}
+	if mode == retireModeHidden && cache.isVariation() {
+		return false, false, false, nil // wariant nie ma catalog_visibility — ukrywa się produkt variable
+	}
+
+	payload := db.WooVisibilityPayload{
+		ImportID:          importID,
+		WooID:             cache.WooID,
+		ParentID:          cache.ParentID,
+		TowarID:           src.TowarID,
+		SKU:               cache.Kod,
+		ProductName:       cache.Name,
+		Retire:            src.retired(),
+		Mode:              mode,
+		CurrentStatus:     cache.Status,
+		CurrentVisibility: cache.CatalogVisibility,
+	}
+	stateKey := "retire"
+
+	if payload.Retire {
+		switch mode {
+		case retireModeDraft, retireModePrivate:
+			if cache.Status == mode {
+				return false, false, false, nil
+			}
+			payload.DesiredStatus = mode
+		case retireModeHidden:
+			if cache.CatalogVisibility == "hidden" {
+				return false, false, false, nil
+			}
+			payload.DesiredVisibility = "hidden"
+		}
+	} else {
+		last, err := lastDoneVisibilityPayload(i.ofShop(tx), cache.WooID)
+		if err != nil || last == nil || !last.Retire {
+			return false, false, false, err
+		}
+		// cofamy w trybie, w którym produkt został wycofany (config mógł się od tego czasu zmienić)
+		payload.Mode = last.Mode
+		switch last.Mode {
+		case retireModeDraft, retireModePrivate:
+			if cache.Status != last.DesiredStatus {
+				return false, false, false, nil
+			}
+			payload.DesiredStatus = "publish"
+		case retireModeHidden:
+			if cache.CatalogVisibility != "hidden" || floatAlmostEqual(i.regularGross(src), 0) {
+				return false, false, false, nil // bez ceny produkt ma zostać ukryty (availability.update)
+			}
+			payload.DesiredVisibility = "visible"
+		default:
+			return false, false, false, nil
+		}
+		stateKey = "restore"
+	}
+
+	task := db.WooTask{
+		TaskKey:     i.taskKey(db.WooTaskKindVisibilityUpdate, cache.WooID, stateKey, payload.Mode),
+		Shop:        i.shop.ID,
+		ImportID:    importID,
+		TowarID:     ptrInt64(src.TowarID),
+		WooID:       ptrUint(cache.WooID),
+		Kind:        db.WooTaskKindVisibilityUpdate,
+		PayloadJSON: mustJSON(payload),
+		Status:      "pending",
+	}
+	return enqueueWooTask(tx, task)
+}
+
+func lastDoneVisibilityPayload(tx *gorm.DB, wooID uint) (*db.WooVisibilityPayload, error) {
+	var tasks []db.WooTask
+	if err := tx.Where("kind = ? AND woo_id = ? AND status = ?", db.WooTaskKindVisibilityUpdate, wooID, "done").
+		Order("finished_at DESC").Order("task_id DESC").
+		Limit(1).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	var payload db.WooVisibilityPayload
+	if err := json.Unmarshal([]byte(tasks[0].PayloadJSON), &payload); err != nil {
+		return nil, fmt.Errorf("decode visibility payload task %d: %w", tasks[0].TaskID, err)
+	}
+	return &payload, nil
+}
+
+// planProductCreateTask planuje założenie produktu w Woo dla niepowiązanego towaru.
+// Zakładane są tylko towary z aktywny_w_SI=Y, bez flagi do_usuniecia, z ceną i EAN —
+// EAN jest potrzebny, żeby kolejny relink (LinkProductsByEAN) utrzymał powiązanie.
+func (i *Importer) planProductCreateTask(tx *gorm.DB, importID uint, src plannerSourceRow, eanOwners map[string][]uint) (created, requeued, existed, skipped bool, err error) {
+	ean := cleanEAN(src.Kod)
+	switch {
+	case !src.AktywnyWSI, src.DoUsuniecia:
+		return false, false, false, false, nil
+	case floatAlmostEqual(i.regularGross(src), 0), ean == "":
+		i.log.Debug().
+			Uint("import_id", importID).
+			Int64("towar_id", src.TowarID).
+			Str("kod", src.Kod).
+			Float64("price", i.regularGross(src)).
+			Msg("task planner: skip product create — missing EAN or price")
+		return false, false, false, true, nil
+	case len(eanOwners[ean]) > 0:
+		i.log.Warn().
+			Uint("import_id", importID).
+			Int64("towar_id", src.TowarID).
+			Str("ean", ean).
+			Interface("owner_woo_ids", eanOwners[ean]).
+			Msg("task planner: skip product create — EAN already present in Woo cache")
+		return false, false, false, true, nil
+	}
+
+	fields := i.desiredPriceFields(src, plannerCacheRow{})
+	_, desiredHurt := hurtField(fields)
+	vat, _ := i.vatRule(src.VatID)
+	payload := db.WooProductCreatePayload{
+		ImportID:        importID,
+		TowarID:         src.TowarID,
+		SKU:             strings.TrimSpace(src.Kod),
+		ProductName:     strings.TrimSpace(src.Nazwa),
+		EAN:             ean,
+		DesiredRegular:  i.desiredRegular(src),
+		DesiredHurt:     desiredHurt,
+		DesiredTaxClass: vat.TaxClass,
+		DesiredStock:    src.DesiredStock,
+		PriceRules:      true,
+		Fields:          fields,
+	}
+	task := db.WooTask{
+		TaskKey:     i.taskKey(db.WooTaskKindProductCreate, 0, strconv.FormatInt(src.TowarID, 10)),
+		Shop:        i.shop.ID,
+		ImportID:    importID,
+		TowarID:     ptrInt64(src.TowarID),
+		Kind:        db.WooTaskKindProductCreate,
+		PayloadJSON: mustJSON(payload),
+		Status:      "pending",
+	}
+
+	// Zakończonego tworzenia nie ponawiamy — drugi POST założyłby duplikat w sklepie.
+	var existing db.WooTask
+	switch err := tx.Where("task_key = ?", task.TaskKey).Take(&existing).Error; {
+	case err == nil:
+		if existing.Status == "done" || existing.Status == "skipped" {
+			return false, false, true, false, nil
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return false, false, false, false, err
+	}
+
+	created, requeued, existed, err = enqueueWooTask(tx, task)
+	return created, requeued, existed, false, err
+}
+
 func enqueueWooTask(tx *gorm.DB, task db.WooTask) (created, requeued, existed bool, err error) {
 	var existing db.WooTask
 	switch err = tx.Where("task_key = ?", task.TaskKey).Take(&existing).Error; {
@@ -633,17 +984,23 @@ func enqueueWooTask(tx *gorm.DB, task db.WooTask) (created, requeued, existed bo
 			return false, false, true, nil
 		default:
 			updates := map[string]any{
-				"import_id":    task.ImportID,
-				"towar_id":     task.TowarID,
-				"woo_id":       task.WooID,
-				"kind":         task.Kind,
-				"payload_json": task.PayloadJSON,
-				"status":       "pending",
-				"attempts":     0,
-				"last_error":   "",
-				"started_at":   nil,
-				"finished_at":  nil,
-				"depends_on":   task.DependsOn,
+				"import_id":       task.ImportID,
+				"towar_id":        task.TowarID,
+				"kind":            task.Kind,
+				"payload_json":    task.PayloadJSON,
+				"status":          "pending",
+				"attempts":        0,
+				"last_error":      "",
+				"started_at":      nil,
+				"finished_at":     nil,
+				"next_attempt_at": nil,
+				"preview_json":    "",
+				"depends_on":      task.DependsOn,
+			}
+			// product.create nie zna woo_id — nie kasujemy tego, który worker zapisał po udanym POST,
+			// inaczej ponowienie założyłoby duplikat zamiast zweryfikować istniejący produkt.
+			if task.WooID != nil || existing.WooID == nil {
+				updates["woo_id"] = task.WooID
 			}
 			if err := tx.Model(&db.WooTask{}).Where("task_id = ?", existing.TaskID).Updates(updates).Error; err != nil {
 				return false, false, false, err
@@ -66,6 +66,179 @@ func TestLinkProductsByEANClearsStaleTowarID(t *testing.T) {
 	}
 }
 
+func TestLinkProductsByEANHonoursManualLinks(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb}
+
+	for _, c := range []db.WooProductCache{
+		{WooID: 10, Kod: "NOEAN", Name: "Woo bez EAN"},
+		{WooID: 11, Ean: "5901111111111", Name: "Duplikat A"},
+		{WooID: 12, Ean: "5901111111111", Name: "Duplikat B"},
+	} {
+		if err := gdb.Create(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []db.StProduct{
+		{ImportID: 1, TowarID: 1, Kod: "", Nazwa: "Towar bez EAN"},
+		{ImportID: 1, TowarID: 2, Kod: "5901111111111", Nazwa: "Towar z duplikatem"},
+	} {
+		if err := gdb.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.PinManualLink(gdb, "", 1, 10, "brak EAN w PCM"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PinManualLink(gdb, "", 2, 12, "właściwy z duplikatów"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PinManualLink(gdb, "", 3, 12, ""); err == nil {
+		t.Fatal("expected error when woo_id is already pinned to another towar")
+	}
+
+	if err := importer.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]*int64{10: ptrInt64(1), 11: nil, 12: ptrInt64(2)}
+	for wooID, towarID := range want {
+		var c db.WooProductCache
+		if err := gdb.Where("woo_id = ?", wooID).Take(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+		if (towarID == nil) != (c.TowarID == nil) || (towarID != nil && *towarID != *c.TowarID) {
+			t.Fatalf("woo_id %d: unexpected towar_id %v", wooID, c.TowarID)
+		}
+	}
+
+	var issues []db.LinkIssue
+	if err := gdb.Find(&issues).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, li := range issues {
+		if li.TowarID == 1 || li.TowarID == 2 || li.Reason == "duplicate_ean_shop" {
+			t.Fatalf("pinned products must not be reported as link issues: %+v", li)
+		}
+	}
+
+	if removed, err := db.UnpinManualLink(gdb, "", 1); err != nil || !removed {
+		t.Fatalf("unpin: removed=%v err=%v", removed, err)
+	}
+	if err := importer.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	mustCount(t, gdb.Model(&db.LinkIssue{}).Where("towar_id = ? AND reason = ?", 1, "missing_ean_src"), &count)
+	if count != 1 {
+		t.Fatalf("expected unpinned product without EAN to be reported again, got %d", count)
+	}
+}
+
+func TestLinkProductsFallsBackToSKUAndNameStrategies(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{Linking: LinkingConfig{
+		Strategies: []string{"ean", "sku", "name"},
+		Name:       NameMatchConfig{Mode: "auto"},
+	}}
+	if err := cfg.Linking.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	for _, c := range []db.WooProductCache{
+		{WooID: 1, Kod: "abc-1", Name: "Coś zupełnie innego"},
+		{WooID: 2, Kod: "", Name: "Castello Chianti Classico Riserva 2019"},
+		{WooID: 3, Kod: "", Name: "Zonin Prosecco"},
+		{WooID: 4, Kod: "", Ean: "5900000000004", Name: "Towar z EAN"},
+	} {
+		if err := gdb.Create(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []db.StProduct{
+		{ImportID: 1, TowarID: 11, Kod: "ABC-1", Nazwa: "Po SKU"},
+		{ImportID: 1, TowarID: 12, Kod: "", Nazwa: "Chianti Classico Riserva Castello"},
+		{ImportID: 1, TowarID: 13, Kod: "", Nazwa: "Prosecco Treviso Extra Dry Zonin"},
+		{ImportID: 1, TowarID: 14, Kod: "5900000000004", Nazwa: "Po EAN"},
+	} {
+		if err := gdb.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := importer.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]*int64{1: ptrInt64(11), 2: ptrInt64(12), 3: nil, 4: ptrInt64(14)}
+	for wooID, towarID := range want {
+		var c db.WooProductCache
+		if err := gdb.Where("woo_id = ?", wooID).Take(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+		if (towarID == nil) != (c.TowarID == nil) || (towarID != nil && *towarID != *c.TowarID) {
+			t.Fatalf("woo_id %d: unexpected towar_id %v", wooID, c.TowarID)
+		}
+	}
+
+	var proposals []db.LinkProposal
+	if err := gdb.Where("status = ?", "pending").Find(&proposals).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 1 || proposals[0].TowarID != 13 || proposals[0].WooID != 3 || proposals[0].Score >= cfg.Linking.Name.AutoThreshold {
+		t.Fatalf("expected one name proposal below auto threshold, got %+v", proposals)
+	}
+
+	var count int64
+	mustCount(t, gdb.Model(&db.LinkIssue{}).Where("towar_id IN ?", []int64{11, 12}), &count)
+	if count != 0 {
+		t.Fatalf("products linked by fallback strategies must not be link issues, got %d", count)
+	}
+	mustCount(t, gdb.Model(&db.LinkIssue{}).Where("towar_id = ? AND reason = ?", 13, "missing_ean_src"), &count)
+	if count != 1 {
+		t.Fatalf("proposed but unapproved product should stay a link issue, got %d", count)
+	}
+
+	if _, err := db.ApproveLinkProposal(gdb, "", proposals[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+	var approved db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 3).Take(&approved).Error; err != nil {
+		t.Fatal(err)
+	}
+	if approved.TowarID == nil || *approved.TowarID != 13 {
+		t.Fatalf("approved proposal should become a manual link, got %v", approved.TowarID)
+	}
+	mustCount(t, gdb.Model(&db.LinkProposal{}).Where("status = ?", "pending"), &count)
+	if count != 0 {
+		t.Fatalf("expected no pending proposals after approval, got %d", count)
+	}
+}
+
+func TestLinkingConfigNormalize(t *testing.T) {
+	cfg := LinkingConfig{Strategies: []string{"EAN", "name", "sku", "name"}}
+	if err := cfg.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(cfg.Strategies) != "[name sku]" || cfg.Name.Mode != nameMatchPropose || cfg.Name.MinScore != defaultNameMinScore {
+		t.Fatalf("unexpected normalized config: %+v", cfg)
+	}
+	for _, bad := range []LinkingConfig{
+		{Strategies: []string{"fuzzy"}},
+		{Name: NameMatchConfig{Mode: "always"}},
+		{Name: NameMatchConfig{AutoThreshold: 1.5}},
+	} {
+		if err := bad.normalize(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
 func TestPlanWooTasksCreatesEANStockAndPriceTasks(t *testing.T) {
 	gdb := newImporterTestDB(t)
 	importer := &Importer{log: zerolog.Nop(), db: gdb}
@@ -355,6 +528,405 @@ func TestPlanWooTasksSkipsStockWhenPCMUnchanged(t *testing.T) {
 	}
 }
 
+func TestPlanWooTasksCreatesProductsForActiveUnlinkedGoods(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{CreateProducts: true}}
+
+	const importID = 12
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_create.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create([]db.StProduct{
+		{ImportID: importID, TowarID: 500, Kod: "5900000000500", Nazwa: "Active New", VatID: 800, CenaDetal: 10.8, CenaHurtowa: 8, AktywnyWSI: true},
+		{ImportID: importID, TowarID: 501, Kod: "5900000000501", Nazwa: "Inactive New", CenaDetal: 12, AktywnyWSI: false},
+		{ImportID: importID, TowarID: 502, Kod: "5900000000502", Nazwa: "To Delete", CenaDetal: 12, AktywnyWSI: true, DoUsuniecia: true},
+		{ImportID: importID, TowarID: 503, Kod: "", Nazwa: "No EAN", CenaDetal: 12, AktywnyWSI: true},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StStock{ImportID: importID, TowarID: 500, MagazynID: 1, Stan: 6, Rezerwacja: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooProductCache{WooID: 1, Kod: "OTHER", Ean: "5900000009999", Name: "Unrelated"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindProductCreate).Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 product.create task, got %d", len(tasks))
+	}
+	if tasks[0].TowarID == nil || *tasks[0].TowarID != 500 || tasks[0].WooID != nil {
+		t.Fatalf("unexpected product.create task %+v", tasks[0])
+	}
+	var payload db.WooProductCreatePayload
+	if err := json.Unmarshal([]byte(tasks[0].PayloadJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.EAN != "5900000000500" || payload.DesiredRegular != 10.8 || payload.DesiredTaxClass != "800" || payload.DesiredStock != 5 {
+		t.Fatalf("unexpected product.create payload %+v", payload)
+	}
+
+	// Zakończone tworzenie nie może być ponowione przy kolejnym planowaniu.
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", tasks[0].TaskID).Update("status", "done").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	var again db.WooTask
+	if err := gdb.Where("task_id = ?", tasks[0].TaskID).Take(&again).Error; err != nil {
+		t.Fatal(err)
+	}
+	if again.Status != "done" {
+		t.Fatalf("expected done product.create task to stay done, got %s", again.Status)
+	}
+}
+
+func TestPlanWooTasksRequeueKeepsCreatedWooID(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{CreateProducts: true}}
+
+	const importID = 14
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_recreate.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 510, Kod: "5900000000510", Nazwa: "Created Once", VatID: 800, CenaDetal: 10, CenaHurtowa: 8, AktywnyWSI: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooProductCache{WooID: 1, Kod: "OTHER", Ean: "5900000009999", Name: "Unrelated"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	var task db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindProductCreate).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Stan po workerze: POST założył produkt 77, weryfikacja GET się nie powiodła.
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", task.TaskID).Updates(map[string]any{
+		"woo_id": 77, "status": "error", "attempts": 3, "last_error": "verify created product: 503 busy",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	var again db.WooTask
+	if err := gdb.Where("task_id = ?", task.TaskID).Take(&again).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Worker ponawia wtedy samą weryfikację woo_id (handleProductCreate), bez drugiego POST.
+	if again.Status != "pending" || again.WooID == nil || *again.WooID != 77 {
+		t.Fatalf("requeued product.create must keep created woo_id, got status=%s woo_id=%v", again.Status, again.WooID)
+	}
+}
+
+func TestPlanWooTasksDoesNotCreateProductsByDefault(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb}
+
+	const importID = 13
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_nocreate.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 600, Kod: "5900000000600", Nazwa: "New", CenaDetal: 10, AktywnyWSI: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooProductCache{WooID: 1, Kod: "OTHER", Ean: "5900000009999", Name: "Unrelated"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := gdb.Model(&db.WooTask{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no tasks without create_products, got %d", count)
+	}
+}
+
+func TestPlanWooTasksRetiresAndRestoresProducts(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{RetireMode: "draft"}}
+
+	const importID = 14
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_retire.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create([]db.StProduct{
+		{ImportID: importID, TowarID: 700, Kod: "5900000000700", Nazwa: "To Delete", CenaDetal: 10, AktywnyWSI: true, DoUsuniecia: true},
+		{ImportID: importID, TowarID: 701, Kod: "5900000000701", Nazwa: "Draft From Create", CenaDetal: 10, AktywnyWSI: true},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	retiredID, draftID := int64(700), int64(701)
+	if err := gdb.Create([]db.WooProductCache{
+		{WooID: 70, TowarID: &retiredID, Ean: "5900000000700", Status: "publish", StockManaged: true, Backorders: "notify", PriceRegular: 10},
+		{WooID: 71, TowarID: &draftID, Ean: "5900000000701", Status: "draft", StockManaged: true, Backorders: "notify", PriceRegular: 10},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindVisibilityUpdate).Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	// szkic, którego integrator nie wycofywał, nie może zostać opublikowany
+	if len(tasks) != 1 || tasks[0].WooID == nil || *tasks[0].WooID != 70 {
+		t.Fatalf("expected single retire task for woo 70, got %+v", tasks)
+	}
+	var payload db.WooVisibilityPayload
+	if err := json.Unmarshal([]byte(tasks[0].PayloadJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !payload.Retire || payload.DesiredStatus != "draft" || payload.DesiredVisibility != "" {
+		t.Fatalf("unexpected retire payload %+v", payload)
+	}
+
+	// worker wycofał produkt, a PCM przywrócił towar
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", tasks[0].TaskID).Update("status", "done").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(&db.WooProductCache{}).Where("woo_id = ?", 70).Update("status", "draft").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(&db.StProduct{}).Where("towar_id = ?", 700).Update("do_usuniecia", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	var restore db.WooTask
+	if err := gdb.Where("kind = ? AND status = ?", db.WooTaskKindVisibilityUpdate, "pending").Take(&restore).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(restore.PayloadJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Retire || payload.DesiredStatus != "publish" || restore.WooID == nil || *restore.WooID != 70 {
+		t.Fatalf("unexpected restore task %+v payload %+v", restore, payload)
+	}
+}
+
+func TestPlanWooTasksAppliesWarehouseSelection(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	half := 0.5
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{Warehouses: WarehouseConfig{
+		Exclude: []int64{9},
+		Rules: []WarehouseRule{
+			{MagazynID: 1, Buffer: 2},
+			{MagazynID: 2, Multiplier: &half},
+		},
+	}}}
+
+	const importID = 15
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_warehouses.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 800, Kod: "5900000000800", Nazwa: "Multi WH", CenaDetal: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create([]db.StStock{
+		{ImportID: importID, TowarID: 800, MagazynID: 1, Stan: 10, Rezerwacja: 1}, // 10-1-2 = 7
+		{ImportID: importID, TowarID: 800, MagazynID: 2, Stan: 7},                 // floor(7*0.5) = 3
+		{ImportID: importID, TowarID: 800, MagazynID: 9, Stan: 100},               // zaplecze — pominięte
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	towarID := int64(800)
+	if err := gdb.Create(&db.WooProductCache{WooID: 80, TowarID: &towarID, Ean: "5900000000800", StockQty: 1, StockManaged: true, Backorders: "notify", PriceRegular: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	var task db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindStockUpdate).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	var payload db.WooStockUpdatePayload
+	if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.DesiredStock != 10 || payload.SourceStock != 17 || payload.SourceReserve != 1 {
+		t.Fatalf("unexpected warehouse-selected stock payload %+v", payload)
+	}
+}
+
+func TestWarehouseConfigValidate(t *testing.T) {
+	if err := (WarehouseConfig{Include: []int64{1}, Exclude: []int64{1}}).validate(); err == nil {
+		t.Fatal("expected error for warehouse in include and exclude")
+	}
+	neg := -1.0
+	if err := (WarehouseConfig{Rules: []WarehouseRule{{MagazynID: 1, Multiplier: &neg}}}).validate(); err == nil {
+		t.Fatal("expected error for negative multiplier")
+	}
+	if err := (WarehouseConfig{Include: []int64{1, 2}, Rules: []WarehouseRule{{MagazynID: 1, Buffer: 3}}}).validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanWooTasksPromoPricesSetAndClearSalePrice(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{PromoPrices: true}}
+
+	const importID = 16
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_promo.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 900, Kod: "5900000000900", Nazwa: "Promo", VatID: 2300,
+		CenaDetal: 8, CenaDetPrzedProm: 10, NajCena30Det: 7.5, CenaHurtowa: 5}).Error; err != nil {
+		t.Fatal(err)
+	}
+	towarID := int64(900)
+	if err := gdb.Create(&db.WooProductCache{WooID: 90, TowarID: &towarID, Ean: "5900000000900", PriceRegular: 10, HurtPrice: 5, TaxClass: "2300",
+		StockManaged: true, Backorders: "notify"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	payload := mustPriceTaskPayload(t, gdb)
+	if !payload.PromoMode || payload.DesiredRegular != 10 || payload.DesiredSale != 8 || payload.DesiredOmnibus != 7.5 {
+		t.Fatalf("unexpected promo payload %+v", payload)
+	}
+
+	// koniec promocji: cena sprzed promocji wyzerowana, w cache nadal sale_price
+	if err := gdb.Where("1 = 1").Delete(&db.WooTask{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(&db.WooProductCache{}).Where("woo_id = ?", 90).
+		Updates(map[string]any{"price_sale": 8, "omnibus_price": 7.5}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(&db.StProduct{}).Where("towar_id = ?", 900).
+		Updates(map[string]any{"cena_detal": 10, "cena_det_przed_prom": 0}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	payload = mustPriceTaskPayload(t, gdb)
+	if !payload.PromoMode || payload.DesiredRegular != 10 || payload.DesiredSale != 0 || payload.DesiredOmnibus != 0 {
+		t.Fatalf("expected promo end to clear sale price, got %+v", payload)
+	}
+}
+
+func TestLinkAndPlanVariationsOfVariableProducts(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	importer := &Importer{log: zerolog.Nop(), db: gdb}
+
+	const importID = 9
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_variations.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []db.WooProductCache{
+		{WooID: 300, Type: "variable", Ean: "5900000000300", Name: "Wino (variable)"},
+		{WooID: 301, ParentID: 300, Type: "variation", Ean: "5900000000301", Name: "Wino 0,75 l", PriceRegular: 10, StockQty: 1, StockManaged: true, Backorders: "notify"},
+	} {
+		if err := gdb.Create(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []db.StProduct{
+		{ImportID: importID, TowarID: 1, Kod: "5900000000301", Nazwa: "Wino 0,75 l", CenaDetal: 25, AktywnyWSI: true},
+		{ImportID: importID, TowarID: 2, Kod: "5900000000300", Nazwa: "Wino", CenaDetal: 30, AktywnyWSI: true},
+	} {
+		if err := gdb.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gdb.Create(&db.StStock{ImportID: importID, TowarID: 1, MagazynID: 1, Stan: 4}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+	var parent, variation db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 300).Take(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Where("woo_id = ?", 301).Take(&variation).Error; err != nil {
+		t.Fatal(err)
+	}
+	if parent.TowarID != nil {
+		t.Fatalf("variable parent must not be linked, got towar_id %v", *parent.TowarID)
+	}
+	if variation.TowarID == nil || *variation.TowarID != 1 {
+		t.Fatalf("expected variation linked to towar 1, got %v", variation.TowarID)
+	}
+	var count int64
+	mustCount(t, gdb.Model(&db.LinkIssue{}).Where("towar_id = ? AND reason = ?", 2, "missing_in_shop_by_ean"), &count)
+	if count != 1 {
+		t.Fatalf("expected EAN of variable parent to be reported as missing, got %d", count)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	var tasks []db.WooTask
+	if err := gdb.Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		kinds[task.Kind] = true
+		if task.WooID == nil || *task.WooID != 301 {
+			t.Fatalf("expected task for variation 301, got %+v", task)
+		}
+		var ref struct {
+			ParentID uint `json:"parent_id"`
+		}
+		if err := json.Unmarshal([]byte(task.PayloadJSON), &ref); err != nil {
+			t.Fatal(err)
+		}
+		if ref.ParentID != 300 {
+			t.Fatalf("expected parent_id 300 in %s payload, got %d", task.Kind, ref.ParentID)
+		}
+	}
+	if !kinds[db.WooTaskKindStockUpdate] || !kinds[db.WooTaskKindPriceUpdate] {
+		t.Fatalf("expected stock and price tasks for variation, got %v", kinds)
+	}
+	if kinds[db.WooTaskKindAvailabilityUpdate] {
+		t.Fatal("variation without catalog_visibility must not get availability.update when already available")
+	}
+}
+
+func mustPriceTaskPayload(t *testing.T, gdb *gorm.DB) db.WooPriceUpdatePayload {
+	t.Helper()
+	var task db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindPriceUpdate).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	var payload db.WooPriceUpdatePayload
+	if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
 func newImporterTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 
@@ -373,6 +945,10 @@ func newImporterTestDB(t *testing.T) *gorm.DB {
 		&db.WooTask{},
 		&db.KV{},
 		&db.LinkIssue{},
+		&db.ManualLink{},
+		&db.LinkProposal{},
+		&db.WooOrder{},
+		&db.WooOrderLine{},
 	); err != nil {
 		t.Fatal(err)
 	}
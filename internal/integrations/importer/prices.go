@@ -0,0 +1,266 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+)
+
+// Reguły cen (importer prices) mapują kolumny cen PCM na pola Woo. Cel to regular_price albo kod
+// custom field z konfiguracji woocommerce (custom_fields) — custom field wskazuje pole top-level
+// i/lub klucz meta, więc zapis do dowolnego meta to custom field z write_meta_key.
+// Bez sekcji prices: regular_price ← cena_detal, hurt_price ← cena_hurtowa (jak dotąd).
+
+// priceTargetRegular to cel reguły zasilającej regular_price. Zerowa cena tej reguły = towar niedostępny.
+const priceTargetRegular = "regular_price"
+
+// Kolumny cen PCM, z których mogą korzystać reguły.
+const (
+	priceSourceDetal     = "cena_detal"
+	priceSourceHurtowa   = "cena_hurtowa"
+	priceSourceNocna     = "cena_nocna"
+	priceSourceDodatkowa = "cena_dodatkowa"
+)
+
+// priceRoundInt zaokrągla do pełnych złotych (round).
+const priceRoundInt = "int"
+
+// PriceRule opisuje jedno pole cenowe Woo wyliczane z ceny PCM.
+type PriceRule struct {
+	Target    string  `json:"target"`               // regular_price albo kod custom field (np. hurt_price)
+	Source    string  `json:"source"`               // cena_detal / cena_hurtowa / cena_nocna / cena_dodatkowa
+	MarkupPct float64 `json:"markup_pct,omitempty"` // narzut w % (ujemny = rabat)
+	Round     string  `json:"round,omitempty"`      // "" = do grosza, "int" = do pełnych, "0.99" = w górę do końcówki
+	PriceMode string  `json:"price_mode,omitempty"` // gross / net; puste = jak źródło importu / sklep
+}
+
+// defaultPriceRules odtwarza mapowanie sprzed reguł.
+func defaultPriceRules() []PriceRule {
+	return []PriceRule{
+		{Target: priceTargetRegular, Source: priceSourceDetal},
+		{Target: "hurt_price", Source: priceSourceHurtowa},
+	}
+}
+
+// normalizePriceRules sprawdza listę reguł: znane kolumny PCM, cele bez duplikatów i dokładnie
+// jedna reguła regular_price. Pusta lista jest poprawna (reguły domyślne).
+func normalizePriceRules(rules []PriceRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(rules))
+	for idx := range rules {
+		r := &rules[idx]
+		r.Target = strings.TrimSpace(r.Target)
+		r.Source = strings.ToLower(strings.TrimSpace(r.Source))
+		r.Round = strings.ToLower(strings.TrimSpace(r.Round))
+
+		switch r.Target {
+		case "":
+			return fmt.Errorf("prices[%d]: brak target", idx)
+		case "sale_price", "omnibus_price":
+			return fmt.Errorf("prices[%d]: %s ustawia tryb promo_prices, nie reguła cen", idx, r.Target)
+		}
+		if _, ok := seen[r.Target]; ok {
+			return fmt.Errorf("prices: zdublowany target %q", r.Target)
+		}
+		seen[r.Target] = struct{}{}
+
+		if err := validPriceSource(r.Source); err != nil {
+			return fmt.Errorf("prices[%s]: %w", r.Target, err)
+		}
+		if r.MarkupPct <= -100 {
+			return fmt.Errorf("prices[%s]: markup_pct %v dałby cenę <= 0", r.Target, r.MarkupPct)
+		}
+		if _, err := priceEnding(r.Round); err != nil {
+			return fmt.Errorf("prices[%s]: %w", r.Target, err)
+		}
+		if strings.TrimSpace(r.PriceMode) != "" {
+			mode, err := normalizePriceMode(r.PriceMode)
+			if err != nil {
+				return fmt.Errorf("prices[%s]: %w", r.Target, err)
+			}
+			r.PriceMode = mode
+		}
+	}
+	if _, ok := seen[priceTargetRegular]; !ok {
+		return fmt.Errorf("prices: brak reguły dla %s", priceTargetRegular)
+	}
+	return nil
+}
+
+func validPriceSource(source string) error {
+	switch source {
+	case priceSourceDetal, priceSourceHurtowa, priceSourceNocna, priceSourceDodatkowa:
+		return nil
+	default:
+		return fmt.Errorf("nieznana kolumna ceny PCM %q (dozwolone: %s, %s, %s, %s)",
+			source, priceSourceDetal, priceSourceHurtowa, priceSourceNocna, priceSourceDodatkowa)
+	}
+}
+
+// priceEnding parsuje round: -1 = do grosza, 0 = do pełnych, (0,1) = końcówka (np. 0.99).
+func priceEnding(round string) (float64, error) {
+	switch round {
+	case "":
+		return -1, nil
+	case priceRoundInt:
+		return 0, nil
+	}
+	ending, err := strconv.ParseFloat(round, 64)
+	if err != nil || ending <= 0 || ending >= 1 {
+		return 0, fmt.Errorf("nieznane round %q (dozwolone: \"\", %q, końcówka 0.01–0.99)", round, priceRoundInt)
+	}
+	return ending, nil
+}
+
+// sourcePrice zwraca cenę brutto PCM z kolumny reguły.
+func (r PriceRule) sourcePrice(src plannerSourceRow) float64 {
+	switch r.Source {
+	case priceSourceHurtowa:
+		return src.CenaHurtowa
+	case priceSourceNocna:
+		return src.CenaNocna
+	case priceSourceDodatkowa:
+		return src.CenaDodatkowa
+	default:
+		return src.CenaDetal
+	}
+}
+
+// apply przelicza cenę brutto PCM: narzut → brutto/netto (stawka vat) → zaokrąglenie. Zero zostaje zerem.
+func (r PriceRule) apply(gross float64, vat VatRule, defaultMode string) float64 {
+	if floatAlmostEqual(gross, 0) {
+		return 0
+	}
+	v := gross * (1 + r.MarkupPct/100)
+	mode := r.PriceMode
+	if mode == "" {
+		mode = defaultMode
+	}
+	if mode == priceModeNet && vat.Rate != 0 {
+		v /= 1 + vat.Rate/100
+	}
+	return roundPrice(v, r.Round)
+}
+
+// roundPrice zaokrągla cenę wg round; końcówka zaokrągla w górę do najbliższej ceny kończącej się na nią.
+func roundPrice(v float64, round string) float64 {
+	ending, err := priceEnding(round)
+	switch {
+	case err != nil, ending < 0:
+		return math.Round(v*100) / 100
+	case ending == 0:
+		return math.Round(v)
+	}
+	v = math.Round(v*100) / 100
+	out := math.Floor(v) + ending
+	if out+0.0001 < v {
+		out++
+	}
+	return math.Round(out*100) / 100
+}
+
+// priceRules zwraca reguły cen sklepu, dla którego planuje importer.
+func (i *Importer) priceRules() []PriceRule {
+	return i.cfg.resolvePriceRules(i.shop)
+}
+
+// resolvePriceRules wybiera reguły sklepu: shops[].prices, potem importer prices, potem domyślne.
+// price_field sklepu podmienia kolumnę reguły regular_price.
+func (c Config) resolvePriceRules(shop ShopConfig) []PriceRule {
+	base := shop.Prices
+	if len(base) == 0 {
+		base = c.Prices
+	}
+	if len(base) == 0 {
+		base = defaultPriceRules()
+	}
+	rules := append([]PriceRule(nil), base...)
+	if shop.PriceField != "" && len(shop.Prices) == 0 {
+		for idx := range rules {
+			if rules[idx].Target == priceTargetRegular {
+				rules[idx].Source = shop.PriceField
+			}
+		}
+	}
+	return rules
+}
+
+// regularRule to reguła regular_price (normalizePriceRules gwarantuje, że istnieje).
+func (i *Importer) regularRule() PriceRule {
+	for _, r := range i.priceRules() {
+		if r.Target == priceTargetRegular {
+			return r
+		}
+	}
+	return PriceRule{Target: priceTargetRegular, Source: priceSourceDetal}
+}
+
+// regularGross to cena brutto PCM zasilająca regular_price sklepu. Zero oznacza towar niedostępny.
+func (i *Importer) regularGross(src plannerSourceRow) float64 {
+	return i.regularRule().sourcePrice(src)
+}
+
+// desiredRegular to regular_price wysyłana do Woo (reguła regular_price).
+func (i *Importer) desiredRegular(src plannerSourceRow) float64 {
+	r := i.regularRule()
+	vat, _ := i.vatRule(src.VatID)
+	return r.apply(r.sourcePrice(src), vat, i.cfg.PriceMode)
+}
+
+// desiredPriceFields wylicza custom fields cenowe wg reguł; Current pochodzi z cache.
+func (i *Importer) desiredPriceFields(src plannerSourceRow, cache plannerCacheRow) []db.WooPriceField {
+	var current map[string]string
+	if cache.CustomFields != "" {
+		_ = json.Unmarshal([]byte(cache.CustomFields), &current)
+	}
+	vat, _ := i.vatRule(src.VatID)
+	var out []db.WooPriceField
+	for _, r := range i.priceRules() {
+		if r.Target == priceTargetRegular {
+			continue
+		}
+		field := db.WooPriceField{Code: r.Target, Desired: r.apply(r.sourcePrice(src), vat, i.cfg.PriceMode)}
+		if r.Target == "hurt_price" {
+			field.Current = cache.HurtPrice
+		} else {
+			field.Current, _ = strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(current[r.Target]), ",", "."), 64)
+		}
+		out = append(out, field)
+	}
+	return out
+}
+
+// priceFieldsMatch: wszystkie custom fields mają już docelową cenę.
+func priceFieldsMatch(fields []db.WooPriceField) bool {
+	for _, f := range fields {
+		if !floatAlmostEqual(f.Current, f.Desired) {
+			return false
+		}
+	}
+	return true
+}
+
+// priceFieldKeys to części klucza taska dla custom fields ("kod=cena").
+func priceFieldKeys(fields []db.WooPriceField) []string {
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, f.Code+"="+normalizeFloatKey(f.Desired))
+	}
+	return out
+}
+
+// hurtField zwraca cenę hurtową z reguł (lustro w DesiredHurt / CurrentHurt payloadu).
+func hurtField(fields []db.WooPriceField) (current, desired float64) {
+	for _, f := range fields {
+		if f.Code == "hurt_price" {
+			return f.Current, f.Desired
+		}
+	}
+	return 0, 0
+}
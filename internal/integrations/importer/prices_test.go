@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestRoundPrice(t *testing.T) {
+	cases := []struct {
+		in    float64
+		round string
+		want  float64
+	}{
+		{12.344, "", 12.34},
+		{12.5, "int", 13},
+		{12.34, "0.99", 12.99},
+		{12.99, "0.99", 12.99},
+		{13.0, "0.99", 13.99},
+		{12.60, "0.49", 13.49},
+	}
+	for _, c := range cases {
+		if got := roundPrice(c.in, c.round); got != c.want {
+			t.Fatalf("roundPrice(%v, %q) = %v, want %v", c.in, c.round, got, c.want)
+		}
+	}
+}
+
+func TestNormalizePriceRulesRequiresRegularPrice(t *testing.T) {
+	if err := normalizePriceRules([]PriceRule{{Target: "hurt_price", Source: "cena_hurtowa"}}); err == nil {
+		t.Fatal("expected error without regular_price rule")
+	}
+	if err := normalizePriceRules([]PriceRule{{Target: "regular_price", Source: "cena_specjalna"}}); err == nil {
+		t.Fatal("expected error for unknown PCM price column")
+	}
+	if err := normalizePriceRules([]PriceRule{{Target: "regular_price", Source: "cena_detal", Round: "1.5"}}); err == nil {
+		t.Fatal("expected error for invalid round")
+	}
+	rules := []PriceRule{{Target: " regular_price ", Source: "CENA_NOCNA", PriceMode: "NET"}}
+	if err := normalizePriceRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	if rules[0].Target != "regular_price" || rules[0].Source != "cena_nocna" || rules[0].PriceMode != priceModeNet {
+		t.Fatalf("expected normalized rule, got %+v", rules[0])
+	}
+}
+
+func TestPlanWooTasksAppliesPriceRules(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{Prices: []PriceRule{
+		{Target: "regular_price", Source: "cena_detal", MarkupPct: 10, Round: "0.99"},
+		{Target: "night_price", Source: "cena_nocna", PriceMode: priceModeNet},
+	}}
+	if err := normalizePriceRules(cfg.Prices); err != nil {
+		t.Fatal(err)
+	}
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	const importID = 41
+	towarID := int64(141)
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_price_rules.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{
+		ImportID:    importID,
+		TowarID:     towarID,
+		Kod:         "5901234567895",
+		Nazwa:       "Price Rules Product",
+		VatID:       2300,
+		CenaDetal:   100,
+		CenaHurtowa: 80,
+		CenaNocna:   123,
+		AktywnyWSI:  true,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooProductCache{
+		WooID:        241,
+		TowarID:      &towarID,
+		Kod:          "SKU-RULES",
+		Ean:          "5901234567895",
+		Name:         "Price Rules Product",
+		PriceRegular: 110.99,
+		HurtPrice:    80,
+		CustomFields: `{"night_price":"90"}`,
+		TaxClass:     "2300",
+		StockManaged: true,
+		Backorders:   "notify",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := mustPriceTaskPayload(t, gdb)
+	if payload.DesiredRegular != 110.99 {
+		t.Fatalf("expected regular 100 +10%% rounded to 110.99, got %v", payload.DesiredRegular)
+	}
+	if !payload.PriceRules || len(payload.Fields) != 1 {
+		t.Fatalf("expected only night_price field, got %+v", payload.Fields)
+	}
+	if f := payload.Fields[0]; f.Code != "night_price" || f.Current != 90 || f.Desired != 100 {
+		t.Fatalf("expected night_price 90 → 100 (net), got %+v", f)
+	}
+	if payload.DesiredHurt != 0 {
+		t.Fatalf("hurt_price is not in rules, expected no hurt mirror, got %v", payload.DesiredHurt)
+	}
+}
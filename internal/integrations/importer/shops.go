@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// Jeden feed PCM może zasilać kilka sklepów Woo — nazwane instancje integracji "woocommerce:<shop>".
+// Każda instancja ma własne wiersze w woo_product_caches i własną partycję kolejki (woo_tasks.shop),
+// więc linker i planner przechodzą po sklepach obecnych w cache. Sklep "" to instancja "woocommerce".
+// Sekcja shops importera ustala reguły cen sklepu; sklep spoza shops dostaje ceny jak domyślny.
+
+// ShopConfig opisuje reguły cen jednego sklepu Woo.
+type ShopConfig struct {
+	ID         string      `json:"id"`                    // nazwa instancji z klucza "woocommerce:<id>"
+	PriceField string      `json:"price_field,omitempty"` // kolumna PCM dla regular_price (np. cena_hurtowa); puste = wg reguł
+	PriceMode  string      `json:"price_mode,omitempty"`  // gross / net; puste = jak źródło importu
+	Prices     []PriceRule `json:"prices,omitempty"`      // własne reguły cen sklepu (zamiast importer prices)
+	Vat        []VatRule   `json:"vat,omitempty"`         // własne mapowanie vat_id → stawka / tax_class (zamiast importer vat)
+}
+
+// normalizeShops sprawdza sekcję shops i uzupełnia domyślne wartości.
+func (c *Config) normalizeShops() error {
+	seen := make(map[string]struct{}, len(c.Shops))
+	for idx := range c.Shops {
+		shop := &c.Shops[idx]
+		shop.ID = strings.TrimSpace(shop.ID)
+		if shop.ID == "" {
+			return fmt.Errorf("importer shops[%d]: brak id", idx)
+		}
+		if strings.ContainsAny(shop.ID, "/: ") {
+			return fmt.Errorf("importer shops[%d]: id %q nie może zawierać '/', ':' ani spacji", idx, shop.ID)
+		}
+		if _, ok := seen[shop.ID]; ok {
+			return fmt.Errorf("importer shops: zdublowane id %q", shop.ID)
+		}
+		seen[shop.ID] = struct{}{}
+
+		var err error
+		if shop.PriceField = strings.ToLower(strings.TrimSpace(shop.PriceField)); shop.PriceField != "" {
+			if err := validPriceSource(shop.PriceField); err != nil {
+				return fmt.Errorf("importer shops[%s]: price_field: %w", shop.ID, err)
+			}
+		}
+		if err := normalizePriceRules(shop.Prices); err != nil {
+			return fmt.Errorf("importer shops[%s]: %w", shop.ID, err)
+		}
+		if err := normalizeVatRules(shop.Vat); err != nil {
+			return fmt.Errorf("importer shops[%s]: %w", shop.ID, err)
+		}
+		if strings.TrimSpace(shop.PriceMode) != "" {
+			if shop.PriceMode, err = normalizePriceMode(shop.PriceMode); err != nil {
+				return fmt.Errorf("importer shops[%s]: %w", shop.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// shopConfig zwraca konfigurację sklepu; sklep bez wpisu w shops liczy ceny jak sklep domyślny.
+func (c Config) shopConfig(id string) ShopConfig {
+	for _, shop := range c.Shops {
+		if shop.ID == id {
+			return shop
+		}
+	}
+	return ShopConfig{ID: id}
+}
+
+// forShop zwraca kopię importera planującą taski sklepu shop (cache, klucze tasków, reguły cen).
+// priceMode to tryb cen źródła importu; price_mode sklepu ma pierwszeństwo.
+func (i *Importer) forShop(id, priceMode string) *Importer {
+	shop := i.cfg.shopConfig(id)
+	scoped := &Importer{log: i.log, cfg: i.cfg, db: i.db, shop: shop}
+	scoped.cfg.PriceMode = priceMode
+	if shop.PriceMode != "" {
+		scoped.cfg.PriceMode = shop.PriceMode
+	}
+	return scoped
+}
+
+// ofShop zawęża zapytanie do wierszy (cache, taski) sklepu, dla którego planuje importer.
+func (i *Importer) ofShop(tx *gorm.DB) *gorm.DB {
+	return tx.Where("shop = ?", i.shop.ID)
+}
+
+// loadPlannerShops zwraca sklepy obecne w cache. Bez cache planujemy dla sklepu domyślnego —
+// wtedy wszystkie towary są niepowiązane i nic nie trafia do kolejki.
+func loadPlannerShops(tx *gorm.DB) ([]string, error) {
+	var shops []string
+	if err := tx.Model(&db.WooProductCache{}).Distinct("shop").Order("shop").Pluck("shop", &shops).Error; err != nil {
+		return nil, fmt.Errorf("błąd odczytu sklepów z woo_product_caches: %w", err)
+	}
+	if len(shops) == 0 {
+		shops = []string{""}
+	}
+	return shops, nil
+}
+
+// taskKey buduje klucz taska w partycji sklepu: klucze sklepu domyślnego zostają bez prefiksu.
+func (i *Importer) taskKey(kind string, wooID uint, parts ...string) string {
+	key := buildTaskKey(kind, wooID, parts...)
+	if i.shop.ID == "" {
+		return key
+	}
+	return i.shop.ID + "/" + key
+}
@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestPlanWooTasksFansOutToShops(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{Shops: []ShopConfig{{ID: "b2b", PriceField: "cena_hurtowa"}}}
+	if err := cfg.normalizeShops(); err != nil {
+		t.Fatal(err)
+	}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	const importID = 31
+	const ean = "5901234567894"
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_shops.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{
+		ImportID:    importID,
+		TowarID:     77,
+		Kod:         ean,
+		Nazwa:       "Shared product",
+		VatID:       2300,
+		CenaDetal:   120,
+		CenaHurtowa: 90,
+		AktywnyWSI:  true,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// ten sam woo_id w dwóch sklepach to dwa różne produkty
+	for _, shop := range []string{"", "b2b"} {
+		if err := gdb.Create(&db.WooProductCache{
+			Shop:         shop,
+			WooID:        500,
+			Kod:          "SKU-" + shop,
+			Ean:          ean,
+			Name:         "Shared product",
+			PriceRegular: 1,
+			StockManaged: true,
+			Backorders:   "notify",
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := imp.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+	var linked int64
+	mustCount(t, gdb.Model(&db.WooProductCache{}).Where("towar_id = ?", 77), &linked)
+	if linked != 2 {
+		t.Fatalf("expected product linked in both shops, got %d", linked)
+	}
+
+	if err := imp.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []db.WooTask
+	if err := gdb.Where("kind = ?", db.WooTaskKindPriceUpdate).Order("shop").Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected one price task per shop, got %d", len(tasks))
+	}
+	want := map[string]float64{"": 120, "b2b": 90}
+	for _, task := range tasks {
+		var payload db.WooPriceUpdatePayload
+		if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.DesiredRegular != want[task.Shop] {
+			t.Fatalf("shop %q: expected regular %v, got %v", task.Shop, want[task.Shop], payload.DesiredRegular)
+		}
+	}
+	if !strings.HasPrefix(tasks[0].TaskKey, db.WooTaskKindPriceUpdate+":") || !strings.HasPrefix(tasks[1].TaskKey, "b2b/"+db.WooTaskKindPriceUpdate+":") {
+		t.Fatalf("expected b2b task key to be scoped, got %q and %q", tasks[0].TaskKey, tasks[1].TaskKey)
+	}
+}
+
+func TestLinkNamedShopUsesOwnManualLinksAndFallbacks(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{
+		Shops:   []ShopConfig{{ID: "b2b"}},
+		Linking: LinkingConfig{Strategies: []string{"sku", "name"}},
+	}
+	if err := cfg.normalizeShops(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Linking.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	for _, p := range []db.StProduct{
+		{ImportID: 1, TowarID: 1, Kod: "", Nazwa: "Karton zbiorczy"},
+		{ImportID: 1, TowarID: 2, Kod: "ABC-2", Nazwa: "Po SKU"},
+		{ImportID: 1, TowarID: 3, Kod: "", Nazwa: "Zonin Prosecco Extra Dry"},
+	} {
+		if err := gdb.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []db.WooProductCache{
+		{Shop: "", WooID: 100, Name: "Opakowanie"},
+		{Shop: "b2b", WooID: 100, Name: "Opakowanie"},
+		{Shop: "b2b", WooID: 101, Kod: "abc-2", Name: "Produkt hurtowy"},
+		{Shop: "b2b", WooID: 102, Name: "Prosecco Zonin Extra Dry"},
+	} {
+		if err := gdb.Create(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ten sam woo_id przypięty w dwóch sklepach do różnych towarów
+	if _, err := db.PinManualLink(gdb, "b2b", 1, 100, "karton w B2B"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PinManualLink(gdb, "", 2, 100, ""); err != nil {
+		t.Fatalf("pin in default shop must not collide with b2b pin: %v", err)
+	}
+	if err := imp.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[[2]any]*int64{
+		{"", uint(100)}:    ptrInt64(2),
+		{"b2b", uint(100)}: ptrInt64(1),
+		{"b2b", uint(101)}: ptrInt64(2),
+		{"b2b", uint(102)}: nil,
+	}
+	for key, towarID := range want {
+		var c db.WooProductCache
+		if err := gdb.Where("shop = ? AND woo_id = ?", key[0], key[1]).Take(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+		if (towarID == nil) != (c.TowarID == nil) || (towarID != nil && *towarID != *c.TowarID) {
+			t.Fatalf("shop %q woo_id %d: unexpected towar_id %v", key[0], key[1], c.TowarID)
+		}
+	}
+
+	var proposals []db.LinkProposal
+	if err := gdb.Where("shop = ? AND status = ?", "b2b", "pending").Find(&proposals).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 1 || proposals[0].TowarID != 3 || proposals[0].WooID != 102 {
+		t.Fatalf("expected b2b name proposal, got %+v", proposals)
+	}
+	if _, err := db.ApproveLinkProposal(gdb, "", proposals[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("proposal of another shop must not be approved, got %v", err)
+	}
+	if _, err := db.ApproveLinkProposal(gdb, "b2b", proposals[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := imp.LinkProductsByEAN(); err != nil {
+		t.Fatal(err)
+	}
+	var approved db.WooProductCache
+	if err := gdb.Where("shop = ? AND woo_id = ?", "b2b", 102).Take(&approved).Error; err != nil {
+		t.Fatal(err)
+	}
+	if approved.TowarID == nil || *approved.TowarID != 3 {
+		t.Fatalf("approved b2b proposal should pin in b2b, got %v", approved.TowarID)
+	}
+}
+
+func TestNormalizeShopsRejectsUnknownPriceField(t *testing.T) {
+	cfg := Config{Shops: []ShopConfig{{ID: "b2b", PriceField: "cena_specjalna"}}}
+	if err := cfg.normalizeShops(); err == nil {
+		t.Fatal("expected error for unsupported price_field")
+	}
+	cfg = Config{Shops: []ShopConfig{{ID: "b2b"}, {ID: "b2b"}}}
+	if err := cfg.normalizeShops(); err == nil {
+		t.Fatal("expected error for duplicated shop id")
+	}
+}
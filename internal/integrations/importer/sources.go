@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Kilka instalacji PC-Market (sklepów) może zasilać jeden sklep Woo. Każde źródło ma własny katalog,
+// interwał odpytywania, tryb cen i wybór magazynów; import_files i st_stocks są oznaczane source_id.
+// Główne watch_dir / poll_sec / price_mode / warehouses to źródło domyślne o ID "".
+//
+// Założenie: źródła dzielą kartotekę — ten sam towar_id oznacza ten sam towar we wszystkich sklepach.
+// st_products jest wspólne, więc dane towaru i ceny pochodzą z ostatniego importu (z dowolnego źródła);
+// rozdzielone są tylko stany magazynowe, łączone przez planner wg stock_combine.
+
+// Sposoby łączenia stanu z kilku źródeł (stock_combine).
+const (
+	stockCombineSum = "sum" // suma stanów źródeł (domyślnie)
+	stockCombineMax = "max" // największy stan spośród źródeł
+	stockCombineMin = "min" // najmniejszy stan spośród źródeł, które mają towar
+)
+
+// SourceConfig opisuje jedno źródło eksportów PCM.
+type SourceConfig struct {
+	ID         string          `json:"id"`                   // np. "sklep2" — trafia do import_files.source_id i st_stocks.source_id
+	WatchDir   string          `json:"watch_dir"`            // katalog eksportów tego źródła
+	PollSec    int             `json:"poll_sec,omitempty"`   // 0 = jak główne poll_sec
+	PriceMode  string          `json:"price_mode,omitempty"` // gross / net; puste = jak główne price_mode
+	Warehouses WarehouseConfig `json:"warehouses,omitempty"` // magazyny tego źródła (puste = wszystkie)
+}
+
+// watchSource to katalog obserwowany przez importer (źródło domyślne albo jedno z sources).
+type watchSource struct {
+	id     string
+	dir    string
+	poll   time.Duration
+	stable *stableTracker // nil = bez sprawdzania stabilności (scanOnce w testach)
+}
+
+func normalizeStockCombine(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", stockCombineSum:
+		return stockCombineSum, nil
+	case stockCombineMax:
+		return stockCombineMax, nil
+	case stockCombineMin:
+		return stockCombineMin, nil
+	default:
+		return "", fmt.Errorf("importer: nieznany stock_combine %q (dozwolone: sum, max, min)", mode)
+	}
+}
+
+// normalizeSources sprawdza sources i uzupełnia domyślne wartości. Wołane po normalizacji price_mode.
+func (c *Config) normalizeSources() error {
+	mode, err := normalizeStockCombine(c.StockCombine)
+	if err != nil {
+		return err
+	}
+	c.StockCombine = mode
+
+	seen := make(map[string]struct{}, len(c.Sources))
+	for idx := range c.Sources {
+		src := &c.Sources[idx]
+		src.ID = strings.TrimSpace(src.ID)
+		if src.ID == "" {
+			return fmt.Errorf("importer sources[%d]: brak id", idx)
+		}
+		// id jest prefiksem nazw plików ("<id>/<plik>") i transmisja_id ("<id>:<transmisja>")
+		if strings.ContainsAny(src.ID, "/:") {
+			return fmt.Errorf("importer sources[%d]: id %q nie może zawierać '/' ani ':'", idx, src.ID)
+		}
+		if _, ok := seen[src.ID]; ok {
+			return fmt.Errorf("importer sources: zdublowane id %q", src.ID)
+		}
+		seen[src.ID] = struct{}{}
+		if strings.TrimSpace(src.WatchDir) == "" {
+			return fmt.Errorf("importer sources[%s]: brak watch_dir", src.ID)
+		}
+		if strings.TrimSpace(src.PriceMode) == "" {
+			src.PriceMode = c.PriceMode
+		}
+		if src.PriceMode, err = normalizePriceMode(src.PriceMode); err != nil {
+			return fmt.Errorf("importer sources[%s]: %w", src.ID, err)
+		}
+		if err := src.Warehouses.validate(); err != nil {
+			return fmt.Errorf("importer sources[%s]: %w", src.ID, err)
+		}
+	}
+	return nil
+}
+
+// defaultSourceActive: źródło "" działa, gdy ustawiono główny watch_dir albo nie ma sources (stary układ).
+func (c Config) defaultSourceActive() bool {
+	return strings.TrimSpace(c.WatchDir) != "" || len(c.Sources) == 0
+}
+
+// sourcePriceMode zwraca price_mode źródła importu; źródło spoza konfiguracji liczy jak główne price_mode.
+func (c Config) sourcePriceMode(id string) string {
+	if src, ok := c.source(id); ok && src.PriceMode != "" {
+		return src.PriceMode
+	}
+	return c.PriceMode
+}
+
+// source zwraca konfigurację źródła. Źródło "" składa się z głównych pól konfiguracji.
+// ok=false dla źródeł usuniętych z konfiguracji — ich stany planner pomija.
+func (c Config) source(id string) (SourceConfig, bool) {
+	if id == "" {
+		return SourceConfig{
+			WatchDir:   c.WatchDir,
+			PollSec:    c.PollSec,
+			PriceMode:  c.PriceMode,
+			Warehouses: c.Warehouses,
+		}, c.defaultSourceActive()
+	}
+	for _, src := range c.Sources {
+		if src.ID == id {
+			return src, true
+		}
+	}
+	return SourceConfig{}, false
+}
+
+// watchSources buduje listę obserwowanych katalogów: źródło domyślne (jeśli aktywne) i wszystkie sources.
+func (i *Importer) watchSources() []*watchSource {
+	out := make([]*watchSource, 0, len(i.cfg.Sources)+1)
+	if i.cfg.defaultSourceActive() {
+		out = append(out, &watchSource{dir: expandHome(i.cfg.WatchDir), poll: i.interval()})
+	}
+	for _, src := range i.cfg.Sources {
+		poll := i.interval()
+		if src.PollSec > 0 {
+			poll = time.Duration(src.PollSec) * time.Second
+		}
+		out = append(out, &watchSource{id: src.ID, dir: expandHome(src.WatchDir), poll: poll})
+	}
+	for _, ws := range out {
+		ws.stable = newStableTracker(i.cfg)
+	}
+	return out
+}
+
+// scopeToSource poprzedza nazwę pliku / transmisja_id identyfikatorem źródła, żeby deduplikacja
+// w import_files nie myliła plików o tej samej nazwie z dwóch sklepów. Źródło "" zostawia wartość bez zmian.
+func scopeToSource(sourceID, sep, value string) string {
+	if sourceID == "" || value == "" {
+		return value
+	}
+	return sourceID + sep + value
+}
+
+// sourceStock to stan jednego towaru w jednym źródle (po wyborze magazynów).
+type sourceStock struct {
+	total, reserved      float64
+	desired, desiredPrev float64
+	hasPrev              bool
+}
+
+// combineSourceStocks łączy stany źródeł wg stock_combine. TotalStock / TotalReserved to zawsze sumy
+// (informacyjnie); DesiredStockPrev jest nil, gdy któreś źródło nie ma historii stanu.
+func combineSourceStocks(mode string, stocks []sourceStock) (total, reserved, desired float64, desiredPrev *float64) {
+	if len(stocks) == 0 {
+		return 0, 0, 0, nil
+	}
+	hasPrev := true
+	var prev float64
+	for idx, s := range stocks {
+		total += s.total
+		reserved += s.reserved
+		hasPrev = hasPrev && s.hasPrev
+		if idx == 0 {
+			desired, prev = s.desired, s.desiredPrev
+			continue
+		}
+		switch mode {
+		case stockCombineMax:
+			desired, prev = math.Max(desired, s.desired), math.Max(prev, s.desiredPrev)
+		case stockCombineMin:
+			desired, prev = math.Min(desired, s.desired), math.Min(prev, s.desiredPrev)
+		default:
+			desired, prev = desired+s.desired, prev+s.desiredPrev
+		}
+	}
+	if hasPrev {
+		desiredPrev = &prev
+	}
+	return total, reserved, desired, desiredPrev
+}
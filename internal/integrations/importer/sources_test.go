@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestScanSourceTagsImportsAndStocksWithSourceID(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	mainDir, shopDir := t.TempDir(), t.TempDir()
+	cfg := Config{PriceMode: "gross", WatchDir: mainDir, Sources: []SourceConfig{{ID: "sklep2", WatchDir: shopDir}}}
+	if err := cfg.normalizeSources(); err != nil {
+		t.Fatal(err)
+	}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	// obie instalacje PCM eksportują plik o tej samej nazwie i transmisja_id
+	const name = "exp_wyk_1_20260101120000.xml"
+	export := func(stan string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>T1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Produkt</nazwa><vat_id>2300</vat_id>
+<cena_detal>10</cena_detal><aktywny_w_SI>Y</aktywny_w_SI><do_usuniecia>N</do_usuniecia>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>` + stan + `</stan_magazynu><rezerwacja_ilosci>0</rezerwacja_ilosci></magazyn></magazyny>
+</towar></towary></wykaz>`
+	}
+	writeExportFile(t, mainDir, name, export("5"))
+	writeExportFile(t, shopDir, name, export("3"))
+
+	for _, src := range imp.watchSources() {
+		src.stable = nil
+		imp.scanSource(src)
+	}
+
+	mainImport := mustImportFile(t, gdb, name)
+	shopImport := mustImportFile(t, gdb, "sklep2/"+name)
+	if mainImport.Status != 1 || mainImport.SourceID != "" || mainImport.TransmisjaID != "T1" {
+		t.Fatalf("unexpected main import %+v", mainImport)
+	}
+	if shopImport.Status != 1 || shopImport.SourceID != "sklep2" || shopImport.TransmisjaID != "sklep2:T1" {
+		t.Fatalf("unexpected source import %+v", shopImport)
+	}
+
+	var stocks []db.StStock
+	if err := gdb.Order("source_id").Find(&stocks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stocks) != 2 || stocks[0].SourceID != "" || stocks[0].Stan != 5 || stocks[1].SourceID != "sklep2" || stocks[1].Stan != 3 {
+		t.Fatalf("expected one stock row per source for the same magazyn_id, got %+v", stocks)
+	}
+}
+
+func TestScanSourcesImportIdenticalFileFromEachSource(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	mainDir, shopDir := t.TempDir(), t.TempDir()
+	cfg := Config{PriceMode: "gross", WatchDir: mainDir, Sources: []SourceConfig{{ID: "sklep2", WatchDir: shopDir}}}
+	if err := cfg.normalizeSources(); err != nil {
+		t.Fatal(err)
+	}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	// ten sam bajt w bajt plik (ten sam SHA) w dwóch źródłach to dwa importy
+	const name = "exp_wyk_1_20260101120000.xml"
+	const export = `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>T1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Produkt</nazwa><vat_id>2300</vat_id>
+<cena_detal>10</cena_detal><aktywny_w_SI>Y</aktywny_w_SI><do_usuniecia>N</do_usuniecia>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>5</stan_magazynu><rezerwacja_ilosci>0</rezerwacja_ilosci></magazyn></magazyny>
+</towar></towary></wykaz>`
+	writeExportFile(t, mainDir, name, export)
+	writeExportFile(t, shopDir, name, export)
+
+	for _, src := range imp.watchSources() {
+		src.stable = nil
+		imp.scanSource(src)
+	}
+
+	mainImport := mustImportFile(t, gdb, name)
+	shopImport := mustImportFile(t, gdb, "sklep2/"+name)
+	if mainImport.SHA256 != shopImport.SHA256 || mainImport.ImportID == shopImport.ImportID || shopImport.Status != 1 {
+		t.Fatalf("expected separate imports per source, got %+v and %+v", mainImport, shopImport)
+	}
+	var stocks int64
+	mustCount(t, gdb.Model(&db.StStock{}), &stocks)
+	if stocks != 2 {
+		t.Fatalf("expected stock row from each source, got %d", stocks)
+	}
+}
+
+func TestPlanWooTasksCombinesStockAcrossSources(t *testing.T) {
+	for _, tc := range []struct {
+		combine string
+		want    float64
+	}{
+		{combine: "", want: 8},
+		{combine: "max", want: 5},
+		{combine: "min", want: 3},
+	} {
+		t.Run("combine_"+tc.combine, func(t *testing.T) {
+			gdb := newImporterTestDB(t)
+			cfg := Config{
+				PriceMode:    "gross",
+				WatchDir:     "/srv/pcm/main",
+				StockCombine: tc.combine,
+				Sources: []SourceConfig{{
+					ID:         "sklep2",
+					WatchDir:   "/srv/pcm/sklep2",
+					Warehouses: WarehouseConfig{Exclude: []int64{9}},
+				}},
+			}
+			if err := cfg.normalizeSources(); err != nil {
+				t.Fatal(err)
+			}
+			imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+			const importID = 30
+			if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "sklep2/exp_stn_1.xml", SourceID: "sklep2", Status: 1}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 900, Kod: "5900000000900", Nazwa: "Two shops", CenaDetal: 10}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := gdb.Create([]db.StStock{
+				{ImportID: importID, TowarID: 900, MagazynID: 1, Stan: 6, Rezerwacja: 1},           // główny: 5
+				{ImportID: importID, TowarID: 900, MagazynID: 1, SourceID: "sklep2", Stan: 3},      // sklep2: 3
+				{ImportID: importID, TowarID: 900, MagazynID: 9, SourceID: "sklep2", Stan: 50},     // zaplecze sklep2 — pominięte
+				{ImportID: importID, TowarID: 900, MagazynID: 1, SourceID: "zamkniety", Stan: 100}, // źródło spoza konfiguracji
+			}).Error; err != nil {
+				t.Fatal(err)
+			}
+			towarID := int64(900)
+			if err := gdb.Create(&db.WooProductCache{WooID: 90, TowarID: &towarID, Ean: "5900000000900", StockQty: 1, StockManaged: true, Backorders: "notify", PriceRegular: 10}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := imp.PlanWooTasks(importID); err != nil {
+				t.Fatal(err)
+			}
+
+			var task db.WooTask
+			if err := gdb.Where("kind = ?", db.WooTaskKindStockUpdate).Take(&task).Error; err != nil {
+				t.Fatal(err)
+			}
+			var payload db.WooStockUpdatePayload
+			if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.DesiredStock != tc.want || payload.SourceStock != 9 || payload.SourceReserve != 1 {
+				t.Fatalf("stock_combine=%q: unexpected payload %+v", tc.combine, payload)
+			}
+		})
+	}
+}
+
+func TestPlanWooTasksUsesSourcePriceMode(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{PriceMode: "gross", Sources: []SourceConfig{{ID: "netto", WatchDir: "/srv/pcm/netto", PriceMode: "net"}}}
+	if err := cfg.normalizeSources(); err != nil {
+		t.Fatal(err)
+	}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	const importID = 31
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "netto/exp_wyk_1.xml", SourceID: "netto", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.StProduct{ImportID: importID, TowarID: 910, Kod: "5900000000910", Nazwa: "Net shop", VatID: 2300, CenaDetal: 123}).Error; err != nil {
+		t.Fatal(err)
+	}
+	towarID := int64(910)
+	if err := gdb.Create(&db.WooProductCache{WooID: 91, TowarID: &towarID, Ean: "5900000000910", PriceRegular: 123, Backorders: "notify"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := imp.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+	if payload := mustPriceTaskPayload(t, gdb); payload.DesiredRegular != 100 {
+		t.Fatalf("expected net price from source price_mode, got %v", payload.DesiredRegular)
+	}
+}
+
+func TestConfigNormalizeSources(t *testing.T) {
+	for _, cfg := range []Config{
+		{Sources: []SourceConfig{{WatchDir: "/a"}}},
+		{Sources: []SourceConfig{{ID: "a/b", WatchDir: "/a"}}},
+		{Sources: []SourceConfig{{ID: "a", WatchDir: "/a"}, {ID: "a", WatchDir: "/b"}}},
+		{Sources: []SourceConfig{{ID: "a"}}},
+		{Sources: []SourceConfig{{ID: "a", WatchDir: "/a", PriceMode: "vat"}}},
+		{StockCombine: "avg"},
+	} {
+		if err := cfg.normalizeSources(); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+
+	cfg := Config{PriceMode: "net", Sources: []SourceConfig{{ID: " b ", WatchDir: "/b"}}}
+	if err := cfg.normalizeSources(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Sources[0].ID != "b" || cfg.Sources[0].PriceMode != "net" || cfg.StockCombine != "sum" {
+		t.Fatalf("unexpected normalized config %+v", cfg)
+	}
+	if cfg.defaultSourceActive() {
+		t.Fatal("default source must be inactive without watch_dir when sources are configured")
+	}
+}
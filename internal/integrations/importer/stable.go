@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PC-Market zapisuje eksport kilka sekund (duże wykazy, udział sieciowy). Plik złapany w trakcie zapisu
+// nie parsuje się i kończy jako status 2, więc przed registerFile sprawdzamy, czy plik jest "stabilny":
+//   - obok leży znacznik <plik>.done — stabilny od razu (przy done_marker=true to jedyna droga),
+//   - albo rozmiar i mtime nie zmieniły się od stable_sec sekund.
+
+const defaultStableSec = 5
+
+const doneMarkerSuffix = ".done"
+
+type fileObservation struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // od kiedy rozmiar / mtime są bez zmian
+}
+
+type stableTracker struct {
+	window     time.Duration
+	markerOnly bool
+	now        func() time.Time
+	seen       map[string]fileObservation
+	waiting    int // pliki odłożone w ostatnim skanie
+}
+
+func newStableTracker(cfg Config) *stableTracker {
+	sec := cfg.StableSec
+	if sec <= 0 {
+		sec = defaultStableSec
+	}
+	return &stableTracker{
+		window:     time.Duration(sec) * time.Second,
+		markerOnly: cfg.DoneMarker,
+		now:        time.Now,
+		seen:       make(map[string]fileObservation),
+	}
+}
+
+// beginScan zeruje licznik odłożonych plików przed kolejnym skanem.
+func (t *stableTracker) beginScan() {
+	if t != nil {
+		t.waiting = 0
+	}
+}
+
+// ready mówi, czy plik można już hashować i przetwarzać. Tracker nil (testy wołające scanOnce
+// bezpośrednio) przepuszcza wszystko.
+func (t *stableTracker) ready(dir, name string) bool {
+	if t == nil {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, name+doneMarkerSuffix)); err == nil {
+		delete(t.seen, name)
+		return true
+	}
+	if t.markerOnly {
+		t.waiting++
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		delete(t.seen, name)
+		return false
+	}
+	now := t.now()
+	prev, ok := t.seen[name]
+	if !ok || prev.size != info.Size() || !prev.modTime.Equal(info.ModTime()) {
+		since := now
+		if !ok && now.Sub(info.ModTime()) >= t.window {
+			// plik leżał w katalogu już przed startem / od dawna — nie czekamy drugi raz
+			since = info.ModTime()
+		}
+		prev = fileObservation{size: info.Size(), modTime: info.ModTime(), since: since}
+		t.seen[name] = prev
+	}
+	if now.Sub(prev.since) >= t.window {
+		delete(t.seen, name)
+		return true
+	}
+	t.waiting++
+	return false
+}
+
+// forget usuwa z pamięci pliki, których już nie ma w katalogu.
+func (t *stableTracker) forget(present map[string]struct{}) {
+	if t == nil {
+		return
+	}
+	for name := range t.seen {
+		if _, ok := present[name]; !ok {
+			delete(t.seen, name)
+		}
+	}
+}
+
+// pending zwraca liczbę plików odłożonych w ostatnim skanie (do ponownego sprawdzenia po oknie).
+func (t *stableTracker) pending() int {
+	if t == nil {
+		return 0
+	}
+	return t.waiting
+}
+
+// removeDoneMarker sprząta znacznik po przeniesieniu pliku do parsed/.
+func removeDoneMarker(dir, name string) {
+	_ = os.Remove(filepath.Join(dir, name+doneMarkerSuffix))
+}
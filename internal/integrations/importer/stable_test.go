@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestStableTrackerWaitsForUnchangedSizeAndMtime(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	tr := newStableTracker(Config{StableSec: 5})
+	tr.now = func() time.Time { return now }
+
+	path := filepath.Join(dir, "exp_wyk_1.xml")
+	if err := os.WriteFile(path, []byte("<wykaz>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.beginScan()
+	if tr.ready(dir, "exp_wyk_1.xml") || tr.pending() != 1 {
+		t.Fatal("freshly written file must wait for the stable window")
+	}
+
+	// PCM dopisuje dalej — okno liczy się od nowa
+	now = now.Add(4 * time.Second)
+	if err := os.WriteFile(path, []byte("<wykaz><towary>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, now, now); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Second)
+	if tr.ready(dir, "exp_wyk_1.xml") {
+		t.Fatal("file changed since last scan must not be ready")
+	}
+
+	now = now.Add(5 * time.Second)
+	tr.beginScan()
+	if !tr.ready(dir, "exp_wyk_1.xml") || tr.pending() != 0 {
+		t.Fatal("file unchanged for the whole window must be ready")
+	}
+
+	old := filepath.Join(dir, "exp_wyk_2.xml")
+	if err := os.WriteFile(old, []byte("<wykaz/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(old, now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !tr.ready(dir, "exp_wyk_2.xml") {
+		t.Fatal("file untouched for longer than the window must be ready on first sight")
+	}
+}
+
+func TestScanOnceWithDoneMarkerWaitsForMarker(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	cfg := Config{PriceMode: "gross", DoneMarker: true}
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg, stable: newStableTracker(cfg)}
+
+	name := "exp_wyk_1_20260101120000.xml"
+	writeExportFile(t, watchDir, name, `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>T1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Produkt</nazwa><vat_id>2300</vat_id>
+<cena_detal>10</cena_detal><aktywny_w_SI>Y</aktywny_w_SI><do_usuniecia>N</do_usuniecia>
+</towar></towary></wykaz>`)
+
+	imp.scanOnce(watchDir)
+	var count int64
+	if err := gdb.Model(&db.ImportFile{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || imp.stable.pending() != 1 {
+		t.Fatalf("file without .done marker must not be registered (imports=%d pending=%d)", count, imp.stable.pending())
+	}
+
+	if err := os.WriteFile(filepath.Join(watchDir, name+".done"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	imp.scanOnce(watchDir)
+
+	rec := mustImportFile(t, gdb, name)
+	if rec.Status != 1 {
+		t.Fatalf("expected processed import, got status=%d error=%q", rec.Status, rec.LastError)
+	}
+	if _, err := os.Stat(filepath.Join(watchDir, name+".done")); !os.IsNotExist(err) {
+		t.Fatalf("marker must be removed after archiving, stat err=%v", err)
+	}
+}
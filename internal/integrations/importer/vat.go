@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// Mapowanie stawek VAT (importer vat) przypisuje vat_id z PC-Market stawkę (przeliczenie netto)
+// i klasę podatkową Woo (tax_class). Towar z vat_id spoza mapowania nie dostaje tasków cen ani
+// tworzenia — trafia do link_issues (unknown_vat_id), zamiast po cichu liczyć się jako 23%.
+// Integracja woocommerce przy starcie zapisuje w kvs klasy z /taxes/classes; klasa spoza tej listy
+// też blokuje ceny towaru (unknown_tax_class).
+
+// taxClassStandard to slug klasy standardowej; w produkcie Woo zapisywana jako pusty tax_class.
+const taxClassStandard = "standard"
+
+// VatRule opisuje jedną stawkę VAT PC-Market.
+type VatRule struct {
+	VatID    int64   `json:"vat_id"`    // vat_id z eksportu PCM (np. 2300, -1 = ZW)
+	Rate     float64 `json:"rate"`      // stawka w % (np. 23)
+	TaxClass string  `json:"tax_class"` // slug klasy podatkowej Woo; "" = standard
+}
+
+// defaultVatRules odtwarza mapowanie sprzed konfiguracji vat.
+func defaultVatRules() []VatRule {
+	return []VatRule{
+		{VatID: 2300, Rate: 23, TaxClass: "2300"},
+		{VatID: 800, Rate: 8, TaxClass: "800"},
+		{VatID: 500, Rate: 5, TaxClass: "500"},
+		{VatID: 0, Rate: 0, TaxClass: "zero-rate"},
+		{VatID: -1, Rate: 0, TaxClass: "zero-rate"}, // ZW
+	}
+}
+
+// normalizeVatRules sprawdza tabelę vat: vat_id bez duplikatów, stawka 0–100.
+// Pusta lista jest poprawna (mapowanie domyślne).
+func normalizeVatRules(rules []VatRule) error {
+	seen := make(map[int64]struct{}, len(rules))
+	for idx := range rules {
+		r := &rules[idx]
+		if _, ok := seen[r.VatID]; ok {
+			return fmt.Errorf("vat: zdublowany vat_id %d", r.VatID)
+		}
+		seen[r.VatID] = struct{}{}
+		if r.Rate < 0 || r.Rate > 100 {
+			return fmt.Errorf("vat[%d]: stawka %v poza zakresem 0–100", r.VatID, r.Rate)
+		}
+		r.TaxClass = strings.TrimSpace(r.TaxClass)
+		if r.TaxClass == taxClassStandard {
+			r.TaxClass = ""
+		}
+	}
+	return nil
+}
+
+// vatRules zwraca mapowanie sklepu: shops[].vat, potem importer vat, potem domyślne.
+func (i *Importer) vatRules() []VatRule {
+	switch {
+	case len(i.shop.Vat) > 0:
+		return i.shop.Vat
+	case len(i.cfg.Vat) > 0:
+		return i.cfg.Vat
+	default:
+		return defaultVatRules()
+	}
+}
+
+// vatRule zwraca stawkę dla vat_id; false = vat_id spoza mapowania.
+func (i *Importer) vatRule(vatID int64) (VatRule, bool) {
+	for _, r := range i.vatRules() {
+		if r.VatID == vatID {
+			return r, true
+		}
+	}
+	return VatRule{}, false
+}
+
+// loadTaxClasses wczytuje z kvs klasy podatkowe sklepu zapisane przez integrację woocommerce.
+// Brak wpisu (Woo jeszcze nie wystartowało) wyłącza sprawdzanie klas.
+func (i *Importer) loadTaxClasses(tx *gorm.DB) error {
+	key := db.TaxClassesKVKey(i.shop.ID)
+	var row db.KV
+	switch err := tx.Where("k = ?", key).Take(&row).Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("błąd odczytu %s z kvs: %w", key, err)
+	}
+	var slugs []string
+	if err := json.Unmarshal([]byte(row.V), &slugs); err != nil {
+		return fmt.Errorf("niepoprawna lista klas podatkowych %s w kvs: %w", key, err)
+	}
+	i.taxClasses = make(map[string]struct{}, len(slugs))
+	for _, slug := range slugs {
+		i.taxClasses[slug] = struct{}{}
+	}
+	for _, r := range i.vatRules() {
+		if !i.taxClassKnown(r.TaxClass) {
+			i.log.Error().
+				Str("shop", i.shop.ID).
+				Int64("vat_id", r.VatID).
+				Str("tax_class", r.TaxClass).
+				Strs("shop_tax_classes", slugs).
+				Msg("vat: klasy podatkowej nie ma w sklepie (/taxes/classes)")
+		}
+	}
+	return nil
+}
+
+func (i *Importer) taxClassKnown(slug string) bool {
+	if i.taxClasses == nil || slug == "" {
+		return true
+	}
+	_, ok := i.taxClasses[slug]
+	return ok
+}
+
+// checkVat sprawdza, czy ceny towaru da się policzyć: vat_id musi być w mapowaniu, a jego klasa
+// podatkowa w sklepie. Problem zapisuje w link_issues sklepu i zwraca false.
+func (i *Importer) checkVat(tx *gorm.DB, src plannerSourceRow) bool {
+	rule, ok := i.vatRule(src.VatID)
+	switch {
+	case !ok:
+		saveLinkIssue(tx, i.shop.ID, src.TowarID, src.Kod, "", "unknown_vat_id",
+			fmt.Sprintf("vat_id=%d nie ma w mapowaniu vat — ceny towaru nie są wysyłane", src.VatID))
+		return false
+	case !i.taxClassKnown(rule.TaxClass):
+		saveLinkIssue(tx, i.shop.ID, src.TowarID, src.Kod, "", "unknown_tax_class",
+			fmt.Sprintf("vat_id=%d → tax_class %q nie istnieje w sklepie (/taxes/classes)", src.VatID, rule.TaxClass))
+		return false
+	}
+	return true
+}
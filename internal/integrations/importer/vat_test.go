@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestPlanWooTasksUsesVatMappingAndReportsUnknownVat(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	cfg := Config{PriceMode: priceModeNet, Vat: []VatRule{
+		{VatID: 2300, Rate: 23, TaxClass: "standard"},
+		{VatID: 800, Rate: 8, TaxClass: "reduced-rate"},
+		{VatID: 500, Rate: 5, TaxClass: "super-reduced"},
+	}}
+	if err := normalizeVatRules(cfg.Vat); err != nil {
+		t.Fatal(err)
+	}
+	importer := &Importer{log: zerolog.Nop(), db: gdb, cfg: cfg}
+
+	// sklep zna standard i reduced-rate — super-reduced z mapowania nie istnieje
+	if err := gdb.Create(&db.KV{K: db.TaxClassesKVKey(""), V: `["standard","reduced-rate"]`}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const importID = 51
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_vat.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	products := []struct {
+		towarID int64
+		vatID   int64
+	}{{151, 800}, {152, 700}, {153, 500}}
+	for idx, p := range products {
+		towarID := p.towarID
+		if err := gdb.Create(&db.StProduct{
+			ImportID:   importID,
+			TowarID:    towarID,
+			Kod:        "590123456789" + string(rune('0'+idx)),
+			Nazwa:      "VAT Product",
+			VatID:      p.vatID,
+			CenaDetal:  108,
+			AktywnyWSI: true,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := gdb.Create(&db.WooProductCache{
+			WooID:        uint(250 + idx),
+			TowarID:      &towarID,
+			Name:         "VAT Product",
+			PriceRegular: 1,
+			StockManaged: true,
+			Backorders:   "notify",
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := importer.PlanWooTasks(importID); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := mustPriceTaskPayload(t, gdb)
+	if payload.TowarID != 151 || payload.DesiredRegular != 100 || payload.DesiredTaxClass != "reduced-rate" {
+		t.Fatalf("expected 108 gross at 8%% → 100 net in reduced-rate, got %+v", payload)
+	}
+
+	var issues []db.LinkIssue
+	if err := gdb.Order("towar_id").Find(&issues).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[0].Reason != "unknown_vat_id" || issues[1].Reason != "unknown_tax_class" {
+		t.Fatalf("expected unknown_vat_id and unknown_tax_class issues, got %+v", issues)
+	}
+}
+
+func TestNormalizeVatRulesRejectsDuplicates(t *testing.T) {
+	if err := normalizeVatRules([]VatRule{{VatID: 2300, Rate: 23}, {VatID: 2300, Rate: 8}}); err == nil {
+		t.Fatal("expected error for duplicated vat_id")
+	}
+	if err := normalizeVatRules([]VatRule{{VatID: 2300, Rate: 123}}); err == nil {
+		t.Fatal("expected error for rate out of range")
+	}
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// WarehouseConfig wybiera magazyny PCM, z których liczony jest stan wysyłany do Woo.
+// Pusta konfiguracja = suma wszystkich magazynów (zachowanie domyślne).
+type WarehouseConfig struct {
+	Include []int64         `json:"include,omitempty"` // tylko te magazyn_id (puste = wszystkie)
+	Exclude []int64         `json:"exclude,omitempty"` // magazyn_id pomijane przy liczeniu stanu
+	Rules   []WarehouseRule `json:"rules,omitempty"`   // mnożniki / bufory per magazyn
+}
+
+// WarehouseRule modyfikuje stan dostępny (stan - rezerwacja) jednego magazynu.
+type WarehouseRule struct {
+	MagazynID  int64    `json:"magazyn_id"`
+	Multiplier *float64 `json:"multiplier,omitempty"` // np. 0.5 — wynik zaokrąglany w dół
+	Buffer     float64  `json:"buffer,omitempty"`     // bufor bezpieczeństwa odejmowany od stanu magazynu
+}
+
+type plannerStockRow struct {
+	TowarID    int64
+	MagazynID  int64
+	SourceID   string
+	Stan       float64
+	StanPrev   *float64
+	Rezerwacja float64
+}
+
+func (c WarehouseConfig) active() bool {
+	return len(c.Include) > 0 || len(c.Exclude) > 0 || len(c.Rules) > 0
+}
+
+func (c WarehouseConfig) validate() error {
+	for _, id := range c.Include {
+		if slices.Contains(c.Exclude, id) {
+			return fmt.Errorf("importer warehouses: magazyn_id %d jest jednocześnie w include i exclude", id)
+		}
+	}
+	seen := make(map[int64]struct{}, len(c.Rules))
+	for _, r := range c.Rules {
+		if _, ok := seen[r.MagazynID]; ok {
+			return fmt.Errorf("importer warehouses: zdublowana reguła dla magazyn_id %d", r.MagazynID)
+		}
+		seen[r.MagazynID] = struct{}{}
+		if r.Multiplier != nil && *r.Multiplier < 0 {
+			return fmt.Errorf("importer warehouses: ujemny multiplier dla magazyn_id %d", r.MagazynID)
+		}
+		if r.Buffer < 0 {
+			return fmt.Errorf("importer warehouses: ujemny buffer dla magazyn_id %d", r.MagazynID)
+		}
+	}
+	return nil
+}
+
+func (c WarehouseConfig) selected(magazynID int64) bool {
+	if len(c.Include) > 0 && !slices.Contains(c.Include, magazynID) {
+		return false
+	}
+	return !slices.Contains(c.Exclude, magazynID)
+}
+
+func (c WarehouseConfig) rule(magazynID int64) (WarehouseRule, bool) {
+	for _, r := range c.Rules {
+		if r.MagazynID == magazynID {
+			return r, true
+		}
+	}
+	return WarehouseRule{}, false
+}
+
+// available liczy stan magazynu wysyłany do Woo: (stan - rezerwacja) × mnożnik - bufor, nie mniej niż 0.
+func (c WarehouseConfig) available(magazynID int64, stan, rezerwacja float64) float64 {
+	qty := math.Max(stan-rezerwacja, 0)
+	if r, ok := c.rule(magazynID); ok {
+		if r.Multiplier != nil {
+			qty = math.Floor(qty * *r.Multiplier)
+		}
+		qty -= r.Buffer
+	}
+	return math.Max(qty, 0)
+}
+
+// applyWarehouseSelection wylicza DesiredStock / DesiredStockPrev dla wierszy plannera.
+// Bez konfiguracji magazynów i bez sources: suma wszystkich magazynów minus rezerwacje (jak w zapytaniu SQL).
+// W przeciwnym razie stan liczony jest osobno dla każdego źródła — tylko wybrane magazyny, każdy z własnym
+// mnożnikiem i buforem — a potem łączony wg stock_combine; TotalStock / TotalReserved są wtedy
+// zastępowane sumami wybranych magazynów.
+func (i *Importer) applyWarehouseSelection(tx *gorm.DB, rows []plannerSourceRow) error {
+	if len(i.cfg.Sources) == 0 && !i.cfg.Warehouses.active() {
+		for idx := range rows {
+			row := &rows[idx]
+			row.DesiredStock = math.Max(row.TotalStock-row.TotalReserved, 0)
+			if row.TotalStockPrev != nil {
+				prev := math.Max(*row.TotalStockPrev-row.TotalReserved, 0)
+				row.DesiredStockPrev = &prev
+			}
+		}
+		return nil
+	}
+
+	towarIDs := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		towarIDs = append(towarIDs, row.TowarID)
+	}
+	stocksByTowar := make(map[int64][]plannerStockRow, len(rows))
+	for start := 0; start < len(towarIDs); start += stagingBatchSize {
+		end := min(start+stagingBatchSize, len(towarIDs))
+		var stocks []plannerStockRow
+		if err := tx.Model(&db.StStock{}).
+			Select("towar_id", "magazyn_id", "source_id", "stan", "stan_prev", "rezerwacja").
+			Where("towar_id IN ?", towarIDs[start:end]).
+			Order("source_id, magazyn_id").
+			Find(&stocks).Error; err != nil {
+			return fmt.Errorf("load st_stocks for warehouse selection: %w", err)
+		}
+		for _, s := range stocks {
+			stocksByTowar[s.TowarID] = append(stocksByTowar[s.TowarID], s)
+		}
+	}
+
+	for idx := range rows {
+		row := &rows[idx]
+		perSource := make([]sourceStock, 0, 1)
+		sourceIdx := make(map[string]int, 1)
+		for _, s := range stocksByTowar[row.TowarID] {
+			src, ok := i.cfg.source(s.SourceID)
+			if !ok || !src.Warehouses.selected(s.MagazynID) {
+				continue
+			}
+			at, ok := sourceIdx[s.SourceID]
+			if !ok {
+				at = len(perSource)
+				sourceIdx[s.SourceID] = at
+				perSource = append(perSource, sourceStock{hasPrev: true})
+			}
+			acc := &perSource[at]
+			acc.total += s.Stan
+			acc.reserved += s.Rezerwacja
+			acc.desired += src.Warehouses.available(s.MagazynID, s.Stan, s.Rezerwacja)
+			if s.StanPrev == nil {
+				acc.hasPrev = false
+				continue
+			}
+			acc.desiredPrev += src.Warehouses.available(s.MagazynID, *s.StanPrev, s.Rezerwacja)
+		}
+		row.TotalStock, row.TotalReserved, row.DesiredStock, row.DesiredStockPrev = combineSourceStocks(i.cfg.StockCombine, perSource)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package importer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// watchDirEvents obserwuje katalog przez inotify. Kanał dostaje sygnał (bez nazw plików — skan i tak
+// czyta cały katalog), gdy plik został zamknięty po zapisie, przeniesiony do katalogu albo utworzony.
+func watchDirEvents(dir string) (<-chan struct{}, func(), error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, err
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, nil, err
+	}
+
+	// deskryptor nieblokujący → os.File korzysta z pollera runtime, a Close przerywa Read
+	f := os.NewFile(uintptr(fd), "inotify")
+	events := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					close(events)
+				}
+				return
+			}
+			if n <= 0 {
+				continue
+			}
+			select {
+			case events <- struct{}{}:
+			default: // skan już czeka
+			}
+		}
+	}()
+	return events, func() { _ = f.Close() }, nil
+}
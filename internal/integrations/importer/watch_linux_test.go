@@ -0,0 +1,28 @@
+//go:build linux
+
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchDirEventsSignalsClosedFile(t *testing.T) {
+	dir := t.TempDir()
+	events, stop, err := watchDirEvents(dir)
+	if err != nil {
+		t.Skipf("inotify unavailable: %v", err)
+	}
+	defer stop()
+
+	if err := os.WriteFile(filepath.Join(dir, "exp_wyk_1.xml"), []byte("<wykaz/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected directory event after writing a file")
+	}
+}
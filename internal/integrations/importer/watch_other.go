@@ -0,0 +1,10 @@
+//go:build !linux
+
+package importer
+
+import "errors"
+
+// watchDirEvents: poza Linuksem nie ma obserwacji zdarzeń — importer zostaje przy odpytywaniu co poll_sec.
+func watchDirEvents(dir string) (<-chan struct{}, func(), error) {
+	return nil, nil, errors.New("obserwacja zdarzeń katalogu niedostępna na tej platformie")
+}
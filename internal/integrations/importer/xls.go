@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"math"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/xlstowary"
+)
+
+// Arkusz XLS „towary” z PC-Market (*.xlsx w watch_dir) to import jak eksport XML: rejestracja
+// w import_files (export_kind=xls), staging, relink i planowanie tasków dla Woo.
+//
+// Arkusz niesie mniej danych niż pełny wykaz, więc nadpisuje tylko swoje kolumny (xlsProductColumns):
+// opis, kategoria, zdjęcia i flagi aktywny_w_SI / do_usuniecia z eksportu XML zostają. Towar znany
+// tylko z arkusza jest zakładany jako aktywny w SI. Ceny w arkuszu są brutto, jak cena_detal w XML.
+// „Ilość” trafia do st_stocks pod magazyn xls_magazyn_id (domyślnie 1), bez rezerwacji.
+
+// xlsRequiredColumns — bez tych kolumn arkusz nie jest importowany (brak dałby zerowe ceny / stany).
+var xlsRequiredColumns = []string{"Cena det.", "Cena hurt.", "VAT %", "Ilość"}
+
+// xlsProductColumns — kolumny st_products nadpisywane przez arkusz XLS.
+var xlsProductColumns = []string{"nazwa", "vat_id", "cena_detal", "cena_hurtowa", "data_aktualizacji"}
+
+const defaultXLSMagazynID = 1
+
+func (c Config) xlsMagazynID() int64 {
+	if c.XLSMagazynID > 0 {
+		return c.XLSMagazynID
+	}
+	return defaultXLSMagazynID
+}
+
+// parseTowaryXLS — arkusz XLS „towary”: dane i ceny towaru + „Ilość” jako stan jednego magazynu.
+// Powtórzony towar_id jest pomijany (liczy się pierwszy wiersz).
+func parseTowaryXLS(i *Importer, w *stagingWriter, path string) error {
+	rows, err := xlstowary.LoadRequired(path, xlsRequiredColumns...)
+	if err != nil {
+		return err
+	}
+	w.productColumns = xlsProductColumns
+	magazynID := i.cfg.xlsMagazynID()
+
+	seen := make(map[int64]struct{}, len(rows))
+	for _, r := range rows {
+		if _, ok := seen[r.TowarID]; ok {
+			continue
+		}
+		seen[r.TowarID] = struct{}{}
+
+		if err := w.addProduct(db.StProduct{
+			TowarID:          r.TowarID,
+			Kod:              r.Code,
+			Nazwa:            r.Name,
+			VatID:            i.xlsVatID(r.VATRate),
+			CenaDetal:        r.DetailPriceGross,
+			CenaHurtowa:      r.WholesaleGross,
+			AktywnyWSI:       true,
+			DataAktualizacji: r.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+		if err := w.addStock(db.StStock{
+			TowarID:   r.TowarID,
+			MagazynID: magazynID,
+			Stan:      r.Quantity,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xlsVatID zamienia stawkę z arkusza (ułamek, np. 0.23) na vat_id z mapowania vat — pierwsza reguła
+// o tej stawce. Stawka spoza mapowania dostaje vat_id wg konwencji PCM (23% → 2300), więc towar
+// trafia do link_issues jako unknown_vat_id zamiast liczyć się w innej stawce.
+func (i *Importer) xlsVatID(rate float64) int64 {
+	pct := rate * 100
+	for _, r := range i.vatRules() {
+		if floatAlmostEqual(r.Rate, pct) {
+			return r.VatID
+		}
+	}
+	return int64(math.Round(pct * 100))
+}
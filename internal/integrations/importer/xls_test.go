@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/reports"
+	"github.com/bartek5186/pcm2www/internal/xlstowary"
+	"github.com/rs/zerolog"
+)
+
+func TestImportXLSTowaryFlowsThroughPipeline(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{PriceMode: "gross"}}
+
+	if err := gdb.Create(&db.WooProductCache{
+		WooID:        10,
+		Kod:          "SKU-10",
+		Ean:          "5901234567890",
+		Name:         "Woo product",
+		PriceRegular: 10,
+		HurtPrice:    7,
+		TaxClass:     "2300",
+		StockQty:     5,
+		StockManaged: true,
+		StockStatus:  "instock",
+		Backorders:   "notify",
+		Status:       "publish",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	writeExportFile(t, watchDir, "exp_wyk_1_20260101120000.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>T1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Produkt</nazwa><opis1>Opis z PCM</opis1><vat_id>2300</vat_id>
+<cena_detal>10</cena_detal><cena_hurtowa>7</cena_hurtowa><aktywny_w_SI>Y</aktywny_w_SI><do_usuniecia>N</do_usuniecia>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>5</stan_magazynu><rezerwacja_ilosci>0</rezerwacja_ilosci></magazyn></magazyny>
+</towar></towary></wykaz>`)
+	imp.scanOnce(watchDir)
+	assertTaskCount(t, gdb, 0)
+
+	writeTowaryXLSX(t, watchDir, "XLS_Towary.xlsx", [][]any{
+		{int64(1), "Produkt XLS", "5901234567890", 12.5, 8.0, 23.0, 9.0},
+		{int64(2), "Nowy", "5900000000002", 4.0, 3.0, 8.0, 1.0},
+		{int64(3), "Stawka spoza mapowania", "5900000000003", 4.0, 3.0, 7.0, 1.0},
+	})
+	writeExportFile(t, watchDir, "~$XLS_Towary.xlsx", "lock")
+	imp.scanOnce(watchDir)
+
+	xls := mustImportFile(t, gdb, "XLS_Towary.xlsx")
+	if xls.Status != 1 || xls.ExportKind != exportKindXLS {
+		t.Fatalf("xls import: status=%d kind=%q error=%q", xls.Status, xls.ExportKind, xls.LastError)
+	}
+	if _, err := os.Stat(filepath.Join(watchDir, "parsed", "XLS_Towary.xlsx")); err != nil {
+		t.Fatalf("xls should be archived to parsed/: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(watchDir, "~$XLS_Towary.xlsx")); err != nil {
+		t.Fatalf("excel lock file should be ignored: %v", err)
+	}
+
+	var product db.StProduct
+	if err := gdb.Where("towar_id = ?", 1).Take(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	if product.Nazwa != "Produkt XLS" || product.CenaDetal != 12.5 || product.CenaHurtowa != 8 ||
+		product.VatID != 2300 || product.ImportID != xls.ImportID {
+		t.Fatalf("xls should update product data and prices, got %+v", product)
+	}
+	if product.Opis1 != "Opis z PCM" || !product.AktywnyWSI {
+		t.Fatalf("xls must keep columns it does not carry, got %+v", product)
+	}
+	var stock db.StStock
+	if err := gdb.Where("towar_id = ? AND magazyn_id = ?", 1, 1).Take(&stock).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stock.Stan != 9 || stock.StanPrev == nil || *stock.StanPrev != 5 {
+		t.Fatalf("xls quantity should update warehouse 1, got %+v", stock)
+	}
+	assertTaskKind(t, gdb, xls.ImportID, db.WooTaskKindPriceUpdate)
+	assertTaskKind(t, gdb, xls.ImportID, db.WooTaskKindStockUpdate)
+
+	var created db.StProduct
+	if err := gdb.Where("towar_id = ?", 2).Take(&created).Error; err != nil {
+		t.Fatal(err)
+	}
+	if created.VatID != 800 || !created.AktywnyWSI || created.Kod != "5900000000002" {
+		t.Fatalf("unexpected product created from xls: %+v", created)
+	}
+	var unmapped db.StProduct
+	if err := gdb.Where("towar_id = ?", 3).Take(&unmapped).Error; err != nil {
+		t.Fatal(err)
+	}
+	if unmapped.VatID != 700 {
+		t.Fatalf("unmapped VAT rate should keep PCM vat_id convention, got %d", unmapped.VatID)
+	}
+
+	// kolejny arkusz (bez transmisja_id) rejestruje się jako osobny import
+	writeTowaryXLSX(t, watchDir, "XLS_Towary_2.xlsx", [][]any{
+		{int64(1), "Produkt XLS", "5901234567890", 13.0, 8.0, 23.0, 9.0},
+	})
+	imp.scanOnce(watchDir)
+	if second := mustImportFile(t, gdb, "XLS_Towary_2.xlsx"); second.Status != 1 {
+		t.Fatalf("second xls import: status=%d error=%q", second.Status, second.LastError)
+	}
+}
+
+func TestImportXLSWithoutPriceColumnFails(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{PriceMode: "gross"}}
+
+	writeXLSX(t, filepath.Join(watchDir, "towary.xlsx"), []xlstowary.Column{
+		{Name: "Id", Kind: xlstowary.CellInt}, {Name: "Nazwa"}, {Name: "Kod"}, {Name: "Cena det.", Kind: xlstowary.CellMoney},
+	}, [][]any{{int64(1), "Produkt", "5901234567890", 10.0}})
+	imp.scanOnce(watchDir)
+
+	rec := mustImportFile(t, gdb, "towary.xlsx")
+	if rec.Status != 2 || rec.LastError == "" {
+		t.Fatalf("xls without required columns should fail, got status=%d error=%q", rec.Status, rec.LastError)
+	}
+	var n int64
+	mustCount(t, gdb.Model(&db.StProduct{}), &n)
+	if n != 0 {
+		t.Fatalf("failed xls import must not stage products, got %d", n)
+	}
+}
+
+func TestXLSMagazineReportMatchesImportedSheet(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb, cfg: Config{PriceMode: "gross"}}
+
+	writeTowaryXLSX(t, watchDir, "XLS_Towary.xlsx", [][]any{
+		{int64(1), "Produkt 23", "5900000000001", 12.3, 9.84, 23.0, 4.0},
+		{int64(2), "Produkt 8", "5900000000002", 10.8, 8.64, 8.0, 2.0},
+	})
+	imp.scanOnce(watchDir)
+	if rec := mustImportFile(t, gdb, "XLS_Towary.xlsx"); rec.Status != 1 {
+		t.Fatalf("xls import: status=%d error=%q", rec.Status, rec.LastError)
+	}
+
+	// ten sam arkusz porównany ze stagingiem, do którego trafił, musi być w pełni zgodny
+	tables, err := reports.Run(gdb, "xls-magazine", reports.Params{XLSXPath: filepath.Join(watchDir, "parsed", "XLS_Towary.xlsx")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || len(tables[0].Rows) != 2 {
+		t.Fatalf("expected one table with 2 rows, got %+v", tables)
+	}
+	for _, row := range tables[0].Rows {
+		if row[0] != "MATCH" {
+			t.Fatalf("imported sheet should match staging, got %v", row)
+		}
+	}
+}
+
+func writeTowaryXLSX(t *testing.T, dir, name string, rows [][]any) {
+	t.Helper()
+	writeXLSX(t, filepath.Join(dir, name), []xlstowary.Column{
+		{Name: "Id", Kind: xlstowary.CellInt}, {Name: "Nazwa"}, {Name: "Kod"},
+		{Name: "Cena det.", Kind: xlstowary.CellMoney}, {Name: "Cena hurt.", Kind: xlstowary.CellMoney},
+		{Name: "VAT %", Kind: xlstowary.CellNumber}, {Name: "Ilość", Kind: xlstowary.CellNumber},
+	}, rows)
+}
+
+func writeXLSX(t *testing.T, path string, cols []xlstowary.Column, rows [][]any) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := xlstowary.NewWriter(f)
+	sheet, err := w.NewSheet("towary", cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := sheet.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
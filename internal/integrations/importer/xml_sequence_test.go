@@ -174,7 +174,7 @@ func TestImportRealXMLSequenceIntoIsolatedDB(t *testing.T) {
 	assertNoDuplicateImportRows(t, gdb)
 
 	lastName := filepath.Base(files[len(files)-1])
-	lastID, already, err := imp.registerFile(filepath.Join(watchDir, "parsed", lastName), lastName)
+	lastID, already, err := imp.registerFile("", filepath.Join(watchDir, "parsed", lastName), lastName, exportKindWykaz)
 	if err != nil {
 		t.Fatalf("re-register %s: %v", lastName, err)
 	}
@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+)
+
+func isZipName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+// processZip przetwarza archiwum eksportu PCM. Każdy plik XML w archiwum jest czytany strumieniowo
+// (bez rozpakowywania na dysk), osobno hashowany i rejestrowany w import_files jako "<archiwum>/<plik>".
+// Pliki są przetwarzane w kolejności nazw; pierwszy błąd przerywa archiwum, żeby nie nałożyć
+// późniejszych części na brakującą wcześniejszą. Po przetworzeniu wszystkich części archiwum trafia do parsed/.
+// Zwraca import_id części przetworzonych w tym przebiegu.
+func (i *Importer) processZip(sourceID, dir, fullPath, name string, zipParser exportParser) ([]uint, error) {
+	zr, err := zip.OpenReader(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			_ = zr.Close()
+		}
+	}()
+
+	entries := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+			continue
+		}
+		entries = append(entries, f)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("archiwum nie zawiera plików XML")
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Name < entries[b].Name })
+
+	processedIDs := make([]uint, 0, len(entries))
+	var firstImportID uint
+	allDone := true
+
+	for _, f := range entries {
+		entryName := name + "/" + f.Name
+		parser := zipEntryParser(f, zipParser)
+
+		importID, already, err := i.registerZipEntry(sourceID, f, entryName, name, parser.Kind)
+		if err != nil {
+			i.log.Error().Err(err).Str("file", entryName).Msg("rejestracja pliku z archiwum nieudana")
+			allDone = false
+			break
+		}
+		if firstImportID == 0 {
+			firstImportID = importID
+		}
+
+		if already {
+			var rec db.ImportFile
+			if err := i.db.Where("import_id = ?", importID).Take(&rec).Error; err == nil && rec.Status == 1 {
+				continue
+			}
+			i.log.Warn().Str("file", entryName).Uint("import_id", importID).Msg("plik z archiwum istnieje, ale nie DONE — ponawiam przetwarzanie")
+		}
+
+		if err := i.processZipEntry(importID, f, parser); err != nil {
+			i.log.Error().Err(err).Str("file", entryName).Uint("import_id", importID).Msg("błąd przetwarzania pliku z archiwum")
+			_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
+				Updates(map[string]any{"status": 2, "last_error": err.Error()})
+			allDone = false
+			break
+		}
+		now := time.Now()
+		_ = i.db.Model(&db.ImportFile{}).Where("import_id = ?", importID).
+			Updates(map[string]any{"status": 1, "processed_at": now})
+		i.log.Info().Str("file", entryName).Uint("import_id", importID).Msg("przetworzono OK")
+		processedIDs = append(processedIDs, importID)
+	}
+
+	if !allDone {
+		return processedIDs, nil
+	}
+
+	// na Windows nie da się przenieść otwartego pliku
+	closed = true
+	_ = zr.Close()
+
+	archivedPath, err := archiveProcessedFile(dir, fullPath, name, firstImportID)
+	if err != nil {
+		return processedIDs, err
+	}
+	i.log.Info().Str("file", name).Str("archived_path", archivedPath).Int("entries", len(entries)).Msg("archiwum przeniesione do parsed")
+	return processedIDs, nil
+}
+
+// zipEntryParser wybiera parser dla pliku z archiwum: po nazwie pliku, po elemencie głównym,
+// a na końcu po nazwie samego archiwum.
+func zipEntryParser(f *zip.File, zipParser exportParser) exportParser {
+	base := path.Base(f.Name)
+	if p, ok := parserForFilename(base); ok {
+		return p
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return zipParser
+	}
+	defer rc.Close()
+	if root, err := readRootElement(rc); err == nil {
+		if p, ok := parserForRoot(root); ok {
+			return p
+		}
+	}
+	return zipParser
+}
+
+func (i *Importer) registerZipEntry(sourceID string, f *zip.File, entryName, archiveName, exportKind string) (uint, bool, error) {
+	h, err := zipEntrySHA256(f)
+	if err != nil {
+		return 0, false, err
+	}
+
+	transID := ""
+	if rc, err := f.Open(); err == nil {
+		transID, _ = readTransmisjaIDFrom(rc)
+		_ = rc.Close()
+	}
+
+	fileTime := inferTimeFromName(path.Base(f.Name))
+	if fileTime == "" {
+		fileTime = inferTimeFromName(archiveName)
+	}
+
+	return i.registerImport(db.ImportFile{
+		Filename:     entryName,
+		FileTimeUTC:  fileTime,
+		TransmisjaID: transID,
+		SHA256:       h,
+		SizeBytes:    int64(f.UncompressedSize64),
+		ExportKind:   exportKind,
+		ArchiveName:  archiveName,
+		SourceID:     sourceID,
+		Status:       0,
+	})
+}
+
+func (i *Importer) processZipEntry(importID uint, f *zip.File, parser exportParser) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return i.processReader(importID, rc, parser)
+}
+
+func zipEntrySHA256(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestImportZipProcessesEntriesInOrderAndArchives(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb}
+
+	zipName := "exp_wyk_1_20260105120000.zip"
+	writeZipFile(t, filepath.Join(watchDir, zipName), map[string]string{
+		"exp_stn_1_20260105120500.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<stany><transmisja_id>Z2</transmisja_id><towary>
+<towar><towar_id>1</towar_id><magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>3</stan_magazynu></magazyn></magazyny></towar>
+</towary></stany>`,
+		"exp_wyk_1_20260105120000.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<wykaz><transmisja_id>Z1</transmisja_id><towary>
+<towar><towar_id>1</towar_id><kod>5901234567890</kod><nazwa>Z archiwum</nazwa><cena_detal>10</cena_detal>
+<magazyny><magazyn><magazyn_id>1</magazyn_id><stan_magazynu>9</stan_magazynu></magazyn></magazyny></towar>
+</towary></wykaz>`,
+		"readme.txt": "pomijany",
+	})
+
+	imp.scanOnce(watchDir)
+
+	var files []db.ImportFile
+	if err := gdb.Order("import_id").Find(&files).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected one import_files row per XML entry, got %d", len(files))
+	}
+	wantNames := []string{zipName + "/exp_stn_1_20260105120500.xml", zipName + "/exp_wyk_1_20260105120000.xml"}
+	wantKinds := []string{exportKindStany, exportKindWykaz}
+	for idx, f := range files {
+		if f.Filename != wantNames[idx] || f.ExportKind != wantKinds[idx] || f.ArchiveName != zipName || f.Status != 1 || f.SHA256 == "" {
+			t.Fatalf("unexpected import row %d: %+v", idx, f)
+		}
+	}
+
+	// kolejność po nazwach: exp_stn_ < exp_wyk_, więc stan końcowy pochodzi z wykazu
+	var stock db.StStock
+	if err := gdb.Where("towar_id = ? AND magazyn_id = ?", 1, 1).Take(&stock).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stock.Stan != 9 {
+		t.Fatalf("expected stock from last entry by name, got %+v", stock)
+	}
+
+	assertFileMissing(t, filepath.Join(watchDir, zipName))
+	assertFileExists(t, filepath.Join(watchDir, "parsed", zipName))
+
+	// ponowne wrzucenie tego samego archiwum nie tworzy nowych importów
+	writeZipFile(t, filepath.Join(watchDir, zipName), map[string]string{
+		"exp_wyk_1_20260105120000.xml": `<?xml version="1.0" encoding="UTF-8"?><wykaz><transmisja_id>Z1</transmisja_id><towary/></wykaz>`,
+	})
+	imp.scanOnce(watchDir)
+	var count int64
+	mustCount(t, gdb.Model(&db.ImportFile{}), &count)
+	if count != 2 {
+		t.Fatalf("duplicate archive should be deduplicated, got %d import rows", count)
+	}
+	assertFileMissing(t, filepath.Join(watchDir, zipName))
+}
+
+func TestImportZipStopsOnBrokenEntry(t *testing.T) {
+	gdb := newImporterTestDB(t)
+	watchDir := t.TempDir()
+	imp := &Importer{log: zerolog.Nop(), db: gdb}
+
+	zipName := "exp_wyk_2_20260106120000.zip"
+	writeZipFile(t, filepath.Join(watchDir, zipName), map[string]string{
+		"a.xml": `<?xml version="1.0" encoding="UTF-8"?><wykaz><transmisja_id>B1</transmisja_id><towary><towar><towar_id>1</towar_id>`,
+		"b.xml": `<?xml version="1.0" encoding="UTF-8"?><wykaz><transmisja_id>B2</transmisja_id><towary/></wykaz>`,
+	})
+
+	imp.scanOnce(watchDir)
+
+	broken := mustImportFile(t, gdb, zipName+"/a.xml")
+	if broken.Status != 2 || broken.ExportKind != exportKindWykaz {
+		t.Fatalf("broken entry should be marked error with archive parser kind, got %+v", broken)
+	}
+	var count int64
+	mustCount(t, gdb.Model(&db.ImportFile{}), &count)
+	if count != 1 {
+		t.Fatalf("entries after broken one must wait, got %d import rows", count)
+	}
+	assertFileExists(t, filepath.Join(watchDir, zipName))
+}
+
+func writeZipFile(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,10 @@
 // internal/integrations/registry.go
 package integrations
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
 
 var (
 	regMu    sync.RWMutex
@@ -14,13 +17,23 @@ func Register(name string, f Factory) {
 	registry[name] = f
 }
 
+// Get zwraca fabrykę dla nazwy z configu. Nazwa może wskazywać nazwaną instancję
+// ("woocommerce:retail") — typ integracji to część przed dwukropkiem.
 func Get(name string) (Factory, bool) {
+	kind, _ := SplitName(name)
 	regMu.RLock()
 	defer regMu.RUnlock()
-	f, ok := registry[name]
+	f, ok := registry[kind]
 	return f, ok
 }
 
+// SplitName rozdziela nazwę integracji z configu na typ i nazwę instancji
+// ("woocommerce:b2b" → "woocommerce", "b2b"; "woocommerce" → "woocommerce", "").
+func SplitName(name string) (kind, instance string) {
+	kind, instance, _ = strings.Cut(strings.TrimSpace(name), ":")
+	return strings.TrimSpace(kind), strings.TrimSpace(instance)
+}
+
 func All() map[string]Factory {
 	regMu.RLock()
 	defer regMu.RUnlock()
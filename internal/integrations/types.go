@@ -14,4 +14,6 @@ type Integration interface {
 	Stop()                           // idempotent
 }
 
-type Factory func(log zerolog.Logger, raw json.RawMessage) (Integration, error)
+// Factory tworzy integrację z surowej sekcji configu. instance to nazwa instancji z klucza
+// "<typ>:<instancja>" ("" dla klucza bez dwukropka).
+type Factory func(log zerolog.Logger, instance string, raw json.RawMessage) (Integration, error)
@@ -22,6 +22,7 @@ func (w *Woo) primeCache(ctx context.Context, gdb *gorm.DB) error {
 
 	perPage := 100
 	page := 1
+	variations := 0
 
 	client := w.http
 	if client == nil {
@@ -72,40 +73,27 @@ func (w *Woo) primeCache(ctx context.Context, gdb *gorm.DB) error {
 		rows = make([]db.WooProductCache, 0, len(items))
 
 		for _, p := range items {
-			rows = append(rows, db.WooProductCache{
-				WooID:             uint(p.ID),
-				TowarID:           nil, // nie znamy jeszcze mapowania z PCM – zostanie uzupełnione później
-				Kod:               p.SKU,
-				Ean:               p.cacheEAN(),
-				Name:              p.Name,
-				PriceRegular:      parsePrice(p.RegularPrice),
-				PriceSale:         parsePrice(p.SalePrice),
-				HurtPrice:         parsePrice(w.customFieldValue(p, "hurt_price")),
-				StockQty:          p.StockQuantity,
-				StockManaged:      p.ManageStock,
-				StockStatus:       p.StockStatus,
-				Backorders:        p.Backorders,
-				CatalogVisibility: p.CatalogVisibility,
-				Status:            p.Status,
-				Type:              p.Type,
-				DateModified:      p.DateModifiedGMT,
-			})
+			rows = append(rows, w.cacheRowFromProduct(p))
 		}
 
 		if err := gdb.Clauses(clause.OnConflict{
-			Columns: []clause.Column{{Name: "woo_id"}}, // klucz unikalny
-			DoUpdates: clause.AssignmentColumns([]string{
-				"kod", "name", "price_regular", "price_sale", "hurt_price",
-				"stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility", "status", "ean", "type", "date_modified",
-			}),
+			Columns:   cacheKeyColumns, // klucz (shop, woo_id)
+			DoUpdates: clause.AssignmentColumns(cacheColumns),
 		}).Create(&rows).Error; err != nil {
 			return fmt.Errorf("upsert cache page %d: %w", page, err)
 		}
 
+		// warianty produktów variable nie przychodzą z /products
+		n, err := w.cacheVariations(ctx, gdb, items)
+		if err != nil {
+			return fmt.Errorf("woo cache page %d: %w", page, err)
+		}
+		variations += n
+
 		page++
 	}
 
-	w.log.Info().Msg("Woo cache primed (products)")
+	w.log.Info().Int("variations", variations).Msg("Woo cache primed (products)")
 	return nil
 }
 
@@ -116,7 +104,9 @@ func (w *Woo) runCacheSweeper(ctx context.Context, gdb *gorm.DB) {
 		return
 	}
 	// pierwszy przelot zaraz po starcie
-	w.sweepOnce(ctx, gdb)
+	if _, err := w.sweepOnce(ctx, gdb); err != nil {
+		w.log.Error().Err(err).Msg("cache sweep failed")
+	}
 
 	ticker := time.NewTicker(intv)
 	defer ticker.Stop()
@@ -126,20 +116,26 @@ func (w *Woo) runCacheSweeper(ctx context.Context, gdb *gorm.DB) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			w.sweepOnce(ctx, gdb)
+			if _, err := w.sweepOnce(ctx, gdb); err != nil {
+				w.log.Error().Err(err).Msg("cache sweep failed")
+			}
 		}
 	}
 }
 
-func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
-	const kvKey = "woo_cache_last_sweep"
+// sweepOnce dociąga produkty zmienione od kursora w kvs; zwraca liczbę zapisanych wierszy cache.
+func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) (int, error) {
+	kvKey := w.kvKey("woo_cache_last_sweep")
 	last, ok := kvGetTime(gdb, kvKey)
 	if !ok {
 		// pierwszy raz: cofamy się o 24h, żeby nie ciągnąć całego sklepu
 		last = time.Now().UTC().Add(-24 * time.Hour)
 	}
 
-	base, _ := url.Parse(w.cfg.BaseURL)
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return 0, err
+	}
 	base.Path = "/wp-json/wc/v3/products"
 
 	perPage := 100
@@ -164,8 +160,7 @@ func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
 
 		req, err := http.NewRequestWithContext(ctx, "GET", base.String(), nil)
 		if err != nil {
-			w.log.Error().Err(err).Msg("sweep: build request")
-			return
+			return total, fmt.Errorf("sweep: build request: %w", err)
 		}
 		req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
 
@@ -173,20 +168,17 @@ func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
 
 		resp, err := client.Do(req)
 		if err != nil {
-			w.log.Error().Err(err).Int("page", page).Msg("sweep request failed")
-			return
+			return total, fmt.Errorf("sweep page %d: %w", page, err)
 		}
 		if resp.StatusCode != 200 {
 			resp.Body.Close()
-			w.log.Error().Int("code", resp.StatusCode).Int("page", page).Msg("sweep http error")
-			return
+			return total, &wooHTTPError{Op: fmt.Sprintf("sweep page %d", page), StatusCode: resp.StatusCode}
 		}
 
 		var items []wcProduct
 		if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
 			resp.Body.Close()
-			w.log.Error().Err(err).Int("page", page).Msg("sweep decode failed")
-			return
+			return total, fmt.Errorf("sweep decode page %d: %w", page, err)
 		}
 		resp.Body.Close()
 
@@ -195,6 +187,7 @@ func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
 		}
 
 		rows := make([]db.WooProductCache, 0, len(items))
+		var changed []wcProduct
 		stop := false
 
 		for _, p := range items {
@@ -215,38 +208,25 @@ func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
 				break
 			}
 
-			rows = append(rows, db.WooProductCache{
-				WooID:             uint(p.ID),
-				TowarID:           nil,
-				Kod:               p.SKU,
-				Ean:               p.cacheEAN(),
-				Name:              p.Name,
-				PriceRegular:      parsePrice(p.RegularPrice),
-				PriceSale:         parsePrice(p.SalePrice),
-				HurtPrice:         parsePrice(w.customFieldValue(p, "hurt_price")),
-				StockQty:          p.StockQuantity,
-				StockManaged:      p.ManageStock,
-				StockStatus:       p.StockStatus,
-				Backorders:        p.Backorders,
-				CatalogVisibility: p.CatalogVisibility,
-				Status:            p.Status,
-				Type:              p.Type,
-				DateModified:      p.DateModifiedGMT,
-			})
+			rows = append(rows, w.cacheRowFromProduct(p))
+			changed = append(changed, p)
 		}
 
 		if len(rows) > 0 {
 			if err := gdb.Clauses(clause.OnConflict{
-				Columns: []clause.Column{{Name: "woo_id"}},
-				DoUpdates: clause.AssignmentColumns([]string{
-					"kod", "ean", "name", "price_regular", "price_sale", "hurt_price",
-					"stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility", "status", "type", "date_modified",
-				}),
+				Columns:   cacheKeyColumns,
+				DoUpdates: clause.AssignmentColumns(cacheColumns),
 			}).Create(&rows).Error; err != nil {
-				w.log.Error().Err(err).Msg("sweep upsert failed")
-				return
+				return total, fmt.Errorf("sweep upsert: %w", err)
 			}
 			total += len(rows)
+
+			// zmieniony produkt variable → odśwież jego warianty
+			n, err := w.cacheVariations(ctx, gdb, changed)
+			if err != nil {
+				return total, fmt.Errorf("sweep variations page %d: %w", page, err)
+			}
+			total += n
 		}
 
 		if stop {
@@ -265,7 +245,7 @@ func (w *Woo) sweepOnce(ctx context.Context, gdb *gorm.DB) {
 	} else {
 		w.log.Debug().Time("since", last).Msg("cache sweep done (no changes)")
 	}
-
+	return total, nil
 }
 func parsePrice(s string) float64 {
 	s = strings.TrimSpace(strings.ReplaceAll(s, ",", "."))
@@ -1,6 +1,12 @@
 package woocommerce
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+)
 
 type CustomFieldConfig struct {
 	Code          string `json:"code"`
@@ -19,6 +25,11 @@ func defaultCustomFieldConfigs() []CustomFieldConfig {
 			WriteTopLevel: "hurt_price",
 			WriteMetaKey:  "_hurt_price",
 		},
+		{
+			Code:         "omnibus_price",
+			ReadMetaKey:  "_omnibus_price",
+			WriteMetaKey: "_omnibus_price",
+		},
 	}
 }
 
@@ -202,3 +213,69 @@ func (w *Woo) applyCustomFieldPayload(body map[string]any, code, value string) {
 	}
 	body["meta_data"] = []map[string]any{row}
 }
+
+// customFieldsJSON zapisuje do cache wartości custom fields spoza hurt_price / omnibus_price
+// (te mają własne kolumny) — planner porównuje z nimi ceny z reguł importera.
+func (w *Woo) customFieldsJSON(product wcProduct) string {
+	values := make(map[string]string)
+	for _, cfg := range w.effectiveCustomFieldConfigs() {
+		if cfg.Code == "hurt_price" || cfg.Code == "omnibus_price" {
+			continue
+		}
+		if value := w.customFieldValue(product, cfg.Code); value != "" {
+			values[cfg.Code] = value
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(values)
+	return string(raw)
+}
+
+// priceFields zwraca custom fields cenowe taska: Fields z reguł cen importera,
+// a dla tasków sprzed reguł samo hurt_price z DesiredHurt.
+func priceFields(rules bool, fields []db.WooPriceField, desiredHurt float64) []db.WooPriceField {
+	if rules {
+		return fields
+	}
+	return []db.WooPriceField{{Code: "hurt_price", Desired: desiredHurt}}
+}
+
+// checkPriceFields odrzuca kody spoza custom_fields — bez definicji nie wiadomo, gdzie zapisać cenę.
+func (w *Woo) checkPriceFields(fields []db.WooPriceField) error {
+	for _, f := range fields {
+		if _, ok := w.customFieldConfig(f.Code); !ok {
+			return fmt.Errorf("price field %q nie jest zdefiniowane w custom_fields", f.Code)
+		}
+	}
+	return nil
+}
+
+// priceFieldsMatch sprawdza, czy produkt ma już ceny custom fields z taska.
+func (w *Woo) priceFieldsMatch(product wcProduct, fields []db.WooPriceField) bool {
+	for _, f := range fields {
+		if !floatAlmostEqual(parsePrice(w.customFieldValue(product, f.Code)), f.Desired) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyPriceFields dopisuje ceny custom fields do body requestu.
+func (w *Woo) applyPriceFields(body map[string]any, fields []db.WooPriceField) {
+	for _, f := range fields {
+		w.applyCustomFieldPayload(body, f.Code, formatWooPrice(f.Desired))
+	}
+}
+
+// describePriceFields formatuje ceny custom fields produktu i taska do komunikatu błędu weryfikacji.
+func (w *Woo) describePriceFields(product wcProduct, fields []db.WooPriceField) (got, want string) {
+	gotParts := make([]string, 0, len(fields))
+	wantParts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		gotParts = append(gotParts, fmt.Sprintf("%s=%v", f.Code, parsePrice(w.customFieldValue(product, f.Code))))
+		wantParts = append(wantParts, fmt.Sprintf("%s=%v", f.Code, f.Desired))
+	}
+	return strings.Join(gotParts, " "), strings.Join(wantParts, " ")
+}
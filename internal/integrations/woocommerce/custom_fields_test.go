@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
 )
 
 func TestEnsureProductFieldsIncludesConfiguredCustomFields(t *testing.T) {
@@ -86,3 +88,37 @@ func TestApplyCustomFieldPayloadWritesConfiguredTargets(t *testing.T) {
 		t.Fatalf("unexpected meta payload: %#v", meta[0])
 	}
 }
+
+func TestPriceFieldsFollowImporterRules(t *testing.T) {
+	w := &Woo{
+		cfg: Config{
+			CustomFields: []CustomFieldConfig{
+				{Code: "night_price", ReadMetaKey: "_night_price", WriteMetaKey: "_night_price"},
+			},
+		},
+	}
+
+	// task sprzed reguł cen: tylko hurt_price z DesiredHurt
+	legacy := priceFields(false, nil, 17)
+	if len(legacy) != 1 || legacy[0].Code != "hurt_price" || legacy[0].Desired != 17 {
+		t.Fatalf("expected legacy hurt_price field, got %+v", legacy)
+	}
+
+	fields := priceFields(true, []db.WooPriceField{{Code: "night_price", Desired: 9.99}}, 17)
+	if err := w.checkPriceFields(fields); err != nil {
+		t.Fatal(err)
+	}
+	body := map[string]any{}
+	w.applyPriceFields(body, fields)
+	if _, ok := body["hurt_price"]; ok {
+		t.Fatal("rule-based payload without hurt_price rule must not write hurt_price")
+	}
+	meta, ok := body["meta_data"].([]map[string]any)
+	if !ok || len(meta) != 1 || meta[0]["key"] != "_night_price" || meta[0]["value"] != "9.99" {
+		t.Fatalf("unexpected meta payload: %#v", body["meta_data"])
+	}
+
+	if err := w.checkPriceFields([]db.WooPriceField{{Code: "unknown_price", Desired: 1}}); err == nil {
+		t.Fatal("expected error for price field missing in custom_fields")
+	}
+}
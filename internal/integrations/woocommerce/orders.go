@@ -0,0 +1,477 @@
+// internal/integrations/woocommerce/orders.go
+package woocommerce
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pobieranie zamówień: pcm2www jest jednokierunkowy PCM → Woo, więc sprzedaż w sklepie była dla PCM
+// niewidoczna (stąd guard stan_prev w plannerze). Order pull odpytuje /orders od kursora w kvs,
+// zapisuje zamówienia w woo_orders / woo_order_lines i każde zamówienie w statusie z `statuses`
+// eksportuje raz jako dokument (CSV / XML) do outbox_dir, skąd zaplecze księguje sprzedaż w PC-Market.
+
+type WooOrders struct {
+	Enabled   bool     `json:"enabled"`
+	PollSec   int      `json:"poll_sec,omitempty"`   // co ile sekund odpytywać /orders (domyślnie 300)
+	Statuses  []string `json:"statuses,omitempty"`   // statusy eksportowane do outbox (domyślnie processing, completed)
+	OutboxDir string   `json:"outbox_dir,omitempty"` // katalog na dokumenty dla PC-Market; puste = tylko zapis w bazie
+	Format    string   `json:"format,omitempty"`     // csv (domyślnie) / xml
+}
+
+const ordersKVKey = "woo_orders_last_modified"
+
+type wcOrder struct {
+	ID              int64         `json:"id"`
+	Number          string        `json:"number"`
+	Status          string        `json:"status"`
+	Currency        string        `json:"currency"`
+	Total           string        `json:"total"`
+	DateCreatedGMT  string        `json:"date_created_gmt"`
+	DateModifiedGMT string        `json:"date_modified_gmt"`
+	LineItems       []wcOrderLine `json:"line_items"`
+}
+
+type wcOrderLine struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	ProductID   int64   `json:"product_id"`
+	VariationID int64   `json:"variation_id"`
+	Quantity    float64 `json:"quantity"`
+	SKU         string  `json:"sku"`
+	Total       string  `json:"total"`     // netto, po rabatach
+	TotalTax    string  `json:"total_tax"` // VAT pozycji
+}
+
+func (c WooOrders) pollInterval() time.Duration {
+	if c.PollSec <= 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(c.PollSec) * time.Second
+}
+
+func (c WooOrders) exportStatuses() []string {
+	if len(c.Statuses) == 0 {
+		return []string{"processing", "completed"}
+	}
+	return c.Statuses
+}
+
+func (c WooOrders) format() string {
+	if strings.EqualFold(strings.TrimSpace(c.Format), "xml") {
+		return "xml"
+	}
+	return "csv"
+}
+
+func (w *Woo) runOrderPuller(ctx context.Context, gdb *gorm.DB) {
+	cfg := w.cfg.Orders
+	if strings.TrimSpace(cfg.OutboxDir) == "" {
+		w.log.Warn().Msg("orders: brak outbox_dir — zamówienia będą tylko zapisywane w bazie")
+	}
+
+	ticker := time.NewTicker(cfg.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.pullOrders(ctx, gdb); err != nil && ctx.Err() == nil {
+			w.log.Error().Err(err).Msg("orders: pull failed")
+		}
+		if _, err := w.exportOrders(gdb); err != nil {
+			w.log.Error().Err(err).Msg("orders: export failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pullOrders pobiera zamówienia zmienione od kursora (rosnąco po date_modified_gmt) i zapisuje je w bazie.
+// Kursor przesuwany jest po każdej stronie, więc przerwany przelot nie gubi postępu.
+func (w *Woo) pullOrders(ctx context.Context, gdb *gorm.DB) (int, error) {
+	cursor, ok := kvGetTime(gdb, w.kvKey(ordersKVKey))
+	if !ok {
+		// pierwszy raz: cofamy się o 24h, żeby nie ciągnąć całej historii sklepu
+		cursor = time.Now().UTC().Add(-24 * time.Hour)
+	}
+
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return 0, err
+	}
+	base.Path = "/wp-json/wc/v3/orders"
+
+	const perPage = 100
+	total := 0
+	// modified_after jest ostre — sekunda zapasu łapie zamówienia z tym samym czasem na granicy stron;
+	// powtórzone zamówienia są po prostu upsertowane
+	since := cursor.Add(-time.Second).UTC().Format("2006-01-02T15:04:05")
+
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("modified_after", since)
+		q.Set("dates_are_gmt", "true")
+		q.Set("orderby", "modified")
+		q.Set("order", "asc")
+		q.Set("per_page", strconv.Itoa(perPage))
+		q.Set("page", strconv.Itoa(page))
+		base.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+		if err != nil {
+			return total, err
+		}
+		req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
+		req.Header.Set("User-Agent", "PCM2WWW/1.0")
+
+		resp, err := w.client().Do(req)
+		if err != nil {
+			return total, fmt.Errorf("orders page %d: %w", page, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return total, &wooHTTPError{Op: "orders", StatusCode: resp.StatusCode}
+		}
+		var items []wcOrder
+		err = json.NewDecoder(resp.Body).Decode(&items)
+		resp.Body.Close()
+		if err != nil {
+			return total, fmt.Errorf("decode orders page %d: %w", page, err)
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		newest, err := w.saveOrders(gdb, items)
+		if err != nil {
+			return total, err
+		}
+		total += len(items)
+		if newest.After(cursor) {
+			cursor = newest
+			if err := kvSetTime(gdb, w.kvKey(ordersKVKey), cursor); err != nil {
+				w.log.Error().Err(err).Msg("kvSetTime failed")
+			}
+		}
+		if len(items) < perPage {
+			break
+		}
+	}
+
+	if total > 0 {
+		w.log.Info().Int("orders", total).Time("cursor", cursor).Msg("orders: pull done")
+	}
+	return total, nil
+}
+
+// saveOrders upsertuje nagłówki i pozycje jednej strony. Zwraca najnowszy date_modified_gmt.
+func (w *Woo) saveOrders(gdb *gorm.DB, items []wcOrder) (time.Time, error) {
+	var newest time.Time
+
+	// towar_id / EAN z cache dla wszystkich produktów na stronie jednym zapytaniem
+	var wooIDs []uint
+	for _, o := range items {
+		for _, li := range o.LineItems {
+			wooIDs = append(wooIDs, orderLineWooID(li))
+		}
+	}
+	byWoo := make(map[uint]db.WooProductCache, len(wooIDs))
+	if len(wooIDs) > 0 {
+		var cached []db.WooProductCache
+		if err := w.ofShop(gdb).Select("woo_id", "towar_id", "ean").Where("woo_id IN ?", wooIDs).Find(&cached).Error; err != nil {
+			return newest, fmt.Errorf("orders: load cache: %w", err)
+		}
+		for _, c := range cached {
+			byWoo[c.WooID] = c
+		}
+	}
+
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		for _, o := range items {
+			if tm, err := parseWooTimeUTC(o.DateModifiedGMT); err == nil && tm.After(newest) {
+				newest = tm
+			}
+
+			var prev db.WooOrder
+			found := w.ofShop(tx).Where("order_id = ?", o.ID).Limit(1).Find(&prev).RowsAffected > 0
+			if found && prev.ExportedAt != nil && prev.Status != o.Status && !slices.Contains(w.cfg.Orders.exportStatuses(), o.Status) {
+				// dokument już poszedł do PCM — korektę (anulowanie, zwrot) trzeba zrobić ręcznie
+				w.log.Warn().
+					Int64("order_id", o.ID).
+					Str("number", o.Number).
+					Str("status", o.Status).
+					Str("export_file", prev.ExportFile).
+					Msg("orders: wyeksportowane zamówienie zmieniło status, wymagana korekta w PCM")
+			}
+
+			order := db.WooOrder{
+				Shop:         w.shop,
+				OrderID:      uint(o.ID),
+				Number:       o.Number,
+				Status:       o.Status,
+				Currency:     o.Currency,
+				Total:        parsePrice(o.Total),
+				DateCreated:  o.DateCreatedGMT,
+				DateModified: o.DateModifiedGMT,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "shop"}, {Name: "order_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"number", "status", "currency", "total", "date_created", "date_modified", "updated_at"}),
+			}).Create(&order).Error; err != nil {
+				return fmt.Errorf("upsert order %d: %w", o.ID, err)
+			}
+
+			// pozycje usunięte z zamówienia w Woo (edycja w panelu) nie mogą zostać w eksporcie do PCM
+			lineIDs := make([]uint, 0, len(o.LineItems))
+			for _, li := range o.LineItems {
+				lineIDs = append(lineIDs, uint(li.ID))
+			}
+			stale := w.ofShop(tx).Where("order_id = ?", o.ID)
+			if len(lineIDs) > 0 {
+				stale = stale.Where("line_id NOT IN ?", lineIDs)
+			}
+			if err := stale.Delete(&db.WooOrderLine{}).Error; err != nil {
+				return fmt.Errorf("delete stale lines of order %d: %w", o.ID, err)
+			}
+
+			if len(o.LineItems) == 0 {
+				continue
+			}
+			lines := make([]db.WooOrderLine, 0, len(o.LineItems))
+			for _, li := range o.LineItems {
+				line := db.WooOrderLine{
+					Shop:        w.shop,
+					OrderID:     uint(o.ID),
+					LineID:      uint(li.ID),
+					ProductID:   uint(max(li.ProductID, 0)),
+					VariationID: uint(max(li.VariationID, 0)),
+					WooID:       orderLineWooID(li),
+					SKU:         li.SKU,
+					Name:        li.Name,
+					Quantity:    li.Quantity,
+					TotalGross:  roundMoney(parsePrice(li.Total) + parsePrice(li.TotalTax)),
+				}
+				if li.Quantity != 0 {
+					line.PriceGross = roundMoney(line.TotalGross / li.Quantity)
+				}
+				if c, ok := byWoo[line.WooID]; ok {
+					line.TowarID = c.TowarID
+					line.Ean = c.Ean
+				}
+				lines = append(lines, line)
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "shop"}, {Name: "order_id"}, {Name: "line_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"product_id", "variation_id", "woo_id", "towar_id", "ean", "sku", "name", "quantity", "price_gross", "total_gross",
+				}),
+			}).Create(&lines).Error; err != nil {
+				return fmt.Errorf("upsert lines of order %d: %w", o.ID, err)
+			}
+		}
+		return nil
+	})
+	return newest, err
+}
+
+func orderLineWooID(li wcOrderLine) uint {
+	if li.VariationID > 0 {
+		return uint(li.VariationID)
+	}
+	return uint(max(li.ProductID, 0))
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// exportOrders zapisuje do outbox dokumenty zamówień w statusie eksportowanym, które jeszcze nie poszły.
+// Plik powstaje jako .tmp i jest przemianowywany, żeby PCM nie wczytał połowy dokumentu.
+func (w *Woo) exportOrders(gdb *gorm.DB) (int, error) {
+	cfg := w.cfg.Orders
+	outbox := expandHome(strings.TrimSpace(cfg.OutboxDir))
+	if outbox == "" {
+		return 0, nil
+	}
+	if err := os.MkdirAll(outbox, 0o755); err != nil {
+		return 0, fmt.Errorf("orders: outbox: %w", err)
+	}
+
+	var orders []db.WooOrder
+	if err := w.ofShop(gdb).Where("exported_at IS NULL AND status IN ?", cfg.exportStatuses()).
+		Order("order_id").Find(&orders).Error; err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	for _, o := range orders {
+		var lines []db.WooOrderLine
+		if err := w.ofShop(gdb).Where("order_id = ?", o.OrderID).Order("line_id").Find(&lines).Error; err != nil {
+			return exported, err
+		}
+
+		name := fmt.Sprintf("woo_zam_%d.%s", o.OrderID, cfg.format())
+		if w.shop != "" {
+			// numery zamówień dwóch sklepów się pokrywają
+			name = fmt.Sprintf("woo_zam_%s_%d.%s", w.shop, o.OrderID, cfg.format())
+		}
+		path := filepath.Join(outbox, name)
+		if err := writeOrderDocument(path, cfg.format(), o, lines); err != nil {
+			return exported, fmt.Errorf("orders: export %d: %w", o.OrderID, err)
+		}
+
+		now := time.Now()
+		if err := w.ofShop(gdb).Model(&db.WooOrder{}).Where("order_id = ?", o.OrderID).
+			Updates(map[string]any{"exported_at": now, "export_file": name}).Error; err != nil {
+			return exported, err
+		}
+		exported++
+
+		unlinked := 0
+		for _, l := range lines {
+			if l.TowarID == nil {
+				unlinked++
+			}
+		}
+		ev := w.log.Info()
+		if unlinked > 0 {
+			ev = w.log.Warn().Int("unlinked_lines", unlinked)
+		}
+		ev.Uint("order_id", o.OrderID).Str("number", o.Number).Int("lines", len(lines)).Str("file", name).
+			Msg("orders: dokument zamówienia zapisany w outbox")
+	}
+	return exported, nil
+}
+
+func writeOrderDocument(path, format string, o db.WooOrder, lines []db.WooOrderLine) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if format == "xml" {
+		err = writeOrderXML(f, o, lines)
+	} else {
+		err = writeOrderCSV(f, o, lines)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeOrderCSV: jedna linia na pozycję, separator ";" (jak w eksportach dla PCM / Excela).
+func writeOrderCSV(f *os.File, o db.WooOrder, lines []db.WooOrderLine) error {
+	cw := csv.NewWriter(f)
+	cw.Comma = ';'
+	if err := cw.Write([]string{"zamowienie_id", "numer", "data", "lp", "towar_id", "kod", "sku", "nazwa", "ilosc", "cena_brutto", "wartosc_brutto"}); err != nil {
+		return err
+	}
+	for i, l := range lines {
+		if err := cw.Write([]string{
+			strconv.FormatUint(uint64(o.OrderID), 10), o.Number, o.DateCreated, strconv.Itoa(i + 1),
+			optionalInt(l.TowarID), l.Ean, l.SKU, l.Name,
+			formatWooPrice(l.Quantity), formatWooPrice(l.PriceGross), formatWooPrice(l.TotalGross),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type orderXML struct {
+	XMLName xml.Name       `xml:"zamowienie"`
+	Zrodlo  string         `xml:"zrodlo,attr"`
+	ID      uint           `xml:"id,attr"`
+	Numer   string         `xml:"numer,attr"`
+	Status  string         `xml:"status,attr"`
+	Data    string         `xml:"data,attr"`
+	Waluta  string         `xml:"waluta,attr"`
+	Wartosc string         `xml:"wartosc_brutto,attr"`
+	Pozycje []orderLineXML `xml:"pozycja"`
+}
+
+type orderLineXML struct {
+	Lp            int    `xml:"lp,attr"`
+	TowarID       string `xml:"towar_id,attr,omitempty"`
+	Kod           string `xml:"kod,attr,omitempty"`
+	SKU           string `xml:"sku,attr,omitempty"`
+	Ilosc         string `xml:"ilosc,attr"`
+	CenaBrutto    string `xml:"cena_brutto,attr"`
+	WartoscBrutto string `xml:"wartosc_brutto,attr"`
+	Nazwa         string `xml:",chardata"`
+}
+
+func writeOrderXML(f *os.File, o db.WooOrder, lines []db.WooOrderLine) error {
+	doc := orderXML{
+		Zrodlo:  "woocommerce",
+		ID:      o.OrderID,
+		Numer:   o.Number,
+		Status:  o.Status,
+		Data:    o.DateCreated,
+		Waluta:  o.Currency,
+		Wartosc: formatWooPrice(o.Total),
+	}
+	for i, l := range lines {
+		doc.Pozycje = append(doc.Pozycje, orderLineXML{
+			Lp:            i + 1,
+			TowarID:       optionalInt(l.TowarID),
+			Kod:           l.Ean,
+			SKU:           l.SKU,
+			Ilosc:         formatWooPrice(l.Quantity),
+			CenaBrutto:    formatWooPrice(l.PriceGross),
+			WartoscBrutto: formatWooPrice(l.TotalGross),
+			Nazwa:         l.Name,
+		})
+	}
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := f.WriteString("\n")
+	return err
+}
+
+func optionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
@@ -0,0 +1,166 @@
+package woocommerce
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestPullOrdersStoresLinesAndExportsDocumentOnce(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+	towarA, towarB := int64(101), int64(202)
+	if err := gdb.Create([]db.WooProductCache{
+		{WooID: 10, TowarID: &towarA, Ean: "5900000000010", Type: "simple"},
+		{WooID: 21, ParentID: 2, TowarID: &towarB, Ean: "5900000000021", Type: "variation"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	orders := []map[string]any{
+		{
+			"id": 500, "number": "500", "status": "processing", "currency": "PLN", "total": "73.80",
+			"date_created_gmt": "2026-03-01T10:00:00", "date_modified_gmt": "2026-03-01T10:05:00",
+			"line_items": []map[string]any{
+				{"id": 1, "name": "Prosty", "product_id": 10, "variation_id": 0, "quantity": 2, "sku": "S-10", "total": "40.00", "total_tax": "9.20"},
+				{"id": 2, "name": "Wino 0,75", "product_id": 2, "variation_id": 21, "quantity": 1, "sku": "W-075", "total": "20.00", "total_tax": "4.60"},
+				{"id": 3, "name": "Spoza PCM", "product_id": 99, "quantity": 1, "total": "0.00", "total_tax": "0.00"},
+			},
+		},
+		{
+			"id": 501, "number": "501", "status": "pending", "currency": "PLN", "total": "10.00",
+			"date_created_gmt": "2026-03-01T11:00:00", "date_modified_gmt": "2026-03-01T11:00:00",
+			"line_items": []map[string]any{},
+		},
+	}
+	var queries []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/wp-json/wc/v3/orders" {
+			return textResponse(http.StatusNotFound, "not found"), nil
+		}
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("page") != "1" {
+			return jsonResponse(http.StatusOK, []map[string]any{})
+		}
+		return jsonResponse(http.StatusOK, orders)
+	})}
+
+	if err := kvSetTime(gdb, ordersKVKey, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	outbox := t.TempDir()
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", Orders: WooOrders{Enabled: true, OutboxDir: outbox}},
+		http: client,
+	}
+	n, err := w.pullOrders(context.Background(), gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 orders, got %d", n)
+	}
+	if !strings.Contains(queries[0], "modified_after=2026-02-28T23%3A59%3A59") || !strings.Contains(queries[0], "dates_are_gmt=true") {
+		t.Fatalf("expected cursor query, got %s", queries[0])
+	}
+	cursor, ok := kvGetTime(gdb, ordersKVKey)
+	if !ok || cursor.Format("2006-01-02T15:04:05") != "2026-03-01T11:00:00" {
+		t.Fatalf("unexpected cursor: %v %v", cursor, ok)
+	}
+
+	var lines []db.WooOrderLine
+	if err := gdb.Where("order_id = ?", 500).Order("line_id").Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %+v", lines)
+	}
+	if lines[0].TowarID == nil || *lines[0].TowarID != towarA || lines[0].PriceGross != 24.6 || lines[0].TotalGross != 49.2 {
+		t.Fatalf("unexpected simple line: %+v", lines[0])
+	}
+	if lines[1].WooID != 21 || lines[1].TowarID == nil || *lines[1].TowarID != towarB || lines[1].Ean != "5900000000021" {
+		t.Fatalf("variation line must map through variation_id: %+v", lines[1])
+	}
+	if lines[2].TowarID != nil {
+		t.Fatalf("unlinked product must not get towar_id: %+v", lines[2])
+	}
+
+	exported, err := w.exportOrders(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Fatalf("only the processing order should be exported, got %d", exported)
+	}
+	raw, err := os.ReadFile(filepath.Join(outbox, "woo_zam_500.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(raw)
+	if !strings.HasPrefix(doc, "zamowienie_id;numer;data;lp;towar_id;kod;sku;nazwa;ilosc;cena_brutto;wartosc_brutto\n") ||
+		!strings.Contains(doc, "500;500;2026-03-01T10:00:00;1;101;5900000000010;S-10;Prosty;2;24.6;49.2\n") ||
+		!strings.Contains(doc, "500;500;2026-03-01T10:00:00;3;;;;Spoza PCM;1;0;0\n") {
+		t.Fatalf("unexpected order document:\n%s", doc)
+	}
+
+	// drugi przelot: zamówienie anulowane po eksporcie — status w bazie się zmienia, dokument nie jest powtarzany
+	orders[0]["status"] = "cancelled"
+	orders[0]["date_modified_gmt"] = "2026-03-01T12:00:00"
+	orders[0]["line_items"] = orders[0]["line_items"].([]map[string]any)[:2]
+	if _, err := w.pullOrders(context.Background(), gdb); err != nil {
+		t.Fatal(err)
+	}
+	if exported, err := w.exportOrders(gdb); err != nil || exported != 0 {
+		t.Fatalf("exported order must not be exported again: %d %v", exported, err)
+	}
+	var order db.WooOrder
+	if err := gdb.Where("order_id = ?", 500).Take(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != "cancelled" || order.ExportedAt == nil || order.ExportFile != "woo_zam_500.csv" {
+		t.Fatalf("unexpected order after second pull: %+v", order)
+	}
+	if err := gdb.Where("order_id = ?", 500).Order("line_id").Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[1].LineID != 2 {
+		t.Fatalf("line removed in Woo must be deleted, got %+v", lines)
+	}
+	if entries, _ := os.ReadDir(outbox); len(entries) != 1 {
+		t.Fatalf("expected exactly one file in outbox, got %d", len(entries))
+	}
+}
+
+func TestExportOrdersWritesXMLDocument(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+	towarID := int64(7)
+	if err := gdb.Create(&db.WooOrder{OrderID: 42, Number: "42", Status: "completed", Currency: "PLN", Total: 12.3, DateCreated: "2026-03-02T08:00:00"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooOrderLine{OrderID: 42, LineID: 1, WooID: 10, TowarID: &towarID, Ean: "590", Name: "Sok & woda", Quantity: 1, PriceGross: 12.3, TotalGross: 12.3}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	outbox := t.TempDir()
+	w := &Woo{log: zerolog.Nop(), cfg: Config{Orders: WooOrders{OutboxDir: outbox, Format: "xml"}}}
+	if n, err := w.exportOrders(gdb); err != nil || n != 1 {
+		t.Fatalf("export: %d %v", n, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(outbox, "woo_zam_42.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(raw)
+	if !strings.Contains(doc, `<zamowienie zrodlo="woocommerce" id="42" numer="42" status="completed"`) ||
+		!strings.Contains(doc, `<pozycja lp="1" towar_id="7" kod="590" ilosc="1" cena_brutto="12.3" wartosc_brutto="12.3">Sok &amp; woda</pozycja>`) {
+		t.Fatalf("unexpected XML document:\n%s", doc)
+	}
+}
@@ -0,0 +1,173 @@
+// internal/integrations/woocommerce/preview.go
+package woocommerce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// Tryb dry_run: worker claim-uje taski i czyta aktualny stan produktów (GET), ale zamiast PUT / POST
+// zapisuje w tasku request, który by wysłał, i różnice pól — status previewed.
+// Planner ponawia taski previewed jak error/skipped, więc po wyłączeniu dry_run kolejny import je wykona.
+
+// errDryRunWrite: zabezpieczenie metod zapisu — handler w trybie dry_run kończy task podglądem
+// (previewProductUpdate / previewWooTask) przed wywołaniem PUT / POST.
+var errDryRunWrite = errors.New("dry_run: zapis do Woo zablokowany")
+
+// newPreview buduje podgląd requestu; live == nil oznacza nowy produkt (wszystkie pola są zmianą).
+func newPreview(method, path string, body map[string]any, live *wcProduct) db.WooTaskPreview {
+	return db.WooTaskPreview{
+		Method: method,
+		Path:   path,
+		Body:   body,
+		Diff:   diffProductBody(body, live),
+	}
+}
+
+// previewProductUpdate kończy pojedynczy task w trybie dry_run podglądem PUT względem produktu
+// pobranego przez handler przed zapisem.
+func (w *Woo) previewProductUpdate(gdb *gorm.DB, task db.WooTask, parentID, wooID uint, body map[string]any, live wcProduct) {
+	w.previewWooTask(gdb, task, newPreview(http.MethodPut, productPath(parentID, wooID), body, &live))
+	w.logImportBatchStatus(gdb, task.ImportID)
+}
+
+func (w *Woo) previewWooTask(gdb *gorm.DB, task db.WooTask, preview db.WooTaskPreview) {
+	raw, err := json.Marshal(preview)
+	if err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("encode dry run preview: %w", err))
+		return
+	}
+	_ = gdb.Model(&db.WooTask{}).
+		Where("task_id = ?", task.TaskID).
+		Updates(map[string]any{
+			"status":       "previewed",
+			"last_error":   "",
+			"preview_json": string(raw),
+			"finished_at":  time.Now(),
+		}).Error
+	w.log.Info().
+		Uint("task_id", task.TaskID).
+		Uint("import_id", task.ImportID).
+		Str("kind", task.Kind).
+		Str("method", preview.Method).
+		Str("path", preview.Path).
+		Int("changes", len(preview.Diff)).
+		Msg("woo worker: dry run, request not sent")
+}
+
+// diffProductBody porównuje pola body z aktualnym produktem. meta_data rozbijane jest na meta_data.<key>.
+func diffProductBody(body map[string]any, live *wcProduct) []db.WooFieldDiff {
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		if key != "id" && key != "meta_data" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var out []db.WooFieldDiff
+	add := func(field, current, desired string) {
+		if !previewValuesEqual(current, desired) {
+			out = append(out, db.WooFieldDiff{Field: field, Current: current, Desired: desired})
+		}
+	}
+	for _, key := range keys {
+		current := ""
+		if live != nil {
+			current = productFieldValue(*live, key)
+		}
+		add(key, current, previewValue(body[key]))
+	}
+	if items, ok := body["meta_data"].([]map[string]any); ok {
+		for _, item := range items {
+			key := fmt.Sprint(item["key"])
+			current := ""
+			if live != nil {
+				current = live.metaValue(key)
+			}
+			add("meta_data."+key, current, previewValue(item["value"]))
+		}
+	}
+	return out
+}
+
+// productFieldValue zwraca pole produktu w tej samej postaci tekstowej co previewValue.
+func productFieldValue(p wcProduct, key string) string {
+	switch key {
+	case "manage_stock":
+		return strconv.FormatBool(p.ManageStock)
+	case "stock_quantity":
+		return formatWooPrice(p.StockQuantity)
+	case "stock_status":
+		return p.StockStatus
+	case "backorders":
+		return p.Backorders
+	case "catalog_visibility":
+		return p.CatalogVisibility
+	case "tax_class":
+		return p.TaxClass
+	}
+	return p.topLevelValue(key)
+}
+
+func previewValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return formatWooPrice(x)
+	case string:
+		return strings.TrimSpace(x)
+	}
+	return fmt.Sprint(v)
+}
+
+func previewValuesEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && floatAlmostEqual(fa, fb)
+}
+
+// PreviewRow to jeden task previewed w eksporcie (raport preview).
+type PreviewRow struct {
+	TaskID   uint              `json:"task_id"`
+	Shop     string            `json:"shop,omitempty"` // instancja woocommerce[:<shop>] ("" = domyślna)
+	ImportID uint              `json:"import_id"`
+	Kind     string            `json:"kind"`
+	WooID    *uint             `json:"woo_id"`
+	TowarID  *int64            `json:"towar_id"`
+	Preview  db.WooTaskPreview `json:"preview"`
+}
+
+// LoadPreviewRows czyta taski previewed (importID == 0 — wszystkie importy).
+func LoadPreviewRows(gdb *gorm.DB, importID uint) ([]PreviewRow, error) {
+	tx := gdb.Where("status = ?", "previewed")
+	if importID != 0 {
+		tx = tx.Where("import_id = ?", importID)
+	}
+	var tasks []db.WooTask
+	if err := tx.Order("shop, import_id, task_id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]PreviewRow, 0, len(tasks))
+	for _, t := range tasks {
+		row := PreviewRow{TaskID: t.TaskID, Shop: t.Shop, ImportID: t.ImportID, Kind: t.Kind, WooID: t.WooID, TowarID: t.TowarID}
+		if err := json.Unmarshal([]byte(t.PreviewJSON), &row.Preview); err != nil {
+			return nil, fmt.Errorf("task %d: decode preview: %w", t.TaskID, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,78 @@
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestWorkerTickDryRunPreviewsWithoutWriting(t *testing.T) {
+	state := map[uint]wcProduct{
+		10: {ID: 10, Name: "Dry Product", SKU: "SKU-10", RegularPrice: "20", ManageStock: true, StockQuantity: 1, Status: "publish", Type: "simple"},
+	}
+	inner := newWooWorkerTestClient(t, state)
+	var writes []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+		}
+		return inner.Transport.RoundTrip(r)
+	})}
+
+	gdb := newWooWorkerTestDB(t)
+	towarID, wooID := int64(101), uint(10)
+	eanPayload, _ := json.Marshal(db.WooEANUpdatePayload{ImportID: 1, WooID: wooID, TowarID: towarID, DesiredEAN: "5901234567890"})
+	stockPayload, _ := json.Marshal(db.WooStockUpdatePayload{ImportID: 1, WooID: wooID, TowarID: towarID, DesiredStock: 4})
+	createPayload, _ := json.Marshal(db.WooProductCreatePayload{ImportID: 1, TowarID: 102, SKU: "NEW", ProductName: "Nowy", EAN: "5909999999999", DesiredRegular: 9.5})
+	if err := gdb.Create([]db.WooTask{
+		{TaskKey: "ean.update:10:5901234567890", ImportID: 1, TowarID: &towarID, WooID: &wooID, Kind: db.WooTaskKindEANUpdate, PayloadJSON: string(eanPayload), Status: "pending"},
+		{TaskKey: "stock.update:10:4", ImportID: 1, TowarID: &towarID, WooID: &wooID, Kind: db.WooTaskKindStockUpdate, PayloadJSON: string(stockPayload), Status: "pending"},
+		{TaskKey: "product.create:0:102", ImportID: 1, Kind: db.WooTaskKindProductCreate, PayloadJSON: string(createPayload), Status: "pending"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", DryRun: true},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	if len(writes) != 0 {
+		t.Fatalf("dry run must not write to Woo, got %v", writes)
+	}
+	if state[wooID].StockQuantity != 1 || state[wooID].GlobalUniqueID != "" || len(state) != 1 {
+		t.Fatalf("live state changed in dry run: %+v", state)
+	}
+
+	rows, err := LoadPreviewRows(gdb, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 previewed tasks, got %+v", rows)
+	}
+	byKind := make(map[string]PreviewRow, len(rows))
+	for _, r := range rows {
+		byKind[r.Kind] = r
+	}
+	stock := byKind[db.WooTaskKindStockUpdate].Preview
+	if stock.Method != http.MethodPost || stock.Path != "/wp-json/wc/v3/products/batch" ||
+		len(stock.Diff) != 1 || stock.Diff[0] != (db.WooFieldDiff{Field: "stock_quantity", Current: "1", Desired: "4"}) {
+		t.Fatalf("unexpected stock preview: %+v", stock)
+	}
+	ean := byKind[db.WooTaskKindEANUpdate].Preview
+	if ean.Method != http.MethodPut || ean.Path != "/wp-json/wc/v3/products/10" ||
+		len(ean.Diff) != 1 || ean.Diff[0].Field != "global_unique_id" || ean.Diff[0].Desired != "5901234567890" {
+		t.Fatalf("unexpected ean preview: %+v", ean)
+	}
+	create := byKind[db.WooTaskKindProductCreate].Preview
+	if create.Method != http.MethodPost || create.Body["sku"] != "NEW" || len(create.Diff) == 0 {
+		t.Fatalf("unexpected create preview: %+v", create)
+	}
+}
@@ -0,0 +1,204 @@
+// internal/integrations/woocommerce/reconcile.go
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// Pełne uzgodnienie cache: sweep widzi tylko produkty zmienione od ostatniego przelotu, więc produkty
+// usunięte albo przeniesione do kosza w Woo zostawały w woo_product_caches na zawsze i dostawały taski.
+// Reconcile pobiera same ID wszystkich produktów i wariantów (_fields=id,type), usuwa z cache wiersze,
+// których sklep już nie zwraca, a pending taski na te woo_id kończy jako skipped.
+
+const reconcileKVKey = "woo_cache_last_reconcile"
+
+func (w *Woo) runCacheReconciler(ctx context.Context, gdb *gorm.DB) {
+	intv := time.Duration(w.cfg.Cache.ReconcileIntervalMinutes) * time.Minute
+	if intv <= 0 {
+		w.log.Info().Msg("cache reconcile disabled (interval <= 0)")
+		return
+	}
+
+	// termin liczony od ostatniego przelotu z kvs — częste restarty nie odsuwają go w nieskończoność
+	wait := time.Duration(0)
+	if last, ok := kvGetTime(gdb, w.kvKey(reconcileKVKey)); ok {
+		wait = max(time.Until(last.Add(intv)), 0)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if _, err := w.reconcileOnce(ctx, gdb); err != nil {
+				w.log.Error().Err(err).Msg("cache reconcile failed")
+			}
+			timer.Reset(intv)
+		}
+	}
+}
+
+// reconcileOnce wykonuje jeden pełny przelot. Zwraca liczbę usuniętych wierszy cache.
+func (w *Woo) reconcileOnce(ctx context.Context, gdb *gorm.DB) (int, error) {
+	// migawka cache PRZED listowaniem — produkt założony w trakcie (product.create, sweep)
+	// nie jest w migawce, więc nie zostanie usunięty, nawet jeśli nie trafił już do listy
+	var cached []uint
+	if err := w.ofShop(gdb).Model(&db.WooProductCache{}).Pluck("woo_id", &cached).Error; err != nil {
+		return 0, fmt.Errorf("load cache ids: %w", err)
+	}
+	if len(cached) == 0 {
+		return 0, nil
+	}
+
+	live, err := w.listLiveProductIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(live) == 0 {
+		// pusta odpowiedź przy niepustym cache to raczej błąd sklepu / uprawnień niż pusty sklep
+		return 0, fmt.Errorf("woo returned no products, cache has %d rows — skipping reconcile", len(cached))
+	}
+
+	var vanished []uint
+	for _, id := range cached {
+		if _, ok := live[id]; !ok {
+			vanished = append(vanished, id)
+		}
+	}
+
+	flagged, err := w.removeVanishedProducts(gdb, vanished)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := kvSetTime(gdb, w.kvKey(reconcileKVKey), time.Now()); err != nil {
+		w.log.Error().Err(err).Msg("kvSetTime failed")
+	}
+	w.log.Info().
+		Int("live", len(live)).
+		Int("cached", len(cached)).
+		Int("removed", len(vanished)).
+		Int64("tasks_skipped", flagged).
+		Msg("cache reconcile done")
+	if len(vanished) > 0 {
+		w.log.Warn().Interface("woo_ids", vanished).Msg("cache reconcile: produkty usunięte w Woo, wiersze cache skasowane")
+	}
+	return len(vanished), nil
+}
+
+// removeVanishedProducts usuwa z cache produkty, których nie ma już w Woo, i kończy ich pending taski
+// jako skipped. Zwraca liczbę oznaczonych tasków. Wspólne dla reconcile i webhooka product.deleted.
+func (w *Woo) removeVanishedProducts(gdb *gorm.DB, wooIDs []uint) (int64, error) {
+	if len(wooIDs) == 0 {
+		return 0, nil
+	}
+	flagged := int64(0)
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(wooIDs); start += 500 {
+			chunk := wooIDs[start:min(start+500, len(wooIDs))]
+			if err := w.ofShop(tx).Where("woo_id IN ?", chunk).Delete(&db.WooProductCache{}).Error; err != nil {
+				return fmt.Errorf("delete vanished cache rows: %w", err)
+			}
+			res := w.ofShop(tx).Model(&db.WooTask{}).
+				Where("status = ? AND woo_id IN ?", "pending", chunk).
+				Updates(map[string]any{
+					"status":      "skipped",
+					"last_error":  "product no longer exists in Woo (deleted or trashed)",
+					"finished_at": time.Now(),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("skip tasks of vanished products: %w", res.Error)
+			}
+			flagged += res.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return flagged, nil
+}
+
+// listLiveProductIDs zwraca ID wszystkich produktów (bez kosza — domyślny status=any go pomija)
+// oraz wariantów produktów variable.
+func (w *Woo) listLiveProductIDs(ctx context.Context) (map[uint]struct{}, error) {
+	live := make(map[uint]struct{})
+	products, err := w.listIDs(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		live[uint(p.ID)] = struct{}{}
+		if p.Type != "variable" {
+			continue
+		}
+		variations, err := w.listIDs(ctx, uint(p.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variations {
+			live[uint(v.ID)] = struct{}{}
+		}
+	}
+	return live, nil
+}
+
+// listIDs stronicuje /products (parentID == 0) albo /products/{parent}/variations z _fields=id,type.
+func (w *Woo) listIDs(ctx context.Context, parentID uint) ([]wcProduct, error) {
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = productsCollectionPath(parentID)
+
+	const perPage = 100
+	var out []wcProduct
+	for page := 1; ; page++ {
+		q := base.Query()
+		q.Set("per_page", strconv.Itoa(perPage))
+		q.Set("page", strconv.Itoa(page))
+		q.Set("orderby", "id")
+		q.Set("order", "asc")
+		q.Set("_fields", "id,type")
+		base.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
+		req.Header.Set("User-Agent", "PCM2WWW/1.0")
+
+		resp, err := w.client().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("reconcile %s page %d: %w", base.Path, page, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, &wooHTTPError{Op: "reconcile " + base.Path, StatusCode: resp.StatusCode}
+		}
+		var items []wcProduct
+		err = json.NewDecoder(resp.Body).Decode(&items)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s page %d: %w", base.Path, page, err)
+		}
+
+		out = append(out, items...)
+		if len(items) < perPage {
+			return out, nil
+		}
+	}
+}
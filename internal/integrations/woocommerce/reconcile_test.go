@@ -0,0 +1,109 @@
+package woocommerce
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestReconcileOnceRemovesProductsDeletedInWoo(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if got := r.URL.Query().Get("_fields"); got != "id,type" {
+			t.Errorf("reconcile must only fetch ids, got _fields=%q", got)
+		}
+		if r.URL.Query().Get("page") != "1" {
+			return jsonResponse(http.StatusOK, []wcProduct{})
+		}
+		switch r.URL.Path {
+		case "/wp-json/wc/v3/products":
+			return jsonResponse(http.StatusOK, []wcProduct{{ID: 1, Type: "simple"}, {ID: 3, Type: "variable"}})
+		case "/wp-json/wc/v3/products/3/variations":
+			return jsonResponse(http.StatusOK, []wcProduct{{ID: 31}})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := gdb.Create([]db.WooProductCache{
+		{WooID: 1, Type: "simple"},
+		{WooID: 2, Type: "simple"},
+		{WooID: 3, Type: "variable"},
+		{WooID: 31, ParentID: 3, Type: "variation"},
+		{WooID: 32, ParentID: 3, Type: "variation"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	live, gone, goneVariation := uint(1), uint(2), uint(32)
+	if err := gdb.Create([]db.WooTask{
+		{TaskKey: "stock.update:1:5", WooID: &live, Kind: db.WooTaskKindStockUpdate, Status: "pending"},
+		{TaskKey: "stock.update:2:5", WooID: &gone, Kind: db.WooTaskKindStockUpdate, Status: "pending"},
+		{TaskKey: "price.update:2:10", WooID: &gone, Kind: db.WooTaskKindPriceUpdate, Status: "done"},
+		{TaskKey: "stock.update:32:5", WooID: &goneVariation, Kind: db.WooTaskKindStockUpdate, Status: "pending"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{log: zerolog.Nop(), cfg: Config{BaseURL: "https://woo.test"}, http: client}
+	removed, err := w.reconcileOnce(context.Background(), gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed cache rows, got %d", removed)
+	}
+
+	var ids []uint
+	if err := gdb.Model(&db.WooProductCache{}).Order("woo_id").Pluck("woo_id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 31 {
+		t.Fatalf("unexpected cache after reconcile: %v", ids)
+	}
+
+	status := map[string]string{}
+	var tasks []db.WooTask
+	if err := gdb.Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		status[task.TaskKey] = task.Status
+	}
+	want := map[string]string{
+		"stock.update:1:5":  "pending",
+		"stock.update:2:5":  "skipped",
+		"price.update:2:10": "done",
+		"stock.update:32:5": "skipped",
+	}
+	for key, st := range want {
+		if status[key] != st {
+			t.Fatalf("task %s: expected %s, got %s", key, st, status[key])
+		}
+	}
+	if _, ok := kvGetTime(gdb, reconcileKVKey); !ok {
+		t.Fatal("expected last reconcile time in kvs")
+	}
+}
+
+func TestReconcileOnceKeepsCacheWhenWooReturnsNothing(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, []wcProduct{})
+	})}
+	if err := gdb.Create(&db.WooProductCache{WooID: 1, Type: "simple"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{log: zerolog.Nop(), cfg: Config{BaseURL: "https://woo.test"}, http: client}
+	if _, err := w.reconcileOnce(context.Background(), gdb); err == nil {
+		t.Fatal("expected error for empty product list")
+	}
+	var count int64
+	gdb.Model(&db.WooProductCache{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("cache must stay untouched, got %d rows", count)
+	}
+}
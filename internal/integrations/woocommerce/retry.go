@@ -0,0 +1,97 @@
+package woocommerce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WooRetry steruje ponawianiem tasków po błędach przejściowych (HTTP 429/5xx, timeouty).
+// Zera = wartości domyślne.
+type WooRetry struct {
+	MaxAttempts  int `json:"max_attempts"`   // łączna liczba prób, po której task przechodzi w error (domyślnie 5)
+	BaseDelaySec int `json:"base_delay_sec"` // opóźnienie po pierwszej nieudanej próbie (domyślnie 30 s)
+	MaxDelaySec  int `json:"max_delay_sec"`  // górny limit opóźnienia (domyślnie 3600 s)
+}
+
+const (
+	defaultRetryMaxAttempts  = 5
+	defaultRetryBaseDelaySec = 30
+	defaultRetryMaxDelaySec  = 3600
+)
+
+func (r WooRetry) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return defaultRetryMaxAttempts
+}
+
+// delay zwraca opóźnienie przed kolejną próbą: base × 2^(attempts-1), nie więcej niż max.
+func (r WooRetry) delay(attempts int) time.Duration {
+	base := time.Duration(r.BaseDelaySec) * time.Second
+	if base <= 0 {
+		base = defaultRetryBaseDelaySec * time.Second
+	}
+	limit := time.Duration(r.MaxDelaySec) * time.Second
+	if limit <= 0 {
+		limit = defaultRetryMaxDelaySec * time.Second
+	}
+	d := base
+	for n := 1; n < attempts && d < limit; n++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// wooHTTPError to odpowiedź Woo z nieoczekiwanym statusem HTTP.
+// Treść komunikatu zostaje w dotychczasowym formacie ("http 400: {...}", "batch POST http 500").
+type wooHTTPError struct {
+	Op         string // np. "batch GET"; puste dla pojedynczych requestów
+	StatusCode int
+	Body       string
+}
+
+func (e *wooHTTPError) Error() string {
+	msg := fmt.Sprintf("http %d", e.StatusCode)
+	if e.Op != "" {
+		msg = e.Op + " " + msg
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// permanentWooError oznacza błąd, którego nie wolno ponawiać, choć pod spodem jest timeout albo
+// przerwanie — np. POST /products bez odpowiedzi: produkt mógł powstać, a ponowny POST założyłby duplikat.
+type permanentWooError struct {
+	err error
+}
+
+func (e *permanentWooError) Error() string { return e.err.Error() }
+func (e *permanentWooError) Unwrap() error { return e.err }
+
+func isPermanentWooError(err error) bool {
+	var permanent *permanentWooError
+	return errors.As(err, &permanent)
+}
+
+// isRetryableWooError: 429, 5xx i timeouty wracają do kolejki; 4xx (walidacja) i reszta błędów — nie.
+func isRetryableWooError(err error) bool {
+	if isPermanentWooError(err) {
+		return false
+	}
+	var httpErr *wooHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
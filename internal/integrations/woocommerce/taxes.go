@@ -0,0 +1,77 @@
+// internal/integrations/woocommerce/taxes.go
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Klasy podatkowe sklepu: przy starcie pobieramy /taxes/classes i zapisujemy slugi w kvs.
+// Planner importera sprawdza nimi mapowanie vat (vat_id → tax_class) — klasa, której sklep nie zna,
+// nie trafia do tasków cen, tylko do link_issues (unknown_tax_class).
+
+type wcTaxClass struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// refreshTaxClasses pobiera klasy podatkowe i zapisuje ich slugi w kvs instancji sklepu.
+func (w *Woo) refreshTaxClasses(ctx context.Context, gdb *gorm.DB) error {
+	classes, err := w.fetchTaxClasses(ctx)
+	if err != nil {
+		return err
+	}
+	slugs := make([]string, 0, len(classes))
+	for _, c := range classes {
+		slugs = append(slugs, c.Slug)
+	}
+	raw, err := json.Marshal(slugs)
+	if err != nil {
+		return err
+	}
+	row := db.KV{K: db.TaxClassesKVKey(w.shop), V: string(raw)}
+	if err := gdb.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "k"}},
+		DoUpdates: clause.AssignmentColumns([]string{"v"}),
+	}).Create(&row).Error; err != nil {
+		return fmt.Errorf("zapis klas podatkowych w kvs: %w", err)
+	}
+	w.log.Info().Strs("tax_classes", slugs).Msg("woocommerce: klasy podatkowe sklepu")
+	return nil
+}
+
+func (w *Woo) fetchTaxClasses(ctx context.Context) ([]wcTaxClass, error) {
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = "/wp-json/wc/v3/taxes/classes"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
+	req.Header.Set("User-Agent", "PCM2WWW/1.0")
+
+	resp, err := w.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", base.Path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &wooHTTPError{Op: "GET " + base.Path, StatusCode: resp.StatusCode}
+	}
+	var classes []wcTaxClass
+	if err := json.NewDecoder(resp.Body).Decode(&classes); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", base.Path, err)
+	}
+	return classes, nil
+}
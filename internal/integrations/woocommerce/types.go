@@ -15,6 +15,7 @@ type wcMetaData struct {
 
 type wcProduct struct {
 	ID              int64                      `json:"id"`
+	ParentID        int64                      `json:"parent_id"` // >0 dla wariantu (variation) produktu variable
 	Name            string                     `json:"name"`
 	SKU             string                     `json:"sku"`
 	GlobalUniqueID  string                     `json:"global_unique_id"`
@@ -29,7 +30,7 @@ type wcProduct struct {
 	StockStatus     string                     `json:"stock_status"` // instock / outofstock / onbackorder
 	Backorders        string                     `json:"backorders"`         // no / notify / yes
 	CatalogVisibility string                     `json:"catalog_visibility"` // visible / hidden / catalog / search
-	Type              string                     `json:"type"`               // "simple","variable", etc.
+	Type              string                     `json:"type"`               // "simple","variable","variation", etc.
 	MetaData        []wcMetaData               `json:"meta_data"`
 	DateModifiedGMT string                     `json:"date_modified_gmt"`
 	ExtraFields     map[string]json.RawMessage `json:"-"`
@@ -42,6 +43,11 @@ func (p wcProduct) cacheEAN() string {
 	return strings.TrimSpace(p.EAN)
 }
 
+// isVariation: wariant produktu variable — aktualizowany przez /products/{parent}/variations.
+func (p wcProduct) isVariation() bool {
+	return p.ParentID > 0
+}
+
 func (p *wcProduct) UnmarshalJSON(data []byte) error {
 	type alias wcProduct
 	var decoded alias
@@ -56,6 +62,7 @@ func (p *wcProduct) UnmarshalJSON(data []byte) error {
 
 	for _, key := range []string{
 		"id",
+		"parent_id",
 		"name",
 		"sku",
 		"global_unique_id",
@@ -0,0 +1,160 @@
+// internal/integrations/woocommerce/variations.go
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Warianty produktów variable żyją pod /products/{parent}/variations — mają własne ID, SKU, EAN,
+// stan i ceny, ale nie są zwracane przez /products. W cache trzymamy je jako osobne wiersze
+// z parent_id; worker wybiera endpoint po parent_id z payloadu taska.
+
+const wooProductsPath = "/wp-json/wc/v3/products"
+
+// productsCollectionPath: /products albo /products/{parent}/variations.
+func productsCollectionPath(parentID uint) string {
+	if parentID == 0 {
+		return wooProductsPath
+	}
+	return wooProductsPath + "/" + strconv.FormatUint(uint64(parentID), 10) + "/variations"
+}
+
+// productPath: /products/{id} albo /products/{parent}/variations/{id}.
+func productPath(parentID, wooID uint) string {
+	return productsCollectionPath(parentID) + "/" + strconv.FormatUint(uint64(wooID), 10)
+}
+
+// markVariation uzupełnia parent_id / type, których Woo nie zawsze zwraca dla wariantów (_fields, starsze wersje).
+func markVariation(p *wcProduct, parentID uint) {
+	if parentID == 0 {
+		return
+	}
+	p.ParentID = int64(parentID)
+	if strings.TrimSpace(p.Type) == "" {
+		p.Type = "variation"
+	}
+}
+
+// cacheColumns to kolumny nadpisywane przy upsercie woo_products_cache z prime / sweep.
+// towar_id i tax_class należą do linkera i workera.
+var cacheColumns = []string{
+	"parent_id", "kod", "ean", "name", "price_regular", "price_sale", "hurt_price", "omnibus_price", "custom_fields",
+	"stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility", "status", "type", "date_modified",
+}
+
+func (w *Woo) cacheRowFromProduct(p wcProduct) db.WooProductCache {
+	return db.WooProductCache{
+		Shop:              w.shop,
+		WooID:             uint(p.ID),
+		ParentID:          uint(max(p.ParentID, 0)),
+		TowarID:           nil, // nie znamy jeszcze mapowania z PCM – zostanie uzupełnione później
+		Kod:               p.SKU,
+		Ean:               p.cacheEAN(),
+		Name:              p.Name,
+		PriceRegular:      parsePrice(p.RegularPrice),
+		PriceSale:         parsePrice(p.SalePrice),
+		HurtPrice:         parsePrice(w.customFieldValue(p, "hurt_price")),
+		OmnibusPrice:      parsePrice(w.customFieldValue(p, "omnibus_price")),
+		CustomFields:      w.customFieldsJSON(p),
+		StockQty:          p.StockQuantity,
+		StockManaged:      p.ManageStock,
+		StockStatus:       p.StockStatus,
+		Backorders:        p.Backorders,
+		CatalogVisibility: p.CatalogVisibility,
+		Status:            p.Status,
+		Type:              p.Type,
+		DateModified:      p.DateModifiedGMT,
+	}
+}
+
+// fetchVariations pobiera wszystkie warianty produktu variable (stronicowanie po 100).
+func (w *Woo) fetchVariations(ctx context.Context, parent wcProduct) ([]wcProduct, error) {
+	parentID := uint(parent.ID)
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = productsCollectionPath(parentID)
+
+	const perPage = 100
+	var out []wcProduct
+	for page := 1; ; page++ {
+		q := base.Query()
+		q.Set("per_page", strconv.Itoa(perPage))
+		q.Set("page", strconv.Itoa(page))
+		q.Set("_fields", w.productFields())
+		base.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
+		req.Header.Set("User-Agent", "PCM2WWW/1.0")
+
+		resp, err := w.client().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("variations of %d page %d: %w", parentID, page, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, &wooHTTPError{Op: fmt.Sprintf("variations of %d", parentID), StatusCode: resp.StatusCode}
+		}
+		var items []wcProduct
+		err = json.NewDecoder(resp.Body).Decode(&items)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode variations of %d page %d: %w", parentID, page, err)
+		}
+
+		for idx := range items {
+			markVariation(&items[idx], parentID)
+			if strings.TrimSpace(items[idx].Name) == "" {
+				items[idx].Name = parent.Name
+			}
+		}
+		out = append(out, items...)
+		if len(items) < perPage {
+			return out, nil
+		}
+	}
+}
+
+// cacheVariations dociąga i upsertuje warianty dla produktów variable z listy. Zwraca liczbę wariantów.
+func (w *Woo) cacheVariations(ctx context.Context, gdb *gorm.DB, products []wcProduct) (int, error) {
+	total := 0
+	for _, p := range products {
+		if p.Type != "variable" {
+			continue
+		}
+		variations, err := w.fetchVariations(ctx, p)
+		if err != nil {
+			return total, err
+		}
+		if len(variations) == 0 {
+			continue
+		}
+		rows := make([]db.WooProductCache, 0, len(variations))
+		for _, v := range variations {
+			rows = append(rows, w.cacheRowFromProduct(v))
+		}
+		if err := gdb.Clauses(clause.OnConflict{
+			Columns:   cacheKeyColumns,
+			DoUpdates: clause.AssignmentColumns(cacheColumns),
+		}).Create(&rows).Error; err != nil {
+			return total, fmt.Errorf("upsert variations of %d: %w", p.ID, err)
+		}
+		total += len(rows)
+	}
+	return total, nil
+}
@@ -0,0 +1,144 @@
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func TestPrimeCacheStoresVariationsWithParent(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		page := r.URL.Query().Get("page")
+		switch r.URL.Path {
+		case "/wp-json/wc/v3/products":
+			if page != "1" {
+				return jsonResponse(http.StatusOK, []wcProduct{})
+			}
+			return jsonResponse(http.StatusOK, []wcProduct{
+				{ID: 1, Name: "Prosty", SKU: "S-1", Type: "simple", Status: "publish"},
+				{ID: 2, Name: "Wino", Type: "variable", Status: "publish"},
+			})
+		case "/wp-json/wc/v3/products/2/variations":
+			if page != "1" {
+				return jsonResponse(http.StatusOK, []wcProduct{})
+			}
+			// Woo nie zawsze zwraca parent_id / type / name dla wariantów
+			return jsonResponse(http.StatusOK, []map[string]any{
+				{"id": 21, "sku": "W-075", "global_unique_id": "5900000000021", "regular_price": "30", "manage_stock": true, "stock_quantity": 3},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	w := &Woo{log: zerolog.Nop(), cfg: Config{BaseURL: "https://woo.test"}, http: client}
+	if err := w.primeCache(context.Background(), gdb); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []db.WooProductCache
+	if err := gdb.Order("woo_id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 2 products + 1 variation in cache, got %+v", rows)
+	}
+	v := rows[2]
+	if v.WooID != 21 || v.ParentID != 2 || v.Type != "variation" || v.Name != "Wino" ||
+		v.Ean != "5900000000021" || v.StockQty != 3 || v.PriceRegular != 30 {
+		t.Fatalf("unexpected variation row: %+v", v)
+	}
+	if rows[0].ParentID != 0 || rows[1].ParentID != 0 {
+		t.Fatalf("top-level products must not have parent_id: %+v", rows[:2])
+	}
+}
+
+func TestWorkerTickUpdatesVariationsThroughVariationsBatch(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	state := map[string]wcProduct{
+		"/wp-json/wc/v3/products":              {ID: 10, Type: "simple", ManageStock: true, StockQuantity: 1},
+		"/wp-json/wc/v3/products/2/variations": {ID: 21, ManageStock: true, StockQuantity: 1},
+	}
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+
+		collection := strings.TrimSuffix(r.URL.Path, "/batch")
+		product, ok := state[collection]
+		if !ok {
+			return textResponse(http.StatusNotFound, "not found"), nil
+		}
+		if r.Method == http.MethodGet {
+			return jsonResponse(http.StatusOK, []wcProduct{product})
+		}
+		var body struct {
+			Update []map[string]any `json:"update"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return textResponse(http.StatusBadRequest, "bad json"), nil
+		}
+		for _, item := range body.Update {
+			applyProductUpdate(&product, item)
+		}
+		state[collection] = product
+		return jsonResponse(http.StatusOK, map[string]any{"update": []wcProduct{product}})
+	})}
+
+	simpleID, variationID, parentID := uint(10), uint(21), uint(2)
+	simplePayload, _ := json.Marshal(db.WooStockUpdatePayload{WooID: simpleID, TowarID: 1, DesiredStock: 5})
+	variationPayload, _ := json.Marshal(db.WooStockUpdatePayload{WooID: variationID, ParentID: parentID, TowarID: 2, DesiredStock: 7})
+	if err := gdb.Create([]db.WooTask{
+		{TaskKey: "stock.update:10:5", WooID: &simpleID, Kind: db.WooTaskKindStockUpdate, PayloadJSON: string(simplePayload), Status: "pending"},
+		{TaskKey: "stock.update:21:7", WooID: &variationID, Kind: db.WooTaskKindStockUpdate, PayloadJSON: string(variationPayload), Status: "pending"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{log: zerolog.Nop(), cfg: Config{BaseURL: "https://woo.test"}, http: client}
+	w.workerTick(context.Background(), gdb)
+
+	var tasks []db.WooTask
+	if err := gdb.Find(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if task.Status != "done" {
+			t.Fatalf("expected done task, got %+v", task)
+		}
+	}
+	want := map[string]bool{
+		"POST /wp-json/wc/v3/products/batch":              false,
+		"POST /wp-json/wc/v3/products/2/variations/batch": false,
+	}
+	for _, call := range calls {
+		if _, ok := want[call]; ok {
+			want[call] = true
+		}
+	}
+	for call, seen := range want {
+		if !seen {
+			t.Fatalf("expected %s, calls: %v", call, calls)
+		}
+	}
+
+	var cache db.WooProductCache
+	if err := gdb.Where("woo_id = ?", variationID).Take(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cache.ParentID != parentID || cache.Type != "variation" || cache.StockQty != 7 {
+		t.Fatalf("unexpected variation cache after worker: %+v", cache)
+	}
+}
@@ -0,0 +1,230 @@
+// internal/integrations/woocommerce/webhook.go
+package woocommerce
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/integrations"
+	"gorm.io/gorm"
+)
+
+// Webhooki Woo (product.created / updated / restored / deleted) aktualizują woo_product_caches od razu,
+// zamiast czekać na sweep co sweep_interval_minutes. Podpis X-WC-Webhook-Signature to
+// base64(HMAC-SHA256(body, secret)) — secret ustawiony przy webhooku w panelu Woo.
+
+type WooWebhook struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen,omitempty"` // domyślnie 127.0.0.1:8788 (wystawienie na zewnątrz przez reverse proxy); każdy sklep osobny
+	Path    string `json:"path,omitempty"`   // domyślnie /woo/webhook
+	Secret  string `json:"secret"`           // wymagany, ten sam co w ustawieniach webhooka w Woo
+}
+
+const maxWebhookBody = 5 << 20
+
+func (c WooWebhook) listenAddr() string {
+	if strings.TrimSpace(c.Listen) == "" {
+		return "127.0.0.1:8788"
+	}
+	return strings.TrimSpace(c.Listen)
+}
+
+// CheckWebhookListeners odrzuca config, w którym dwie instancje woocommerce z włączonym webhookiem
+// nasłuchują na tym samym adresie — druga nie otworzyłaby portu dopiero przy starcie. Bez webhook.listen
+// każda instancja dostaje ten sam adres domyślny, więc przy kilku sklepach listen trzeba ustawić.
+func CheckWebhookListeners(sections map[string]json.RawMessage) error {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		if kind, _ := integrations.SplitName(name); kind == "woocommerce" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	owners := make(map[string]string, len(names))
+	for _, name := range names {
+		var cfg Config
+		if err := json.Unmarshal(sections[name], &cfg); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !cfg.Webhook.Enabled {
+			continue
+		}
+		addr := cfg.Webhook.listenAddr()
+		if other, ok := owners[addr]; ok {
+			return fmt.Errorf("%s: webhook.listen %s zajęty już przez %s — każdy sklep potrzebuje własnego webhook.listen", name, addr, other)
+		}
+		owners[addr] = name
+	}
+	return nil
+}
+
+func (c WooWebhook) path() string {
+	p := strings.TrimSpace(c.Path)
+	if p == "" {
+		return "/woo/webhook"
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// startWebhookServer otwiera port webhooka i zamyka serwer razem z kontekstem integracji.
+func (w *Woo) startWebhookServer(ctx context.Context, gdb *gorm.DB) error {
+	cfg := w.cfg.Webhook
+	if strings.TrimSpace(cfg.Secret) == "" {
+		return errors.New("woocommerce webhook: brak secret (webhook.secret)")
+	}
+	ln, err := net.Listen("tcp", cfg.listenAddr())
+	if err != nil {
+		return fmt.Errorf("woocommerce webhook: listen %s: %w", cfg.listenAddr(), err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST "+cfg.path(), w.webhookHandler(gdb))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.log.Error().Err(err).Msg("woocommerce webhook: serwer zakończony z błędem")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	w.log.Info().Str("listen", ln.Addr().String()).Str("path", cfg.path()).Msg("woocommerce webhook: start")
+	return nil
+}
+
+func (w *Woo) webhookHandler(gdb *gorm.DB) http.Handler {
+	secret := []byte(w.cfg.Webhook.Secret)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(rw, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		topic := strings.TrimSpace(r.Header.Get("X-WC-Webhook-Topic"))
+		if topic == "" {
+			// ping Woo przy zapisie webhooka (webhook_id=N, bez podpisu) — nic nie zmienia
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+		if !validWebhookSignature(body, r.Header.Get("X-WC-Webhook-Signature"), secret) {
+			w.log.Warn().Str("topic", topic).Str("remote", r.RemoteAddr).Msg("woocommerce webhook: niepoprawny podpis")
+			http.Error(rw, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if err := w.applyProductWebhook(gdb, topic, body); err != nil {
+			w.log.Error().Err(err).Str("topic", topic).Msg("woocommerce webhook: apply failed")
+			// 5xx — Woo ponowi dostarczenie
+			http.Error(rw, "apply failed", http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func validWebhookSignature(body []byte, signature string, secret []byte) bool {
+	got, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// applyProductWebhook upsertuje albo usuwa wiersz cache. Mapowanie pól jest to samo co po weryfikacji
+// w workerze (upsertCacheProduct); powiązanie towar_id z linkera zostaje bez zmian.
+func (w *Woo) applyProductWebhook(gdb *gorm.DB, topic string, body []byte) error {
+	switch topic {
+	case "product.created", "product.updated", "product.restored":
+		var p wcProduct
+		if err := json.Unmarshal(body, &p); err != nil {
+			return fmt.Errorf("decode product: %w", err)
+		}
+		if p.ID <= 0 {
+			return fmt.Errorf("product without id")
+		}
+		if p.Status == "trash" {
+			return w.removeWebhookProduct(gdb, uint(p.ID))
+		}
+		if w.isStaleWebhook(gdb, p) {
+			w.log.Debug().Int64("woo_id", p.ID).Str("date_modified_gmt", p.DateModifiedGMT).
+				Msg("woocommerce webhook: starszy niż cache, pomijam")
+			return nil
+		}
+		if err := w.upsertCacheProduct(gdb, p, nil); err != nil {
+			return err
+		}
+		w.log.Info().Str("topic", topic).Int64("woo_id", p.ID).Int64("parent_id", p.ParentID).Msg("woocommerce webhook: cache updated")
+		return nil
+
+	case "product.deleted":
+		var p struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return fmt.Errorf("decode product: %w", err)
+		}
+		if p.ID <= 0 {
+			return fmt.Errorf("product without id")
+		}
+		return w.removeWebhookProduct(gdb, uint(p.ID))
+	}
+
+	w.log.Debug().Str("topic", topic).Msg("woocommerce webhook: temat pominięty")
+	return nil
+}
+
+// isStaleWebhook: Woo dostarcza webhooki asynchronicznie (kolejka Action Scheduler), więc stare
+// powiadomienie może przyjść po weryfikacji workera — nie cofamy wtedy cache.
+func (w *Woo) isStaleWebhook(gdb *gorm.DB, p wcProduct) bool {
+	incoming, err := parseWooTimeUTC(p.DateModifiedGMT)
+	if err != nil {
+		return false
+	}
+	var cached db.WooProductCache
+	if w.ofShop(gdb).Select("woo_id", "date_modified").Where("woo_id = ?", p.ID).Limit(1).Find(&cached).RowsAffected == 0 {
+		return false
+	}
+	current, err := parseWooTimeUTC(cached.DateModified)
+	return err == nil && incoming.Before(current)
+}
+
+// removeWebhookProduct usuwa produkt (i warianty, jeśli był variable) tak jak reconcile.
+func (w *Woo) removeWebhookProduct(gdb *gorm.DB, wooID uint) error {
+	ids := []uint{wooID}
+	var variations []uint
+	if err := w.ofShop(gdb).Model(&db.WooProductCache{}).Where("parent_id = ?", wooID).Pluck("woo_id", &variations).Error; err != nil {
+		return err
+	}
+	ids = append(ids, variations...)
+
+	flagged, err := w.removeVanishedProducts(gdb, ids)
+	if err != nil {
+		return err
+	}
+	w.log.Info().Uint("woo_id", wooID).Int("variations", len(variations)).Int64("tasks_skipped", flagged).
+		Msg("woocommerce webhook: product removed from cache")
+	return nil
+}
@@ -0,0 +1,127 @@
+package woocommerce
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/rs/zerolog"
+)
+
+func signWebhook(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func postWebhook(t *testing.T, h http.Handler, topic, body, signature string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/woo/webhook", strings.NewReader(body))
+	if topic != "" {
+		req.Header.Set("X-WC-Webhook-Topic", topic)
+	}
+	if signature != "" {
+		req.Header.Set("X-WC-Webhook-Signature", signature)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestProductWebhookUpsertsAndDeletesCacheRows(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+	towarID := int64(101)
+	if err := gdb.Create([]db.WooProductCache{
+		{WooID: 10, TowarID: &towarID, Ean: "5900000000010", StockQty: 1, PriceRegular: 10, Type: "simple", DateModified: "2026-03-01T10:00:00"},
+		{WooID: 2, Type: "variable"},
+		{WooID: 21, ParentID: 2, Type: "variation"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	gone := uint(21)
+	if err := gdb.Create(&db.WooTask{TaskKey: "stock.update:21:3", WooID: &gone, Kind: db.WooTaskKindStockUpdate, Status: "pending"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const secret = "tajne"
+	w := &Woo{log: zerolog.Nop(), cfg: Config{Webhook: WooWebhook{Enabled: true, Secret: secret}}}
+	h := w.webhookHandler(gdb)
+
+	updated := `{"id":10,"name":"Po zmianie","sku":"S-10","global_unique_id":"5900000000010","regular_price":"12.50","manage_stock":true,"stock_quantity":7,"status":"publish","type":"simple","tax_class":"800","date_modified_gmt":"2026-03-01T12:00:00"}`
+	if code := postWebhook(t, h, "product.updated", updated, "AAAA"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad signature, got %d", code)
+	}
+	if code := postWebhook(t, h, "product.updated", updated, signWebhook(updated, secret)); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	var row db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 10).Take(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.StockQty != 7 || row.PriceRegular != 12.5 || row.Name != "Po zmianie" || row.TaxClass != "800" {
+		t.Fatalf("cache not updated from webhook: %+v", row)
+	}
+	if row.TowarID == nil || *row.TowarID != towarID {
+		t.Fatalf("webhook must keep linker towar_id: %+v", row)
+	}
+
+	// spóźniony webhook ze starszym date_modified nie cofa cache
+	stale := `{"id":10,"stock_quantity":3,"manage_stock":true,"type":"simple","date_modified_gmt":"2026-03-01T11:00:00"}`
+	if code := postWebhook(t, h, "product.updated", stale, signWebhook(stale, secret)); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if err := gdb.Where("woo_id = ?", 10).Take(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.StockQty != 7 {
+		t.Fatalf("stale webhook overwrote cache: %+v", row)
+	}
+
+	deleted := `{"id":2}`
+	if code := postWebhook(t, h, "product.deleted", deleted, signWebhook(deleted, secret)); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	var count int64
+	gdb.Model(&db.WooProductCache{}).Where("woo_id IN ?", []uint{2, 21}).Count(&count)
+	if count != 0 {
+		t.Fatalf("deleted variable product and its variations must leave cache, %d rows left", count)
+	}
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "stock.update:21:3").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "skipped" {
+		t.Fatalf("pending task of deleted product must be skipped, got %+v", task)
+	}
+
+	// ping przy zapisie webhooka w Woo — bez tematu i podpisu
+	if code := postWebhook(t, h, "", "webhook_id=5", ""); code != http.StatusOK {
+		t.Fatalf("expected 200 for ping, got %d", code)
+	}
+}
+
+func TestCheckWebhookListenersRejectsSharedAddress(t *testing.T) {
+	sections := map[string]json.RawMessage{
+		"woocommerce":        json.RawMessage(`{"webhook":{"enabled":true,"secret":"s"}}`),
+		"woocommerce:b2b":    json.RawMessage(`{"webhook":{"enabled":true,"secret":"s","listen":"127.0.0.1:8789"}}`),
+		"woocommerce:outlet": json.RawMessage(`{"webhook":{"enabled":false}}`),
+		"importer":           json.RawMessage(`{"webhook":{"enabled":true}}`),
+	}
+	if err := CheckWebhookListeners(sections); err != nil {
+		t.Fatalf("distinct listen addresses must pass, got %v", err)
+	}
+
+	// drugi sklep bez webhook.listen dostałby ten sam adres domyślny co pierwszy
+	sections["woocommerce:outlet"] = json.RawMessage(`{"webhook":{"enabled":true,"secret":"s"}}`)
+	err := CheckWebhookListeners(sections)
+	if err == nil || !strings.Contains(err.Error(), "woocommerce:outlet") || !strings.Contains(err.Error(), "127.0.0.1:8788") {
+		t.Fatalf("shared default listen address must be rejected, got %v", err)
+	}
+}
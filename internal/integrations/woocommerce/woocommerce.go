@@ -6,17 +6,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/bartek5186/pcm2www/internal/integrations"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WooCache struct {
-	PrimeOnStart         bool   `json:"prime_on_start"`
-	SweepIntervalMinutes int    `json:"sweep_interval_minutes"`
-	Fields               string `json:"fields"`
+	PrimeOnStart             bool   `json:"prime_on_start"`
+	SweepIntervalMinutes     int    `json:"sweep_interval_minutes"`
+	ReconcileIntervalMinutes int    `json:"reconcile_interval_minutes,omitempty"` // pełne uzgodnienie ID (usunięte produkty); 0 = wyłączone
+	Fields                   string `json:"fields"`
 }
 
 type Config struct {
@@ -26,6 +29,10 @@ type Config struct {
 	PollSec      int                 `json:"poll_sec"` // co ile sekund sprawdzać (dev)
 	Workers      int                 `json:"workers"`  // liczba równoległych workerów (domyślnie 3)
 	Cache        WooCache            `json:"cache"`
+	Retry        WooRetry            `json:"retry,omitempty"`   // ponawianie po 429/5xx/timeoutach
+	DryRun       bool                `json:"dry_run,omitempty"` // worker tylko czyta Woo i zapisuje podgląd zmian (status previewed)
+	Orders       WooOrders           `json:"orders,omitempty"`  // pobieranie zamówień i eksport do PC-Market
+	Webhook      WooWebhook          `json:"webhook,omitempty"` // odbiór webhooków product.* (natychmiastowa aktualizacja cache)
 	CustomFields []CustomFieldConfig `json:"custom_fields,omitempty"`
 }
 
@@ -33,16 +40,25 @@ type Woo struct {
 	log  zerolog.Logger
 	cfg  Config
 	http *http.Client
+	shop string // nazwa instancji z klucza "woocommerce:<shop>" ("" = sklep domyślny)
 
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-func (w *Woo) Name() string { return "woocommerce" }
+func (w *Woo) Name() string {
+	if w.shop == "" {
+		return "woocommerce"
+	}
+	return "woocommerce:" + w.shop
+}
 
 func (w *Woo) Start(ctx context.Context) error {
 	w.ctx, w.cancel = context.WithCancel(ctx)
 	w.log.Info().Str("integration", w.Name()).Msg("start")
+	if w.cfg.DryRun {
+		w.log.Warn().Msg("woocommerce: dry_run — zmiany nie są wysyłane do sklepu, taski kończą się jako previewed")
+	}
 
 	// weź *gorm.DB z kontekstu (tak, jak w importerze)
 	raw := ctx.Value("gormDB")
@@ -51,6 +67,11 @@ func (w *Woo) Start(ctx context.Context) error {
 		return fmt.Errorf("woocommerce: brak *gorm.DB w kontekście")
 	}
 
+	// klasy podatkowe sklepu — planner sprawdza nimi mapowanie vat importera
+	if err := w.refreshTaxClasses(ctx, gdb); err != nil {
+		w.log.Error().Err(err).Msg("tax classes fetch failed")
+	}
+
 	// 1) PRIME CACHE — jednorazowo przy starcie
 	if w.cfg.Cache.PrimeOnStart {
 		if err := w.primeCache(ctx, gdb); err != nil {
@@ -62,6 +83,19 @@ func (w *Woo) Start(ctx context.Context) error {
 	if w.cfg.Cache.SweepIntervalMinutes > 0 {
 		go w.runCacheSweeper(w.ctx, gdb)
 	}
+	if w.cfg.Cache.ReconcileIntervalMinutes > 0 {
+		go w.runCacheReconciler(w.ctx, gdb)
+	}
+
+	if w.cfg.Webhook.Enabled {
+		if err := w.startWebhookServer(w.ctx, gdb); err != nil {
+			w.log.Error().Err(err).Msg("webhook start failed")
+			// bez webhooka cache dalej odświeża sweep
+		}
+	}
+	if w.cfg.Orders.Enabled {
+		go w.runOrderPuller(w.ctx, gdb)
+	}
 
 	// 2) odpal N workerów zadań
 	for range w.numWorkers() {
@@ -114,18 +148,54 @@ func (w *Woo) tick() {
 		Msg("ping (dev) – tutaj pobierz np. /wp-json/wc/v3/orders?per_page=1")
 }
 
-func factory(log zerolog.Logger, raw json.RawMessage) (integrations.Integration, error) {
+// cacheKeyColumns to klucz upsertu woo_product_caches: każda instancja sklepu ma własne wiersze.
+var cacheKeyColumns = []clause.Column{{Name: "shop"}, {Name: "woo_id"}}
+
+// kvKey rozdziela kursory w kvs (sweep, reconcile, zamówienia) między instancje sklepów.
+func (w *Woo) kvKey(base string) string {
+	if w.shop == "" {
+		return base
+	}
+	return base + ":" + w.shop
+}
+
+// ofShop zawęża zapytanie do wierszy (cache, taski, zamówienia) tej instancji sklepu.
+func (w *Woo) ofShop(gdb *gorm.DB) *gorm.DB {
+	return gdb.Where("shop = ?", w.shop)
+}
+
+func factory(log zerolog.Logger, instance string, raw json.RawMessage) (integrations.Integration, error) {
+	return New(log, instance, raw)
+}
+
+// New tworzy instancję sklepu z sekcji configu. Bez Start nadaje się do jednorazowych
+// operacji (CLI: cache prime / sweep) — nie uruchamia workerów ani odświeżania cache.
+func New(log zerolog.Logger, instance string, raw json.RawMessage) (*Woo, error) {
 	var cfg Config
 	if err := json.Unmarshal(raw, &cfg); err != nil {
 		return nil, err
 	}
+	if strings.ContainsAny(instance, "/: ") {
+		return nil, fmt.Errorf("woocommerce: niepoprawna nazwa instancji %q", instance)
+	}
 	return &Woo{
 		log:  log,
 		cfg:  cfg,
 		http: &http.Client{Timeout: 15 * time.Second},
+		shop: instance,
 	}, nil
 }
 
+// PrimeCache pobiera wszystkie produkty sklepu do woo_product_caches (jak prime_on_start).
+func (w *Woo) PrimeCache(ctx context.Context, gdb *gorm.DB) error {
+	return w.primeCache(ctx, gdb)
+}
+
+// SweepCache dociąga produkty zmienione od ostatniego sweepu i zwraca liczbę zapisanych wierszy.
+func (w *Woo) SweepCache(ctx context.Context, gdb *gorm.DB) (int, error) {
+	return w.sweepOnce(ctx, gdb)
+}
+
 func init() {
 	integrations.Register("woocommerce", factory)
 }
@@ -48,7 +48,7 @@ func (w *Woo) workerTick(ctx context.Context, gdb *gorm.DB) {
 		// 1) Spróbuj batch dla każdego batchable kind
 		didBatch := false
 		for _, kind := range batchableKinds {
-			tasks, err := claimNextNWooTasksOfKind(gdb, kind, workerBatchSize)
+			tasks, err := claimNextNWooTasksOfKind(gdb, w.shop, kind, workerBatchSize)
 			if err != nil {
 				w.log.Error().Err(err).Str("kind", kind).Msg("woo worker: claim batch failed")
 				return
@@ -65,7 +65,7 @@ func (w *Woo) workerTick(ctx context.Context, gdb *gorm.DB) {
 		}
 
 		// 2) Pozostałe typy (ean.update, availability.update) — sekwencyjnie
-		task, err := claimNextSequentialWooTask(gdb)
+		task, err := claimNextSequentialWooTask(gdb, w.shop)
 		if err != nil {
 			w.log.Error().Err(err).Msg("woo worker: claim task failed")
 			return
@@ -80,11 +80,11 @@ func (w *Woo) workerTick(ctx context.Context, gdb *gorm.DB) {
 	}
 }
 
-// claimNextNWooTasksOfKind atomicznie claim-uje do n tasków danego kind.
-func claimNextNWooTasksOfKind(gdb *gorm.DB, kind string, n int) ([]db.WooTask, error) {
+// claimNextNWooTasksOfKind atomicznie claim-uje do n tasków danego kind z kolejki sklepu shop.
+func claimNextNWooTasksOfKind(gdb *gorm.DB, shop, kind string, n int) ([]db.WooTask, error) {
 	var claimed []db.WooTask
 	for range n {
-		task, err := claimOneWooTaskOfKind(gdb, kind)
+		task, err := claimOneWooTaskOfKind(gdb, shop, kind)
 		if err != nil {
 			return claimed, err
 		}
@@ -96,11 +96,12 @@ func claimNextNWooTasksOfKind(gdb *gorm.DB, kind string, n int) ([]db.WooTask, e
 	return claimed, nil
 }
 
-func claimOneWooTaskOfKind(gdb *gorm.DB, kind string) (*db.WooTask, error) {
+func claimOneWooTaskOfKind(gdb *gorm.DB, shop, kind string) (*db.WooTask, error) {
 	for range 5 {
 		var tasks []db.WooTask
 		if err := gdb.
-			Where("status = ? AND kind = ?", "pending", kind).
+			Where("status = ? AND shop = ? AND kind = ?", "pending", shop, kind).
+			Where("next_attempt_at IS NULL OR next_attempt_at <= ?", time.Now()).
 			Order("created_at ASC, task_id ASC").
 			Limit(1).
 			Find(&tasks).Error; err != nil {
@@ -121,11 +122,12 @@ func claimOneWooTaskOfKind(gdb *gorm.DB, kind string) (*db.WooTask, error) {
 	return nil, nil
 }
 
-// claimNextSequentialWooTask claim-uje jeden task spoza batchableKinds.
-func claimNextSequentialWooTask(gdb *gorm.DB) (*db.WooTask, error) {
+// claimNextSequentialWooTask claim-uje jeden task spoza batchableKinds z kolejki sklepu shop.
+func claimNextSequentialWooTask(gdb *gorm.DB, shop string) (*db.WooTask, error) {
 	var tasks []db.WooTask
 	if err := gdb.
-		Where("status = ? AND kind NOT IN ?", "pending", batchableKinds).
+		Where("status = ? AND shop = ? AND kind NOT IN ?", "pending", shop, batchableKinds).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", time.Now()).
 		Order("created_at ASC, task_id ASC").
 		Limit(1).
 		Find(&tasks).Error; err != nil {
@@ -142,10 +144,11 @@ func claimWooTask(gdb *gorm.DB, task db.WooTask) (*db.WooTask, error) {
 	res := gdb.Model(&db.WooTask{}).
 		Where("task_id = ? AND status = ?", task.TaskID, "pending").
 		Updates(map[string]any{
-			"status":     "running",
-			"started_at": now,
-			"attempts":   gorm.Expr("attempts + 1"),
-			"last_error": "",
+			"status":          "running",
+			"started_at":      now,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      "",
+			"next_attempt_at": nil,
 		})
 	if res.Error != nil {
 		return nil, res.Error
@@ -156,6 +159,7 @@ func claimWooTask(gdb *gorm.DB, task db.WooTask) (*db.WooTask, error) {
 	task.Status = "running"
 	task.StartedAt = &now
 	task.Attempts++
+	task.NextAttemptAt = nil
 	return &task, nil
 }
 
@@ -201,13 +205,29 @@ func (w *Woo) executeWooTask(ctx context.Context, gdb *gorm.DB, task db.WooTask)
 		}
 		w.handleAvailabilityUpdate(ctx, gdb, task, payload)
 
+	case db.WooTaskKindProductCreate:
+		var payload db.WooProductCreatePayload
+		if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+			w.failWooTask(gdb, task, fmt.Errorf("decode product create payload: %w", err))
+			return
+		}
+		w.handleProductCreate(ctx, gdb, task, payload)
+
+	case db.WooTaskKindVisibilityUpdate:
+		var payload db.WooVisibilityPayload
+		if err := json.Unmarshal([]byte(task.PayloadJSON), &payload); err != nil {
+			w.failWooTask(gdb, task, fmt.Errorf("decode visibility payload: %w", err))
+			return
+		}
+		w.handleVisibilityUpdate(ctx, gdb, task, payload)
+
 	default:
 		w.failWooTask(gdb, task, fmt.Errorf("unsupported task kind: %s", task.Kind))
 	}
 }
 
 func (w *Woo) handleEANUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooEANUpdatePayload) {
-	product, err := w.fetchProduct(ctx, payload.WooID)
+	product, err := w.fetchProduct(ctx, payload.ParentID, payload.WooID)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("fetch live product before ean update: %w", err))
 		return
@@ -248,7 +268,7 @@ func (w *Woo) handleEANUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask
 	}
 
 	var duplicateOwners []uint
-	if err := gdb.Model(&db.WooProductCache{}).
+	if err := w.ofShop(gdb).Model(&db.WooProductCache{}).
 		Where("woo_id <> ? AND ean = ?", payload.WooID, payload.DesiredEAN).
 		Pluck("woo_id", &duplicateOwners).Error; err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("check duplicate ean in cache: %w", err))
@@ -268,9 +288,12 @@ func (w *Woo) handleEANUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask
 		return
 	}
 
-	verified, err := w.updateAndVerifyProduct(ctx, payload.WooID, map[string]any{
-		"global_unique_id": payload.DesiredEAN,
-	})
+	body := map[string]any{"global_unique_id": payload.DesiredEAN}
+	if w.cfg.DryRun {
+		w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+		return
+	}
+	verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
 	if err != nil {
 		if strings.Contains(err.Error(), "product_invalid_global_unique_id") {
 			w.completeWooTask(gdb, task, "skipped", err.Error(), "")
@@ -306,7 +329,7 @@ func (w *Woo) handleEANUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask
 }
 
 func (w *Woo) handleStockUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooStockUpdatePayload) {
-	product, err := w.fetchProduct(ctx, payload.WooID)
+	product, err := w.fetchProduct(ctx, payload.ParentID, payload.WooID)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("fetch live product before stock update: %w", err))
 		return
@@ -345,9 +368,12 @@ func (w *Woo) handleStockUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTa
 		return
 	}
 
-	verified, err := w.updateAndVerifyProduct(ctx, payload.WooID, map[string]any{
-		"stock_quantity": payload.DesiredStock,
-	})
+	body := map[string]any{"stock_quantity": payload.DesiredStock}
+	if w.cfg.DryRun {
+		w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+		return
+	}
+	verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("update stock: %w", err))
 		return
@@ -371,14 +397,18 @@ func (w *Woo) handleStockUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTa
 }
 
 func (w *Woo) handlePriceUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooPriceUpdatePayload) {
-	product, err := w.fetchProduct(ctx, payload.WooID)
+	if err := w.checkPriceFields(priceFields(payload.PriceRules, payload.Fields, payload.DesiredHurt)); err != nil {
+		w.failWooTask(gdb, task, err)
+		return
+	}
+	product, err := w.fetchProduct(ctx, payload.ParentID, payload.WooID)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("fetch live product before price update: %w", err))
 		return
 	}
 
 	switch {
-	case parsePrice(product.SalePrice) > 0:
+	case !payload.PromoMode && parsePrice(product.SalePrice) > 0:
 		if err := w.syncCacheFromVerifiedProduct(gdb, product, payload.TowarID); err != nil {
 			w.failWooTask(gdb, task, fmt.Errorf("cache sync after price policy skip: %w", err))
 			return
@@ -394,9 +424,7 @@ func (w *Woo) handlePriceUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTa
 		w.logImportBatchStatus(gdb, task.ImportID)
 		return
 
-	case floatAlmostEqual(parsePrice(product.RegularPrice), payload.DesiredRegular) &&
-		floatAlmostEqual(parsePrice(w.customFieldValue(product, "hurt_price")), payload.DesiredHurt) &&
-		product.TaxClass == payload.DesiredTaxClass:
+	case w.priceMatches(product, payload):
 		if err := w.syncCacheFromVerifiedProduct(gdb, product, payload.TowarID); err != nil {
 			w.failWooTask(gdb, task, fmt.Errorf("cache sync after already-set price: %w", err))
 			return
@@ -414,25 +442,19 @@ func (w *Woo) handlePriceUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTa
 		return
 	}
 
-	body := map[string]any{
-		"regular_price": formatWooPrice(payload.DesiredRegular),
-		"tax_class":     payload.DesiredTaxClass,
-	}
-	w.applyCustomFieldPayload(body, "hurt_price", formatWooPrice(payload.DesiredHurt))
+	body := w.priceUpdateBody(payload)
 
-	verified, err := w.updateAndVerifyProduct(ctx, payload.WooID, body)
+	if w.cfg.DryRun {
+		w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+		return
+	}
+	verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("update price: %w", err))
 		return
 	}
-	if !floatAlmostEqual(parsePrice(verified.RegularPrice), payload.DesiredRegular) ||
-		!floatAlmostEqual(parsePrice(w.customFieldValue(verified, "hurt_price")), payload.DesiredHurt) ||
-		verified.TaxClass != payload.DesiredTaxClass {
-		w.failWooTask(gdb, task, fmt.Errorf(
-			"price verification mismatch: got regular=%v hurt=%v tax_class=%v want regular=%v hurt=%v tax_class=%v",
-			parsePrice(verified.RegularPrice), parsePrice(w.customFieldValue(verified, "hurt_price")), verified.TaxClass,
-			payload.DesiredRegular, payload.DesiredHurt, payload.DesiredTaxClass,
-		))
+	if !w.priceMatches(verified, payload) {
+		w.failWooTask(gdb, task, w.priceMismatchError(verified, payload))
 		return
 	}
 	if err := w.syncCacheFromVerifiedProduct(gdb, verified, payload.TowarID); err != nil {
@@ -451,15 +473,54 @@ func (w *Woo) handlePriceUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTa
 	w.logImportBatchStatus(gdb, task.ImportID)
 }
 
+// priceMatches sprawdza, czy produkt ma już ceny z payloadu.
+// W trybie promocji porównywane są też sale_price i cena Omnibus.
+func (w *Woo) priceMatches(product wcProduct, payload db.WooPriceUpdatePayload) bool {
+	if !floatAlmostEqual(parsePrice(product.RegularPrice), payload.DesiredRegular) ||
+		!w.priceFieldsMatch(product, priceFields(payload.PriceRules, payload.Fields, payload.DesiredHurt)) ||
+		product.TaxClass != payload.DesiredTaxClass {
+		return false
+	}
+	if !payload.PromoMode {
+		return true
+	}
+	return floatAlmostEqual(parsePrice(product.SalePrice), payload.DesiredSale) &&
+		floatAlmostEqual(parsePrice(w.customFieldValue(product, "omnibus_price")), payload.DesiredOmnibus)
+}
+
+func (w *Woo) priceUpdateBody(payload db.WooPriceUpdatePayload) map[string]any {
+	body := map[string]any{
+		"regular_price": formatWooPrice(payload.DesiredRegular),
+		"tax_class":     payload.DesiredTaxClass,
+	}
+	w.applyPriceFields(body, priceFields(payload.PriceRules, payload.Fields, payload.DesiredHurt))
+	if payload.PromoMode {
+		// pusty string czyści sale_price / meta po końcu promocji
+		body["sale_price"] = formatOptionalWooPrice(payload.DesiredSale)
+		w.applyCustomFieldPayload(body, "omnibus_price", formatOptionalWooPrice(payload.DesiredOmnibus))
+	}
+	return body
+}
+
+func (w *Woo) priceMismatchError(product wcProduct, payload db.WooPriceUpdatePayload) error {
+	gotFields, wantFields := w.describePriceFields(product, priceFields(payload.PriceRules, payload.Fields, payload.DesiredHurt))
+	return fmt.Errorf(
+		"price verification mismatch: got regular=%v sale=%v omnibus=%v tax_class=%v %s want regular=%v sale=%v omnibus=%v tax_class=%v %s",
+		parsePrice(product.RegularPrice), parsePrice(product.SalePrice),
+		parsePrice(w.customFieldValue(product, "omnibus_price")), product.TaxClass, gotFields,
+		payload.DesiredRegular, payload.DesiredSale, payload.DesiredOmnibus, payload.DesiredTaxClass, wantFields,
+	)
+}
+
 func (w *Woo) handleAvailabilityUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooAvailabilityPayload) {
-	product, err := w.fetchProduct(ctx, payload.WooID)
+	product, err := w.fetchProduct(ctx, payload.ParentID, payload.WooID)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("fetch live product before availability update: %w", err))
 		return
 	}
 
 	if payload.Unavailable {
-		if !product.ManageStock && product.StockStatus == "outofstock" && product.CatalogVisibility == "hidden" {
+		if availabilitySet(product, true) {
 			if err := w.syncCacheFromVerifiedProduct(gdb, product, payload.TowarID); err != nil {
 				w.failWooTask(gdb, task, fmt.Errorf("cache sync after already-set unavailable: %w", err))
 				return
@@ -470,16 +531,17 @@ func (w *Woo) handleAvailabilityUpdate(ctx context.Context, gdb *gorm.DB, task d
 			w.logImportBatchStatus(gdb, task.ImportID)
 			return
 		}
-		verified, err := w.updateAndVerifyProduct(ctx, payload.WooID, map[string]any{
-			"manage_stock":       false,
-			"stock_status":       "outofstock",
-			"catalog_visibility": "hidden",
-		})
+		body := availabilityUpdateBody(payload.ParentID, true)
+		if w.cfg.DryRun {
+			w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+			return
+		}
+		verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
 		if err != nil {
 			w.failWooTask(gdb, task, fmt.Errorf("update availability (unavailable): %w", err))
 			return
 		}
-		if verified.ManageStock || verified.StockStatus != "outofstock" || verified.CatalogVisibility != "hidden" {
+		if !availabilitySet(verified, true) {
 			w.failWooTask(gdb, task, fmt.Errorf("availability verification mismatch: got manage_stock=%v stock_status=%q catalog_visibility=%q", verified.ManageStock, verified.StockStatus, verified.CatalogVisibility))
 			return
 		}
@@ -495,7 +557,7 @@ func (w *Woo) handleAvailabilityUpdate(ctx context.Context, gdb *gorm.DB, task d
 	}
 
 	// available: manage_stock=true, backorders=notify, catalog_visibility=visible
-	if product.ManageStock && product.Backorders == "notify" && product.CatalogVisibility != "hidden" {
+	if availabilitySet(product, false) {
 		if err := w.syncCacheFromVerifiedProduct(gdb, product, payload.TowarID); err != nil {
 			w.failWooTask(gdb, task, fmt.Errorf("cache sync after already-set available: %w", err))
 			return
@@ -506,11 +568,12 @@ func (w *Woo) handleAvailabilityUpdate(ctx context.Context, gdb *gorm.DB, task d
 		w.logImportBatchStatus(gdb, task.ImportID)
 		return
 	}
-	verified, err := w.updateAndVerifyProduct(ctx, payload.WooID, map[string]any{
-		"manage_stock":       true,
-		"backorders":         "notify",
-		"catalog_visibility": "visible",
-	})
+	body := availabilityUpdateBody(payload.ParentID, false)
+	if w.cfg.DryRun {
+		w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+		return
+	}
+	verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
 	if err != nil {
 		w.failWooTask(gdb, task, fmt.Errorf("update availability (available): %w", err))
 		return
@@ -529,12 +592,226 @@ func (w *Woo) handleAvailabilityUpdate(ctx context.Context, gdb *gorm.DB, task d
 	w.logImportBatchStatus(gdb, task.ImportID)
 }
 
-func (w *Woo) fetchProduct(ctx context.Context, wooID uint) (wcProduct, error) {
+// availabilitySet sprawdza stan z availability.update. Warianty nie mają catalog_visibility
+// (widoczność dziedziczą z produktu variable), więc dla nich to pole jest pomijane.
+func availabilitySet(product wcProduct, unavailable bool) bool {
+	if unavailable {
+		return !product.ManageStock && product.StockStatus == "outofstock" &&
+			(product.isVariation() || product.CatalogVisibility == "hidden")
+	}
+	return product.ManageStock && product.Backorders == "notify" &&
+		(product.isVariation() || product.CatalogVisibility != "hidden")
+}
+
+func availabilityUpdateBody(parentID uint, unavailable bool) map[string]any {
+	var body map[string]any
+	if unavailable {
+		body = map[string]any{"manage_stock": false, "stock_status": "outofstock", "catalog_visibility": "hidden"}
+	} else {
+		body = map[string]any{"manage_stock": true, "backorders": "notify", "catalog_visibility": "visible"}
+	}
+	if parentID != 0 {
+		delete(body, "catalog_visibility")
+	}
+	return body
+}
+
+func visibilityMatches(product wcProduct, payload db.WooVisibilityPayload) bool {
+	if payload.DesiredStatus != "" && product.Status != payload.DesiredStatus {
+		return false
+	}
+	if payload.DesiredVisibility != "" && product.CatalogVisibility != payload.DesiredVisibility {
+		return false
+	}
+	return true
+}
+
+func (w *Woo) handleVisibilityUpdate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooVisibilityPayload) {
+	product, err := w.fetchProduct(ctx, payload.ParentID, payload.WooID)
+	if err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("fetch live product before visibility update: %w", err))
+		return
+	}
+
+	if product.Status == "trash" {
+		w.completeWooTask(gdb, task, "skipped", "policy skip: product is in trash", "")
+		w.log.Warn().Uint("task_id", task.TaskID).Uint("woo_id", payload.WooID).
+			Msg("woo worker: skip visibility update, product in trash")
+		w.logImportBatchStatus(gdb, task.ImportID)
+		return
+	}
+
+	if visibilityMatches(product, payload) {
+		if err := w.syncCacheFromVerifiedProduct(gdb, product, payload.TowarID); err != nil {
+			w.failWooTask(gdb, task, fmt.Errorf("cache sync after already-set visibility: %w", err))
+			return
+		}
+		w.completeWooTask(gdb, task, "done", "", "")
+		w.log.Info().Uint("task_id", task.TaskID).Uint("woo_id", payload.WooID).
+			Msg("woo worker: product visibility already set and verified")
+		w.logImportBatchStatus(gdb, task.ImportID)
+		return
+	}
+
+	body := map[string]any{}
+	if payload.DesiredStatus != "" {
+		body["status"] = payload.DesiredStatus
+	}
+	if payload.DesiredVisibility != "" {
+		body["catalog_visibility"] = payload.DesiredVisibility
+	}
+	if w.cfg.DryRun {
+		w.previewProductUpdate(gdb, task, payload.ParentID, payload.WooID, body, product)
+		return
+	}
+	verified, err := w.updateAndVerifyProduct(ctx, payload.ParentID, payload.WooID, body)
+	if err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("update visibility: %w", err))
+		return
+	}
+	if !visibilityMatches(verified, payload) {
+		w.failWooTask(gdb, task, fmt.Errorf("visibility verification mismatch: got status=%q catalog_visibility=%q want status=%q catalog_visibility=%q",
+			verified.Status, verified.CatalogVisibility, payload.DesiredStatus, payload.DesiredVisibility))
+		return
+	}
+	if err := w.syncCacheFromVerifiedProduct(gdb, verified, payload.TowarID); err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("cache sync after visibility update: %w", err))
+		return
+	}
+	w.completeWooTask(gdb, task, "done", "", "")
+	w.log.Info().
+		Uint("task_id", task.TaskID).
+		Uint("import_id", task.ImportID).
+		Uint("woo_id", payload.WooID).
+		Bool("retire", payload.Retire).
+		Str("mode", payload.Mode).
+		Str("verified_status", verified.Status).
+		Str("verified_catalog_visibility", verified.CatalogVisibility).
+		Msg("woo worker: product visibility updated and verified")
+	w.logImportBatchStatus(gdb, task.ImportID)
+}
+
+func (w *Woo) handleProductCreate(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooProductCreatePayload) {
+	// poprzednia próba założyła już produkt (POST zwrócił ID) — tylko weryfikacja, bez ponownego POST
+	if task.WooID != nil {
+		w.verifyCreatedProduct(ctx, gdb, task, payload, *task.WooID)
+		return
+	}
+
+	var owners []uint
+	if err := w.ofShop(gdb).Model(&db.WooProductCache{}).
+		Where("ean = ? OR towar_id = ?", payload.EAN, payload.TowarID).
+		Pluck("woo_id", &owners).Error; err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("check existing product in cache: %w", err))
+		return
+	}
+	if len(owners) > 0 {
+		msg := fmt.Sprintf("policy skip: product already present in cache on Woo IDs %v", owners)
+		w.completeWooTask(gdb, task, "skipped", msg, "")
+		w.log.Warn().
+			Uint("task_id", task.TaskID).
+			Uint("import_id", task.ImportID).
+			Int64("towar_id", payload.TowarID).
+			Str("ean", payload.EAN).
+			Interface("owners", owners).
+			Msg("woo worker: skip product create, product already in cache")
+		w.logImportBatchStatus(gdb, task.ImportID)
+		return
+	}
+
+	body := map[string]any{
+		"name":             payload.ProductName,
+		"type":             "simple",
+		"status":           "draft",
+		"sku":              payload.SKU,
+		"global_unique_id": payload.EAN,
+		"regular_price":    formatWooPrice(payload.DesiredRegular),
+		"tax_class":        payload.DesiredTaxClass,
+		"manage_stock":     true,
+		"stock_quantity":   payload.DesiredStock,
+		"backorders":       "notify",
+	}
+	fields := priceFields(payload.PriceRules, payload.Fields, payload.DesiredHurt)
+	if err := w.checkPriceFields(fields); err != nil {
+		w.failWooTask(gdb, task, err)
+		return
+	}
+	w.applyPriceFields(body, fields)
+	if w.cfg.DryRun {
+		w.previewWooTask(gdb, task, newPreview(http.MethodPost, productsCollectionPath(0), body, nil))
+		w.logImportBatchStatus(gdb, task.ImportID)
+		return
+	}
+
+	wooID, err := w.createProduct(ctx, body)
+	if err != nil {
+		if strings.Contains(err.Error(), "product_invalid_sku") || strings.Contains(err.Error(), "product_invalid_global_unique_id") {
+			w.completeWooTask(gdb, task, "skipped", err.Error(), "")
+			w.log.Warn().
+				Uint("task_id", task.TaskID).
+				Uint("import_id", task.ImportID).
+				Int64("towar_id", payload.TowarID).
+				Str("sku", payload.SKU).
+				Err(err).
+				Msg("woo worker: product create rejected by Woo policy")
+			w.logImportBatchStatus(gdb, task.ImportID)
+			return
+		}
+		w.failWooTask(gdb, task, fmt.Errorf("create product: %w", err))
+		return
+	}
+	// woo_id w tasku jest jedynym śladem produktu, którego nie ma jeszcze w cache —
+	// bez niego ponowienie wysłałoby drugi POST
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", task.TaskID).Update("woo_id", wooID).Error; err != nil {
+		w.failWooTask(gdb, task, &permanentWooError{fmt.Errorf("save woo_id=%d of created product: %w", wooID, err)})
+		return
+	}
+	task.WooID = &wooID
+	w.verifyCreatedProduct(ctx, gdb, task, payload, wooID)
+}
+
+// verifyCreatedProduct sprawdza produkt założony przez product.create. Produkt trafia do cache
+// (z towar_id) także przy niezgodności pól, żeby nie został w Woo poza wiedzą linkera.
+func (w *Woo) verifyCreatedProduct(ctx context.Context, gdb *gorm.DB, task db.WooTask, payload db.WooProductCreatePayload, wooID uint) {
+	verified, err := w.fetchProduct(ctx, 0, wooID)
+	if err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("fetch created product (woo_id=%d): %w", wooID, err))
+		return
+	}
+	if err := w.syncCacheFromVerifiedProduct(gdb, verified, payload.TowarID); err != nil {
+		w.failWooTask(gdb, task, fmt.Errorf("cache sync after product create: %w", err))
+		return
+	}
+
+	if verified.cacheEAN() != payload.EAN ||
+		!floatAlmostEqual(parsePrice(verified.RegularPrice), payload.DesiredRegular) ||
+		verified.TaxClass != payload.DesiredTaxClass ||
+		!floatAlmostEqual(verified.StockQuantity, payload.DesiredStock) {
+		w.failWooTask(gdb, task, fmt.Errorf(
+			"product create verification mismatch (woo_id=%d): got ean=%q regular=%v tax_class=%q stock=%v want ean=%q regular=%v tax_class=%q stock=%v",
+			wooID, verified.cacheEAN(), parsePrice(verified.RegularPrice), verified.TaxClass, verified.StockQuantity,
+			payload.EAN, payload.DesiredRegular, payload.DesiredTaxClass, payload.DesiredStock,
+		))
+		return
+	}
+	w.completeWooTask(gdb, task, "done", "", verified.cacheEAN())
+	w.log.Info().
+		Uint("task_id", task.TaskID).
+		Uint("import_id", task.ImportID).
+		Int64("towar_id", payload.TowarID).
+		Uint("woo_id", wooID).
+		Str("sku", verified.SKU).
+		Msg("woo worker: product created (draft) and verified")
+	w.logImportBatchStatus(gdb, task.ImportID)
+}
+
+// fetchProduct pobiera produkt albo wariant (parentID != 0).
+func (w *Woo) fetchProduct(ctx context.Context, parentID, wooID uint) (wcProduct, error) {
 	base, err := url.Parse(w.cfg.BaseURL)
 	if err != nil {
 		return wcProduct{}, err
 	}
-	base.Path = "/wp-json/wc/v3/products/" + strconv.FormatUint(uint64(wooID), 10)
+	base.Path = productPath(parentID, wooID)
 	q := base.Query()
 	q.Set("_fields", w.productFields())
 	base.RawQuery = q.Encode()
@@ -553,22 +830,26 @@ func (w *Woo) fetchProduct(ctx context.Context, wooID uint) (wcProduct, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return wcProduct{}, fmt.Errorf("http %d", resp.StatusCode)
+		return wcProduct{}, &wooHTTPError{StatusCode: resp.StatusCode}
 	}
 
 	var product wcProduct
 	if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
 		return wcProduct{}, err
 	}
+	markVariation(&product, parentID)
 	return product, nil
 }
 
-func (w *Woo) updateAndVerifyProduct(ctx context.Context, wooID uint, body map[string]any) (wcProduct, error) {
+func (w *Woo) updateAndVerifyProduct(ctx context.Context, parentID, wooID uint, body map[string]any) (wcProduct, error) {
 	base, err := url.Parse(w.cfg.BaseURL)
 	if err != nil {
 		return wcProduct{}, err
 	}
-	base.Path = "/wp-json/wc/v3/products/" + strconv.FormatUint(uint64(wooID), 10)
+	base.Path = productPath(parentID, wooID)
+	if w.cfg.DryRun {
+		return wcProduct{}, errDryRunWrite
+	}
 
 	rawBody, err := json.Marshal(body)
 	if err != nil {
@@ -592,50 +873,98 @@ func (w *Woo) updateAndVerifyProduct(ctx context.Context, wooID uint, body map[s
 		var payload map[string]any
 		if err := json.NewDecoder(resp.Body).Decode(&payload); err == nil {
 			if raw, marshalErr := json.Marshal(payload); marshalErr == nil {
-				return wcProduct{}, fmt.Errorf("http %d: %s", resp.StatusCode, string(raw))
+				return wcProduct{}, &wooHTTPError{StatusCode: resp.StatusCode, Body: string(raw)}
+			}
+		}
+		return wcProduct{}, &wooHTTPError{StatusCode: resp.StatusCode}
+	}
+
+	return w.fetchProduct(ctx, parentID, wooID)
+}
+
+// createProduct wysyła POST /products i zwraca ID nowego produktu (weryfikacja: verifyCreatedProduct).
+// Brak odpowiedzi (timeout, przerwanie) nie jest ponawiany — produkt mógł powstać mimo błędu.
+func (w *Woo) createProduct(ctx context.Context, body map[string]any) (uint, error) {
+	base, err := url.Parse(w.cfg.BaseURL)
+	if err != nil {
+		return 0, err
+	}
+	base.Path = productsCollectionPath(0)
+	if w.cfg.DryRun {
+		return 0, errDryRunWrite
+	}
+
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base.String(), bytes.NewReader(rawBody))
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(w.cfg.ConsumerKey, w.cfg.ConsumerSec)
+	req.Header.Set("User-Agent", "PCM2WWW/1.0")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client().Do(req)
+	if err != nil {
+		return 0, &permanentWooError{fmt.Errorf("no response to POST /products, product may exist in Woo (check SKU %v before retrying): %w", body["sku"], err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var payload map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err == nil {
+			if raw, marshalErr := json.Marshal(payload); marshalErr == nil {
+				return 0, &wooHTTPError{StatusCode: resp.StatusCode, Body: string(raw)}
 			}
 		}
-		return wcProduct{}, fmt.Errorf("http %d", resp.StatusCode)
+		return 0, &wooHTTPError{StatusCode: resp.StatusCode}
 	}
 
-	return w.fetchProduct(ctx, wooID)
+	var created wcProduct
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return 0, &permanentWooError{fmt.Errorf("decode POST /products response: %w", err)}
+	}
+	if created.ID <= 0 {
+		return 0, fmt.Errorf("create response without product id")
+	}
+	return uint(created.ID), nil
 }
 
 func (w *Woo) syncCacheFromVerifiedProduct(gdb *gorm.DB, product wcProduct, towarID int64) error {
-	row := db.WooProductCache{
-		WooID:        uint(product.ID),
-		TowarID:      ptrInt64(towarID),
-		Kod:          product.SKU,
-		Ean:          product.cacheEAN(),
-		Name:         product.Name,
-		PriceRegular: parsePrice(product.RegularPrice),
-		PriceSale:    parsePrice(product.SalePrice),
-		HurtPrice:    parsePrice(w.customFieldValue(product, "hurt_price")),
-		TaxClass:     product.TaxClass,
-		StockQty:     product.StockQuantity,
-		StockManaged:      product.ManageStock,
-		StockStatus:       product.StockStatus,
-		Backorders:        product.Backorders,
-		CatalogVisibility: product.CatalogVisibility,
-		Status:            product.Status,
-		Type:              product.Type,
-		DateModified:      product.DateModifiedGMT,
+	return w.upsertCacheProduct(gdb, product, ptrInt64(towarID))
+}
+
+// upsertCacheProduct zapisuje pełny produkt z Woo w cache (razem z tax_class).
+// towarID == nil zostawia powiązanie linkera bez zmian (webhook nie zna towar_id).
+func (w *Woo) upsertCacheProduct(gdb *gorm.DB, product wcProduct, towarID *int64) error {
+	row := w.cacheRowFromProduct(product)
+	row.TaxClass = product.TaxClass
+	columns := append([]string{"tax_class"}, cacheColumns...)
+	if towarID != nil {
+		row.TowarID = towarID
+		columns = append(columns, "towar_id")
 	}
 
 	return gdb.Clauses(clause.OnConflict{
-		Columns: []clause.Column{{Name: "woo_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{
-			"towar_id", "kod", "ean", "name", "price_regular", "price_sale", "hurt_price", "tax_class",
-			"stock_qty", "stock_managed", "stock_status", "backorders", "catalog_visibility", "status", "type", "date_modified",
-		}),
+		Columns:   cacheKeyColumns,
+		DoUpdates: clause.AssignmentColumns(columns),
 	}).Create(&row).Error
 }
 
+// failWooTask zapisuje błąd taska. Przerwanie workera oddaje task do kolejki bez zmian,
+// błędy przejściowe (429/5xx/timeout) wracają do pending z rosnącym odstępem,
+// aż do wyczerpania retry.max_attempts; pozostałe błędy (i permanentWooError) kończą task od razu.
 func (w *Woo) failWooTask(gdb *gorm.DB, task db.WooTask, err error) {
-	if isWorkerContextInterruption(err) {
+	if isWorkerContextInterruption(err) && !isPermanentWooError(err) {
 		w.requeueWooTask(gdb, task, err)
 		return
 	}
+	if isRetryableWooError(err) && task.Attempts < w.cfg.Retry.maxAttempts() {
+		w.scheduleWooTaskRetry(gdb, task, err)
+		return
+	}
 
 	msg := err.Error()
 	now := time.Now()
@@ -673,6 +1002,32 @@ func (w *Woo) requeueWooTask(gdb *gorm.DB, task db.WooTask, err error) {
 	w.logImportBatchStatus(gdb, task.ImportID)
 }
 
+// scheduleWooTaskRetry oddaje task do pending z next_attempt_at = teraz + backoff.
+// last_error zostaje, żeby było widać, dlaczego task czeka.
+func (w *Woo) scheduleWooTaskRetry(gdb *gorm.DB, task db.WooTask, err error) {
+	delay := w.cfg.Retry.delay(task.Attempts)
+	next := time.Now().Add(delay)
+	_ = gdb.Model(&db.WooTask{}).
+		Where("task_id = ?", task.TaskID).
+		Updates(map[string]any{
+			"status":          "pending",
+			"last_error":      err.Error(),
+			"started_at":      nil,
+			"finished_at":     nil,
+			"next_attempt_at": next,
+		}).Error
+	w.log.Warn().
+		Err(err).
+		Uint("task_id", task.TaskID).
+		Uint("import_id", task.ImportID).
+		Str("kind", task.Kind).
+		Int("attempts", task.Attempts).
+		Int("max_attempts", w.cfg.Retry.maxAttempts()).
+		Dur("retry_in", delay).
+		Msg("woo worker: transient failure, task scheduled for retry")
+	w.logImportBatchStatus(gdb, task.ImportID)
+}
+
 func (w *Woo) completeWooTask(gdb *gorm.DB, task db.WooTask, status, detail, responseEAN string) {
 	now := time.Now()
 	lastError := detail
@@ -700,7 +1055,7 @@ func (w *Woo) logImportBatchStatus(gdb *gorm.DB, importID uint) {
 		Status string
 		Count  int
 	}
-	if err := gdb.Model(&db.WooTask{}).
+	if err := w.ofShop(gdb).Model(&db.WooTask{}).
 		Select("status, COUNT(*) AS count").
 		Where("import_id = ?", importID).
 		Group("status").
@@ -717,11 +1072,13 @@ func (w *Woo) logImportBatchStatus(gdb *gorm.DB, importID uint) {
 	w.log.Info().
 		Uint("import_id", importID).
 		Str("file", filename).
+		Str("shop", w.shop).
 		Int("pending", counts["pending"]).
 		Int("running", counts["running"]).
 		Int("done", counts["done"]).
 		Int("skipped", counts["skipped"]).
 		Int("error", counts["error"]).
+		Int("previewed", counts["previewed"]).
 		Msg("woo worker: import batch task status")
 }
 
@@ -736,12 +1093,21 @@ func formatWooPrice(v float64) string {
 	return strconv.FormatFloat(v, 'f', -1, 64)
 }
 
+func formatOptionalWooPrice(v float64) string {
+	if floatAlmostEqual(v, 0) {
+		return ""
+	}
+	return formatWooPrice(v)
+}
+
 func floatAlmostEqual(a, b float64) bool {
 	return math.Abs(a-b) < 0.0001
 }
 
+// isWorkerContextInterruption: zatrzymanie workera (anulowany kontekst). Timeout requestu
+// (DeadlineExceeded) to błąd przejściowy obsługiwany przez retry, nie przerwanie.
 func isWorkerContextInterruption(err error) bool {
-	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	return errors.Is(err, context.Canceled)
 }
 
 func ptrInt64(v int64) *int64 {
@@ -749,18 +1115,47 @@ func ptrInt64(v int64) *int64 {
 }
 
 // executeBatch przekazuje grupę tasków do właściwego batch handlera.
+// Warianty idą przez /products/{parent}/variations/batch, więc taski są dzielone wg parent_id.
 func (w *Woo) executeBatch(ctx context.Context, gdb *gorm.DB, kind string, tasks []db.WooTask) {
-	switch kind {
-	case db.WooTaskKindPriceUpdate:
-		w.handlePriceUpdateBatch(ctx, gdb, tasks)
-	case db.WooTaskKindStockUpdate:
-		w.handleStockUpdateBatch(ctx, gdb, tasks)
-	case db.WooTaskKindAvailabilityUpdate:
-		w.handleAvailabilityUpdateBatch(ctx, gdb, tasks)
+	for _, group := range groupTasksByParent(tasks) {
+		switch kind {
+		case db.WooTaskKindPriceUpdate:
+			w.handlePriceUpdateBatch(ctx, gdb, group.parentID, group.tasks)
+		case db.WooTaskKindStockUpdate:
+			w.handleStockUpdateBatch(ctx, gdb, group.parentID, group.tasks)
+		case db.WooTaskKindAvailabilityUpdate:
+			w.handleAvailabilityUpdateBatch(ctx, gdb, group.parentID, group.tasks)
+		}
+	}
+}
+
+type parentTaskGroup struct {
+	parentID uint
+	tasks    []db.WooTask
+}
+
+// groupTasksByParent dzieli taski wg parent_id z payloadu (0 = zwykłe produkty), zachowując kolejność.
+// Task z niepoprawnym payloadem trafia do grupy 0 — handler oznaczy go jako błąd.
+func groupTasksByParent(tasks []db.WooTask) []parentTaskGroup {
+	var groups []parentTaskGroup
+	index := make(map[uint]int)
+	for _, task := range tasks {
+		var ref struct {
+			ParentID uint `json:"parent_id"`
+		}
+		_ = json.Unmarshal([]byte(task.PayloadJSON), &ref)
+		idx, ok := index[ref.ParentID]
+		if !ok {
+			idx = len(groups)
+			index[ref.ParentID] = idx
+			groups = append(groups, parentTaskGroup{parentID: ref.ParentID})
+		}
+		groups[idx].tasks = append(groups[idx].tasks, task)
 	}
+	return groups
 }
 
-func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []db.WooTask) {
+func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, parentID uint, tasks []db.WooTask) {
 	// 1. Parsuj payloady
 	type entry struct {
 		task    db.WooTask
@@ -784,7 +1179,7 @@ func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	for i, e := range entries {
 		wooIDs[i] = e.payload.WooID
 	}
-	live, err := w.fetchProductsBatch(ctx, wooIDs)
+	live, err := w.fetchProductsBatch(ctx, parentID, wooIDs)
 	if err != nil {
 		for _, e := range entries {
 			w.failWooTask(gdb, e.task, fmt.Errorf("batch GET: %w", err))
@@ -806,22 +1201,20 @@ func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 			w.failWooTask(gdb, e.task, fmt.Errorf("product %d missing in batch GET response", e.payload.WooID))
 			continue
 		}
+		if err := w.checkPriceFields(priceFields(e.payload.PriceRules, e.payload.Fields, e.payload.DesiredHurt)); err != nil {
+			w.failWooTask(gdb, e.task, err)
+			continue
+		}
 		switch {
-		case parsePrice(product.SalePrice) > 0:
+		case !e.payload.PromoMode && parsePrice(product.SalePrice) > 0:
 			_ = w.syncCacheFromVerifiedProduct(gdb, product, e.payload.TowarID)
 			w.completeWooTask(gdb, e.task, "skipped", fmt.Sprintf("policy skip: live sale_price=%v", product.SalePrice), "")
-		case floatAlmostEqual(parsePrice(product.RegularPrice), e.payload.DesiredRegular) &&
-			floatAlmostEqual(parsePrice(w.customFieldValue(product, "hurt_price")), e.payload.DesiredHurt) &&
-			product.TaxClass == e.payload.DesiredTaxClass:
+		case w.priceMatches(product, e.payload):
 			_ = w.syncCacheFromVerifiedProduct(gdb, product, e.payload.TowarID)
 			w.completeWooTask(gdb, e.task, "done", "", "")
 		default:
-			upd := map[string]any{
-				"id":            e.payload.WooID,
-				"regular_price": formatWooPrice(e.payload.DesiredRegular),
-				"tax_class":     e.payload.DesiredTaxClass,
-			}
-			w.applyCustomFieldPayload(upd, "hurt_price", formatWooPrice(e.payload.DesiredHurt))
+			upd := w.priceUpdateBody(e.payload)
+			upd["id"] = e.payload.WooID
 			toUpdate = append(toUpdate, pending{e, upd})
 			byWooID[e.payload.WooID] = e
 		}
@@ -830,13 +1223,21 @@ func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	if len(toUpdate) == 0 {
 		return
 	}
+	if w.cfg.DryRun {
+		for _, p := range toUpdate {
+			product := live[p.entry.payload.WooID]
+			w.previewWooTask(gdb, p.entry.task, newPreview(http.MethodPost, productsCollectionPath(parentID)+"/batch", p.update, &product))
+		}
+		w.logImportBatchStatus(gdb, tasks[0].ImportID)
+		return
+	}
 
 	// 4. Batch POST
 	updates := make([]map[string]any, len(toUpdate))
 	for i, p := range toUpdate {
 		updates[i] = p.update
 	}
-	verified, err := w.batchUpdateProducts(ctx, updates)
+	verified, err := w.batchUpdateProducts(ctx, parentID, updates)
 	if err != nil {
 		for _, p := range toUpdate {
 			w.failWooTask(gdb, p.entry.task, fmt.Errorf("batch POST: %w", err))
@@ -852,14 +1253,8 @@ func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 			continue
 		}
 		verifiedIDs[uint(prod.ID)] = struct{}{}
-		if !floatAlmostEqual(parsePrice(prod.RegularPrice), e.payload.DesiredRegular) ||
-			!floatAlmostEqual(parsePrice(w.customFieldValue(prod, "hurt_price")), e.payload.DesiredHurt) ||
-			prod.TaxClass != e.payload.DesiredTaxClass {
-			w.failWooTask(gdb, e.task, fmt.Errorf(
-				"price verification mismatch: got regular=%v hurt=%v tax=%v want regular=%v hurt=%v tax=%v",
-				parsePrice(prod.RegularPrice), parsePrice(w.customFieldValue(prod, "hurt_price")), prod.TaxClass,
-				e.payload.DesiredRegular, e.payload.DesiredHurt, e.payload.DesiredTaxClass,
-			))
+		if !w.priceMatches(prod, e.payload) {
+			w.failWooTask(gdb, e.task, w.priceMismatchError(prod, e.payload))
 			continue
 		}
 		_ = w.syncCacheFromVerifiedProduct(gdb, prod, e.payload.TowarID)
@@ -874,7 +1269,7 @@ func (w *Woo) handlePriceUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	w.logImportBatchStatus(gdb, tasks[0].ImportID)
 }
 
-func (w *Woo) handleStockUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []db.WooTask) {
+func (w *Woo) handleStockUpdateBatch(ctx context.Context, gdb *gorm.DB, parentID uint, tasks []db.WooTask) {
 	// 1. Parsuj payloady
 	type entry struct {
 		task    db.WooTask
@@ -898,7 +1293,7 @@ func (w *Woo) handleStockUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	for i, e := range entries {
 		wooIDs[i] = e.payload.WooID
 	}
-	live, err := w.fetchProductsBatch(ctx, wooIDs)
+	live, err := w.fetchProductsBatch(ctx, parentID, wooIDs)
 	if err != nil {
 		for _, e := range entries {
 			w.failWooTask(gdb, e.task, fmt.Errorf("batch GET: %w", err))
@@ -939,13 +1334,21 @@ func (w *Woo) handleStockUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	if len(toUpdate) == 0 {
 		return
 	}
+	if w.cfg.DryRun {
+		for _, p := range toUpdate {
+			product := live[p.entry.payload.WooID]
+			w.previewWooTask(gdb, p.entry.task, newPreview(http.MethodPost, productsCollectionPath(parentID)+"/batch", p.update, &product))
+		}
+		w.logImportBatchStatus(gdb, tasks[0].ImportID)
+		return
+	}
 
 	// 4. Batch POST
 	updates := make([]map[string]any, len(toUpdate))
 	for i, p := range toUpdate {
 		updates[i] = p.update
 	}
-	verified, err := w.batchUpdateProducts(ctx, updates)
+	verified, err := w.batchUpdateProducts(ctx, parentID, updates)
 	if err != nil {
 		for _, p := range toUpdate {
 			w.failWooTask(gdb, p.entry.task, fmt.Errorf("batch POST: %w", err))
@@ -976,7 +1379,7 @@ func (w *Woo) handleStockUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []
 	w.logImportBatchStatus(gdb, tasks[0].ImportID)
 }
 
-func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, tasks []db.WooTask) {
+func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, parentID uint, tasks []db.WooTask) {
 	type entry struct {
 		task    db.WooTask
 		payload db.WooAvailabilityPayload
@@ -998,7 +1401,7 @@ func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, t
 	for i, e := range entries {
 		wooIDs[i] = e.payload.WooID
 	}
-	live, err := w.fetchProductsBatch(ctx, wooIDs)
+	live, err := w.fetchProductsBatch(ctx, parentID, wooIDs)
 	if err != nil {
 		for _, e := range entries {
 			w.failWooTask(gdb, e.task, fmt.Errorf("batch GET: %w", err))
@@ -1019,23 +1422,13 @@ func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, t
 			w.failWooTask(gdb, e.task, fmt.Errorf("product %d missing in batch GET response", e.payload.WooID))
 			continue
 		}
-		alreadySet := func() bool {
-			if e.payload.Unavailable {
-				return !product.ManageStock && product.StockStatus == "outofstock" && product.CatalogVisibility == "hidden"
-			}
-			return product.ManageStock && product.Backorders == "notify" && product.CatalogVisibility != "hidden"
-		}()
-		if alreadySet {
+		if availabilitySet(product, e.payload.Unavailable) {
 			_ = w.syncCacheFromVerifiedProduct(gdb, product, e.payload.TowarID)
 			w.completeWooTask(gdb, e.task, "done", "", "")
 			continue
 		}
-		var upd map[string]any
-		if e.payload.Unavailable {
-			upd = map[string]any{"id": e.payload.WooID, "manage_stock": false, "stock_status": "outofstock", "catalog_visibility": "hidden"}
-		} else {
-			upd = map[string]any{"id": e.payload.WooID, "manage_stock": true, "backorders": "notify", "catalog_visibility": "visible"}
-		}
+		upd := availabilityUpdateBody(parentID, e.payload.Unavailable)
+		upd["id"] = e.payload.WooID
 		toUpdate = append(toUpdate, pending{e, upd})
 		byWooID[e.payload.WooID] = e
 	}
@@ -1043,12 +1436,20 @@ func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, t
 	if len(toUpdate) == 0 {
 		return
 	}
+	if w.cfg.DryRun {
+		for _, p := range toUpdate {
+			product := live[p.entry.payload.WooID]
+			w.previewWooTask(gdb, p.entry.task, newPreview(http.MethodPost, productsCollectionPath(parentID)+"/batch", p.update, &product))
+		}
+		w.logImportBatchStatus(gdb, tasks[0].ImportID)
+		return
+	}
 
 	updates := make([]map[string]any, len(toUpdate))
 	for i, p := range toUpdate {
 		updates[i] = p.update
 	}
-	verified, err := w.batchUpdateProducts(ctx, updates)
+	verified, err := w.batchUpdateProducts(ctx, parentID, updates)
 	if err != nil {
 		for _, p := range toUpdate {
 			w.failWooTask(gdb, p.entry.task, fmt.Errorf("batch POST: %w", err))
@@ -1065,7 +1466,7 @@ func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, t
 		verifiedIDs[uint(prod.ID)] = struct{}{}
 		ok = func() bool {
 			if e.payload.Unavailable {
-				return !prod.ManageStock && prod.StockStatus == "outofstock" && prod.CatalogVisibility == "hidden"
+				return availabilitySet(prod, true)
 			}
 			return prod.ManageStock && prod.Backorders == "notify"
 		}()
@@ -1085,7 +1486,8 @@ func (w *Woo) handleAvailabilityUpdateBatch(ctx context.Context, gdb *gorm.DB, t
 }
 
 // fetchProductsBatch pobiera wiele produktów jednym GET (?include=id1,id2,...).
-func (w *Woo) fetchProductsBatch(ctx context.Context, wooIDs []uint) (map[uint]wcProduct, error) {
+// Dla parentID != 0 pobiera warianty tego produktu (/products/{parent}/variations).
+func (w *Woo) fetchProductsBatch(ctx context.Context, parentID uint, wooIDs []uint) (map[uint]wcProduct, error) {
 	if len(wooIDs) == 0 {
 		return nil, nil
 	}
@@ -1093,7 +1495,7 @@ func (w *Woo) fetchProductsBatch(ctx context.Context, wooIDs []uint) (map[uint]w
 	if err != nil {
 		return nil, err
 	}
-	base.Path = "/wp-json/wc/v3/products"
+	base.Path = productsCollectionPath(parentID)
 	ids := make([]string, len(wooIDs))
 	for i, id := range wooIDs {
 		ids[i] = strconv.FormatUint(uint64(id), 10)
@@ -1117,7 +1519,7 @@ func (w *Woo) fetchProductsBatch(ctx context.Context, wooIDs []uint) (map[uint]w
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("batch GET http %d", resp.StatusCode)
+		return nil, &wooHTTPError{Op: "batch GET", StatusCode: resp.StatusCode}
 	}
 
 	var products []wcProduct
@@ -1126,6 +1528,7 @@ func (w *Woo) fetchProductsBatch(ctx context.Context, wooIDs []uint) (map[uint]w
 	}
 	result := make(map[uint]wcProduct, len(products))
 	for _, p := range products {
+		markVariation(&p, parentID)
 		result[uint(p.ID)] = p
 	}
 	return result, nil
@@ -1136,7 +1539,8 @@ type wcBatchResponse struct {
 }
 
 // batchUpdateProducts wysyła POST /products/batch {"update": [...]} i zwraca zaktualizowane produkty.
-func (w *Woo) batchUpdateProducts(ctx context.Context, updates []map[string]any) ([]wcProduct, error) {
+// Dla parentID != 0 aktualizuje warianty przez /products/{parent}/variations/batch.
+func (w *Woo) batchUpdateProducts(ctx context.Context, parentID uint, updates []map[string]any) ([]wcProduct, error) {
 	if len(updates) == 0 {
 		return nil, nil
 	}
@@ -1144,7 +1548,7 @@ func (w *Woo) batchUpdateProducts(ctx context.Context, updates []map[string]any)
 	if err != nil {
 		return nil, err
 	}
-	base.Path = "/wp-json/wc/v3/products/batch"
+	base.Path = productsCollectionPath(parentID) + "/batch"
 
 	rawBody, err := json.Marshal(map[string]any{"update": updates})
 	if err != nil {
@@ -1167,15 +1571,18 @@ func (w *Woo) batchUpdateProducts(ctx context.Context, updates []map[string]any)
 		var payload map[string]any
 		if err := json.NewDecoder(resp.Body).Decode(&payload); err == nil {
 			if raw, merr := json.Marshal(payload); merr == nil {
-				return nil, fmt.Errorf("batch POST http %d: %s", resp.StatusCode, string(raw))
+				return nil, &wooHTTPError{Op: "batch POST", StatusCode: resp.StatusCode, Body: string(raw)}
 			}
 		}
-		return nil, fmt.Errorf("batch POST http %d", resp.StatusCode)
+		return nil, &wooHTTPError{Op: "batch POST", StatusCode: resp.StatusCode}
 	}
 
 	var batchResp wcBatchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&batchResp); err != nil {
 		return nil, err
 	}
+	for idx := range batchResp.Update {
+		markVariation(&batchResp.Update[idx], parentID)
+	}
 	return batchResp.Update, nil
 }
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bartek5186/pcm2www/internal/db"
 	"github.com/rs/zerolog"
@@ -210,7 +211,7 @@ func TestWorkerTickAppliesSafetyPolicy(t *testing.T) {
 	}
 }
 
-func TestWorkerTickMarksBatchPostFailureAsError(t *testing.T) {
+func TestWorkerTickRetriesBatchPostFailureWithBackoff(t *testing.T) {
 	gdb := newWooWorkerTestDB(t)
 	importID := uint(5)
 	towarID := int64(501)
@@ -280,8 +281,13 @@ func TestWorkerTickMarksBatchPostFailureAsError(t *testing.T) {
 	})}
 
 	w := &Woo{
-		log:  zerolog.Nop(),
-		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"},
+		log: zerolog.Nop(),
+		cfg: Config{
+			BaseURL:     "https://woo.test",
+			ConsumerKey: "ck",
+			ConsumerSec: "cs",
+			Retry:       WooRetry{MaxAttempts: 2, BaseDelaySec: 60},
+		},
 		http: client,
 	}
 
@@ -291,14 +297,39 @@ func TestWorkerTickMarksBatchPostFailureAsError(t *testing.T) {
 	if err := gdb.Where("task_key = ?", "price.update:50:25:17").Take(&task).Error; err != nil {
 		t.Fatal(err)
 	}
-	if task.Status != "error" {
-		t.Fatalf("expected batch POST failure to mark task error, got %+v", task)
+	if task.Status != "pending" || task.NextAttemptAt == nil || !task.NextAttemptAt.After(time.Now().Add(50*time.Second)) {
+		t.Fatalf("expected http 500 to return task to pending with backoff, got %+v", task)
 	}
 	if !strings.Contains(task.LastError, "batch POST") || !strings.Contains(task.LastError, "http 500") {
 		t.Fatalf("expected last_error to mention batch POST http 500, got %q", task.LastError)
 	}
-	if task.Attempts != 1 {
-		t.Fatalf("expected one attempt, got %+v", task)
+	if task.Attempts != 1 || task.StartedAt != nil {
+		t.Fatalf("expected one attempt and reset started_at, got %+v", task)
+	}
+
+	// przed next_attempt_at task nie jest claim-owany
+	w.workerTick(context.Background(), gdb)
+	if err := gdb.Where("task_id = ?", task.TaskID).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "pending" || task.Attempts != 1 {
+		t.Fatalf("task must wait for next_attempt_at, got %+v", task)
+	}
+
+	// po terminie druga próba wyczerpuje max_attempts → error
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", task.TaskID).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	w.workerTick(context.Background(), gdb)
+	if err := gdb.Where("task_id = ?", task.TaskID).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "error" || task.Attempts != 2 {
+		t.Fatalf("expected task error after max attempts, got %+v", task)
+	}
+	if !strings.Contains(task.LastError, "batch POST http 500") {
+		t.Fatalf("expected last_error to mention batch POST http 500, got %q", task.LastError)
 	}
 
 	var cache db.WooProductCache
@@ -310,6 +341,67 @@ func TestWorkerTickMarksBatchPostFailureAsError(t *testing.T) {
 	}
 }
 
+func TestWorkerTickFailsValidationErrorImmediately(t *testing.T) {
+	gdb := newWooWorkerTestDB(t)
+	importID := uint(6)
+	wooID := uint(60)
+	towarID := int64(601)
+
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_validation.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(db.WooEANUpdatePayload{ImportID: importID, WooID: wooID, TowarID: towarID, DesiredEAN: "5901234567890"})
+	if err := gdb.Create(&db.WooTask{
+		TaskKey:     "ean.update:60:5901234567890",
+		ImportID:    importID,
+		TowarID:     &towarID,
+		WooID:       &wooID,
+		Kind:        db.WooTaskKindEANUpdate,
+		PayloadJSON: string(payload),
+		Status:      "pending",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet {
+			return jsonResponse(http.StatusOK, wcProduct{ID: int64(wooID), SKU: "SKU-60", Status: "publish", Type: "simple"})
+		}
+		return jsonResponse(http.StatusBadRequest, map[string]any{"code": "rest_invalid_param", "message": "invalid"})
+	})}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "ean.update:60:5901234567890").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "error" || task.Attempts != 1 || task.NextAttemptAt != nil {
+		t.Fatalf("expected 4xx to fail task without retry, got %+v", task)
+	}
+	if !strings.Contains(task.LastError, "http 400") {
+		t.Fatalf("expected last_error to mention http 400, got %q", task.LastError)
+	}
+}
+
+func TestWooRetryDelayGrowsExponentially(t *testing.T) {
+	r := WooRetry{BaseDelaySec: 10, MaxDelaySec: 60}
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 60 * time.Second, 60 * time.Second}
+	for idx, d := range want {
+		if got := r.delay(idx + 1); got != d {
+			t.Fatalf("delay(%d): got %s want %s", idx+1, got, d)
+		}
+	}
+	if (WooRetry{}).maxAttempts() != defaultRetryMaxAttempts {
+		t.Fatal("expected default max attempts")
+	}
+}
+
 func TestWorkerTickDoesNotClaimWhenContextCanceled(t *testing.T) {
 	gdb := newWooWorkerTestDB(t)
 	importID := uint(3)
@@ -371,7 +463,7 @@ func TestExecuteWooTaskRequeuesInterruptedRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	task, err := claimNextSequentialWooTask(gdb)
+	task, err := claimNextSequentialWooTask(gdb, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -412,6 +504,293 @@ func TestExecuteWooTaskRequeuesInterruptedRequest(t *testing.T) {
 	}
 }
 
+func TestWorkerTickCreatesDraftProductAndLinksCache(t *testing.T) {
+	state := map[uint]wcProduct{}
+	client := newWooWorkerTestClient(t, state)
+
+	gdb := newWooWorkerTestDB(t)
+	importID := uint(6)
+	towarID := int64(601)
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_create.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(db.WooProductCreatePayload{
+		ImportID:        importID,
+		TowarID:         towarID,
+		SKU:             "5900000000601",
+		ProductName:     "Created Product",
+		EAN:             "5900000000601",
+		DesiredRegular:  19.99,
+		DesiredHurt:     12,
+		DesiredTaxClass: "2300",
+		DesiredStock:    7,
+	})
+	if err := gdb.Create(&db.WooTask{
+		TaskKey:     "product.create:0:601",
+		ImportID:    importID,
+		TowarID:     &towarID,
+		Kind:        db.WooTaskKindProductCreate,
+		PayloadJSON: string(payload),
+		Status:      "pending",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "product.create:0:601").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "done" {
+		t.Fatalf("expected done product.create task, got %+v", task)
+	}
+	if task.WooID == nil || *task.WooID != 1 {
+		t.Fatalf("expected task woo_id to be filled, got %+v", task.WooID)
+	}
+	if state[1].Status != "draft" || state[1].Type != "simple" {
+		t.Fatalf("expected draft simple product, got %+v", state[1])
+	}
+
+	var cache db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 1).Take(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cache.TowarID == nil || *cache.TowarID != towarID || cache.Ean != "5900000000601" || cache.StockQty != 7 {
+		t.Fatalf("cache not linked after product create: %+v", cache)
+	}
+}
+
+func TestProductCreateRetryVerifiesStoredWooIDWithoutSecondPost(t *testing.T) {
+	state := map[uint]wcProduct{}
+	inner := newWooWorkerTestClient(t, state)
+	posts, failGet := 0, true
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodPost {
+			posts++
+		}
+		if r.Method == http.MethodGet && failGet {
+			failGet = false
+			return textResponse(http.StatusServiceUnavailable, "busy"), nil
+		}
+		return inner.Transport.RoundTrip(r)
+	})}
+
+	gdb := newWooWorkerTestDB(t)
+	towarID := int64(602)
+	payload, _ := json.Marshal(db.WooProductCreatePayload{
+		ImportID: 6, TowarID: towarID, SKU: "5900000000602", ProductName: "Retry Create",
+		EAN: "5900000000602", DesiredRegular: 5, DesiredTaxClass: "2300", DesiredStock: 1,
+	})
+	if err := gdb.Create(&db.WooTask{
+		TaskKey: "product.create:0:602", ImportID: 6, TowarID: &towarID,
+		Kind: db.WooTaskKindProductCreate, PayloadJSON: string(payload), Status: "pending",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs", Retry: WooRetry{MaxAttempts: 3}},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "product.create:0:602").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "pending" || task.WooID == nil || *task.WooID != 1 {
+		t.Fatalf("failed verify GET should keep created woo_id and schedule retry, got %+v", task)
+	}
+
+	if err := gdb.Model(&db.WooTask{}).Where("task_id = ?", task.TaskID).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	w.workerTick(context.Background(), gdb)
+	if err := gdb.Where("task_id = ?", task.TaskID).Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "done" || posts != 1 || len(state) != 1 {
+		t.Fatalf("retry must verify stored woo_id without a second POST, got status=%s posts=%d products=%d", task.Status, posts, len(state))
+	}
+}
+
+func TestProductCreatePostTimeoutIsNotRetried(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodPost {
+			return nil, context.DeadlineExceeded
+		}
+		return jsonResponse(http.StatusOK, []wcProduct{})
+	})}
+
+	gdb := newWooWorkerTestDB(t)
+	towarID := int64(603)
+	payload, _ := json.Marshal(db.WooProductCreatePayload{
+		ImportID: 6, TowarID: towarID, SKU: "5900000000603", ProductName: "Timeout Create",
+		EAN: "5900000000603", DesiredRegular: 5, DesiredTaxClass: "2300",
+	})
+	if err := gdb.Create(&db.WooTask{
+		TaskKey: "product.create:0:603", ImportID: 6, TowarID: &towarID,
+		Kind: db.WooTaskKindProductCreate, PayloadJSON: string(payload), Status: "pending",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{log: zerolog.Nop(), cfg: Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"}, http: client}
+	w.workerTick(context.Background(), gdb)
+
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "product.create:0:603").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "error" || !strings.Contains(task.LastError, "no response to POST /products") {
+		t.Fatalf("POST timeout must end the task without retry, got %+v", task)
+	}
+}
+
+func TestWorkerTickRetiresProductToDraft(t *testing.T) {
+	state := map[uint]wcProduct{
+		70: {ID: 70, Name: "Retired Product", SKU: "SKU-70", Status: "publish", CatalogVisibility: "visible", Type: "simple"},
+	}
+	client := newWooWorkerTestClient(t, state)
+
+	gdb := newWooWorkerTestDB(t)
+	importID := uint(7)
+	towarID := int64(701)
+	wooID := uint(70)
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_retire.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Create(&db.WooProductCache{WooID: wooID, TowarID: &towarID, Kod: "SKU-70", Status: "publish", CatalogVisibility: "visible", Type: "simple"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(db.WooVisibilityPayload{
+		ImportID:      importID,
+		WooID:         wooID,
+		TowarID:       towarID,
+		Retire:        true,
+		Mode:          "draft",
+		CurrentStatus: "publish",
+		DesiredStatus: "draft",
+	})
+	if err := gdb.Create(&db.WooTask{
+		TaskKey:     "visibility.update:70:retire:draft",
+		ImportID:    importID,
+		TowarID:     &towarID,
+		WooID:       &wooID,
+		Kind:        db.WooTaskKindVisibilityUpdate,
+		PayloadJSON: string(payload),
+		Status:      "pending",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	var task db.WooTask
+	if err := gdb.Where("task_key = ?", "visibility.update:70:retire:draft").Take(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "done" {
+		t.Fatalf("expected done visibility task, got %+v", task)
+	}
+	if state[wooID].Status != "draft" || state[wooID].CatalogVisibility != "visible" {
+		t.Fatalf("expected only status to change, got %+v", state[wooID])
+	}
+
+	var cache db.WooProductCache
+	if err := gdb.Where("woo_id = ?", wooID).Take(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cache.Status != "draft" {
+		t.Fatalf("cache not updated after visibility change: %+v", cache)
+	}
+}
+
+func TestWorkerTickAppliesPromoPrices(t *testing.T) {
+	state := map[uint]wcProduct{
+		80: {ID: 80, Name: "Promo", SKU: "SKU-80", RegularPrice: "10", SalePrice: "", HurtPrice: "5",
+			MetaData: []wcMetaData{{Key: "_hurt_price", Value: "5"}}, Status: "publish", Type: "simple"},
+		81: {ID: 81, Name: "Promo End", SKU: "SKU-81", RegularPrice: "10", SalePrice: "8", HurtPrice: "5",
+			MetaData: []wcMetaData{{Key: "_hurt_price", Value: "5"}, {Key: "_omnibus_price", Value: "7.5"}}, Status: "publish", Type: "simple"},
+	}
+	client := newWooWorkerTestClient(t, state)
+
+	gdb := newWooWorkerTestDB(t)
+	importID := uint(8)
+	if err := gdb.Create(&db.ImportFile{ImportID: importID, Filename: "exp_wyk_promo.xml", Status: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	tasks := make([]db.WooTask, 0, 2)
+	for _, p := range []db.WooPriceUpdatePayload{
+		{ImportID: importID, WooID: 80, TowarID: 800, DesiredRegular: 10, DesiredHurt: 5, PromoMode: true, DesiredSale: 8, DesiredOmnibus: 7.5},
+		{ImportID: importID, WooID: 81, TowarID: 801, DesiredRegular: 10, DesiredHurt: 5, PromoMode: true},
+	} {
+		towarID, wooID := p.TowarID, p.WooID
+		if err := gdb.Create(&db.WooProductCache{WooID: wooID, TowarID: &towarID, Status: "publish"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := json.Marshal(p)
+		tasks = append(tasks, db.WooTask{
+			TaskKey:     fmt.Sprintf("price.update:%d:promo", wooID),
+			ImportID:    importID,
+			TowarID:     &towarID,
+			WooID:       &wooID,
+			Kind:        db.WooTaskKindPriceUpdate,
+			PayloadJSON: string(raw),
+			Status:      "pending",
+		})
+	}
+	if err := gdb.Create(&tasks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Woo{
+		log:  zerolog.Nop(),
+		cfg:  Config{BaseURL: "https://woo.test", ConsumerKey: "ck", ConsumerSec: "cs"},
+		http: client,
+	}
+	w.workerTick(context.Background(), gdb)
+
+	var done int64
+	if err := gdb.Model(&db.WooTask{}).Where("status = ?", "done").Count(&done).Error; err != nil {
+		t.Fatal(err)
+	}
+	if done != 2 {
+		var all []db.WooTask
+		_ = gdb.Find(&all).Error
+		t.Fatalf("expected both promo tasks done, got %+v", all)
+	}
+	if state[80].SalePrice != "8" || state[80].metaValue("_omnibus_price") != "7.5" {
+		t.Fatalf("expected promo applied, got %+v", state[80])
+	}
+	if state[81].SalePrice != "" || state[81].metaValue("_omnibus_price") != "" {
+		t.Fatalf("expected promo cleared, got %+v", state[81])
+	}
+
+	var cache db.WooProductCache
+	if err := gdb.Where("woo_id = ?", 80).Take(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cache.PriceSale != 8 || cache.OmnibusPrice != 7.5 {
+		t.Fatalf("cache not updated after promo: %+v", cache)
+	}
+}
+
 func newWooWorkerTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
 
@@ -430,6 +809,10 @@ func newWooWorkerTestDB(t *testing.T) *gorm.DB {
 		&db.WooTask{},
 		&db.KV{},
 		&db.LinkIssue{},
+		&db.ManualLink{},
+		&db.LinkProposal{},
+		&db.WooOrder{},
+		&db.WooOrderLine{},
 	); err != nil {
 		t.Fatal(err)
 	}
@@ -473,6 +856,29 @@ func newWooWorkerTestClient(t *testing.T, state map[uint]wcProduct) *http.Client
 			return jsonResponse(http.StatusOK, map[string]any{"update": updated})
 		}
 
+		if path == "" && r.Method == http.MethodPost {
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				return textResponse(http.StatusBadRequest, "bad json"), nil
+			}
+			var id uint = 1
+			for existing := range state {
+				if existing >= id {
+					id = existing + 1
+				}
+			}
+			product := wcProduct{
+				ID:     int64(id),
+				Name:   fmt.Sprint(body["name"]),
+				SKU:    fmt.Sprint(body["sku"]),
+				Status: fmt.Sprint(body["status"]),
+				Type:   fmt.Sprint(body["type"]),
+			}
+			applyProductUpdate(&product, body)
+			state[id] = product
+			return jsonResponse(http.StatusCreated, product)
+		}
+
 		if path == "" {
 			var products []wcProduct
 			for _, product := range state {
@@ -591,6 +997,9 @@ func applyProductUpdate(product *wcProduct, body map[string]any) {
 	if raw, ok := body["regular_price"]; ok {
 		product.RegularPrice = fmt.Sprint(raw)
 	}
+	if raw, ok := body["sale_price"]; ok {
+		product.SalePrice = fmt.Sprint(raw)
+	}
 	if raw, ok := body["hurt_price"]; ok {
 		_ = raw // top-level hurt_price is ignored by the live store
 	}
@@ -611,6 +1020,9 @@ func applyProductUpdate(product *wcProduct, body map[string]any) {
 	if raw, ok := body["catalog_visibility"]; ok {
 		product.CatalogVisibility = fmt.Sprint(raw)
 	}
+	if raw, ok := body["status"]; ok {
+		product.Status = fmt.Sprint(raw)
+	}
 	if raw, ok := body["meta_data"]; ok {
 		applyMetaDataUpdate(product, raw)
 	}
@@ -0,0 +1,205 @@
+// internal/namematch/namematch.go
+package namematch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Dopasowanie nazw towarów PCM i produktów Woo bez EAN — wspólne dla linkera (strategia name)
+// i raportu missing-ean-names.
+
+var nameReplacer = strings.NewReplacer(
+	"ą", "a", "ć", "c", "ę", "e", "ł", "l", "ń", "n", "ó", "o", "ś", "s", "ź", "z", "ż", "z",
+	"Ą", "a", "Ć", "c", "Ę", "e", "Ł", "l", "Ń", "n", "Ó", "o", "Ś", "s", "Ź", "z", "Ż", "z",
+	"ă", "a", "â", "a", "î", "i", "ș", "s", "ş", "s", "ț", "t", "ţ", "t",
+	"Ă", "a", "Â", "a", "Î", "i", "Ș", "s", "Ş", "s", "Ț", "t", "Ţ", "t",
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ý", "y", "ÿ", "y",
+)
+
+var nameStopWords = map[string]struct{}{
+	"aoc": {}, "bio": {}, "brut": {}, "demi": {}, "doc": {}, "docg": {}, "dop": {},
+	"do": {}, "dry": {}, "edition": {}, "extra": {}, "ig": {}, "igt": {}, "igp": {},
+	"millesimato": {}, "organic": {}, "reserve": {}, "reserva": {}, "semi": {}, "special": {},
+	"vegan": {}, "vintage": {},
+}
+
+// Name to nazwa po normalizacji, gotowa do Score.
+type Name struct {
+	norm   string
+	tokens []string
+	set    map[string]struct{}
+}
+
+// Empty: nazwa bez tokenów po odrzuceniu szumu — nie da się jej dopasować.
+func (p Name) Empty() bool {
+	return p.norm == "" || len(p.tokens) == 0
+}
+
+// Tokens zwraca liczbę znaczących tokenów nazwy.
+func (p Name) Tokens() int {
+	return len(p.tokens)
+}
+
+// Normalize: małe litery bez diakrytyków, tylko litery i cyfry rozdzielone pojedynczą spacją.
+func Normalize(s string) string {
+	s = strings.TrimSpace(strings.ToLower(nameReplacer.Replace(s)))
+	if s == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	lastSpace := true
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			lastSpace = false
+		case !lastSpace:
+			b.WriteByte(' ')
+			lastSpace = true
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+func Prepare(s string) Name {
+	norm := Normalize(s)
+	if norm == "" {
+		return Name{}
+	}
+
+	rawTokens := strings.Fields(norm)
+	tokens := make([]string, 0, len(rawTokens))
+	tokenSet := make(map[string]struct{}, len(rawTokens))
+	for _, token := range rawTokens {
+		if isNoiseToken(token) {
+			continue
+		}
+		if _, ok := tokenSet[token]; ok {
+			continue
+		}
+		tokenSet[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+
+	if len(tokens) == 0 {
+		return Name{norm: norm}
+	}
+	return Name{norm: norm, tokens: tokens, set: tokenSet}
+}
+
+func isNoiseToken(token string) bool {
+	if token == "" {
+		return true
+	}
+	if _, ok := nameStopWords[token]; ok {
+		return true
+	}
+	if len(token) == 4 && (strings.HasPrefix(token, "19") || strings.HasPrefix(token, "20")) {
+		return true
+	}
+	return false
+}
+
+// Score zwraca wynik 0..1, jakość dopasowania (exact/strong/medium/fuzzy) i wspólne tokeny.
+// ok=false oznacza brak sensownego dopasowania.
+func Score(a, b Name) (float64, string, []string, bool) {
+	if len(a.tokens) == 0 || len(b.tokens) == 0 {
+		return 0, "", nil, false
+	}
+
+	shared := make([]string, 0, min(len(a.tokens), len(b.tokens)))
+	for _, token := range a.tokens {
+		if _, ok := b.set[token]; ok {
+			shared = append(shared, token)
+		}
+	}
+
+	if len(shared) == 0 {
+		return 0, "", nil, false
+	}
+
+	union := len(a.set)
+	for token := range b.set {
+		if _, ok := a.set[token]; !ok {
+			union++
+		}
+	}
+
+	sharedCount := float64(len(shared))
+	containment := sharedCount / float64(min(len(a.set), len(b.set)))
+	jaccard := sharedCount / float64(union)
+	dice := diceCoefficient(a.norm, b.norm)
+	score := 0.50*containment + 0.30*jaccard + 0.20*dice
+
+	if a.norm == b.norm {
+		return 1, "exact_name", shared, true
+	}
+	if strings.Contains(a.norm, b.norm) || strings.Contains(b.norm, a.norm) {
+		score += 0.05
+	}
+	if score > 0.99 {
+		score = 0.99
+	}
+
+	switch {
+	case len(shared) >= 3 && score >= 0.70:
+		return score, "strong_name_match", shared, true
+	case len(shared) >= 2 && score >= 0.55:
+		return score, "medium_name_match", shared, true
+	case len(shared) >= 1 && dice >= 0.82:
+		return score, "fuzzy_name_match", shared, true
+	default:
+		return 0, "", nil, false
+	}
+}
+
+func diceCoefficient(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	aBigrams := make(map[string]int)
+	for _, gram := range stringBigrams(a) {
+		aBigrams[gram]++
+	}
+
+	common := 0
+	for _, gram := range stringBigrams(b) {
+		if aBigrams[gram] <= 0 {
+			continue
+		}
+		common++
+		aBigrams[gram]--
+	}
+
+	total := len(stringBigrams(a)) + len(stringBigrams(b))
+	if total == 0 {
+		return 0
+	}
+	return float64(2*common) / float64(total)
+}
+
+func stringBigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		return []string{s}
+	}
+
+	out := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		out = append(out, string(runes[i:i+2]))
+	}
+	return out
+}
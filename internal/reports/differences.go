@@ -0,0 +1,223 @@
+package reports
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/namematch"
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Report{
+		Name:        "differences",
+		Description: "różnice sklep ↔ magazyn po EAN (braki, duplikaty, produkty tylko po jednej stronie)",
+		Build:       buildDifferences,
+	})
+	Register(Report{
+		Name:        "missing-ean-names",
+		Description: "kandydaci z magazynu (top 5 po nazwie) dla produktów sklepu bez EAN",
+		Build:       buildMissingEANNames,
+	})
+}
+
+var reDigits = regexp.MustCompile(`\D+`)
+
+func cleanEAN(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return reDigits.ReplaceAllString(s, "")
+}
+
+// differenceRow: jedna strona (magazyn albo sklep) jest pusta.
+type differenceRow struct {
+	kind    string
+	towarID any
+	magEAN  string
+	magName string
+	wooID   any
+	sku     string
+	shopEAN string
+	name    string
+	note    string
+}
+
+const (
+	diffMagazineMissingEAN = "MAGAZINE_MISSING_EAN"
+	diffDuplicateMagazine  = "DUPLICATE_MAGAZINE_EAN"
+	diffMagazineNotInShop  = "MAGAZINE_NOT_IN_SHOP_BY_EAN"
+	diffDuplicateShop      = "DUPLICATE_SHOP_EAN"
+	diffShopMissingEAN     = "SHOP_MISSING_EAN"
+	diffShopNotInMagazine  = "SHOP_NOT_IN_MAGAZINE_BY_EAN"
+)
+
+func buildDifferences(gdb *gorm.DB, p Params) ([]Table, error) {
+	shopRows, err := loadShopProducts(gdb, p.Shop)
+	if err != nil {
+		return nil, err
+	}
+	magRows, err := loadMagazineProducts(gdb)
+	if err != nil {
+		return nil, err
+	}
+	rows := differences(shopRows, magRows)
+
+	t := Table{Name: "differences", Columns: []Column{
+		{"difference_type", Text}, {"local_towar_id", Int}, {"local_ean", Text}, {"local_name", Text},
+		{"shop_woo_id", Int}, {"shop_sku", Text}, {"shop_ean", Text}, {"shop_name", Text}, {"note", Text},
+	}}
+	for _, r := range rows {
+		t.Append(r.kind, r.towarID, r.magEAN, r.magName, r.wooID, r.sku, r.shopEAN, r.name, r.note)
+	}
+	return splitByType(t, p.Split), nil
+}
+
+func differences(shopRows []db.WooProductCache, magRows []magazineProduct) []differenceRow {
+	shopByEAN := make(map[string]int)
+	magByEAN := make(map[string]int)
+	for _, row := range shopRows {
+		if key := cleanEAN(row.Ean); key != "" {
+			shopByEAN[key]++
+		}
+	}
+	for _, row := range magRows {
+		if key := cleanEAN(row.Kod); key != "" {
+			magByEAN[key]++
+		}
+	}
+
+	out := make([]differenceRow, 0)
+	for _, row := range magRows {
+		ean := cleanEAN(row.Kod)
+		var kind, note string
+		switch {
+		case ean == "":
+			kind, note = diffMagazineMissingEAN, "Pole kod/EAN w magazynie jest puste lub nienumeryczne"
+		case magByEAN[ean] > 1:
+			kind, note = diffDuplicateMagazine, "Ten EAN występuje wielokrotnie po stronie magazynu"
+		case shopByEAN[ean] == 0:
+			kind, note = diffMagazineNotInShop, "Brak produktu w sklepie z takim EAN"
+		case shopByEAN[ean] > 1:
+			kind, note = diffDuplicateShop, "Ten EAN występuje wielokrotnie po stronie sklepu"
+		default:
+			continue
+		}
+		out = append(out, differenceRow{kind: kind, towarID: row.TowarID, magEAN: row.Kod, magName: row.Nazwa, note: note})
+	}
+	for _, row := range shopRows {
+		ean := cleanEAN(row.Ean)
+		var kind, note string
+		switch {
+		case ean == "":
+			kind, note = diffShopMissingEAN, "Produkt sklepowy nie ma EAN, więc nie można go porównać po EAN"
+		case shopByEAN[ean] > 1:
+			kind, note = diffDuplicateShop, "Ten EAN występuje wielokrotnie po stronie sklepu"
+		case magByEAN[ean] == 0:
+			kind, note = diffShopNotInMagazine, "Brak produktu w magazynie z takim EAN"
+		case magByEAN[ean] > 1:
+			kind, note = diffDuplicateMagazine, "Ten EAN występuje wielokrotnie po stronie magazynu"
+		default:
+			continue
+		}
+		out = append(out, differenceRow{kind: kind, wooID: int64(row.WooID), sku: row.Kod, shopEAN: row.Ean, name: row.Name, note: note})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].kind != out[j].kind {
+			return out[i].kind < out[j].kind
+		}
+		ti, _ := out[i].towarID.(int64)
+		tj, _ := out[j].towarID.(int64)
+		if ti != tj {
+			return ti < tj
+		}
+		wi, _ := out[i].wooID.(int64)
+		wj, _ := out[j].wooID.(int64)
+		return wi < wj
+	})
+	return out
+}
+
+func buildMissingEANNames(gdb *gorm.DB, p Params) ([]Table, error) {
+	shopRows, err := loadShopProducts(gdb, p.Shop)
+	if err != nil {
+		return nil, err
+	}
+	magRows, err := loadMagazineProducts(gdb)
+	if err != nil {
+		return nil, err
+	}
+
+	type preparedMagazine struct {
+		row  magazineProduct
+		name namematch.Name
+	}
+	type candidate struct {
+		row     magazineProduct
+		tokens  int
+		score   float64
+		quality string
+		shared  []string
+	}
+
+	mags := make([]preparedMagazine, 0, len(magRows))
+	for _, row := range magRows {
+		if cleanEAN(row.Kod) == "" {
+			continue
+		}
+		if name := namematch.Prepare(row.Nazwa); !name.Empty() {
+			mags = append(mags, preparedMagazine{row: row, name: name})
+		}
+	}
+
+	t := Table{Name: "missing_ean_names", Columns: []Column{
+		{"shop_woo_id", Int}, {"shop_sku", Text}, {"shop_name", Text}, {"candidate_rank", Int},
+		{"match_quality", Text}, {"match_score", Number}, {"shared_tokens", Text},
+		{"shared_token_count", Int}, {"shop_token_count", Int}, {"mag_token_count", Int},
+		{"mag_towar_id", Int}, {"mag_ean", Text}, {"mag_name", Text}, {"mag_stock", Number}, {"mag_price", Money},
+	}}
+	for _, shop := range shopRows {
+		if cleanEAN(shop.Ean) != "" {
+			continue
+		}
+		shopName := namematch.Prepare(shop.Name)
+		if shopName.Empty() {
+			continue
+		}
+
+		var candidates []candidate
+		for _, mag := range mags {
+			score, quality, shared, ok := namematch.Score(shopName, mag.name)
+			if !ok {
+				continue
+			}
+			candidates = append(candidates, candidate{row: mag.row, tokens: mag.name.Tokens(), score: score, quality: quality, shared: shared})
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].score != candidates[j].score {
+				return candidates[i].score > candidates[j].score
+			}
+			if len(candidates[i].shared) != len(candidates[j].shared) {
+				return len(candidates[i].shared) > len(candidates[j].shared)
+			}
+			if candidates[i].row.TotalStock != candidates[j].row.TotalStock {
+				return candidates[i].row.TotalStock > candidates[j].row.TotalStock
+			}
+			return candidates[i].row.TowarID < candidates[j].row.TowarID
+		})
+		if len(candidates) > 5 {
+			candidates = candidates[:5]
+		}
+
+		for idx, c := range candidates {
+			t.Append(int64(shop.WooID), shop.Kod, shop.Name, int64(idx+1), c.quality, c.score,
+				strings.Join(c.shared, "|"), int64(len(c.shared)), int64(shopName.Tokens()), int64(c.tokens),
+				c.row.TowarID, c.row.Kod, c.row.Nazwa, c.row.TotalStock, c.row.CenaDetal)
+		}
+	}
+	return []Table{t}, nil
+}
@@ -0,0 +1,115 @@
+package reports
+
+import (
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/integrations/woocommerce"
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Report{
+		Name:        "shop-products",
+		Description: "produkty z cache sklepu Woo (--shop)",
+		Build:       buildShopProducts,
+	})
+	Register(Report{
+		Name:        "magazine-products",
+		Description: "towary ze stagingu PCM ze stanem zsumowanym po magazynach",
+		Build:       buildMagazineProducts,
+	})
+	Register(Report{
+		Name:        "link-issues",
+		Description: "problemy linkowania towarów z produktami Woo (--shop zawęża do sklepu)",
+		Build:       buildLinkIssues,
+	})
+	Register(Report{
+		Name:        "preview",
+		Description: "podgląd zmian dry_run, jeden wiersz na zmienione pole (--import)",
+		Build:       buildPreview,
+	})
+}
+
+func buildShopProducts(gdb *gorm.DB, p Params) ([]Table, error) {
+	rows, err := loadShopProducts(gdb, p.Shop)
+	if err != nil {
+		return nil, err
+	}
+	t := Table{Name: "shop_products", Columns: []Column{
+		{"woo_id", Int}, {"shop_sku", Text}, {"shop_ean", Text}, {"shop_name", Text},
+		{"shop_status", Text}, {"shop_type", Text}, {"stock_qty", Number},
+		{"price_regular", Money}, {"price_sale", Money}, {"hurt_price", Money}, {"date_modified", Text},
+	}}
+	for _, r := range rows {
+		t.Append(int64(r.WooID), r.Kod, r.Ean, r.Name, r.Status, r.Type, r.StockQty,
+			r.PriceRegular, r.PriceSale, r.HurtPrice, r.DateModified)
+	}
+	return []Table{t}, nil
+}
+
+func buildMagazineProducts(gdb *gorm.DB, _ Params) ([]Table, error) {
+	rows, err := loadMagazineProducts(gdb)
+	if err != nil {
+		return nil, err
+	}
+	t := Table{Name: "magazine_products", Columns: []Column{
+		{"towar_id", Int}, {"mag_ean", Text}, {"mag_name", Text}, {"cena_detal", Money},
+		{"cena_hurtowa", Money}, {"aktywny_wsi", Int}, {"do_usuniecia", Int}, {"total_stock", Number},
+		{"total_reserved", Number}, {"import_id", Int}, {"updated_at", Text},
+	}}
+	for _, r := range rows {
+		t.Append(r.TowarID, r.Kod, r.Nazwa, r.CenaDetal, r.CenaHurtowa, boolInt(r.AktywnyWSI),
+			boolInt(r.DoUsuniecia), r.TotalStock, r.TotalReserved, int64(r.ImportID), formatTime(r.StProduct))
+	}
+	return []Table{t}, nil
+}
+
+func buildLinkIssues(gdb *gorm.DB, p Params) ([]Table, error) {
+	tx := gdb.Order("shop, towar_id, reason")
+	if p.ShopSet {
+		tx = tx.Where("shop = ?", p.Shop)
+	}
+	var issues []db.LinkIssue
+	if err := tx.Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	t := Table{Name: "link_issues", Columns: []Column{
+		{"shop", Text}, {"towar_id", Int}, {"kod", Text}, {"reason", Text},
+		{"woo_ids", Text}, {"details", Text}, {"updated_at", Text},
+	}}
+	for _, is := range issues {
+		t.Append(is.Shop, is.TowarID, is.Kod, is.Reason, is.WooIDs, is.Details, is.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return []Table{t}, nil
+}
+
+func buildPreview(gdb *gorm.DB, p Params) ([]Table, error) {
+	rows, err := woocommerce.LoadPreviewRows(gdb, p.ImportID)
+	if err != nil {
+		return nil, err
+	}
+	t := Table{Name: "preview", Columns: []Column{
+		{"task_id", Int}, {"import_id", Int}, {"kind", Text}, {"woo_id", Int}, {"towar_id", Int},
+		{"method", Text}, {"path", Text}, {"field", Text}, {"current", Text}, {"desired", Text}, {"shop", Text},
+	}}
+	for _, r := range rows {
+		var wooID, towarID any
+		if r.WooID != nil {
+			wooID = int64(*r.WooID)
+		}
+		if r.TowarID != nil {
+			towarID = *r.TowarID
+		}
+		for _, d := range r.Preview.Diff {
+			t.Append(int64(r.TaskID), int64(r.ImportID), r.Kind, wooID, towarID,
+				r.Preview.Method, r.Preview.Path, d.Field, d.Current, d.Desired, r.Shop)
+		}
+	}
+	return []Table{t}, nil
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
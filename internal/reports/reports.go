@@ -0,0 +1,130 @@
+// internal/reports/reports.go
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Kind mówi, jak zapisać wartości kolumny (CSV: format liczby, XLSX: typ komórki).
+type Kind int
+
+const (
+	Text   Kind = iota
+	Int         // identyfikatory, liczniki
+	Number      // ilości, wyniki dopasowań — bez zaokrąglania
+	Money       // ceny — zawsze 2 miejsca po przecinku
+)
+
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// Table to jeden arkusz raportu. Wartości w Rows: string, int64, float64 albo nil (pusta komórka);
+// kolumna liczbowa może zawierać string, gdy wartości jest kilka (np. "12.00|13.00").
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]any
+}
+
+func (t *Table) Append(values ...any) {
+	t.Rows = append(t.Rows, values)
+}
+
+// Params to wspólne parametry raportów; każdy raport czyta tylko te, których potrzebuje.
+type Params struct {
+	Shop     string // instancja woocommerce[:<shop>] dla raportów z cache Woo ("" = sklep domyślny)
+	ShopSet  bool   // --shop podany jawnie; link-issues bez niego pokazuje wszystkie sklepy
+	XLSXPath string // plik XLS „towary” z PC-Market (raporty xls-*)
+	ImportID uint   // zawężenie do importu (preview; 0 = wszystkie)
+	OnlyDiff bool   // porównania: pomiń wiersze zgodne (MATCH)
+	Split    bool   // raporty różnic: osobna tabela (arkusz XLSX) na każdy typ różnicy
+}
+
+// Report to nazwany raport z rejestru. Build zwraca co najmniej jedną tabelę.
+type Report struct {
+	Name        string
+	Description string
+	NeedsXLSX   bool // wymaga Params.XLSXPath
+	Build       func(gdb *gorm.DB, p Params) ([]Table, error)
+}
+
+var (
+	regMu    sync.RWMutex
+	registry = map[string]Report{}
+)
+
+func Register(r Report) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	registry[r.Name] = r
+}
+
+func Get(name string) (Report, bool) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// All zwraca raporty posortowane po nazwie.
+func All() []Report {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	out := make([]Report, 0, len(registry))
+	for _, r := range registry {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// splitByType dzieli tabelę różnic wg kolumny typu (difference_type / status) na tabele
+// nazwane typem, poprzedzone podsumowaniem liczby wierszy. Bez split zwraca tabelę bez zmian.
+func splitByType(t Table, split bool) []Table {
+	if !split {
+		return []Table{t}
+	}
+	byType := make(map[string]*Table)
+	var types []string
+	for _, row := range t.Rows {
+		kind, _ := row[0].(string)
+		part, ok := byType[kind]
+		if !ok {
+			part = &Table{Name: kind, Columns: t.Columns}
+			byType[kind] = part
+			types = append(types, kind)
+		}
+		part.Rows = append(part.Rows, row)
+	}
+	sort.Strings(types)
+
+	summary := Table{Name: "summary", Columns: []Column{{t.Columns[0].Name, Text}, {"rows", Int}}}
+	out := []Table{summary}
+	for _, kind := range types {
+		out[0].Append(kind, int64(len(byType[kind].Rows)))
+		out = append(out, *byType[kind])
+	}
+	return out
+}
+
+// ErrUnknownReport: brak raportu o podanej nazwie w rejestrze.
+var ErrUnknownReport = errors.New("nieznany raport")
+
+// Run buduje raport z rejestru.
+func Run(gdb *gorm.DB, name string, p Params) ([]Table, error) {
+	r, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownReport, name)
+	}
+	if r.NeedsXLSX && p.XLSXPath == "" {
+		return nil, fmt.Errorf("raport %s wymaga pliku XLS towary (--xlsx)", name)
+	}
+	return r.Build(gdb, p)
+}
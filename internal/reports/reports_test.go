@@ -0,0 +1,214 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dir := t.TempDir()
+	h, err := db.OpenWithConfig(dir, db.OpenConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := h.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := h.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return h.DB
+}
+
+func TestDifferencesReport(t *testing.T) {
+	gdb := openTestDB(t)
+	rows := []any{
+		&db.StProduct{TowarID: 1, Kod: "5900000000011", Nazwa: "Wino Czerwone", CenaDetal: 20},
+		&db.StProduct{TowarID: 2, Kod: "", Nazwa: "Bez kodu"},
+		&db.StProduct{TowarID: 3, Kod: "5900000000028", Nazwa: "Tylko magazyn"},
+		&db.StStock{TowarID: 1, MagazynID: 1, Stan: 3},
+		&db.StStock{TowarID: 1, MagazynID: 2, Stan: 2},
+		&db.WooProductCache{WooID: 10, Ean: "5900000000011", Name: "Wino Czerwone"},
+		&db.WooProductCache{WooID: 11, Ean: "", Name: "Wino Czerwone Wytrawne"},
+		&db.WooProductCache{WooID: 12, Shop: "b2b", Ean: "5900000000028", Name: "Inny sklep"},
+	}
+	for _, r := range rows {
+		if err := gdb.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tables, err := Run(gdb, "differences", Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, row := range tables[0].Rows {
+		kinds[row[0].(string)]++
+	}
+	want := map[string]int{diffMagazineMissingEAN: 1, diffMagazineNotInShop: 1, diffShopMissingEAN: 1}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	for k, n := range want {
+		if kinds[k] != n {
+			t.Fatalf("expected %v, got %v", want, kinds)
+		}
+	}
+
+	split, err := Run(gdb, "differences", Params{Split: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(split) != 4 || split[0].Name != "summary" || split[1].Name != diffMagazineMissingEAN || len(split[1].Rows) != 1 {
+		t.Fatalf("expected summary and one table per difference type, got %d tables", len(split))
+	}
+
+	mag, err := Run(gdb, "magazine-products", Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, mag[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "1,5900000000011,Wino Czerwone,20.00,0.00,0,0,5,0,") {
+		t.Fatalf("unexpected magazine csv:\n%s", buf.String())
+	}
+
+	names, err := Run(gdb, "missing-ean-names", Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names[0].Rows) != 1 || names[0].Rows[0][10] != int64(1) {
+		t.Fatalf("expected towar 1 as name candidate for woo 11, got %v", names[0].Rows)
+	}
+}
+
+func TestWriteJSONAndXLSX(t *testing.T) {
+	tables := []Table{
+		{Name: "a", Columns: []Column{{"id", Int}, {"cena", Money}, {"nazwa", Text}}, Rows: [][]any{{int64(1), 12.345, "Żubrówka & co"}, {int64(2), nil, ""}}},
+		{Name: "b", Columns: []Column{{"x", Text}}},
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(&js, tables); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string][]map[string]any
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json %s: %v", js.String(), err)
+	}
+	if decoded["a"][0]["cena"] != 12.35 || decoded["a"][1]["cena"] != nil || len(decoded["b"]) != 0 {
+		t.Fatalf("unexpected json: %s", js.String())
+	}
+
+	var xl bytes.Buffer
+	if err := WriteXLSX(&xl, tables); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(xl.Bytes()), int64(xl.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheets := 0
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "xl/worksheets/sheet") {
+			sheets++
+		}
+	}
+	if sheets != 2 {
+		t.Fatalf("expected 2 worksheets, got %d", sheets)
+	}
+}
+
+func TestXLSShopReportReadsWrittenWorkbook(t *testing.T) {
+	gdb := openTestDB(t)
+	for _, r := range []any{
+		&db.WooProductCache{WooID: 10, Ean: "5900000000011", Name: "Wino", PriceRegular: 24.6, StockQty: 5},
+		&db.WooProductCache{WooID: 11, Ean: "5900000000028", Name: "Piwo", PriceRegular: 5, PriceSale: 4, StockQty: 1},
+	} {
+		if err := gdb.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// plik XLS towary z nagłówkiem jak w eksporcie PC-Market
+	towary := Table{Name: "towary", Columns: []Column{
+		{"Id", Int}, {"Nazwa", Text}, {"Kod", Text}, {"Cena det.", Money}, {"Cena hurt.", Money}, {"VAT %", Number}, {"Ilość", Number},
+	}, Rows: [][]any{
+		{int64(1), "Wino", "5900000000011", 24.6, 0.0, 23.0, 5.0},
+		{int64(2), "Piwo", "5900000000028", 5.0, 0.0, 23.0, 1.0},
+		{int64(3), "Sok", "5900000000035", 3.0, 0.0, 5.0, 0.0},
+	}}
+	path := filepath.Join(t.TempDir(), "towary.xlsx")
+	if _, err := WriteFile(path, FormatXLSX, []Table{towary}); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := Run(gdb, "xls-shop", Params{XLSXPath: path, OnlyDiff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, row := range tables[0].Rows {
+		got[row[1].(string)] = row[0].(string) + ": " + row[len(row)-1].(string)
+	}
+	if len(got) != 2 ||
+		got["5900000000028"] != "DIFF: Różnice: effective_price, sale_active" ||
+		!strings.HasPrefix(got["5900000000035"], "ONLY_XLS") {
+		t.Fatalf("unexpected xls-shop rows: %v", got)
+	}
+}
+
+func TestLinkIssuesAndPreviewReports(t *testing.T) {
+	gdb := openTestDB(t)
+	wooID := uint(10)
+	rows := []any{
+		&db.LinkIssue{TowarID: 1, Reason: "missing_ean"},
+		&db.LinkIssue{Shop: "b2b", TowarID: 2, Reason: "missing_ean"},
+		&db.WooTask{TaskKey: "stock.update:10", Shop: "b2b", ImportID: 7, WooID: &wooID, Kind: db.WooTaskKindStockUpdate, Status: "previewed",
+			PreviewJSON: `{"method":"POST","path":"/wp-json/wc/v3/products/batch","diff":[{"field":"stock_quantity","current":"1","desired":"4"}]}`},
+	}
+	for _, r := range rows {
+		if err := gdb.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bez --shop wszystkie sklepy, z --shop (także pustym) tylko wskazany
+	for _, tc := range []struct {
+		p    Params
+		want int
+	}{{Params{}, 2}, {Params{Shop: "", ShopSet: true}, 1}, {Params{Shop: "b2b", ShopSet: true}, 1}} {
+		tables, err := Run(gdb, "link-issues", tc.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tables[0].Rows) != tc.want {
+			t.Fatalf("link-issues %+v: expected %d rows, got %d", tc.p, tc.want, len(tables[0].Rows))
+		}
+	}
+
+	tables, err := Run(gdb, "preview", Params{ImportID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, tables); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "stock.update,10,,POST,/wp-json/wc/v3/products/batch,stock_quantity,1,4,b2b") {
+		t.Fatalf("unexpected CSV preview:\n%s", buf.String())
+	}
+}
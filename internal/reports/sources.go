@@ -0,0 +1,61 @@
+package reports
+
+import (
+	"github.com/bartek5186/pcm2www/internal/db"
+	"gorm.io/gorm"
+)
+
+// magazineProduct to towar ze stagingu ze stanem zsumowanym po magazynach i źródłach.
+type magazineProduct struct {
+	db.StProduct
+	TotalStock    float64
+	TotalReserved float64
+}
+
+// loadShopProducts czyta cache produktów jednej instancji sklepu.
+func loadShopProducts(gdb *gorm.DB, shop string) ([]db.WooProductCache, error) {
+	var rows []db.WooProductCache
+	err := gdb.Where("shop = ?", shop).Order("woo_id").Find(&rows).Error
+	return rows, err
+}
+
+// loadMagazineProducts czyta st_products i sumy st_stocks. Zapytania bez SQL zależnego
+// od silnika — raporty działają tak samo na sqlite, postgres i mysql.
+func loadMagazineProducts(gdb *gorm.DB) ([]magazineProduct, error) {
+	var products []db.StProduct
+	if err := gdb.Order("towar_id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	var sums []struct {
+		TowarID    int64
+		Stan       float64
+		Rezerwacja float64
+	}
+	if err := gdb.Model(&db.StStock{}).
+		Select("towar_id, COALESCE(SUM(stan), 0) AS stan, COALESCE(SUM(rezerwacja), 0) AS rezerwacja").
+		Group("towar_id").Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+	stock := make(map[int64]int, len(sums))
+	for i, s := range sums {
+		stock[s.TowarID] = i
+	}
+
+	rows := make([]magazineProduct, 0, len(products))
+	for _, p := range products {
+		row := magazineProduct{StProduct: p}
+		if i, ok := stock[p.TowarID]; ok {
+			row.TotalStock = sums[i].Stan
+			row.TotalReserved = sums[i].Rezerwacja
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func formatTime(p db.StProduct) string {
+	if p.UpdatedAt.IsZero() {
+		return ""
+	}
+	return p.UpdatedAt.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,187 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/xlstowary"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatXLSX = "xlsx"
+)
+
+// ParseFormat sprawdza nazwę formatu (--format).
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case FormatCSV, FormatJSON, FormatXLSX:
+		return f, nil
+	}
+	return "", fmt.Errorf("nieznany format %q (csv, json, xlsx)", s)
+}
+
+// Write zapisuje raport do jednego strumienia. CSV mieści tylko jedną tabelę —
+// raport wielotabelowy trzeba zapisać przez WriteFile.
+func Write(w io.Writer, format string, tables []Table) error {
+	switch format {
+	case FormatCSV:
+		if len(tables) != 1 {
+			return fmt.Errorf("raport ma %d tabel — CSV wymaga zapisu do pliku (--out)", len(tables))
+		}
+		return WriteCSV(w, tables[0])
+	case FormatJSON:
+		return WriteJSON(w, tables)
+	case FormatXLSX:
+		return WriteXLSX(w, tables)
+	}
+	return fmt.Errorf("nieznany format %q", format)
+}
+
+// WriteFile zapisuje raport do pliku. CSV z kilkoma tabelami trafia do osobnych plików
+// <nazwa>_<tabela>.csv obok path. Zwraca ścieżki zapisanych plików.
+func WriteFile(path, format string, tables []Table) ([]string, error) {
+	if format == FormatCSV && len(tables) > 1 {
+		base := strings.TrimSuffix(path, filepath.Ext(path))
+		paths := make([]string, 0, len(tables))
+		for _, t := range tables {
+			p := base + "_" + t.Name + ".csv"
+			if err := writeFile(p, format, []Table{t}); err != nil {
+				return paths, err
+			}
+			paths = append(paths, p)
+		}
+		return paths, nil
+	}
+	if err := writeFile(path, format, tables); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+func writeFile(path, format string, tables []Table) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, format, tables); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, c := range t.Columns {
+			record[i] = formatValue(c.Kind, cell(row, i))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON: jedna tabela — tablica obiektów; kilka — obiekt {tabela: [...]}.
+// Klucze wierszy zachowują kolejność kolumn.
+func WriteJSON(w io.Writer, tables []Table) error {
+	var buf bytes.Buffer
+	if len(tables) == 1 {
+		writeJSONRows(&buf, tables[0])
+	} else {
+		buf.WriteString("{")
+		for i, t := range tables {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			name, _ := json.Marshal(t.Name)
+			buf.Write(name)
+			buf.WriteString(":")
+			writeJSONRows(&buf, t)
+		}
+		buf.WriteString("}")
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func writeJSONRows(buf *bytes.Buffer, t Table) {
+	buf.WriteString("[")
+	for r, row := range t.Rows {
+		if r > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("{")
+		for i, c := range t.Columns {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			key, _ := json.Marshal(c.Name)
+			buf.Write(key)
+			buf.WriteString(":")
+			v := cell(row, i)
+			if f, ok := v.(float64); ok && c.Kind == Money {
+				v = xlstowary.RoundMoney(f)
+			}
+			val, err := json.Marshal(v)
+			if err != nil {
+				val, _ = json.Marshal(fmt.Sprint(v))
+			}
+			buf.Write(val)
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+}
+
+func cell(row []any, i int) any {
+	if i < len(row) {
+		return row[i]
+	}
+	return nil
+}
+
+// formatValue: tekstowa postać wartości (CSV, komórki tekstowe XLSX).
+func formatValue(kind Kind, v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		switch kind {
+		case Money:
+			return xlstowary.FormatMoney(x)
+		case Int:
+			return strconv.FormatInt(int64(x), 10)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
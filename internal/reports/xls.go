@@ -0,0 +1,335 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bartek5186/pcm2www/internal/db"
+	"github.com/bartek5186/pcm2www/internal/xlstowary"
+	"gorm.io/gorm"
+)
+
+// Porównania z plikiem XLS „towary” z PC-Market (--xlsx). --only-diff pomija wiersze MATCH,
+// --split rozdziela wynik na tabele wg statusu.
+
+func init() {
+	Register(Report{
+		Name:        "xls-products",
+		Description: "towary z pliku XLS z cenami netto wyliczonymi z VAT",
+		NeedsXLSX:   true,
+		Build:       buildXLSProducts,
+	})
+	Register(Report{
+		Name:        "xls-magazine",
+		Description: "XLS ↔ staging magazynu po towar_id (nazwa, kod, ceny, stan)",
+		NeedsXLSX:   true,
+		Build:       buildXLSMagazine,
+	})
+	Register(Report{
+		Name:        "xls-shop",
+		Description: "XLS ↔ cache sklepu Woo po EAN (nazwa, ceny, promocja, stan)",
+		NeedsXLSX:   true,
+		Build:       buildXLSShop,
+	})
+}
+
+const (
+	statusMatch = "MATCH"
+	statusDiff  = "DIFF"
+)
+
+func buildXLSProducts(_ *gorm.DB, p Params) ([]Table, error) {
+	rows, err := xlstowary.Load(p.XLSXPath)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx %s: %w", p.XLSXPath, err)
+	}
+	t := Table{Name: "xls_products", Columns: []Column{
+		{"towar_id", Int}, {"name", Text}, {"cash_name", Text}, {"code", Text},
+		{"purchase_price_net", Money}, {"detail_price_gross", Money}, {"detail_price_net", Money},
+		{"wholesale_gross", Money}, {"wholesale_net", Money}, {"vat_rate", Number}, {"quantity", Number},
+		{"category", Text}, {"producer", Text}, {"updated_at", Text},
+	}}
+	for _, r := range rows {
+		t.Append(r.TowarID, r.Name, r.CashName, r.Code, r.PurchasePriceNet, r.DetailPriceGross, r.DetailPriceNet(),
+			r.WholesaleGross, r.WholesaleNet(), r.VATRate, r.Quantity, r.Category, r.Producer, r.UpdatedAt)
+	}
+	return []Table{t}, nil
+}
+
+func buildXLSMagazine(gdb *gorm.DB, p Params) ([]Table, error) {
+	xlsRows, err := xlstowary.Load(p.XLSXPath)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx %s: %w", p.XLSXPath, err)
+	}
+	magRows, err := loadMagazineProducts(gdb)
+	if err != nil {
+		return nil, err
+	}
+
+	xlsByID := make(map[int64]xlstowary.Row, len(xlsRows))
+	magByID := make(map[int64]magazineProduct, len(magRows))
+	var ids []int64
+	for _, row := range xlsRows {
+		xlsByID[row.TowarID] = row
+		ids = append(ids, row.TowarID)
+	}
+	for _, row := range magRows {
+		if _, ok := xlsByID[row.TowarID]; !ok {
+			ids = append(ids, row.TowarID)
+		}
+		magByID[row.TowarID] = row
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	t := Table{Name: "xls_magazine", Columns: []Column{
+		{"status", Text}, {"towar_id", Int}, {"xls_name", Text}, {"magazine_name", Text}, {"name_match", Text},
+		{"xls_code", Text}, {"magazine_code", Text}, {"code_match", Text}, {"xls_vat_rate", Number},
+		{"xls_purchase_price_net", Money}, {"xls_detail_price_gross", Money}, {"xls_detail_price_net", Money},
+		{"magazine_detail_price_gross", Money}, {"detail_price_match", Text}, {"xls_wholesale_gross", Money},
+		{"xls_wholesale_net", Money}, {"magazine_wholesale_gross", Money}, {"wholesale_price_match", Text},
+		{"xls_quantity", Number}, {"magazine_quantity", Number}, {"stock_match", Text},
+		{"category", Text}, {"producer", Text}, {"xls_updated_at", Text}, {"magazine_updated_at", Text}, {"note", Text},
+	}}
+	for _, id := range ids {
+		xls, hasXLS := xlsByID[id]
+		mag, hasMag := magByID[id]
+
+		var status, note, nameMatch, codeMatch, detailMatch, wholesaleMatch, stockMatch string
+		switch {
+		case hasXLS && !hasMag:
+			status, note = "ONLY_XLS", "Produkt jest w XLS, ale nie ma go w bieżącym stagingu magazynowym"
+		case !hasXLS && hasMag:
+			status, note = "ONLY_MAGAZINE", "Produkt jest w stagingu magazynowym, ale nie ma go w XLS"
+		default:
+			var diffs []string
+			nameMatch = matchField(&diffs, "name", xlstowary.EqualFoldTrim(xls.Name, mag.Nazwa))
+			codeMatch = matchField(&diffs, "code", xlstowary.EqualFoldTrim(xls.Code, mag.Kod))
+			// staging trzyma ceny brutto (cena_detal z XML, „Cena det.” z arkusza) — porównanie brutto do brutto
+			detailMatch = matchField(&diffs, "detail_price", xlstowary.SameMoney(xls.DetailPriceGross, mag.CenaDetal))
+			wholesaleMatch = matchField(&diffs, "wholesale_price", xlstowary.SameMoney(xls.WholesaleGross, mag.CenaHurtowa))
+			stockMatch = matchField(&diffs, "stock", xlstowary.SameMoney(xls.Quantity, mag.TotalStock))
+			status, note = diffStatus(diffs, "Pełna zgodność po towar_id")
+		}
+		if p.OnlyDiff && status == statusMatch {
+			continue
+		}
+
+		var magUpdated string
+		if hasMag {
+			magUpdated = formatTime(mag.StProduct)
+		}
+		t.Append(status, id, xls.Name, mag.Nazwa, nameMatch, xls.Code, mag.Kod, codeMatch, xls.VATRate,
+			xls.PurchasePriceNet, xls.DetailPriceGross, xls.DetailPriceNet(), mag.CenaDetal, detailMatch,
+			xls.WholesaleGross, xls.WholesaleNet(), mag.CenaHurtowa, wholesaleMatch,
+			xls.Quantity, mag.TotalStock, stockMatch, xls.Category, xls.Producer, xls.UpdatedAt, magUpdated, note)
+	}
+	return splitByType(t, p.Split), nil
+}
+
+func buildXLSShop(gdb *gorm.DB, p Params) ([]Table, error) {
+	xlsRows, err := xlstowary.Load(p.XLSXPath)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx %s: %w", p.XLSXPath, err)
+	}
+	shopRows, err := loadShopProducts(gdb, p.Shop)
+	if err != nil {
+		return nil, err
+	}
+
+	xlsByCode := make(map[string][]xlstowary.Row)
+	shopByEAN := make(map[string][]db.WooProductCache)
+	var xlsMissingCode []xlstowary.Row
+	var shopMissingEAN []db.WooProductCache
+	for _, row := range xlsRows {
+		if code := strings.TrimSpace(row.Code); code != "" {
+			xlsByCode[code] = append(xlsByCode[code], row)
+		} else {
+			xlsMissingCode = append(xlsMissingCode, row)
+		}
+	}
+	for _, row := range shopRows {
+		if ean := strings.TrimSpace(row.Ean); ean != "" {
+			shopByEAN[ean] = append(shopByEAN[ean], row)
+		} else {
+			shopMissingEAN = append(shopMissingEAN, row)
+		}
+	}
+
+	type comparison struct {
+		status, code string
+		values       []any
+	}
+	var rows []comparison
+	add := func(status, code string, xList []xlstowary.Row, sList []db.WooProductCache, matches [5]string, note string) {
+		rows = append(rows, comparison{status: status, code: code, values: []any{
+			status, code,
+			joinXLS(xList, "|", func(r xlstowary.Row) any { return r.TowarID }),
+			joinXLS(xList, " | ", func(r xlstowary.Row) any { return r.Name }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return int64(r.WooID) }),
+			joinShop(sList, " | ", func(r db.WooProductCache) any { return r.Kod }),
+			joinShop(sList, " | ", func(r db.WooProductCache) any { return r.Name }),
+			matches[0],
+			joinXLS(xList, "|", func(r xlstowary.Row) any { return r.DetailPriceGross }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.PriceRegular }),
+			matches[1],
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.PriceSale }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return effectivePrice(r) }),
+			matches[2],
+			joinXLS(xList, "|", func(r xlstowary.Row) any { return r.WholesaleNet() }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.HurtPrice }),
+			matches[3],
+			joinXLS(xList, "|", func(r xlstowary.Row) any { return r.Quantity }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.StockQty }),
+			matches[4],
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.Status }),
+			joinShop(sList, "|", func(r db.WooProductCache) any { return r.Type }),
+			joinXLS(xList, " | ", func(r xlstowary.Row) any { return r.Category }),
+			joinXLS(xList, " | ", func(r xlstowary.Row) any { return r.Producer }),
+			joinXLS(xList, " | ", func(r xlstowary.Row) any { return r.UpdatedAt }),
+			joinShop(sList, " | ", func(r db.WooProductCache) any { return r.DateModified }),
+			note,
+		}})
+	}
+
+	for _, row := range xlsMissingCode {
+		add("XLS_MISSING_CODE", "", []xlstowary.Row{row}, nil, [5]string{},
+			"Produkt w XLS nie ma kodu/EAN, więc nie da się go porównać ze sklepem po EAN")
+	}
+	for _, row := range shopMissingEAN {
+		add("SHOP_MISSING_EAN", "", nil, []db.WooProductCache{row}, [5]string{},
+			"Produkt w sklepie nie ma EAN, więc nie da się go porównać z XLS po EAN")
+	}
+
+	codes := make(map[string]struct{}, len(xlsByCode)+len(shopByEAN))
+	for code := range xlsByCode {
+		codes[code] = struct{}{}
+	}
+	for ean := range shopByEAN {
+		codes[ean] = struct{}{}
+	}
+	for code := range codes {
+		xList, sList := xlsByCode[code], shopByEAN[code]
+		switch {
+		case len(xList) > 1:
+			add("DUPLICATE_XLS_CODE", code, xList, sList, [5]string{},
+				fmt.Sprintf("Kod/EAN %s występuje wielokrotnie w XLS (%d razy)", code, len(xList)))
+		case len(sList) > 1:
+			add("DUPLICATE_SHOP_EAN", code, xList, sList, [5]string{},
+				fmt.Sprintf("EAN %s występuje wielokrotnie w sklepie (%d razy)", code, len(sList)))
+		case len(sList) == 0:
+			add("ONLY_XLS", code, xList, nil, [5]string{}, "Produkt jest w XLS, ale nie ma go w sklepie po EAN")
+		case len(xList) == 0:
+			add("ONLY_SHOP", code, nil, sList, [5]string{}, "Produkt jest w sklepie, ale nie ma go w XLS po EAN")
+		default:
+			x, s := xList[0], sList[0]
+			var diffs []string
+			matches := [5]string{
+				matchField(&diffs, "name", xlstowary.EqualFoldTrim(x.Name, s.Name)),
+				matchField(&diffs, "regular_price", xlstowary.SameMoney(x.DetailPriceGross, s.PriceRegular)),
+				matchField(&diffs, "effective_price", xlstowary.SameMoney(x.DetailPriceGross, effectivePrice(s))),
+				matchField(&diffs, "hurt_price", xlstowary.SameMoney(x.WholesaleNet(), s.HurtPrice)),
+				matchField(&diffs, "stock", xlstowary.SameMoney(x.Quantity, s.StockQty)),
+			}
+			if s.PriceSale > 0 {
+				diffs = append(diffs, "sale_active")
+			}
+			status, note := diffStatus(diffs, "Pełna zgodność XLS -> sklep po EAN")
+			if p.OnlyDiff && status == statusMatch {
+				continue
+			}
+			add(status, code, xList, sList, matches, note)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].status != rows[j].status {
+			return rows[i].status < rows[j].status
+		}
+		return rows[i].code < rows[j].code
+	})
+
+	t := Table{Name: "xls_shop", Columns: []Column{
+		{"status", Text}, {"code", Text}, {"xls_towar_ids", Int}, {"xls_names", Text}, {"shop_woo_ids", Int},
+		{"shop_skus", Text}, {"shop_names", Text}, {"name_match", Text}, {"xls_detail_price_gross", Money},
+		{"shop_regular_price", Money}, {"regular_price_match", Text}, {"shop_sale_price", Money},
+		{"shop_effective_price", Money}, {"effective_price_match", Text}, {"xls_wholesale_net", Money},
+		{"shop_hurt_price", Money}, {"wholesale_price_match", Text}, {"xls_quantity", Number},
+		{"shop_stock_qty", Number}, {"stock_match", Text}, {"shop_status", Text}, {"shop_type", Text},
+		{"category", Text}, {"producer", Text}, {"xls_updated_at", Text}, {"shop_date_modified", Text}, {"note", Text},
+	}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, r.values)
+	}
+	return splitByType(t, p.Split), nil
+}
+
+// matchField zwraca TAK/NIE i dopisuje nazwę pola do listy różnic.
+func matchField(diffs *[]string, field string, ok bool) string {
+	if !ok {
+		*diffs = append(*diffs, field)
+	}
+	return xlstowary.YesNo(ok)
+}
+
+func diffStatus(diffs []string, matchNote string) (string, string) {
+	if len(diffs) == 0 {
+		return statusMatch, matchNote
+	}
+	return statusDiff, "Różnice: " + strings.Join(diffs, ", ")
+}
+
+func effectivePrice(r db.WooProductCache) float64 {
+	if r.PriceSale > 0 {
+		return r.PriceSale
+	}
+	return r.PriceRegular
+}
+
+// joinXLS / joinShop: jedna wartość zostaje liczbą (typowana komórka), kilka — tekstem z separatorem.
+// Puste wartości tekstowe są pomijane.
+func joinXLS(rows []xlstowary.Row, sep string, value func(xlstowary.Row) any) any {
+	values := make([]any, 0, len(rows))
+	for _, r := range rows {
+		values = append(values, value(r))
+	}
+	return joinValues(values, sep)
+}
+
+func joinShop(rows []db.WooProductCache, sep string, value func(db.WooProductCache) any) any {
+	values := make([]any, 0, len(rows))
+	for _, r := range rows {
+		values = append(values, value(r))
+	}
+	return joinValues(values, sep)
+}
+
+func joinValues(values []any, sep string) any {
+	parts := make([]string, 0, len(values))
+	kept := make([]any, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok && strings.TrimSpace(s) == "" {
+			continue
+		}
+		kept = append(kept, v)
+		parts = append(parts, formatJoined(v))
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	return strings.Join(parts, sep)
+}
+
+func formatJoined(v any) string {
+	switch x := v.(type) {
+	case float64:
+		return xlstowary.FormatMoney(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	}
+	return fmt.Sprint(v)
+}
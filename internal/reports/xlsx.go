@@ -0,0 +1,36 @@
+package reports
+
+import (
+	"io"
+
+	"github.com/bartek5186/pcm2www/internal/xlstowary"
+)
+
+var xlsxKinds = map[Kind]xlstowary.CellKind{
+	Text:   xlstowary.CellText,
+	Int:    xlstowary.CellInt,
+	Number: xlstowary.CellNumber,
+	Money:  xlstowary.CellMoney,
+}
+
+// WriteXLSX zapisuje tabele jako skoroszyt XLSX — jeden arkusz na tabelę, z typowanymi
+// komórkami liczbowymi, formatem kwot, zamrożonym nagłówkiem i autofiltrem.
+func WriteXLSX(w io.Writer, tables []Table) error {
+	xw := xlstowary.NewWriter(w)
+	for _, t := range tables {
+		cols := make([]xlstowary.Column, len(t.Columns))
+		for i, c := range t.Columns {
+			cols[i] = xlstowary.Column{Name: c.Name, Kind: xlsxKinds[c.Kind]}
+		}
+		sheet, err := xw.NewSheet(t.Name, cols)
+		if err != nil {
+			return err
+		}
+		for _, row := range t.Rows {
+			if err := sheet.WriteRow(row...); err != nil {
+				return err
+			}
+		}
+	}
+	return xw.Close()
+}
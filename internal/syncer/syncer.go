@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bartek5186/pcm2www/internal/adminapi"
 	conf "github.com/bartek5186/pcm2www/internal/config"
 	"github.com/bartek5186/pcm2www/internal/integrations" // + import rejestru/typów
 	_ "github.com/bartek5186/pcm2www/internal/integrations/importer"
@@ -28,9 +29,10 @@ type Syncer struct {
 	cfg     *conf.Config   // aktualna konfiguracja
 	running bool           // czy syncer działa
 	cancel  context.CancelFunc
-	wg      sync.WaitGroup // śledzi goroutines
-	ticks   uint64         // licznik heartbeatów
-	ints    []runningInt   // lista aktywnych integracji
+	wg      sync.WaitGroup   // śledzi goroutines
+	ticks   uint64           // licznik heartbeatów
+	ints    []runningInt     // lista aktywnych integracji
+	admin   *adminapi.Server // lokalne API administracyjne (nil = wyłączone)
 }
 
 func New(log zerolog.Logger, cfg *conf.Config, gdb *gorm.DB) *Syncer {
@@ -52,6 +54,7 @@ func (s *Syncer) Start(ctx context.Context) error {
 	// zbuduj i odpal integracje
 	ints := s.buildIntegrationsLocked()
 	s.ints = ints
+	s.admin = s.startAdminAPILocked()
 	s.mu.Unlock()
 
 	s.log.Info().Msg("Syncer(dev): start")
@@ -86,7 +89,8 @@ func (s *Syncer) buildIntegrationsLocked() []runningInt {
 			s.log.Warn().Str("integration", name).Msg("brak fabryki – pomijam")
 			continue
 		}
-		inst, err := f(s.log.With().Str("integration", name).Logger(), json.RawMessage(raw))
+		_, instance := integrations.SplitName(name)
+		inst, err := f(s.log.With().Str("integration", name).Logger(), instance, json.RawMessage(raw))
 		if err != nil {
 			s.log.Error().Err(err).Str("integration", name).Msg("błąd inicjalizacji")
 			continue
@@ -97,6 +101,24 @@ func (s *Syncer) buildIntegrationsLocked() []runningInt {
 	return out
 }
 
+// startAdminAPILocked uruchamia API administracyjne, jeśli włączone w configu.
+// Błąd API nie blokuje integracji — jest tylko logowany.
+func (s *Syncer) startAdminAPILocked() *adminapi.Server {
+	if s.cfg == nil || !s.cfg.AdminAPI.Enabled {
+		return nil
+	}
+	srv, err := adminapi.New(s.log.With().Str("component", "admin_api").Logger(), s.db, s.cfg.AdminAPI)
+	if err != nil {
+		s.log.Error().Err(err).Msg("admin api: nie uruchomiono")
+		return nil
+	}
+	if err := srv.Start(); err != nil {
+		s.log.Error().Err(err).Msg("admin api: nie uruchomiono")
+		return nil
+	}
+	return srv
+}
+
 func (s *Syncer) Stop() {
 	s.mu.Lock()
 	if !s.running {
@@ -106,10 +128,17 @@ func (s *Syncer) Stop() {
 	s.running = false
 	cancel := s.cancel
 	ints := s.ints
+	admin := s.admin
 	s.ints = nil
+	s.admin = nil
 	s.cancel = nil
 	s.mu.Unlock()
 
+	if admin != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = admin.Shutdown(shutdownCtx)
+		cancelShutdown()
+	}
 	for _, ri := range ints {
 		ri.Inst.Stop()
 	}
@@ -0,0 +1,371 @@
+package xlstowary
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Strumieniowy zapis XLSX — odpowiednik Load po stronie zapisu. Wiersze trafiają od razu do
+// archiwum zip (bez trzymania arkusza w pamięci), więc arkusze zapisuje się po kolei:
+// NewSheet zamyka poprzedni. Liczby są komórkami liczbowymi (Excel pokazuje je w formacie
+// regionalnym, np. z przecinkiem dziesiętnym), tekst to inline string w UTF-8.
+
+type CellKind int
+
+const (
+	CellText   CellKind = iota
+	CellInt             // format "0"
+	CellNumber          // format ogólny
+	CellMoney           // format "#,##0.00"
+)
+
+// Style z styles.xml (indeksy cellXfs).
+const (
+	styleDefault = 0
+	styleMoney   = 1
+	styleHeader  = 2
+	styleInt     = 3
+)
+
+type Column struct {
+	Name  string
+	Kind  CellKind
+	Width float64 // szerokość w znakach; 0 = domyślna dla rodzaju kolumny
+}
+
+type Writer struct {
+	zw     *zip.Writer
+	sheets []string
+	rows   []int // liczba wierszy (z nagłówkiem) każdego arkusza — zakres autofiltra
+	cols   []int
+	cur    *Sheet
+	closed bool
+}
+
+type Sheet struct {
+	w      *Writer
+	bw     *bufio.Writer
+	cols   []Column
+	row    int
+	closed bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// NewSheet zaczyna kolejny arkusz: zamrożony wiersz nagłówka z autofiltrem na wszystkich kolumnach.
+// Nazwa jest skracana do 31 znaków i oczyszczana ze znaków niedozwolonych w Excelu.
+func (w *Writer) NewSheet(name string, cols []Column) (*Sheet, error) {
+	if w.closed {
+		return nil, errors.New("xlsx: writer zamknięty")
+	}
+	if len(cols) == 0 {
+		return nil, errors.New("xlsx: arkusz bez kolumn")
+	}
+	if err := w.finishSheet(); err != nil {
+		return nil, err
+	}
+	name = w.uniqueSheetName(name)
+	fw, err := w.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(w.sheets)+1))
+	if err != nil {
+		return nil, err
+	}
+	w.sheets = append(w.sheets, name)
+	w.rows = append(w.rows, 0)
+	w.cols = append(w.cols, len(cols))
+
+	s := &Sheet{w: w, bw: bufio.NewWriterSize(fw, 64<<10), cols: cols}
+	w.cur = s
+
+	s.bw.WriteString(xml.Header)
+	s.bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	s.bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	s.bw.WriteString(`<selection pane="bottomLeft" activeCell="A2" sqref="A2"/></sheetView></sheetViews>`)
+	s.bw.WriteString(`<cols>`)
+	for i, c := range cols {
+		fmt.Fprintf(s.bw, `<col min="%d" max="%d" width="%s" customWidth="1"/>`, i+1, i+1, formatNumber(columnWidth(c)))
+	}
+	s.bw.WriteString(`</cols><sheetData>`)
+
+	s.row = 1
+	s.bw.WriteString(`<row r="1">`)
+	for i, c := range cols {
+		writeStringCell(s.bw, cellRef(i, 1), c.Name, styleHeader)
+	}
+	s.bw.WriteString(`</row>`)
+	return s, s.bw.Flush()
+}
+
+// WriteRow dopisuje wiersz. Wartości: string, int, int64, uint, float64 albo nil (pusta komórka).
+// Liczba w kolumnie CellText trafia jako tekst; string w kolumnie liczbowej — jako tekst bez formatu.
+func (s *Sheet) WriteRow(values ...any) error {
+	if s.closed {
+		return errors.New("xlsx: arkusz zamknięty")
+	}
+	s.row++
+	fmt.Fprintf(s.bw, `<row r="%d">`, s.row)
+	for i, c := range s.cols {
+		var v any
+		if i < len(values) {
+			v = values[i]
+		}
+		ref := cellRef(i, s.row)
+		switch x := v.(type) {
+		case nil:
+		case string:
+			writeStringCell(s.bw, ref, x, styleDefault)
+		case int:
+			writeNumberCell(s.bw, ref, c.Kind, float64(x))
+		case int64:
+			writeNumberCell(s.bw, ref, c.Kind, float64(x))
+		case uint:
+			writeNumberCell(s.bw, ref, c.Kind, float64(x))
+		case float64:
+			writeNumberCell(s.bw, ref, c.Kind, x)
+		default:
+			writeStringCell(s.bw, ref, fmt.Sprint(v), styleDefault)
+		}
+	}
+	s.bw.WriteString(`</row>`)
+	if s.bw.Buffered() > 48<<10 {
+		return s.bw.Flush()
+	}
+	return nil
+}
+
+func (s *Sheet) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.bw.WriteString(`</sheetData>`)
+	fmt.Fprintf(s.bw, `<autoFilter ref="%s"/>`, filterRange(len(s.cols), s.row))
+	s.bw.WriteString(`</worksheet>`)
+	s.w.rows[len(s.w.rows)-1] = s.row
+	return s.bw.Flush()
+}
+
+func (w *Writer) finishSheet() error {
+	if w.cur == nil {
+		return nil
+	}
+	err := w.cur.close()
+	w.cur = nil
+	return err
+}
+
+// Close kończy ostatni arkusz i dopisuje części skoroszytu (workbook, style, relacje).
+// Skoroszyt bez arkuszy dostaje pusty arkusz — Excel nie otwiera plików bez arkuszy.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if len(w.sheets) == 0 {
+		if _, err := w.NewSheet("Arkusz1", []Column{{Name: ""}}); err != nil {
+			return err
+		}
+	}
+	if err := w.finishSheet(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypesXML(len(w.sheets))},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", w.workbookXML()},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML(len(w.sheets))},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, p := range parts {
+		fw, err := w.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, p.body); err != nil {
+			return err
+		}
+	}
+	return w.zw.Close()
+}
+
+func (w *Writer) uniqueSheetName(name string) string {
+	clean := strings.NewReplacer("[", "_", "]", "_", ":", "_", "*", "_", "?", "_", "/", "_", `\`, "_")
+	base := strings.Trim(clean.Replace(strings.TrimSpace(name)), "'")
+	if base == "" {
+		base = fmt.Sprintf("Arkusz%d", len(w.sheets)+1)
+	}
+	taken := func(n string) bool {
+		for _, s := range w.sheets {
+			if strings.EqualFold(s, n) {
+				return true
+			}
+		}
+		return false
+	}
+	candidate := truncateRunes(base, 31)
+	for n := 2; taken(candidate); n++ {
+		suffix := "_" + strconv.Itoa(n)
+		candidate = truncateRunes(base, 31-len(suffix)) + suffix
+	}
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+func writeNumberCell(bw *bufio.Writer, ref string, kind CellKind, v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	switch kind {
+	case CellText:
+		writeStringCell(bw, ref, formatNumber(v), styleDefault)
+		return
+	case CellMoney:
+		fmt.Fprintf(bw, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleMoney, formatNumber(RoundMoney(v)))
+	case CellInt:
+		fmt.Fprintf(bw, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleInt, formatNumber(v))
+	default:
+		fmt.Fprintf(bw, `<c r="%s"><v>%s</v></c>`, ref, formatNumber(v))
+	}
+}
+
+func writeStringCell(bw *bufio.Writer, ref, s string, style int) {
+	if s == "" {
+		return
+	}
+	if style != styleDefault {
+		fmt.Fprintf(bw, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	} else {
+		fmt.Fprintf(bw, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	}
+	_ = xml.EscapeText(bw, []byte(stripInvalidXML(s)))
+	bw.WriteString(`</t></is></c>`)
+}
+
+// stripInvalidXML usuwa znaki sterujące niedozwolone w XML 1.0 (zdarzają się w nazwach z PCM).
+func stripInvalidXML(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func columnWidth(c Column) float64 {
+	if c.Width > 0 {
+		return c.Width
+	}
+	w := float64(len([]rune(c.Name))) + 4 // miejsce na strzałkę autofiltra
+	min := 12.0
+	if c.Kind == CellText {
+		min = 18
+	}
+	return math.Max(w, min)
+}
+
+// cellRef zamienia indeks kolumny (od 0) i numer wiersza na adres A1.
+func cellRef(col, row int) string {
+	return columnName(col) + strconv.Itoa(row)
+}
+
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+func filterRange(cols, rows int) string {
+	return "A1:" + cellRef(cols-1, rows)
+}
+
+// quoteSheetName: nazwa arkusza w formule (definedName autofiltra).
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+func (w *Writer) workbookXML() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, name := range w.sheets {
+		b.WriteString(`<sheet name="`)
+		_ = xml.EscapeText(&b, []byte(name))
+		fmt.Fprintf(&b, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+	b.WriteString(`</sheets><definedNames>`)
+	for i, name := range w.sheets {
+		ref := quoteSheetName(name) + "!$A$1:$" + columnName(w.cols[i]-1) + "$" + strconv.Itoa(w.rows[i])
+		fmt.Fprintf(&b, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">`, i)
+		_ = xml.EscapeText(&b, []byte(ref))
+		b.WriteString(`</definedName>`)
+	}
+	b.WriteString(`</definedNames></workbook>`)
+	return b.String()
+}
+
+const rootRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/><family val="2"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/><family val="2"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="1" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func contentTypesXML(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func workbookRelsXML(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
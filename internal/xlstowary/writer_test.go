@@ -0,0 +1,95 @@
+package xlstowary
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterRoundTripsTowarySheet(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	summary, err := w.NewSheet("podsumowanie", []Column{{Name: "typ"}, {Name: "wiersze", Kind: CellInt}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := summary.WriteRow("DIFF", 2); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := w.NewSheet("towary/2026", []Column{
+		{Name: "Id", Kind: CellInt}, {Name: "Nazwa"}, {Name: "Kod"}, {Name: "Cena det.", Kind: CellMoney},
+		{Name: "Cena hurt.", Kind: CellMoney}, {Name: "VAT %", Kind: CellNumber}, {Name: "Ilość", Kind: CellNumber},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sheet.WriteRow(int64(7), "Żubrówka <Bison> & co", "5900000000011", 39.999, 30.0, 23.0, 2.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := sheet.WriteRow(int64(8), "Bez ceny", nil, nil, nil, 8.0, 0.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet2 := readPart(t, zr, "xl/worksheets/sheet2.xml")
+	for _, want := range []string{`state="frozen"`, `<autoFilter ref="A1:G3"/>`, `<c r="D2" s="1"><v>40</v></c>`} {
+		if !strings.Contains(sheet2, want) {
+			t.Fatalf("sheet2 missing %s:\n%s", want, sheet2)
+		}
+	}
+	if wb := readPart(t, zr, "xl/workbook.xml"); !strings.Contains(wb, `name="towary_2026"`) {
+		t.Fatalf("expected sanitized sheet name:\n%s", wb)
+	}
+
+	// Load czyta pierwszy arkusz — zapisujemy sam arkusz towarów
+	var only bytes.Buffer
+	w = NewWriter(&only)
+	sheet, _ = w.NewSheet("towary", []Column{{Name: "Id", Kind: CellInt}, {Name: "Nazwa"}, {Name: "Kod"}, {Name: "Cena det.", Kind: CellMoney}, {Name: "Cena hurt.", Kind: CellMoney}, {Name: "VAT %", Kind: CellNumber}, {Name: "Ilość", Kind: CellNumber}})
+	_ = sheet.WriteRow(int64(7), "Żubrówka <Bison> & co", "5900000000011", 39.999, 30.0, 23.0, 2.5)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "towary.xlsx")
+	if err := os.WriteFile(path, only.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Name != "Żubrówka <Bison> & co" || rows[0].DetailPriceGross != 40 ||
+		rows[0].VATRate != 0.23 || rows[0].Quantity != 2.5 {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+}
+
+func readPart(t *testing.T, zr *zip.Reader, name string) string {
+	t.Helper()
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	t.Fatalf("missing %s", name)
+	return ""
+}
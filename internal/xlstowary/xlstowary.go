@@ -68,7 +68,18 @@ type sharedStringItem struct {
 	Runs []xlsxTextRun `xml:"r"`
 }
 
+// Load czyta pierwszy arkusz pliku „towary”. Kolumny brakujące w nagłówku dają puste / zerowe pola.
 func Load(xlsxPath string) ([]Row, error) {
+	return load(xlsxPath, nil)
+}
+
+// LoadRequired działa jak Load, ale arkusz bez wiersza nagłówka albo bez którejś z kolumn
+// required to błąd — zamiast wierszy z zerami w brakujących kolumnach.
+func LoadRequired(xlsxPath string, required ...string) ([]Row, error) {
+	return load(xlsxPath, required)
+}
+
+func load(xlsxPath string, required []string) ([]Row, error) {
 	zr, err := zip.OpenReader(xlsxPath)
 	if err != nil {
 		return nil, err
@@ -117,6 +128,13 @@ func Load(xlsxPath string) ([]Row, error) {
 					}
 				}
 			}
+			if header != nil {
+				for _, col := range required {
+					if _, ok := header[col]; !ok {
+						return nil, fmt.Errorf("arkusz %s: brak kolumny %q", sheetPath, col)
+					}
+				}
+			}
 			continue
 		}
 
@@ -130,17 +148,21 @@ func Load(xlsxPath string) ([]Row, error) {
 			Name:             cellValue(values, header, "Nazwa"),
 			CashName:         cellValue(values, header, "Na kasie"),
 			Code:             cellValue(values, header, "Kod"),
-			PurchasePriceNet: ParseFloat(values[header["Cena ew."]]),
-			DetailPriceGross: ParseFloat(values[header["Cena det."]]),
-			WholesaleGross:   ParseFloat(values[header["Cena hurt."]]),
-			VATRate:          NormalizeVAT(ParseFloat(values[header["VAT %"]])),
-			Quantity:         ParseFloat(values[header["Ilość"]]),
+			PurchasePriceNet: ParseFloat(cellValue(values, header, "Cena ew.")),
+			DetailPriceGross: ParseFloat(cellValue(values, header, "Cena det.")),
+			WholesaleGross:   ParseFloat(cellValue(values, header, "Cena hurt.")),
+			VATRate:          NormalizeVAT(ParseFloat(cellValue(values, header, "VAT %"))),
+			Quantity:         ParseFloat(cellValue(values, header, "Ilość")),
 			Category:         cellValue(values, header, "Kategoria"),
 			Producer:         cellValue(values, header, "Producent"),
-			UpdatedAt:        ParseExcelDate(values[header["Ost. zmiana"]]),
+			UpdatedAt:        ParseExcelDate(cellValue(values, header, "Ost. zmiana")),
 		})
 	}
 
+	if header == nil && len(required) > 0 {
+		return nil, fmt.Errorf("arkusz %s: brak wiersza nagłówka (Id, Nazwa, Kod)", sheetPath)
+	}
+
 	sort.Slice(rows, func(i, j int) bool { return rows[i].TowarID < rows[j].TowarID })
 	return rows, nil
 }
@@ -14,8 +14,6 @@ import (
 	"time"
 
 	conf "github.com/bartek5186/pcm2www/internal/config"
-	"github.com/bartek5186/pcm2www/internal/db"
-	logs "github.com/bartek5186/pcm2www/internal/logs"
 	syncer "github.com/bartek5186/pcm2www/internal/syncer"
 	"gorm.io/gorm"
 )
@@ -23,32 +21,17 @@ import (
 var ver = "1.0.0"
 
 func main() {
-	appDir := mustAppDataDir("pcm2www")
-	log := logs.New(filepath.Join(appDir, "app.log"), true)
-
-	cfgPath := filepath.Join(appDir, "config.json")
-	cfg, firstRun, err := conf.LoadOrCreate(cfgPath)
-	if err != nil {
-		panic(err)
-	}
-	if firstRun {
-		log.Info().Msgf("Utworzono domyślną konfigurację: %s", cfgPath)
+	// pcm2www <komenda> ... — tryb nieinteraktywny (systemd, cron, skrypty)
+	if len(os.Args) > 1 {
+		os.Exit(runSubcommand(os.Args[1:]))
 	}
 
-	dbh, err := db.OpenWithConfig(appDir, db.OpenConfig{
-		Driver: cfg.Database.Driver,
-		DSN:    cfg.Database.DSN,
-		Path:   cfg.Database.Path,
-	})
+	app, err := openApp(true, true)
 	if err != nil {
-		log.Fatal().Err(err).Msg("DB open error")
-	}
-	if err := dbh.Migrate(); err != nil {
-		log.Fatal().Err(err).Msg("DB migrate error")
+		panic(err)
 	}
-	log.Info().Str("driver", dbh.Driver).Str("db", dbh.Path).Msg("DB ready")
-	sqlDB, _ := dbh.DB.DB()
-	defer sqlDB.Close()
+	defer app.Close()
+	log, cfg, cfgPath, appDir, dbh := app.log, app.cfg, app.cfgPath, app.appDir, app.dbh
 
 	log.Info().Msg("Aplikacja (CLI) uruchomiona")
 
@@ -68,13 +51,22 @@ func main() {
 
 	// Prosta pętla poleceń w terminalu
 	fmt.Println("PCM2WWW CLI", ver)
-	fmt.Println("Komendy: start | stop | reload | status | paths | resetdb! | quit")
+	fmt.Println("Komendy: start | stop | reload | status | paths | pin <towar_id> <woo_id> [notatka] | unpin <towar_id> | pins | proposals | approve <id> | reject <id> (powiązania: [--shop <id>]) | preview <csv|json|xlsx> [import_id] [plik] | migrate <status|up> | resetdb! | quit")
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
 		fmt.Print("> ")
 		line, _ := reader.ReadString('\n')
 		cmd := strings.TrimSpace(strings.ToLower(line))
+		if runLinkCommand(dbh.DB, line) {
+			continue
+		}
+		if runPreviewCommand(dbh.DB, line) {
+			continue
+		}
+		if runMigrateCommand(dbh, line) {
+			continue
+		}
 
 		switch cmd {
 		case "start":
@@ -136,7 +128,7 @@ func main() {
 		case "":
 			// enter – ignoruj
 		default:
-			fmt.Println("Nieznana komenda. Użyj: start | stop | reload | status | paths | resetdb! | quit")
+			fmt.Println("Nieznana komenda. Użyj: start | stop | reload | status | paths | pin <towar_id> <woo_id> [notatka] | unpin <towar_id> | pins | proposals | approve <id> | reject <id> (powiązania: [--shop <id>]) | preview <csv|json|xlsx> [import_id] [plik] | migrate <status|up> | resetdb! | quit")
 		}
 	}
 }
@@ -160,6 +152,10 @@ func resetDB(gdb *gorm.DB) error {
 		"woo_product_caches",
 		"woo_tasks",
 		"link_issues",
+		"manual_links",
+		"link_proposals",
+		"woo_order_lines",
+		"woo_orders",
 		"kvs",
 	}
 